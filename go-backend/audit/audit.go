@@ -0,0 +1,153 @@
+// Package audit 审计日志，供 middleware、controller 和 service 共用（middleware 不能依赖 service）
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+)
+
+// 请求参数和快照中需要脱敏的字段
+var sensitiveKeys = map[string]bool{
+	"pwd":             true,
+	"password":        true,
+	"currentPassword": true,
+	"newPassword":     true,
+	"confirmPassword": true,
+	"secret":          true,
+	"code":            true,
+	"token":           true,
+	"refreshToken":    true,
+	"preAuthToken":    true,
+	"key":             true,
+}
+
+const (
+	masked = "***"
+	// 单条记录请求参数的最大长度
+	maxRequestLen = 4096
+)
+
+// Record 写入一条审计日志，失败只打印日志不影响业务
+func Record(entry *model.AuditLog) {
+	if entry.CreatedTime == 0 {
+		entry.CreatedTime = time.Now().UnixMilli()
+	}
+	if err := global.DB.Create(entry).Error; err != nil {
+		log.Printf("写入审计日志失败: %v", err)
+	}
+}
+
+// System 记录系统自动执行的操作，如超额暂停、到期禁用
+func System(action, targetType string, targetId int64, message string) {
+	Record(&model.AuditLog{
+		ActorType:  model.AuditActorSystem,
+		ActorName:  "system",
+		Action:     action,
+		TargetType: targetType,
+		TargetId:   fmt.Sprint(targetId),
+		Message:    message,
+	})
+}
+
+// Snapshot 将对象转换为字段 map，用于对比修改前后的差异
+func Snapshot(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var m map[string]interface{}
+	if json.Unmarshal(data, &m) != nil {
+		return nil
+	}
+	return m
+}
+
+// Diff 对比前后快照，返回变更字段的 JSON；敏感字段只记录发生了变化
+func Diff(before, after map[string]interface{}) string {
+	changes := make(map[string]map[string]interface{})
+	for k, b := range before {
+		a, ok := after[k]
+		if after != nil && ok && equal(a, b) {
+			continue
+		}
+		changes[k] = map[string]interface{}{"before": b, "after": a}
+	}
+	for k, a := range after {
+		if _, ok := before[k]; !ok {
+			changes[k] = map[string]interface{}{"before": nil, "after": a}
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+	for k, c := range changes {
+		if sensitiveKeys[k] || model.SecretConfigs[k] {
+			c["before"], c["after"] = masked, masked
+		}
+	}
+	data, _ := json.Marshal(changes)
+	return string(data)
+}
+
+// Sanitize 脱敏 JSON 请求参数；非 JSON 内容不记录
+func Sanitize(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var v interface{}
+	if json.Unmarshal(body, &v) != nil {
+		return ""
+	}
+	data, _ := json.Marshal(mask(v))
+	if len(data) > maxRequestLen {
+		return string(data[:maxRequestLen])
+	}
+	return string(data)
+}
+
+// Prune 删除超过保留天数的审计日志，days <= 0 表示永久保留
+func Prune(now time.Time, days int) int64 {
+	if days <= 0 {
+		return 0
+	}
+	cutoff := now.AddDate(0, 0, -days).UnixMilli()
+	return global.DB.Where("created_time < ?", cutoff).Delete(&model.AuditLog{}).RowsAffected
+}
+
+func mask(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if sensitiveKeys[k] || model.SecretConfigs[k] {
+				t[k] = masked
+			} else {
+				t[k] = mask(val)
+			}
+		}
+		// 单项配置更新 {"name": ..., "value": ...}
+		if name, ok := t["name"].(string); ok && model.SecretConfigs[name] {
+			if _, ok := t["value"]; ok {
+				t["value"] = masked
+			}
+		}
+	case []interface{}:
+		for i := range t {
+			t[i] = mask(t[i])
+		}
+	}
+	return v
+}
+
+func equal(a, b interface{}) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
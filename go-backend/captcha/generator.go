@@ -7,6 +7,7 @@ import (
 	"image"
 	"image/color"
 	imagedraw "image/draw"
+	_ "image/jpeg"
 	"image/png"
 	"math/rand"
 	"os"
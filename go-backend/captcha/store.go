@@ -0,0 +1,163 @@
+package captcha
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+type challenge struct {
+	targetX int
+	width   int
+	expires time.Time
+}
+
+type attemptWindow struct {
+	start    time.Time
+	requests int
+	failures int
+}
+
+// Store 验证码挑战、校验凭证和按 IP 的尝试次数，均保存在内存中
+// 挑战和凭证都只能使用一次，取出即删除
+type Store struct {
+	ChallengeTTL time.Duration // 挑战有效期
+	TokenTTL     time.Duration // 校验通过后凭证的有效期
+	Window       time.Duration // 尝试次数统计窗口
+	MaxRequests  int           // 窗口内每个 IP 最多获取的验证码数
+	MaxFailures  int           // 窗口内每个 IP 最多校验失败次数
+
+	mu         sync.Mutex
+	challenges map[string]challenge
+	tokens     map[string]time.Time
+	attempts   map[string]*attemptWindow
+	lastSweep  time.Time
+}
+
+func NewStore() *Store {
+	return &Store{
+		ChallengeTTL: 2 * time.Minute,
+		TokenTTL:     2 * time.Minute,
+		Window:       10 * time.Minute,
+		MaxRequests:  60,
+		MaxFailures:  10,
+		challenges:   make(map[string]challenge),
+		tokens:       make(map[string]time.Time),
+		attempts:     make(map[string]*attemptWindow),
+	}
+}
+
+// NewId 生成随机 ID，用于挑战和凭证
+func NewId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// PutChallenge 保存一次滑块挑战，targetX 为缺口在原图（width 宽）上的横坐标
+func (s *Store) PutChallenge(id string, targetX, width int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep()
+	s.challenges[id] = challenge{targetX: targetX, width: width, expires: time.Now().Add(s.ChallengeTTL)}
+}
+
+// TakeChallenge 取出并删除挑战，无论随后校验是否通过都不能再次使用
+func (s *Store) TakeChallenge(id string) (targetX, width int, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, found := s.challenges[id]
+	if !found {
+		return 0, 0, false
+	}
+	delete(s.challenges, id)
+	if time.Now().After(c.expires) {
+		return 0, 0, false
+	}
+	return c.targetX, c.width, true
+}
+
+// IssueToken 签发校验通过的凭证，登录时消费
+func (s *Store) IssueToken() string {
+	token := NewId()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[token] = time.Now().Add(s.TokenTTL)
+	return token
+}
+
+// ConsumeToken 消费凭证，凭证不存在、已使用或已过期时返回 false
+func (s *Store) ConsumeToken(token string) bool {
+	if token == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expires, ok := s.tokens[token]
+	if !ok {
+		return false
+	}
+	delete(s.tokens, token)
+	return time.Now().Before(expires)
+}
+
+// AllowRequest 记录一次获取验证码，超过窗口内的请求数或失败次数时拒绝
+func (s *Store) AllowRequest(ip string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w := s.window(ip)
+	if w.requests >= s.MaxRequests || w.failures >= s.MaxFailures {
+		return false
+	}
+	w.requests++
+	return true
+}
+
+// Blocked 该 IP 在窗口内校验失败次数是否已达上限
+func (s *Store) Blocked(ip string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.window(ip).failures >= s.MaxFailures
+}
+
+// RecordFailure 记录一次校验失败
+func (s *Store) RecordFailure(ip string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.window(ip).failures++
+}
+
+func (s *Store) window(ip string) *attemptWindow {
+	now := time.Now()
+	w, ok := s.attempts[ip]
+	if !ok || now.Sub(w.start) >= s.Window {
+		w = &attemptWindow{start: now}
+		s.attempts[ip] = w
+	}
+	return w
+}
+
+// sweep 每分钟最多清理一次过期数据，调用方需持有锁
+func (s *Store) sweep() {
+	now := time.Now()
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for id, c := range s.challenges {
+		if now.After(c.expires) {
+			delete(s.challenges, id)
+		}
+	}
+	for token, expires := range s.tokens {
+		if now.After(expires) {
+			delete(s.tokens, token)
+		}
+	}
+	for ip, w := range s.attempts {
+		if now.Sub(w.start) >= s.Window {
+			delete(s.attempts, ip)
+		}
+	}
+}
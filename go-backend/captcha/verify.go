@@ -0,0 +1,45 @@
+package captcha
+
+import (
+	"math"
+	"time"
+)
+
+// TrackPoint 滑动轨迹中的一个点，坐标为前端显示尺寸下的像素
+type TrackPoint struct {
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+	Type string  `json:"type"`
+	T    int64   `json:"t"`
+}
+
+// Track 前端提交的滑动轨迹（tianai-captcha 格式）
+type Track struct {
+	BgImageWidth        float64      `json:"bgImageWidth"`
+	BgImageHeight       float64      `json:"bgImageHeight"`
+	TemplateImageWidth  float64      `json:"templateImageWidth"`
+	TemplateImageHeight float64      `json:"templateImageHeight"`
+	StartTime           time.Time    `json:"startTime"`
+	StopTime            time.Time    `json:"stopTime"`
+	TrackList           []TrackPoint `json:"trackList"`
+}
+
+const (
+	// Tolerance 允许的位置误差，占背景图宽度的比例
+	Tolerance = 0.02
+	// 轨迹点数和滑动耗时的下限，用于拦截直接提交终点坐标的脚本
+	minTrackPoints = 3
+	minSlideTime   = 200 * time.Millisecond
+)
+
+// VerifySlider 按比例换算前端滑动距离，与缺口位置的误差在容差内即通过
+func VerifySlider(targetX, width int, track Track) bool {
+	if width <= 0 || track.BgImageWidth <= 0 || len(track.TrackList) < minTrackPoints {
+		return false
+	}
+	if track.StopTime.Sub(track.StartTime) < minSlideTime {
+		return false
+	}
+	moveX := track.TrackList[len(track.TrackList)-1].X
+	return math.Abs(moveX/track.BgImageWidth-float64(targetX)/float64(width)) <= Tolerance
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -11,10 +12,14 @@ type Config struct {
 	Database  DatabaseConfig
 	JwtSecret string
 	LogDir    string
+	// MetricsToken 为空时不开放 /metrics
+	MetricsToken string
 }
 
 type ServerConfig struct {
 	Port int
+	// TrustedProxies 反向代理（Nginx/Caddy）地址或网段，只信任来自这些地址的 X-Forwarded-For
+	TrustedProxies []string
 }
 
 type DatabaseConfig struct {
@@ -32,6 +37,7 @@ func InitConfig() {
 	viper.SetDefault("server.port", 6365)
 	viper.SetDefault("jwt-secret", "your-secret-key")
 	viper.SetDefault("log-dir", "./logs")
+	viper.SetDefault("server.trusted-proxies", "127.0.0.1,::1")
 
 	// 数据库默认值(优先读取环境变量)
 	viper.BindEnv("database.type", "DB_TYPE")
@@ -41,8 +47,10 @@ func InitConfig() {
 	viper.BindEnv("database.password", "DB_PASSWORD")
 
 	viper.BindEnv("server.port", "SERVER_PORT")
+	viper.BindEnv("server.trusted-proxies", "TRUSTED_PROXIES")
 	viper.BindEnv("jwt-secret", "JWT_SECRET")
 	viper.BindEnv("log-dir", "LOG_DIR")
+	viper.BindEnv("metrics-token", "METRICS_TOKEN")
 
 	viper.SetDefault("database.host", "127.0.0.1")
 	viper.SetDefault("database.port", 3306)
@@ -62,8 +70,10 @@ func InitConfig() {
 	}
 
 	AppConfig.Server.Port = viper.GetInt("server.port")
+	AppConfig.Server.TrustedProxies = splitList(viper.GetStringSlice("server.trusted-proxies"))
 	AppConfig.JwtSecret = viper.GetString("jwt-secret")
 	AppConfig.LogDir = viper.GetString("log-dir")
+	AppConfig.MetricsToken = viper.GetString("metrics-token")
 
 	AppConfig.Database.Type = viper.GetString("database.type")
 	AppConfig.Database.Host = viper.GetString("database.host")
@@ -72,3 +82,16 @@ func InitConfig() {
 	AppConfig.Database.User = viper.GetString("database.user")
 	AppConfig.Database.Password = viper.GetString("database.password")
 }
+
+// splitList 兼容 YAML 列表和逗号分隔的环境变量
+func splitList(values []string) []string {
+	var list []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"net/http"
+
+	"go-backend/model/dto"
+	"go-backend/service"
+	"go-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ApiKeyController struct{}
+
+func (a *ApiKeyController) Create(c *gin.Context) {
+	var dto dto.ApiKeyCreateDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.ApiKey.Create(claims, dto))
+}
+
+func (a *ApiKeyController) List(c *gin.Context) {
+	var dto dto.ApiKeyListDto
+	// 请求体可为空，表示查看自己的
+	_ = c.ShouldBindJSON(&dto)
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.ApiKey.List(claims, dto))
+}
+
+func (a *ApiKeyController) Delete(c *gin.Context) {
+	var dto dto.ApiKeyDeleteDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.ApiKey.Delete(claims, dto.ID))
+}
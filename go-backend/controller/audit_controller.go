@@ -0,0 +1,21 @@
+package controller
+
+import (
+	"net/http"
+
+	"go-backend/model/dto"
+	"go-backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditController struct{}
+
+func (a *AuditController) List(c *gin.Context) {
+	var query dto.AuditQueryDto
+	if err := c.ShouldBindJSON(&query); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, service.Audit.List(query))
+}
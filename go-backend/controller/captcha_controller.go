@@ -13,8 +13,7 @@ import (
 type CaptchaController struct{}
 
 func (u *CaptchaController) Check(c *gin.Context) {
-	config := service.ViteConfig.GetValue("captcha_enabled")
-	if config != "true" {
+	if !service.Captcha.Enabled() {
 		c.JSON(http.StatusOK, result.Ok(0))
 		return
 	}
@@ -22,9 +21,7 @@ func (u *CaptchaController) Check(c *gin.Context) {
 }
 
 func (u *CaptchaController) Generate(c *gin.Context) {
-	// TODO: Implement actual captcha generation if enabled
-	// For now return error or empty as it's disabled
-	c.JSON(http.StatusOK, result.Err(-1, "Captcha not implemented"))
+	c.JSON(http.StatusOK, service.Captcha.Generate(c.ClientIP()))
 }
 
 func (u *CaptchaController) Verify(c *gin.Context) {
@@ -33,9 +30,5 @@ func (u *CaptchaController) Verify(c *gin.Context) {
 		c.JSON(http.StatusOK, result.Err(-1, "参数错误"))
 		return
 	}
-
-	// Mock success for now since we disabled it, but if frontend calls verify, it expects validToken
-	// But if check returns 0, frontend shouldn't call verify.
-
-	c.JSON(http.StatusOK, result.Ok(map[string]interface{}{"validToken": "mock_token"}))
+	c.JSON(http.StatusOK, service.Captcha.Verify(c.ClientIP(), dto))
 }
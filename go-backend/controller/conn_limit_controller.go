@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"net/http"
+
+	"go-backend/model/dto"
+	"go-backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ConnLimitController struct{}
+
+func (c *ConnLimitController) Create(ctx *gin.Context) {
+	var connLimitDto dto.ConnLimitDto
+	if err := ctx.ShouldBindJSON(&connLimitDto); err != nil {
+		service.ResponseError(ctx, -1, "参数错误: "+err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, service.ConnLimit.CreateConnLimit(connLimitDto))
+}
+
+func (c *ConnLimitController) List(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, service.ConnLimit.GetAllConnLimits())
+}
+
+func (c *ConnLimitController) Update(ctx *gin.Context) {
+	var updateDto dto.ConnLimitUpdateDto
+	if err := ctx.ShouldBindJSON(&updateDto); err != nil {
+		service.ResponseError(ctx, -1, "参数错误: "+err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, service.ConnLimit.UpdateConnLimit(updateDto))
+}
+
+func (c *ConnLimitController) Delete(ctx *gin.Context) {
+	var params map[string]interface{}
+	if err := ctx.ShouldBindJSON(&params); err != nil {
+		service.ResponseError(ctx, -1, "参数错误")
+		return
+	}
+	id, ok := params["id"].(float64)
+	if !ok {
+		service.ResponseError(ctx, -1, "参数错误")
+		return
+	}
+	ctx.JSON(http.StatusOK, service.ConnLimit.DeleteConnLimit(int64(id)))
+}
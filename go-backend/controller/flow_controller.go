@@ -5,16 +5,21 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 
+	"go-backend/audit"
 	"go-backend/global"
+	"go-backend/metrics"
 	"go-backend/model"
 	"go-backend/model/dto"
+	"go-backend/service"
 	"go-backend/utils"
 	"go-backend/websocket"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type FlowController struct{}
@@ -43,14 +48,11 @@ func (c *FlowController) Config(ctx *gin.Context) {
 		return
 	}
 
-	var rawData string
-	if err := ctx.ShouldBindJSON(&rawData); err != nil {
-		ctx.String(http.StatusOK, SUCCESS_RESPONSE)
-		return
-	}
+	// 节点上报的是 JSON 对象（加密包装或原始配置），按原始字节读取
+	body, _ := ctx.GetRawData()
 
 	// 解密数据
-	decryptedData, err := decryptIfNeeded(rawData, secret)
+	decryptedData, err := decryptIfNeeded(string(body), secret)
 	if err != nil {
 		log.Printf("解密配置数据失败: %v", err)
 		ctx.String(http.StatusOK, SUCCESS_RESPONSE)
@@ -65,8 +67,9 @@ func (c *FlowController) Config(ctx *gin.Context) {
 		return
 	}
 
-	// 触发异步配置检查（Java 中的 CheckGostConfigAsync）
-	go checkGostConfig(node.ID, &gostConfig)
+	// 异步对账，补齐节点缺失或过期的配置并清理多余服务
+	// 差异数量写入日志，结果保存供节点页面通过 /node/reconcile 查看
+	go service.Reconcile.ReconcileNode(node.ID, &gostConfig)
 
 	log.Printf("🔓 节点 %d 配置数据接收成功", node.ID)
 	ctx.String(http.StatusOK, SUCCESS_RESPONSE)
@@ -77,7 +80,8 @@ func (c *FlowController) Upload(ctx *gin.Context) {
 	secret := ctx.Query("secret")
 
 	// 验证节点
-	if !isValidNode(secret) {
+	var node model.Node
+	if err := global.DB.Where("secret = ?", secret).First(&node).Error; err != nil {
 		ctx.String(http.StatusOK, SUCCESS_RESPONSE)
 		return
 	}
@@ -111,7 +115,7 @@ func (c *FlowController) Upload(ctx *gin.Context) {
 	log.Printf("节点上报流量数据: %+v", flowData)
 
 	// 处理流量数据
-	processFlowData(&flowData)
+	processFlowData(&flowData, node.ID)
 
 	ctx.String(http.StatusOK, SUCCESS_RESPONSE)
 }
@@ -146,41 +150,8 @@ func decryptIfNeeded(rawData string, secret string) (string, error) {
 	return rawData, nil
 }
 
-// isValidNode 验证节点密钥
-func isValidNode(secret string) bool {
-	var count int64
-	global.DB.Model(&model.Node{}).Where("secret = ?", secret).Count(&count)
-	return count > 0
-}
-
-// checkGostConfig 检查 Gost 配置
-func checkGostConfig(nodeId int64, config *dto.GostConfigDto) {
-	// 获取数据库中该节点的所有转发
-	var forwards []model.Forward
-	global.DB.Joins("JOIN tunnel ON forward.tunnel_id = tunnel.id").
-		Where("tunnel.in_node_id = ?", nodeId).
-		Find(&forwards)
-
-	// 构建期望的服务名列表
-	expectedServices := make(map[string]bool)
-	for _, forward := range forwards {
-		var userTunnel model.UserTunnel
-		global.DB.Where("user_id = ? AND tunnel_id = ?", forward.UserId, forward.TunnelId).First(&userTunnel)
-
-		serviceName := fmt.Sprintf("%d_%d_%d", forward.ID, forward.UserId, userTunnel.ID)
-		expectedServices[serviceName] = true
-	}
-
-	// 检查配置中多余的服务
-	for _, svc := range config.Services {
-		if !expectedServices[svc.Name] && !strings.HasPrefix(svc.Name, "web_api") {
-			log.Printf("⚠️ 发现多余的 Gost 服务: %s，将由节点清理", svc.Name)
-		}
-	}
-}
-
 // processFlowData 处理流量数据
-func processFlowData(flowData *dto.FlowDto) {
+func processFlowData(flowData *dto.FlowDto, nodeId int64) {
 	// 解析服务名
 	parts := strings.Split(flowData.N, "_")
 	if len(parts) < 3 {
@@ -218,20 +189,48 @@ func processFlowData(flowData *dto.FlowDto) {
 
 	// 应用流量倍率和单双向计算
 	inFlow, outFlow := calculateFlow(rawIn, rawOut, &tunnel)
+	metrics.FlowReports.Inc()
+	metrics.FlowBytes.Add(float64(inFlow), "in")
+	metrics.FlowBytes.Add(float64(outFlow), "out")
 
 	// 更新流量统计（并发安全）
 	updateForwardFlow(forwardId, inFlow, outFlow)
 	updateUserFlow(userId, inFlow, outFlow)
 	updateUserTunnelFlow(userTunnelId, inFlow, outFlow)
+	parentId, parentTunnelId := rollUpParentFlow(forward.UserId, forward.TunnelId, inFlow, outFlow)
+	service.Traffic.Record(forward.ID, tunnel.ID, nodeId, forward.UserId, inFlow, outFlow)
 
 	// 检查限制并自动暂停
-	serviceName := fmt.Sprintf("%s_%s_%s", forwardId, userId, userTunnelId)
 	if userTunnelId != DEFAULT_USER_TUNNEL_ID {
-		checkUserLimits(userId, serviceName)
-		checkUserTunnelLimits(userTunnelId, serviceName, userId)
+		checkUserLimits(userId)
+		checkUserTunnelLimits(userTunnelId, userId)
+		// 上级额度用尽时，上级和全部下级的转发一起暂停
+		if parentId != "" {
+			checkUserLimits(parentId)
+			if parentTunnelId != "" {
+				checkUserTunnelLimits(parentTunnelId, parentId)
+			}
+		}
 	}
 }
 
+// rollUpParentFlow 分销商下级的流量同时计入上级的账号和隧道额度，返回上级用户 ID 和上级用户隧道 ID
+func rollUpParentFlow(userId int64, tunnelId int64, inFlow, outFlow int64) (parentId string, parentTunnelId string) {
+	var user model.User
+	if err := global.DB.Select("id", "parent_id").First(&user, userId).Error; err != nil || user.ParentId == 0 {
+		return "", ""
+	}
+	parentId = strconv.FormatInt(user.ParentId, 10)
+	updateUserFlow(parentId, inFlow, outFlow)
+
+	var parentTunnel model.UserTunnel
+	if err := global.DB.Where("user_id = ? AND tunnel_id = ?", user.ParentId, tunnelId).First(&parentTunnel).Error; err == nil {
+		parentTunnelId = strconv.Itoa(parentTunnel.ID)
+		updateUserTunnelFlow(parentTunnelId, inFlow, outFlow)
+	}
+	return parentId, parentTunnelId
+}
+
 // calculateFlow 计算流量（考虑倍率和单双向）
 func calculateFlow(rawIn, rawOut int64, tunnel *model.Tunnel) (inFlow, outFlow int64) {
 	ratio := float64(tunnel.TrafficRatio)
@@ -283,106 +282,122 @@ func updateUserTunnelFlow(userTunnelId string, inFlow, outFlow int64) {
 		inFlow, outFlow, userTunnelId)
 }
 
+// quotaPauseReasons 暂停事件指标名对应的转发暂停原因
+var quotaPauseReasons = map[string]string{
+	"user_flow":       model.PauseUserQuota,
+	"user_expired":    model.PauseExpired,
+	"user_disabled":   model.PauseAdmin,
+	"tunnel_flow":     model.PauseTunnelQuota,
+	"tunnel_expired":  model.PauseExpired,
+	"tunnel_disabled": model.PauseAdmin,
+}
+
 // checkUserLimits 检查用户流量和状态限制
-func checkUserLimits(userId string, serviceName string) {
+func checkUserLimits(userId string) {
 	var user model.User
 	if err := global.DB.Where("id = ?", userId).First(&user).Error; err != nil {
 		return
 	}
 
-	shouldPause := false
+	// reason 用于暂停事件指标
+	reason := ""
 
 	// 检查流量限制
 	totalFlow := user.InFlow + user.OutFlow
 	if user.Flow > 0 && totalFlow >= user.Flow*BYTES_TO_GB {
-		shouldPause = true
+		reason = "user_flow"
 		log.Printf("用户 %d 流量超限，暂停所有服务", user.ID)
 	}
 
 	// 检查到期时间
 	if user.ExpTime > 0 && user.ExpTime <= utils.CurrentTimeMillis() {
-		shouldPause = true
+		reason = "user_expired"
 		log.Printf("用户 %d 已到期，暂停所有服务", user.ID)
 	}
 
 	// 检查用户状态
 	if user.Status != 1 {
-		shouldPause = true
+		reason = "user_disabled"
 	}
 
-	if shouldPause {
-		pauseAllUserForwards(user.ID, serviceName)
+	if reason != "" {
+		paused := pauseAllUserForwards(user.ID, quotaPauseReasons[reason])
+		metrics.QuotaPauses.Add(float64(paused), reason)
+		// 已暂停的不再重复记录
+		if paused > 0 {
+			audit.System("user.quota_pause", "user", user.ID, fmt.Sprintf("%s: 暂停 %d 条转发", reason, paused))
+		}
 	}
+
+	service.Notify.CheckUserFlow(&user)
 }
 
 // checkUserTunnelLimits 检查用户隧道限制
-func checkUserTunnelLimits(userTunnelId string, serviceName string, userId string) {
+func checkUserTunnelLimits(userTunnelId string, userId string) {
 	var userTunnel model.UserTunnel
 	if err := global.DB.Where("id = ?", userTunnelId).First(&userTunnel).Error; err != nil {
 		return
 	}
 
-	shouldPause := false
+	// reason 用于暂停事件指标
+	reason := ""
 
 	// 检查流量限制
 	totalFlow := userTunnel.InFlow + userTunnel.OutFlow
 	if userTunnel.Flow > 0 && totalFlow >= int64(userTunnel.Flow)*BYTES_TO_GB {
-		shouldPause = true
+		reason = "tunnel_flow"
 		log.Printf("用户隧道 %d 流量超限，暂停服务", userTunnel.ID)
 	}
 
 	// 检查到期时间
 	if userTunnel.ExpTime > 0 && userTunnel.ExpTime <= utils.CurrentTimeMillis() {
-		shouldPause = true
+		reason = "tunnel_expired"
 		log.Printf("用户隧道 %d 已到期，暂停服务", userTunnel.ID)
 	}
 
 	// 检查状态
 	if userTunnel.Status != 1 {
-		shouldPause = true
+		reason = "tunnel_disabled"
 	}
 
-	if shouldPause {
-		pauseTunnelForwards(int64(userTunnel.TunnelId), userId, serviceName)
+	if reason != "" {
+		paused := pauseTunnelForwards(int64(userTunnel.TunnelId), userId, quotaPauseReasons[reason])
+		metrics.QuotaPauses.Add(float64(paused), reason)
+		if paused > 0 {
+			audit.System("user_tunnel.quota_pause", "user_tunnel", int64(userTunnel.ID), fmt.Sprintf("%s: 暂停 %d 条转发", reason, paused))
+		}
 	}
+
+	service.Notify.CheckUserTunnelFlow(&userTunnel)
 }
 
-// pauseAllUserForwards 暂停用户所有转发
-func pauseAllUserForwards(userId int64, serviceName string) {
+// pauseAllUserForwards 暂停用户（及其下级用户）所有转发，已暂停的只记录原因，返回新暂停的转发数
+func pauseAllUserForwards(userId int64, reason string) int {
 	var forwards []model.Forward
-	global.DB.Where("user_id = ?", userId).Find(&forwards)
+	global.DB.Where("user_id = ? OR user_id IN (?)", userId, subUserIds(userId)).Find(&forwards)
 
-	for _, forward := range forwards {
-		pauseForwardService(&forward, serviceName)
-	}
+	return autoPauseForwards(forwards, reason)
 }
 
-// pauseTunnelForwards 暂停隧道下的转发
-func pauseTunnelForwards(tunnelId int64, userId string, serviceName string) {
+// pauseTunnelForwards 暂停用户（及其下级用户）在隧道下的转发，已暂停的只记录原因，返回新暂停的转发数
+func pauseTunnelForwards(tunnelId int64, userId string, reason string) int {
 	var forwards []model.Forward
-	global.DB.Where("tunnel_id = ? AND user_id = ?", tunnelId, userId).Find(&forwards)
+	global.DB.Where("tunnel_id = ? AND (user_id = ? OR user_id IN (?))", tunnelId, userId, subUserIds(userId)).Find(&forwards)
 
-	for _, forward := range forwards {
-		pauseForwardService(&forward, serviceName)
-	}
+	return autoPauseForwards(forwards, reason)
 }
 
-// pauseForwardService 暂停转发服务
-func pauseForwardService(forward *model.Forward, serviceName string) {
-	var tunnel model.Tunnel
-	if err := global.DB.First(&tunnel, forward.TunnelId).Error; err != nil {
-		return
-	}
-
-	// 暂停入口服务
-	utils.PauseService(tunnel.InNodeId, serviceName)
-
-	// 如果是隧道转发，暂停远程服务
-	if tunnel.Type == 2 {
-		utils.PauseRemoteService(tunnel.OutNodeId, serviceName)
+func autoPauseForwards(forwards []model.Forward, reason string) int {
+	paused := 0
+	for i := range forwards {
+		if service.Forward.AutoPauseForward(&forwards[i], reason) {
+			paused++
+		}
 	}
+	return paused
+}
 
-	// 更新转发状态
-	forward.Status = 0
-	global.DB.Save(forward)
+// subUserIds 下级用户 ID 子查询
+func subUserIds(userId interface{}) *gorm.DB {
+	return global.DB.Model(&model.User{}).Select("id").Where("parent_id = ?", userId)
 }
@@ -44,6 +44,9 @@ func (u *ForwardController) Update(c *gin.Context) {
 		InPort:        updateDto.InPort,
 		InterfaceName: updateDto.InterfaceName,
 		Strategy:      updateDto.Strategy,
+		ConnLimitId:   updateDto.ConnLimitId,
+		AllowCidrs:    updateDto.AllowCidrs,
+		DenyCidrs:     updateDto.DenyCidrs,
 	}
 
 	claims := c.MustGet("claims").(*utils.UserClaims)
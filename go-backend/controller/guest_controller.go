@@ -3,8 +3,6 @@ package controller
 import (
 	"net/http"
 
-	"go-backend/global"
-	"go-backend/model"
 	"go-backend/model/dto"
 	"go-backend/result"
 	"go-backend/service"
@@ -23,65 +21,13 @@ func (g *GuestController) GetDashboard(c *gin.Context) {
 		return
 	}
 
-	var guestLink model.GuestLink
-	if err := global.DB.Where("token = ?", token).First(&guestLink).Error; err != nil {
-		c.JSON(http.StatusOK, result.Err(401, "Invalid token"))
+	// 流量历史，可通过 start / end / period 查询参数指定范围
+	var rng dto.TrafficRangeDto
+	if err := c.ShouldBindQuery(&rng); err != nil {
+		c.JSON(http.StatusOK, result.Err(-1, "参数错误: "+err.Error()))
 		return
 	}
-
-	var user model.User
-	if err := global.DB.First(&user, guestLink.UserID).Error; err != nil {
-		c.JSON(http.StatusOK, result.Err(404, "User not found"))
-		return
-	}
-
-	// Fetch Forwards
-	var forwards []model.Forward
-	global.DB.Where("user_id = ?", user.ID).Find(&forwards)
-
-	forwardDtos := make([]dto.UserForwardDetailDto, 0, len(forwards))
-	for _, f := range forwards {
-		var tunnel model.Tunnel
-		// Best effort to find tunnel. If deleted, might be empty or error.
-		global.DB.First(&tunnel, f.TunnelId)
-
-		forwardDtos = append(forwardDtos, dto.UserForwardDetailDto{
-			ID:         f.ID,
-			Name:       f.Name,
-			TunnelId:   f.TunnelId,
-			TunnelName: tunnel.Name,
-			InIP:       tunnel.InIp,
-			InPort:     f.InPort,
-			RemoteAddr: "",
-			InFlow:     f.InFlow,
-			OutFlow:    f.OutFlow,
-			Status:     f.Status,
-			CreatedAt:  f.CreatedTime,
-		})
-	}
-
-	// Fetch StatisticsFlows (Last 24h)
-	flowList := service.User.GetLast24HoursFlowStatistics(user.ID)
-
-	// Fetch Tunnel Permissions
-	permissions := service.User.GetTunnelPermissions(user.ID)
-
-	resp := dto.GuestDashboardDto{
-		UserInfo: dto.GuestUserInfoDto{
-			Status:        user.Status,
-			Flow:          user.Flow,
-			InFlow:        user.InFlow,
-			OutFlow:       user.OutFlow,
-			Num:           user.Num,
-			FlowResetTime: user.FlowResetTime,
-			ExpTime:       user.ExpTime,
-		},
-		TunnelPermissions: permissions,
-		Forwards:          forwardDtos,
-		StatisticsFlows:   flowList,
-	}
-
-	c.JSON(http.StatusOK, result.Ok(resp))
+	c.JSON(http.StatusOK, service.GuestLink.Dashboard(token, c.ClientIP(), rng))
 }
 
 func (g *GuestController) DebugCrash(c *gin.Context) {
@@ -92,6 +38,6 @@ func (g *GuestController) DebugCrash(c *gin.Context) {
 		},
 	}
 	// Call the service method that is suspected to crash
-	res := service.User.GetUserPackageInfo(claims)
+	res := service.User.GetUserPackageInfo(claims, dto.TrafficRangeDto{})
 	c.JSON(http.StatusOK, res)
 }
@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"go-backend/config"
+	"go-backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MetricsController struct{}
+
+// Metrics Prometheus 抓取入口，未配置 metrics-token 时视为未开放
+func (m *MetricsController) Metrics(c *gin.Context) {
+	expected := config.AppConfig.MetricsToken
+	if expected == "" {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if token == "" {
+		token = c.Query("token")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	service.Metrics.Write(c.Writer)
+}
@@ -52,3 +52,24 @@ func (u *NodeController) Install(c *gin.Context) {
 	id := int64(params["id"].(float64))
 	c.JSON(http.StatusOK, service.Node.GetInstallCommand(id))
 }
+
+func (u *NodeController) Commands(c *gin.Context) {
+	var query dto.NodeCommandQueryDto
+	c.ShouldBindJSON(&query)
+	c.JSON(http.StatusOK, service.NodeCommand.ListCommands(query))
+}
+
+func (u *NodeController) Reconcile(c *gin.Context) {
+	var query dto.NodeReconcileQueryDto
+	c.ShouldBindJSON(&query)
+	c.JSON(http.StatusOK, service.Reconcile.LastReports(query.NodeId))
+}
+
+func (u *NodeController) PurgeCommands(c *gin.Context) {
+	var req dto.NodeCommandPurgeDto
+	if err := c.ShouldBindJSON(&req); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, service.NodeCommand.PurgeCommands(req))
+}
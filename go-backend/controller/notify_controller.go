@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"net/http"
+
+	"go-backend/model/dto"
+	"go-backend/service"
+	"go-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotifyController struct{}
+
+func (n *NotifyController) Get(c *gin.Context) {
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.Notify.GetSetting(claims))
+}
+
+func (n *NotifyController) Update(c *gin.Context) {
+	var dto dto.NotifySettingDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.Notify.UpdateSetting(claims, dto))
+}
+
+func (n *NotifyController) Test(c *gin.Context) {
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.Notify.Test(claims))
+}
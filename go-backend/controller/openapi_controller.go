@@ -8,7 +8,7 @@ import (
 	"go-backend/global"
 	"go-backend/model"
 	"go-backend/result"
-	"go-backend/utils"
+	"go-backend/service"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -30,9 +30,15 @@ func (o *OpenAPIController) SubStore(c *gin.Context) {
 		return
 	}
 
+	if err := service.LoginGuard.Check(c.ClientIP(), username); err != nil {
+		c.JSON(http.StatusOK, result.Err(-1, err.Error()))
+		return
+	}
+
 	var user model.User
 	if err := global.DB.Where("user = ?", username).First(&user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
+			service.LoginGuard.Fail(c.ClientIP(), username, service.LoginSourceSubStore)
 			c.JSON(http.StatusOK, result.Err(-1, "鉴权失败"))
 		} else {
 			c.JSON(http.StatusOK, result.Err(-1, "查询用户失败"))
@@ -40,10 +46,12 @@ func (o *OpenAPIController) SubStore(c *gin.Context) {
 		return
 	}
 
-	if utils.Md5(password) != user.Pwd {
+	if !service.User.CheckPassword(&user, password) {
+		service.LoginGuard.Fail(c.ClientIP(), username, service.LoginSourceSubStore)
 		c.JSON(http.StatusOK, result.Err(-1, "鉴权失败"))
 		return
 	}
+	service.LoginGuard.Succeed(username)
 
 	tunnelParam := c.DefaultQuery("tunnel", "-1")
 	headerValue, err := o.buildHeaderValue(user, tunnelParam)
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"net/http"
+
+	"go-backend/model/dto"
+	"go-backend/service"
+	"go-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PlanController struct{}
+
+func (p *PlanController) List(c *gin.Context) {
+	c.JSON(http.StatusOK, service.Plan.List())
+}
+
+func (p *PlanController) Create(c *gin.Context) {
+	var dto dto.PlanDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, service.Plan.Create(dto))
+}
+
+func (p *PlanController) Update(c *gin.Context) {
+	var dto dto.PlanUpdateDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, service.Plan.Update(dto))
+}
+
+func (p *PlanController) Delete(c *gin.Context) {
+	var dto dto.PlanDeleteDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, service.Plan.Delete(dto.ID))
+}
+
+func (p *PlanController) Assign(c *gin.Context) {
+	var dto dto.PlanAssignDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.Plan.Assign(claims, dto))
+}
+
+func (p *PlanController) Renew(c *gin.Context) {
+	var dto dto.PlanRenewDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.Plan.Renew(claims, dto))
+}
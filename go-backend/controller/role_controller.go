@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"net/http"
+
+	"go-backend/model/dto"
+	"go-backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoleController struct{}
+
+func (r *RoleController) List(c *gin.Context) {
+	c.JSON(http.StatusOK, service.Role.List())
+}
+
+func (r *RoleController) Create(c *gin.Context) {
+	var dto dto.RoleDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, service.Role.Create(dto))
+}
+
+func (r *RoleController) Update(c *gin.Context) {
+	var dto dto.RoleUpdateDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, service.Role.Update(dto))
+}
+
+func (r *RoleController) Delete(c *gin.Context) {
+	var dto dto.RoleDeleteDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, service.Role.Delete(dto.ID))
+}
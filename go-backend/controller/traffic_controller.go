@@ -0,0 +1,24 @@
+package controller
+
+import (
+	"net/http"
+
+	"go-backend/model/dto"
+	"go-backend/service"
+	"go-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TrafficController struct{}
+
+// Series 查询转发 / 隧道 / 节点 / 用户的流量时序
+func (c *TrafficController) Series(ctx *gin.Context) {
+	var queryDto dto.TrafficQueryDto
+	if err := ctx.ShouldBindJSON(&queryDto); err != nil {
+		service.ResponseError(ctx, -1, "参数错误: "+err.Error())
+		return
+	}
+	claims := ctx.MustGet("claims").(*utils.UserClaims)
+	ctx.JSON(http.StatusOK, service.Traffic.QuerySeries(queryDto, claims))
+}
@@ -52,13 +52,15 @@ func (u *TunnelController) AssignUserTunnel(c *gin.Context) {
 		service.ResponseError(c, -1, "参数错误: "+err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, service.UserTunnel.AssignUserTunnel(userTunnelDto))
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.UserTunnel.AssignUserTunnel(userTunnelDto, claims))
 }
 
 func (u *TunnelController) ListUserTunnels(c *gin.Context) {
 	var queryDto dto.UserTunnelQueryDto
 	c.ShouldBindJSON(&queryDto)
-	c.JSON(http.StatusOK, service.UserTunnel.GetUserTunnelList(queryDto))
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.UserTunnel.GetUserTunnelList(queryDto, claims))
 }
 
 func (u *TunnelController) RemoveUserTunnel(c *gin.Context) {
@@ -68,7 +70,8 @@ func (u *TunnelController) RemoveUserTunnel(c *gin.Context) {
 		return
 	}
 	id := int(params["id"].(float64))
-	c.JSON(http.StatusOK, service.UserTunnel.RemoveUserTunnel(id))
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.UserTunnel.RemoveUserTunnel(id, claims))
 }
 
 func (u *TunnelController) UpdateUserTunnel(c *gin.Context) {
@@ -77,7 +80,8 @@ func (u *TunnelController) UpdateUserTunnel(c *gin.Context) {
 		service.ResponseError(c, -1, "参数错误: "+err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, service.UserTunnel.UpdateUserTunnel(updateDto))
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.UserTunnel.UpdateUserTunnel(updateDto, claims))
 }
 
 func (u *TunnelController) GetUserTunnels(c *gin.Context) {
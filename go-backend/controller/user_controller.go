@@ -3,17 +3,13 @@ package controller
 import (
 	"net/http"
 	"strconv"
-	"time"
 
-	"go-backend/global"
-	"go-backend/model"
 	"go-backend/model/dto"
 	"go-backend/result"
 	"go-backend/service"
 	"go-backend/utils"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
 type UserController struct{}
@@ -24,7 +20,17 @@ func (u *UserController) Login(c *gin.Context) {
 		service.ResponseError(c, -1, "参数错误: "+err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, service.User.Login(loginDto))
+	c.JSON(http.StatusOK, service.User.Login(loginDto, clientInfo(c)))
+}
+
+// LoginTotp 登录第二步，校验两步验证码
+func (u *UserController) LoginTotp(c *gin.Context) {
+	var loginDto dto.TotpLoginDto
+	if err := c.ShouldBindJSON(&loginDto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, service.Totp.Login(loginDto, clientInfo(c)))
 }
 
 func (u *UserController) Create(c *gin.Context) {
@@ -33,11 +39,13 @@ func (u *UserController) Create(c *gin.Context) {
 		service.ResponseError(c, -1, "参数错误")
 		return
 	}
-	c.JSON(http.StatusOK, service.User.CreateUser(dto))
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.User.CreateUser(dto, claims))
 }
 
 func (u *UserController) List(c *gin.Context) {
-	c.JSON(http.StatusOK, service.User.GetAllUsers())
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.User.GetAllUsers(claims))
 }
 
 func (u *UserController) Update(c *gin.Context) {
@@ -46,7 +54,8 @@ func (u *UserController) Update(c *gin.Context) {
 		service.ResponseError(c, -1, "参数错误")
 		return
 	}
-	c.JSON(http.StatusOK, service.User.UpdateUser(dto))
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.User.UpdateUser(dto, claims))
 }
 
 func (u *UserController) Delete(c *gin.Context) {
@@ -56,7 +65,8 @@ func (u *UserController) Delete(c *gin.Context) {
 		return
 	}
 	id := int64(params["id"].(float64))
-	c.JSON(http.StatusOK, service.User.DeleteUser(id))
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.User.DeleteUser(id, claims))
 }
 
 func (u *UserController) UpdatePassword(c *gin.Context) {
@@ -70,8 +80,11 @@ func (u *UserController) UpdatePassword(c *gin.Context) {
 }
 
 func (u *UserController) Package(c *gin.Context) {
+	// 流量历史查询范围可选，请求体为空时使用默认范围
+	var rng dto.TrafficRangeDto
+	c.ShouldBindJSON(&rng)
 	claims := c.MustGet("claims").(*utils.UserClaims)
-	c.JSON(http.StatusOK, service.User.GetUserPackageInfo(claims))
+	c.JSON(http.StatusOK, service.User.GetUserPackageInfo(claims, rng))
 }
 
 func (u *UserController) Reset(c *gin.Context) {
@@ -80,42 +93,167 @@ func (u *UserController) Reset(c *gin.Context) {
 		service.ResponseError(c, -1, "参数错误: "+err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, service.User.ResetFlow(dto))
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.User.ResetFlow(dto, claims))
 }
 
+// GenerateGuestLink 获取默认访客链接，可管理目标用户时可通过 userId 指定
 func (u *UserController) GenerateGuestLink(c *gin.Context) {
 	claims := c.MustGet("claims").(*utils.UserClaims)
-	targetUserId := claims.GetUserId()
-
-	// If userId param is provided and user is admin, use that userId
-	userIdStr := c.Query("userId")
-	if userIdStr != "" && claims.RoleId == 0 {
+	var userId int64
+	if userIdStr := c.Query("userId"); userIdStr != "" {
 		var err error
-		targetUserId, err = strconv.ParseInt(userIdStr, 10, 64)
+		userId, err = strconv.ParseInt(userIdStr, 10, 64)
 		if err != nil {
 			service.ResponseError(c, -1, "Invalid parameters")
 			return
 		}
 	}
+	c.JSON(http.StatusOK, service.GuestLink.Default(claims, userId))
+}
+
+func (u *UserController) GuestLinks(c *gin.Context) {
+	var dto dto.GuestLinkListDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.GuestLink.List(claims, dto.UserId))
+}
+
+func (u *UserController) CreateGuestLink(c *gin.Context) {
+	var dto dto.GuestLinkCreateDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.GuestLink.Create(claims, dto))
+}
+
+func (u *UserController) UpdateGuestLink(c *gin.Context) {
+	var dto dto.GuestLinkUpdateDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.GuestLink.Update(claims, dto))
+}
+
+func (u *UserController) RevokeGuestLink(c *gin.Context) {
+	var dto dto.GuestLinkRevokeDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.GuestLink.Revoke(claims, dto.ID))
+}
+
+func (u *UserController) TotpSetup(c *gin.Context) {
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.Totp.Setup(claims))
+}
+
+func (u *UserController) TotpEnable(c *gin.Context) {
+	var dto dto.TotpEnableDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.Totp.Enable(claims, dto, clientInfo(c)))
+}
+
+func (u *UserController) TotpDisable(c *gin.Context) {
+	var dto dto.TotpDisableDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.Totp.Disable(claims, dto))
+}
 
-	// Check if link exists
-	var link model.GuestLink
-	if err := global.DB.Where("user_id = ?", targetUserId).First(&link).Error; err == nil {
-		c.JSON(http.StatusOK, result.Ok(dto.GuestLinkDto{Token: link.Token}))
+// TotpReset 管理员重置用户的两步验证
+func (u *UserController) TotpReset(c *gin.Context) {
+	var dto dto.TotpResetDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
 		return
 	}
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.Totp.Reset(claims, dto.ID))
+}
 
-	// Create new link
-	newLink := model.GuestLink{
-		UserID:      targetUserId,
-		Token:       uuid.New().String(),
-		CreatedTime: time.Now().UnixMilli(),
+// RefreshToken 使用 refresh token 换取新的 access token
+func (u *UserController) RefreshToken(c *gin.Context) {
+	var dto dto.RefreshTokenDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
 	}
+	c.JSON(http.StatusOK, service.Session.Refresh(dto, clientInfo(c)))
+}
+
+// Sessions 登录会话列表，管理员可指定用户
+func (u *UserController) Sessions(c *gin.Context) {
+	var dto dto.SessionListDto
+	// 请求体可为空，表示查看自己的会话
+	_ = c.ShouldBindJSON(&dto)
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.Session.List(claims, dto))
+}
 
-	if err := global.DB.Create(&newLink).Error; err != nil {
-		c.JSON(http.StatusOK, result.Err(-1, "Failed to create guest link"))
+func (u *UserController) RevokeSession(c *gin.Context) {
+	var dto dto.SessionRevokeDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
 		return
 	}
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.Session.Revoke(claims, dto.ID))
+}
+
+func (u *UserController) Logout(c *gin.Context) {
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.Session.Logout(claims))
+}
+
+// LogoutAll 退出所有设备上的登录
+func (u *UserController) LogoutAll(c *gin.Context) {
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, result.Ok(service.Session.RevokeAll(claims.GetUserId())))
+}
+
+// RevokeAllSessions 管理员注销指定用户的全部会话
+func (u *UserController) RevokeAllSessions(c *gin.Context) {
+	var dto dto.SessionRevokeAllDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.Session.RevokeUser(claims, dto.UserId))
+}
+
+// LoginFailures 查看登录失败统计及锁定中的 IP / 账号
+func (u *UserController) LoginFailures(c *gin.Context) {
+	c.JSON(http.StatusOK, service.LoginGuard.List())
+}
+
+// UnlockLogin 解除 IP 或账号的登录锁定
+func (u *UserController) UnlockLogin(c *gin.Context) {
+	var dto dto.LoginUnlockDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, service.LoginGuard.Unlock(dto.ID))
+}
 
-	c.JSON(http.StatusOK, result.Ok(dto.GuestLinkDto{Token: newLink.Token}))
+func clientInfo(c *gin.Context) dto.ClientInfo {
+	return dto.ClientInfo{Ip: c.ClientIP(), UserAgent: c.Request.UserAgent()}
 }
@@ -3,7 +3,6 @@ package controller
 import (
 	"go-backend/result"
 	"go-backend/service"
-	"go-backend/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -30,15 +29,8 @@ func (c *ViteConfigController) GetConfigByName(ctx *gin.Context) {
 	ctx.JSON(200, service.ViteConfig.GetConfigByName(name))
 }
 
+// UpdateConfigs 需要 config:write 权限，在路由上校验
 func (c *ViteConfigController) UpdateConfigs(ctx *gin.Context) {
-	// Require Admin Role
-	claims, _ := ctx.Get("claims")
-	userClaims := claims.(*utils.UserClaims)
-	if userClaims.RoleId != 0 {
-		ctx.JSON(200, result.Err(-1, "无权操作"))
-		return
-	}
-
 	var body map[string]string
 	if err := ctx.ShouldBindJSON(&body); err != nil {
 		ctx.JSON(200, result.Err(-1, "参数错误"))
@@ -47,15 +39,8 @@ func (c *ViteConfigController) UpdateConfigs(ctx *gin.Context) {
 	ctx.JSON(200, service.ViteConfig.UpdateConfigs(body))
 }
 
+// UpdateConfig 需要 config:write 权限，在路由上校验
 func (c *ViteConfigController) UpdateConfig(ctx *gin.Context) {
-	// Require Admin Role
-	claims, _ := ctx.Get("claims")
-	userClaims := claims.(*utils.UserClaims)
-	if userClaims.RoleId != 0 {
-		ctx.JSON(200, result.Err(-1, "无权操作"))
-		return
-	}
-
 	var body map[string]interface{}
 	if err := ctx.ShouldBindJSON(&body); err != nil {
 		ctx.JSON(200, result.Err(-1, "参数错误"))
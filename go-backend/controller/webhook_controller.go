@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"net/http"
+
+	"go-backend/model/dto"
+	"go-backend/service"
+	"go-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookController struct{}
+
+func (w *WebhookController) List(c *gin.Context) {
+	c.JSON(http.StatusOK, service.Webhook.List())
+}
+
+func (w *WebhookController) Create(c *gin.Context) {
+	var dto dto.WebhookDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	claims := c.MustGet("claims").(*utils.UserClaims)
+	c.JSON(http.StatusOK, service.Webhook.Create(claims, dto))
+}
+
+func (w *WebhookController) Update(c *gin.Context) {
+	var dto dto.WebhookUpdateDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, service.Webhook.Update(dto))
+}
+
+func (w *WebhookController) Delete(c *gin.Context) {
+	var dto dto.WebhookIdDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, service.Webhook.Delete(dto.ID))
+}
+
+func (w *WebhookController) Test(c *gin.Context) {
+	var dto dto.WebhookIdDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, service.Webhook.Test(dto.ID))
+}
+
+func (w *WebhookController) Deliveries(c *gin.Context) {
+	var dto dto.WebhookDeliveryQueryDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, service.Webhook.Deliveries(dto))
+}
+
+func (w *WebhookController) Redeliver(c *gin.Context) {
+	var dto dto.WebhookIdDto
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		service.ResponseError(c, -1, "参数错误: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, service.Webhook.Redeliver(dto.ID))
+}
@@ -10,6 +10,7 @@ import (
 	"go-backend/router"
 	"go-backend/service"
 	"go-backend/utils"
+	"go-backend/websocket"
 )
 
 func main() {
@@ -19,43 +20,30 @@ func main() {
 	// 2. 初始化数据库
 	global.InitDB()
 
-	// Start Scheduled Tasks
-	service.StatisticsFlow.StartScheduledTask()
-	service.Task.StartScheduledTasks()
-
-	// Initialize Database Schema and Default Data (SQLite)
-	if config.AppConfig.Database.Type == "sqlite" {
-		fmt.Println("⚙️ Initializing SQLite Schema...")
-		err := global.DB.AutoMigrate(
-			&model.User{},
-			&model.Node{},
-			&model.Tunnel{},
-			&model.Forward{},
-			&model.SpeedLimit{},
-			&model.UserTunnel{},
-			&model.StatisticsFlow{},
-			&model.ViteConfig{},
-			&model.GuestLink{},
-		)
-		if err != nil {
-			fmt.Printf("❌ AutoMigrate failed: %v\n", err)
-		}
+	// Initialize Database Schema，所有数据库类型都需要建新表、补新列并运行数据迁移
+	fmt.Println("⚙️ Initializing Schema...")
+	if err := migration.EnsureSchema(global.DB, model.All()...); err != nil {
+		fmt.Printf("❌ Schema init failed: %v\n", err)
+	}
 
-		// 运行数据迁移
-		fmt.Println("⚙️ Running Migrations...")
-		if err := migration.RunMigrations(global.DB); err != nil {
-			fmt.Printf("❌ Migration failed: %v\n", err)
-		}
+	// 运行数据迁移
+	fmt.Println("⚙️ Running Migrations...")
+	if err := migration.RunMigrations(global.DB); err != nil {
+		fmt.Printf("❌ Migration failed: %v\n", err)
+	}
 
+	// Default Data (SQLite)，MySQL 的默认数据由 gost.sql 导入
+	if config.AppConfig.Database.Type == "sqlite" {
 		// Seed Admin User
 		var count int64
 		global.DB.Model(&model.User{}).Count(&count)
 		if count == 0 {
 			fmt.Println("🌱 Seeding Default Admin User...")
-			// Default: admin_user / admin_user (MD5: 3c85cdebade1c51cf64ca9f3c09d182d)
+			// Default: admin_user / admin_user，首次登录后要求修改
+			pwd, _ := utils.HashPassword("admin_user")
 			admin := model.User{
 				User:          "admin_user",
-				Pwd:           utils.Md5("admin_user"),
+				Pwd:           pwd,
 				RoleId:        0, // Admin (Actually field is RoleId in struct but let's check model)
 				Status:        1,
 				CreatedTime:   1748914865000,
@@ -77,6 +65,13 @@ func main() {
 		}
 	}
 
+	// Start Scheduled Tasks
+	service.StatisticsFlow.StartScheduledTask()
+	service.Task.StartScheduledTasks()
+	service.Reconcile.Start()
+	service.Webhook.Start()
+	websocket.StartCommandQueue()
+
 	// 3. 初始化路由
 	r := router.InitRouter()
 
@@ -0,0 +1,199 @@
+// Package metrics 面板自身的 Prometheus 指标，按文本格式输出，不依赖 client_golang
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// collector 可输出为 Prometheus 文本格式的指标
+type collector interface {
+	write(w io.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []collector
+)
+
+func register(c collector) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, c)
+}
+
+// WriteAll 输出所有进程内累计的指标
+func WriteAll(w io.Writer) {
+	registryMu.Lock()
+	collectors := append([]collector(nil), registry...)
+	registryMu.Unlock()
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Sample 单个采样值，Labels 按 name, value 成对排列
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+// WriteMetric 输出一个指标族，供抓取时从数据库等处临时计算的指标使用
+func WriteMetric(w io.Writer, name, help, kind string, samples []Sample) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	for _, s := range samples {
+		fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(s.Labels), formatValue(s.Value))
+	}
+}
+
+// CounterVec 带标签的计数器
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]*counterEntry
+}
+
+type counterEntry struct {
+	labels []string
+	value  float64
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labels: labels, values: make(map[string]*counterEntry)}
+	register(c)
+	return c
+}
+
+// Add 按标签值累加，标签值顺序与创建时的标签名一致
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.values[key]
+	if e == nil {
+		e = &counterEntry{labels: pairLabels(c.labels, labelValues)}
+		c.values[key] = e
+	}
+	e.value += v
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	samples := make([]Sample, 0, len(c.values))
+	for _, key := range sortedKeys(c.values) {
+		e := c.values[key]
+		samples = append(samples, Sample{Labels: e.labels, Value: e.value})
+	}
+	c.mu.Unlock()
+	WriteMetric(w, c.name, c.help, "counter", samples)
+}
+
+// HistogramVec 带标签的直方图
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramEntry
+}
+
+type histogramEntry struct {
+	labels []string
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, values: make(map[string]*histogramEntry)}
+	register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	e := h.values[key]
+	if e == nil {
+		e = &histogramEntry{labels: pairLabels(h.labels, labelValues), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = e
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			e.counts[i]++
+		}
+	}
+	e.sum += v
+	e.count++
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, key := range sortedKeys(h.values) {
+		e := h.values[key]
+		for i, upper := range h.buckets {
+			labels := append(append([]string(nil), e.labels...), "le", formatValue(upper))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(labels), e.counts[i])
+		}
+		labels := append(append([]string(nil), e.labels...), "le", "+Inf")
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(labels), e.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(e.labels), formatValue(e.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(e.labels), e.count)
+	}
+}
+
+func pairLabels(names, values []string) []string {
+	labels := make([]string, 0, len(names)*2)
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		labels = append(labels, name, value)
+	}
+	return labels
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, labels[i], labelEscaper.Replace(labels[i+1])))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
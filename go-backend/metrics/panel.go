@@ -0,0 +1,21 @@
+package metrics
+
+// 面板运行期间累计的指标，节点在线状态、用户 / 隧道流量等在抓取时从数据库读取
+var (
+	// WsCommands 下发到节点的命令，result 为 ok / error / timeout / offline
+	WsCommands = NewCounterVec("flux_ws_commands_total", "Commands sent to nodes over websocket.", "type", "result")
+
+	// WsCommandDuration 命令从下发到收到节点响应的耗时
+	WsCommandDuration = NewHistogramVec("flux_ws_command_duration_seconds", "Latency of websocket commands sent to nodes.",
+		[]float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}, "type")
+
+	// FlowReports 节点上报的流量记录数
+	FlowReports = NewCounterVec("flux_flow_reports_total", "Flow reports received from nodes.")
+
+	// FlowBytes 节点上报的计费流量（已按隧道倍率换算），direction 为 in / out
+	FlowBytes = NewCounterVec("flux_flow_bytes_total", "Billed bytes reported by nodes.", "direction")
+
+	// QuotaPauses 因流量超限、到期或禁用被自动暂停的转发数
+	// reason 为 user_flow / user_expired / user_disabled / tunnel_flow / tunnel_expired / tunnel_disabled
+	QuotaPauses = NewCounterVec("flux_quota_pauses_total", "Forwards paused automatically by quota or expiry checks.", "reason")
+)
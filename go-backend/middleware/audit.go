@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"go-backend/audit"
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditRoute 需要审计的接口：target 为操作对象类型，idKey 为请求中对象 ID 的字段（默认 id）；
+// typeTargets 按请求中的 type 字段区分对象类型
+type auditRoute struct {
+	action      string
+	target      string
+	idKey       string
+	typeTargets map[int]string
+}
+
+// auditRoutes 所有修改数据的接口，对应 Java 版 @LogAnnotation
+var auditRoutes = map[string]auditRoute{
+	"/api/v1/user/login":                 {action: "user.login", target: "user"},
+	"/api/v1/user/login/totp":            {action: "user.login_totp", target: "user"},
+	"/api/v1/user/create":                {action: "user.create", target: "user"},
+	"/api/v1/user/update":                {action: "user.update", target: "user"},
+	"/api/v1/user/updatePassword":        {action: "user.update_password", target: "user"},
+	"/api/v1/user/delete":                {action: "user.delete", target: "user"},
+	"/api/v1/user/reset":                 {action: "user.reset_flow", target: "user", typeTargets: map[int]string{2: "user_tunnel"}},
+	"/api/v1/user/totp/enable":           {action: "user.totp_enable", target: "user"},
+	"/api/v1/user/totp/disable":          {action: "user.totp_disable", target: "user"},
+	"/api/v1/user/totp/reset":            {action: "user.totp_reset", target: "user"},
+	"/api/v1/user/sessions/revoke":       {action: "session.revoke", target: "session"},
+	"/api/v1/user/sessions/revoke_all":   {action: "session.revoke_all", target: "user", idKey: "userId"},
+	"/api/v1/user/logout_all":            {action: "session.logout_all", target: "user"},
+	"/api/v1/user/login_failures/unlock": {action: "user.login_unlock", target: "login_throttle"},
+	"/api/v1/user/notify/update":         {action: "user.notify_update", target: "user"},
+	"/api/v1/user/guest_link/create":     {action: "guest_link.create", target: "user", idKey: "userId"},
+	"/api/v1/user/guest_link/update":     {action: "guest_link.update", target: "guest_link"},
+	"/api/v1/user/guest_link/revoke":     {action: "guest_link.revoke", target: "guest_link"},
+	"/api/v1/node/create":                {action: "node.create", target: "node"},
+	"/api/v1/node/update":                {action: "node.update", target: "node"},
+	"/api/v1/node/delete":                {action: "node.delete", target: "node"},
+	"/api/v1/node/commands/purge":        {action: "node.commands_purge", target: "node", idKey: "nodeId"},
+	"/api/v1/tunnel/create":              {action: "tunnel.create", target: "tunnel"},
+	"/api/v1/tunnel/update":              {action: "tunnel.update", target: "tunnel"},
+	"/api/v1/tunnel/delete":              {action: "tunnel.delete", target: "tunnel"},
+	"/api/v1/tunnel/user/assign":         {action: "user_tunnel.assign", target: "user", idKey: "userId"},
+	"/api/v1/tunnel/user/remove":         {action: "user_tunnel.remove", target: "user_tunnel"},
+	"/api/v1/tunnel/user/update":         {action: "user_tunnel.update", target: "user_tunnel"},
+	"/api/v1/forward/create":             {action: "forward.create", target: "forward"},
+	"/api/v1/forward/update":             {action: "forward.update", target: "forward"},
+	"/api/v1/forward/delete":             {action: "forward.delete", target: "forward"},
+	"/api/v1/forward/pause":              {action: "forward.pause", target: "forward"},
+	"/api/v1/forward/resume":             {action: "forward.resume", target: "forward"},
+	"/api/v1/forward/force-delete":       {action: "forward.force_delete", target: "forward"},
+	"/api/v1/forward/update-order":       {action: "forward.update_order", target: "forward"},
+	"/api/v1/role/create":                {action: "role.create", target: "role"},
+	"/api/v1/role/update":                {action: "role.update", target: "role"},
+	"/api/v1/role/delete":                {action: "role.delete", target: "role"},
+	"/api/v1/api_key/create":             {action: "api_key.create", target: "api_key"},
+	"/api/v1/api_key/delete":             {action: "api_key.delete", target: "api_key"},
+	"/api/v1/speed-limit/create":         {action: "speed_limit.create", target: "speed_limit"},
+	"/api/v1/speed-limit/update":         {action: "speed_limit.update", target: "speed_limit"},
+	"/api/v1/speed-limit/delete":         {action: "speed_limit.delete", target: "speed_limit"},
+	"/api/v1/conn-limit/create":          {action: "conn_limit.create", target: "conn_limit"},
+	"/api/v1/conn-limit/update":          {action: "conn_limit.update", target: "conn_limit"},
+	"/api/v1/conn-limit/delete":          {action: "conn_limit.delete", target: "conn_limit"},
+	"/api/v1/plan/create":                {action: "plan.create", target: "plan"},
+	"/api/v1/plan/update":                {action: "plan.update", target: "plan"},
+	"/api/v1/plan/delete":                {action: "plan.delete", target: "plan"},
+	"/api/v1/plan/assign":                {action: "plan.assign", target: "user", idKey: "userId"},
+	"/api/v1/plan/renew":                 {action: "plan.renew", target: "user", idKey: "userId"},
+	"/api/v1/webhook/create":             {action: "webhook.create", target: "webhook"},
+	"/api/v1/webhook/update":             {action: "webhook.update", target: "webhook"},
+	"/api/v1/webhook/delete":             {action: "webhook.delete", target: "webhook"},
+	"/api/v1/webhook/redeliver":          {action: "webhook.redeliver", target: "webhook_delivery"},
+	"/api/v1/config/update":              {action: "config.update", target: "config"},
+	"/api/v1/config/update-single":       {action: "config.update", target: "config", idKey: "name"},
+}
+
+// auditModels 用于加载修改前后快照的对象类型
+var auditModels = map[string]func() interface{}{
+	"user":           func() interface{} { return &model.User{} },
+	"node":           func() interface{} { return &model.Node{} },
+	"tunnel":         func() interface{} { return &model.Tunnel{} },
+	"forward":        func() interface{} { return &model.Forward{} },
+	"user_tunnel":    func() interface{} { return &model.UserTunnel{} },
+	"role":           func() interface{} { return &model.Role{} },
+	"api_key":        func() interface{} { return &model.ApiKey{} },
+	"speed_limit":    func() interface{} { return &model.SpeedLimit{} },
+	"conn_limit":     func() interface{} { return &model.ConnLimit{} },
+	"plan":           func() interface{} { return &model.Plan{} },
+	"guest_link":     func() interface{} { return &model.GuestLink{} },
+	"login_throttle": func() interface{} { return &model.LoginThrottle{} },
+	"webhook":        func() interface{} { return &model.Webhook{} },
+}
+
+// auditWriter 保留响应内容，用于记录操作结果
+type auditWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// Audit 记录修改类接口的操作者、对象、前后差异、来源 IP 和结果，需放在 Auth 之后
+func Audit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, ok := auditRoutes[c.FullPath()]
+		if !ok || c.Request.Method != "POST" {
+			c.Next()
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			body, _ = io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		var params map[string]interface{}
+		json.Unmarshal(body, &params)
+
+		entry := &model.AuditLog{
+			ActorType:  model.AuditActorAnonymous,
+			Action:     route.action,
+			TargetType: route.target,
+			Request:    audit.Sanitize(body),
+			Ip:         c.ClientIP(),
+		}
+		if t, ok := params["type"].(float64); ok && route.typeTargets[int(t)] != "" {
+			entry.TargetType = route.typeTargets[int(t)]
+		}
+		idKey := route.idKey
+		if idKey == "" {
+			idKey = "id"
+		}
+		if v, ok := params[idKey]; ok && v != nil {
+			entry.TargetId = fmt.Sprint(v)
+		}
+		if claims, ok := c.Get("claims"); ok {
+			uc := claims.(*utils.UserClaims)
+			entry.ActorType = model.AuditActorUser
+			entry.ActorId = uc.GetUserId()
+			entry.ActorName = uc.User
+			if uc.ApiKeyId != 0 {
+				entry.ActorType = model.AuditActorApiKey
+				entry.ApiKeyId = uc.ApiKeyId
+			}
+			// 修改自身的接口不带 ID，对象即操作者本人
+			if entry.TargetId == "" && entry.TargetType == "user" && route.idKey == "" {
+				entry.TargetId = fmt.Sprint(entry.ActorId)
+			}
+		} else if name, ok := params["username"].(string); ok {
+			entry.ActorName = name
+		}
+
+		before := loadSnapshot(entry.TargetType, entry.TargetId)
+		writer := &auditWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		if before != nil {
+			entry.Changes = audit.Diff(before, loadSnapshot(entry.TargetType, entry.TargetId))
+		}
+
+		var resp struct {
+			Code int    `json:"code"`
+			Msg  string `json:"msg"`
+		}
+		if json.Unmarshal(writer.body.Bytes(), &resp) == nil {
+			entry.Result = resp.Code
+			entry.Message = resp.Msg
+		} else {
+			entry.Result = -1
+			entry.Message = fmt.Sprintf("HTTP %d", writer.Status())
+		}
+		audit.Record(entry)
+	}
+}
+
+// loadSnapshot 读取对象当前状态，不存在时返回 nil
+func loadSnapshot(target, id string) map[string]interface{} {
+	newModel, ok := auditModels[target]
+	if !ok || id == "" {
+		return nil
+	}
+	obj := newModel()
+	if err := global.DB.Where("id = ?", id).First(obj).Error; err != nil {
+		return nil
+	}
+	return audit.Snapshot(obj)
+}
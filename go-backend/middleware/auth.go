@@ -2,10 +2,14 @@ package middleware
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
 
 	"go-backend/config"
+	"go-backend/model"
+	"go-backend/rbac"
 	"go-backend/result"
+	"go-backend/session"
 	"go-backend/utils"
 
 	"github.com/gin-gonic/gin"
@@ -29,6 +33,12 @@ func Auth() gin.HandlerFunc {
 			// Fallback: Treat entire header as token (Java parity)
 			tokenString = authHeader
 		}
+
+		if strings.HasPrefix(tokenString, session.ApiKeyPrefix) {
+			apiKeyAuth(c, tokenString)
+			return
+		}
+
 		claims := &utils.UserClaims{}
 
 		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
@@ -41,11 +51,90 @@ func Auth() gin.HandlerFunc {
 			return
 		}
 
+		// 会话已撤销、或 token 签发于修改密码之前时拒绝
+		if claims.IssuedAt == nil || session.Validate(claims.ID, claims.GetUserId(), claims.IssuedAt.Time, c.ClientIP()) != nil {
+			c.JSON(http.StatusOK, result.Err(-1, "Token无效或已过期"))
+			c.Abort()
+			return
+		}
+
+		// 尚未绑定两步验证的 token 只能访问绑定接口
+		if claims.TotpSetup && !totpSetupPaths[c.FullPath()] {
+			c.JSON(http.StatusOK, result.Err(-1, "请先启用两步验证"))
+			c.Abort()
+			return
+		}
+
 		c.Set("claims", claims)
 		c.Next()
 	}
 }
 
+var totpSetupPaths = map[string]bool{
+	"/api/v1/user/totp/setup":  true,
+	"/api/v1/user/totp/enable": true,
+}
+
+// apiKeyAuth 使用 API Key 访问，只能调用 apiKeyScopes 中列出且在密钥权限范围内的接口
+func apiKeyAuth(c *gin.Context, raw string) {
+	key, user, err := session.ValidateApiKey(raw, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusOK, result.Err(-1, err.Error()))
+		c.Abort()
+		return
+	}
+
+	scope, ok := apiKeyScopes[c.FullPath()]
+	if !ok {
+		c.JSON(http.StatusOK, result.Err(-1, "API Key 不能访问该接口"))
+		c.Abort()
+		return
+	}
+	claims := &utils.UserClaims{
+		RoleId:           user.RoleId,
+		User:             user.User,
+		Name:             user.User,
+		ApiKeyId:         key.ID,
+		Scopes:           key.ScopeList(),
+		RegisteredClaims: jwt.RegisteredClaims{Subject: strconv.FormatInt(user.ID, 10)},
+	}
+	if !claims.HasScope(scope) {
+		c.JSON(http.StatusOK, result.Err(-1, "API Key 缺少权限: "+scope))
+		c.Abort()
+		return
+	}
+
+	c.Set("claims", claims)
+	c.Next()
+}
+
+// apiKeyScopes API Key 可访问的接口及所需权限；会话、密钥管理等接口不在其中
+var apiKeyScopes = map[string]string{
+	"/api/v1/user/package":       model.ScopeUsageRead,
+	"/api/v1/user/list":          model.ScopeUsageRead,
+	"/api/v1/tunnel/list":        model.ScopeUsageRead,
+	"/api/v1/tunnel/user/tunnel": model.ScopeUsageRead,
+	"/api/v1/tunnel/user/list":   model.ScopeUsageRead,
+	"/api/v1/forward/list":       model.ScopeUsageRead,
+	"/api/v1/traffic/series":     model.ScopeUsageRead,
+
+	"/api/v1/forward/create":       model.ScopeForwardWrite,
+	"/api/v1/forward/update":       model.ScopeForwardWrite,
+	"/api/v1/forward/delete":       model.ScopeForwardWrite,
+	"/api/v1/forward/pause":        model.ScopeForwardWrite,
+	"/api/v1/forward/resume":       model.ScopeForwardWrite,
+	"/api/v1/forward/force-delete": model.ScopeForwardWrite,
+	"/api/v1/forward/update-order": model.ScopeForwardWrite,
+
+	"/api/v1/user/create":        model.ScopeUserWrite,
+	"/api/v1/user/update":        model.ScopeUserWrite,
+	"/api/v1/user/delete":        model.ScopeUserWrite,
+	"/api/v1/user/reset":         model.ScopeUserWrite,
+	"/api/v1/tunnel/user/assign": model.ScopeUserWrite,
+	"/api/v1/tunnel/user/remove": model.ScopeUserWrite,
+	"/api/v1/tunnel/user/update": model.ScopeUserWrite,
+}
+
 func RequireRole(roleId int) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		claims, exists := c.Get("claims")
@@ -63,3 +152,24 @@ func RequireRole(roleId int) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// RequirePermission 要求当前角色拥有任一指定权限点
+func RequirePermission(perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, exists := c.Get("claims")
+		if !exists {
+			c.JSON(http.StatusOK, result.Err(-1, "未授权"))
+			c.Abort()
+			return
+		}
+		roleId := claims.(*utils.UserClaims).RoleId
+		for _, perm := range perms {
+			if rbac.Has(roleId, perm) {
+				c.Next()
+				return
+			}
+		}
+		c.JSON(http.StatusOK, result.Err(-1, "权限不足"))
+		c.Abort()
+	}
+}
@@ -25,6 +25,8 @@ var migrations = []struct {
 }{
 	{"001_tunnel_out_port", migrate001TunnelOutPort},
 	{"002_node_port_ranges", migrate002NodePortRanges},
+	{"003_builtin_roles", migrate003BuiltinRoles},
+	{"004_reseller_subuser", migrate004ResellerSubUser},
 }
 
 // RunMigrations 在程序启动时执行所有待处理的迁移
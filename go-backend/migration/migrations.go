@@ -3,6 +3,8 @@ package migration
 import (
 	"fmt"
 
+	"go-backend/model"
+
 	"gorm.io/gorm"
 )
 
@@ -13,7 +15,6 @@ func migrate001TunnelOutPort(db *gorm.DB) error {
 		return nil
 	}
 
-	// SQLite 语法
 	return db.Exec("ALTER TABLE tunnel ADD COLUMN out_port INTEGER DEFAULT 0").Error
 }
 
@@ -26,6 +27,11 @@ func migrate002NodePortRanges(db *gorm.DB) error {
 		}
 	}
 
+	// 新安装的数据库没有旧列，无需迁移数据
+	if !columnExists(db, "node", "port_sta") {
+		return nil
+	}
+
 	// 2. 将旧数据迁移到新格式
 	// 使用 CASE 处理 port_sta == port_end 的情况
 	migrateSql := `
@@ -36,6 +42,17 @@ func migrate002NodePortRanges(db *gorm.DB) error {
 		END
 		WHERE port_ranges = '' OR port_ranges IS NULL
 	`
+	if db.Dialector.Name() == "mysql" {
+		// MySQL 中 || 是逻辑或，且不能 CAST 为 TEXT
+		migrateSql = `
+			UPDATE node
+			SET port_ranges = CASE
+				WHEN port_sta = port_end THEN CAST(port_sta AS CHAR)
+				ELSE CONCAT(port_sta, '-', port_end)
+			END
+			WHERE port_ranges = '' OR port_ranges IS NULL
+		`
+	}
 	if err := db.Exec(migrateSql).Error; err != nil {
 		return fmt.Errorf("failed to migrate port data: %w", err)
 	}
@@ -43,9 +60,32 @@ func migrate002NodePortRanges(db *gorm.DB) error {
 	return nil
 }
 
-// columnExists 检查列是否存在 (SQLite)
+// migrate003BuiltinRoles 写入内置角色，已存在的不覆盖
+func migrate003BuiltinRoles(db *gorm.DB) error {
+	now := nowMilli()
+	for _, role := range model.BuiltinRoles {
+		var count int64
+		db.Model(&model.Role{}).Where("id = ?", role.ID).Count(&count)
+		if count > 0 {
+			continue
+		}
+		role.CreatedTime = now
+		role.UpdatedTime = now
+		if err := db.Create(&role).Error; err != nil {
+			return fmt.Errorf("failed to create role %s: %w", role.Code, err)
+		}
+	}
+	return nil
+}
+
+// migrate004ResellerSubUser 分销商改为只管理自己的下级用户；管理员改过的权限不覆盖
+func migrate004ResellerSubUser(db *gorm.DB) error {
+	return db.Model(&model.Role{}).
+		Where("id = ? AND permissions = ?", model.RoleReseller, "user:read,user:write,tunnel:read").
+		Updates(map[string]interface{}{"permissions": model.PermSubUserWrite, "updated_time": nowMilli()}).Error
+}
+
+// columnExists 检查列是否存在
 func columnExists(db *gorm.DB, tableName, columnName string) bool {
-	var count int64
-	db.Raw("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", tableName, columnName).Scan(&count)
-	return count > 0
+	return db.Migrator().HasColumn(tableName, columnName)
 }
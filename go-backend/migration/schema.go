@@ -0,0 +1,52 @@
+package migration
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// EnsureSchema 创建缺少的表，并为已有的表补齐缺少的列和索引，所有数据库类型都在启动时执行
+// SQLite 的表由 AutoMigrate 创建，直接 AutoMigrate；MySQL 的基础表来自 gost.sql，列类型与模型不一致，
+// AutoMigrate 会把 varchar 改成 longtext（带唯一索引的列会失败），所以只新增不修改
+func EnsureSchema(db *gorm.DB, models ...interface{}) error {
+	if db.Dialector.Name() == "sqlite" {
+		return db.AutoMigrate(models...)
+	}
+	return addMissing(db, models...)
+}
+
+// addMissing 只新增缺少的表、列和索引，已有的列保持不变
+func addMissing(db *gorm.DB, models ...interface{}) error {
+	m := db.Migrator()
+	for _, model := range models {
+		if !m.HasTable(model) {
+			if err := m.CreateTable(model); err != nil {
+				return err
+			}
+			continue
+		}
+
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		for _, name := range stmt.Schema.DBNames {
+			if m.HasColumn(model, name) {
+				continue
+			}
+			if err := m.AddColumn(model, name); err != nil {
+				return fmt.Errorf("add column %s.%s: %w", stmt.Schema.Table, name, err)
+			}
+		}
+		for _, idx := range stmt.Schema.ParseIndexes() {
+			if m.HasIndex(model, idx.Name) {
+				continue
+			}
+			if err := m.CreateIndex(model, idx.Name); err != nil {
+				return fmt.Errorf("create index %s.%s: %w", stmt.Schema.Table, idx.Name, err)
+			}
+		}
+	}
+	return nil
+}
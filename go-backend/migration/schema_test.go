@@ -0,0 +1,55 @@
+package migration
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+type schemaNode struct {
+	ID         int64  `gorm:"primaryKey"`
+	Name       string `gorm:"size:100"`
+	PortRanges string
+	GroupId    int64 `gorm:"index"`
+}
+
+func (schemaNode) TableName() string { return "node" }
+
+type schemaSession struct {
+	ID  int64  `gorm:"primaryKey"`
+	Sid string `gorm:"uniqueIndex;size:64"`
+}
+
+func (schemaSession) TableName() string { return "user_session" }
+
+// TestAddMissing verifies the MySQL path creates new tables and adds missing
+// columns and indexes without touching the columns gost.sql created.
+func TestAddMissing(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "schema.db")), &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{SingularTable: true},
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Exec("CREATE TABLE node (id INTEGER PRIMARY KEY, name varchar(100) NOT NULL, port_sta int NOT NULL DEFAULT 0)").Error)
+	require.NoError(t, db.Exec("INSERT INTO node (id, name) VALUES (1, 'old')").Error)
+
+	require.NoError(t, addMissing(db, &schemaNode{}, &schemaSession{}))
+	// 再次执行不报错
+	require.NoError(t, addMissing(db, &schemaNode{}, &schemaSession{}))
+
+	m := db.Migrator()
+	assert.True(t, m.HasTable(&schemaSession{}))
+	assert.True(t, m.HasIndex(&schemaSession{}, "idx_user_session_sid"))
+	assert.True(t, m.HasColumn(&schemaNode{}, "port_ranges"))
+	assert.True(t, m.HasColumn(&schemaNode{}, "group_id"))
+	assert.True(t, m.HasIndex(&schemaNode{}, "idx_node_group_id"))
+	assert.True(t, m.HasColumn(&schemaNode{}, "port_sta"), "existing columns are kept")
+
+	var name string
+	db.Raw("SELECT name FROM node WHERE id = 1").Scan(&name)
+	assert.Equal(t, "old", name)
+}
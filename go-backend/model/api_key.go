@@ -0,0 +1,39 @@
+package model
+
+import "strings"
+
+// API Key 权限范围
+const (
+	ScopeUsageRead    = "usage:read"     // 查询套餐、流量、转发和隧道
+	ScopeForwardWrite = "forwards:write" // 创建、修改、暂停、删除转发
+	ScopeUserWrite    = "users:write"    // 创建、修改、删除用户，分配隧道，重置流量（仅管理员）
+)
+
+var ApiKeyScopes = []string{ScopeUsageRead, ScopeForwardWrite, ScopeUserWrite}
+
+// ApiKey 供自动化和计费系统使用的访问密钥，只保存 SHA-256 哈希
+// 完整密钥格式为 fpk_<Prefix>_<secret>，Prefix 用于查找
+type ApiKey struct {
+	ID           int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserId       int64  `gorm:"index" json:"userId"`
+	Name         string `json:"name"`
+	Prefix       string `gorm:"uniqueIndex;size:16" json:"prefix"`
+	KeyHash      string `json:"-"`
+	Scopes       string `json:"scopes"`  // 逗号分隔
+	ExpTime      int64  `json:"expTime"` // 0 表示永不过期
+	LastUsedTime int64  `json:"lastUsedTime"`
+	LastUsedIp   string `json:"lastUsedIp"`
+	CreatedBy    int64  `json:"createdBy"`
+	CreatedTime  int64  `json:"createdTime"`
+}
+
+func (ApiKey) TableName() string {
+	return "api_key"
+}
+
+func (k *ApiKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return nil
+	}
+	return strings.Split(k.Scopes, ",")
+}
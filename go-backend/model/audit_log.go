@@ -0,0 +1,31 @@
+package model
+
+// 审计日志操作者类型
+const (
+	AuditActorUser      = "user"
+	AuditActorApiKey    = "api_key"
+	AuditActorSystem    = "system"
+	AuditActorAnonymous = "anonymous" // 登录等未认证请求
+)
+
+// AuditLog 审计日志，记录谁在何时对什么对象做了什么操作及结果
+type AuditLog struct {
+	ID          int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	CreatedTime int64  `gorm:"index" json:"createdTime"`
+	ActorType   string `gorm:"size:16" json:"actorType"`
+	ActorId     int64  `gorm:"index" json:"actorId"`
+	ActorName   string `json:"actorName"`
+	ApiKeyId    int64  `json:"apiKeyId"`
+	Action      string `gorm:"index;size:64" json:"action"` // 如 forward.update、user.quota_pause
+	TargetType  string `gorm:"index:idx_audit_target;size:32" json:"targetType"`
+	TargetId    string `gorm:"index:idx_audit_target;size:64" json:"targetId"`
+	Changes     string `gorm:"type:text" json:"changes"` // 变更字段 {"field":{"before":x,"after":y}}
+	Request     string `gorm:"type:text" json:"request"` // 请求参数，敏感字段已脱敏
+	Ip          string `json:"ip"`
+	Result      int    `json:"result"` // 0 成功，其他为失败码
+	Message     string `json:"message"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
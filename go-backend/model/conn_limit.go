@@ -0,0 +1,18 @@
+package model
+
+// ConnLimit 连接数限制规则，可分配给用户隧道或单个转发，0 表示不限制
+type ConnLimit struct {
+	ID          int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string `gorm:"size:100" json:"name"`
+	Conns       int    `gorm:"comment:服务总并发连接数" json:"conns"`
+	IpConns     int    `gorm:"comment:单IP并发连接数" json:"ipConns"`
+	Rate        int    `gorm:"comment:服务每秒新建连接数" json:"rate"`
+	IpRate      int    `gorm:"comment:单IP每秒新建连接数" json:"ipRate"`
+	Status      int    `json:"status"`
+	CreatedTime int64  `json:"createdTime"`
+	UpdatedTime int64  `json:"updatedTime"`
+}
+
+func (ConnLimit) TableName() string {
+	return "conn_limit"
+}
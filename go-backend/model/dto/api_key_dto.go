@@ -0,0 +1,26 @@
+package dto
+
+// ApiKeyCreateDto 管理员可通过 userId 为其他用户签发，为 0 时签发给自己
+type ApiKeyCreateDto struct {
+	Name    string   `json:"name" binding:"required"`
+	Scopes  []string `json:"scopes" binding:"required"`
+	ExpTime int64    `json:"expTime"` // 毫秒时间戳，0 表示永不过期
+	UserId  int64    `json:"userId"`
+}
+
+type ApiKeyListDto struct {
+	UserId int64 `json:"userId"`
+}
+
+type ApiKeyDeleteDto struct {
+	ID int64 `json:"id" binding:"required"`
+}
+
+// ApiKeyCreatedDto 完整密钥只在创建时返回一次
+type ApiKeyCreatedDto struct {
+	ID      int64    `json:"id"`
+	Name    string   `json:"name"`
+	Key     string   `json:"key"`
+	Scopes  []string `json:"scopes"`
+	ExpTime int64    `json:"expTime"`
+}
@@ -0,0 +1,17 @@
+package dto
+
+// AuditQueryDto 审计日志查询条件，时间为毫秒时间戳
+type AuditQueryDto struct {
+	ActorId    *int64 `json:"actorId"`
+	ActorName  string `json:"actorName"`
+	ActorType  string `json:"actorType"`
+	Action     string `json:"action"` // 前缀匹配，如 forward. 匹配所有转发操作
+	TargetType string `json:"targetType"`
+	TargetId   string `json:"targetId"`
+	Ip         string `json:"ip"`
+	Result     *int   `json:"result"` // 0 仅成功，其他值仅失败
+	StartTime  int64  `json:"startTime"`
+	EndTime    int64  `json:"endTime"`
+	Page       int    `json:"page"`
+	Size       int    `json:"size"`
+}
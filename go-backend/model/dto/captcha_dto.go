@@ -1,6 +1,28 @@
 package dto
 
+import "go-backend/captcha"
+
 type CaptchaVerifyDto struct {
-	ID   string      `json:"id"`
-	Data interface{} `json:"data"` // Generic for now, frontend sends ImageCaptchaTrack
+	ID   string        `json:"id"`
+	Data captcha.Track `json:"data"`
+}
+
+// CaptchaGenerateDto 返回给滑块组件的验证码，结构与 tianai-captcha 一致
+type CaptchaGenerateDto struct {
+	ID      string          `json:"id"`
+	Captcha CaptchaImageDto `json:"captcha"`
+}
+
+type CaptchaImageDto struct {
+	Type                  string `json:"type"`
+	BackgroundImage       string `json:"backgroundImage"`
+	TemplateImage         string `json:"templateImage"`
+	BackgroundImageWidth  int    `json:"backgroundImageWidth"`
+	BackgroundImageHeight int    `json:"backgroundImageHeight"`
+	TemplateImageWidth    int    `json:"templateImageWidth"`
+	TemplateImageHeight   int    `json:"templateImageHeight"`
+}
+
+type CaptchaTokenDto struct {
+	ValidToken string `json:"validToken"`
 }
@@ -0,0 +1,20 @@
+package dto
+
+// ConnLimitDto 连接数限制规则创建 DTO
+type ConnLimitDto struct {
+	Name    string `json:"name" binding:"required"`
+	Conns   int    `json:"conns" binding:"min=0"`
+	IpConns int    `json:"ipConns" binding:"min=0"`
+	Rate    int    `json:"rate" binding:"min=0"`
+	IpRate  int    `json:"ipRate" binding:"min=0"`
+}
+
+// ConnLimitUpdateDto 连接数限制规则更新 DTO
+type ConnLimitUpdateDto struct {
+	ID      int64  `json:"id" binding:"required"`
+	Name    string `json:"name" binding:"required"`
+	Conns   int    `json:"conns" binding:"min=0"`
+	IpConns int    `json:"ipConns" binding:"min=0"`
+	Rate    int    `json:"rate" binding:"min=0"`
+	IpRate  int    `json:"ipRate" binding:"min=0"`
+}
@@ -12,13 +12,65 @@ type FlowDto struct {
 
 // GostConfigDto Gost 配置数据结构
 type GostConfigDto struct {
-	Services []GostService `json:"services"`
+	Services   []GostService   `json:"services"`
+	Chains     []GostChain     `json:"chains"`
+	Limiters   []GostLimiter   `json:"limiters"`
+	CLimiters  []GostLimiter   `json:"climiters"`
+	RLimiters  []GostLimiter   `json:"rlimiters"`
+	Admissions []GostAdmission `json:"admissions"`
 }
 
 // GostService Gost 服务配置
 type GostService struct {
+	Name       string                 `json:"name"`
+	Addr       string                 `json:"addr"`
+	Limiter    string                 `json:"limiter"`
+	CLimiter   string                 `json:"climiter"`
+	RLimiter   string                 `json:"rlimiter"`
+	Admissions []string               `json:"admissions"`
+	Handler    map[string]interface{} `json:"handler"`
+	Listener   map[string]interface{} `json:"listener"`
+	Forwarder  *GostForwarder         `json:"forwarder"`
+	Metadata   map[string]interface{} `json:"metadata"`
+}
+
+// GostForwarder Gost 转发目标配置
+type GostForwarder struct {
+	Nodes    []GostNode             `json:"nodes"`
+	Selector map[string]interface{} `json:"selector"`
+}
+
+// GostNode Gost 节点配置 (forwarder / hop 共用)
+type GostNode struct {
+	Name      string                 `json:"name"`
+	Addr      string                 `json:"addr"`
+	Interface string                 `json:"interface"`
+	Connector map[string]interface{} `json:"connector"`
+	Dialer    map[string]interface{} `json:"dialer"`
+}
+
+// GostChain Gost 转发链配置
+type GostChain struct {
+	Name string    `json:"name"`
+	Hops []GostHop `json:"hops"`
+}
+
+// GostHop Gost 转发链中的一跳
+type GostHop struct {
 	Name     string                 `json:"name"`
-	Addr     string                 `json:"addr"`
-	Handler  map[string]interface{} `json:"handler"`
-	Listener map[string]interface{} `json:"listener"`
+	Nodes    []GostNode             `json:"nodes"`
+	Selector map[string]interface{} `json:"selector"`
+}
+
+// GostLimiter Gost 限流器配置
+type GostLimiter struct {
+	Name   string   `json:"name"`
+	Limits []string `json:"limits"`
+}
+
+// GostAdmission Gost 准入控制配置
+type GostAdmission struct {
+	Name      string   `json:"name"`
+	Whitelist bool     `json:"whitelist"`
+	Matchers  []string `json:"matchers"`
 }
@@ -1,10 +1,12 @@
 package dto
 
 type GuestDashboardDto struct {
+	Link              GuestLinkInfoDto       `json:"link"`
 	UserInfo          GuestUserInfoDto       `json:"userInfo"`
 	TunnelPermissions []UserTunnelDetailDto  `json:"tunnelPermissions"`
 	Forwards          []UserForwardDetailDto `json:"forwards"`
 	StatisticsFlows   []StatisticsFlowDto    `json:"statisticsFlows"`
+	FlowHistory       *TrafficSeriesDto      `json:"flowHistory"`
 }
 
 type GuestUserInfoDto struct {
@@ -17,6 +19,44 @@ type GuestUserInfoDto struct {
 	ExpTime       int64 `json:"expTime"`
 }
 
+// GuestLinkInfoDto 访客链接自身的信息，前端据此隐藏对应内容
+type GuestLinkInfoDto struct {
+	Name     string `json:"name"`
+	ExpTime  int64  `json:"expTime"`
+	Scoped   bool   `json:"scoped"`
+	HideFlow bool   `json:"hideFlow"`
+	HideIp   bool   `json:"hideIp"`
+}
+
 type GuestLinkDto struct {
 	Token string `json:"token"`
 }
+
+// GuestLinkCreateDto 创建访客链接；userId 为 0 时为自己创建，ForwardIds / TunnelIds 均为空表示全部转发
+type GuestLinkCreateDto struct {
+	UserId     int64   `json:"userId"`
+	Name       string  `json:"name" binding:"required"`
+	ExpTime    int64   `json:"expTime"`
+	ForwardIds []int64 `json:"forwardIds"`
+	TunnelIds  []int64 `json:"tunnelIds"`
+	HideFlow   bool    `json:"hideFlow"`
+	HideIp     bool    `json:"hideIp"`
+}
+
+type GuestLinkUpdateDto struct {
+	ID         int64   `json:"id" binding:"required"`
+	Name       string  `json:"name" binding:"required"`
+	ExpTime    int64   `json:"expTime"`
+	ForwardIds []int64 `json:"forwardIds"`
+	TunnelIds  []int64 `json:"tunnelIds"`
+	HideFlow   bool    `json:"hideFlow"`
+	HideIp     bool    `json:"hideIp"`
+}
+
+type GuestLinkListDto struct {
+	UserId int64 `json:"userId"`
+}
+
+type GuestLinkRevokeDto struct {
+	ID int64 `json:"id" binding:"required"`
+}
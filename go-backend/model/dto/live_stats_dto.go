@@ -0,0 +1,19 @@
+package dto
+
+// ForwardLiveStatsDto 转发在某个节点上的实时连接与速率，同一转发的 tcp / udp 服务合并统计
+type ForwardLiveStatsDto struct {
+	ForwardId    int64  `json:"forwardId"`
+	NodeId       int64  `json:"nodeId"`
+	CurrentConns uint64 `json:"currentConns"`
+	TotalConns   uint64 `json:"totalConns"`
+	TotalErrs    uint64 `json:"totalErrs"`
+	InputRate    uint64 `json:"inputRate"`  // 字节/秒
+	OutputRate   uint64 `json:"outputRate"` // 字节/秒
+	Time         int64  `json:"time"`
+}
+
+// LiveStatsSubscribeDto 前端订阅 / 取消订阅转发实时数据，ForwardIds 为空时订阅有权限查看的全部转发
+type LiveStatsSubscribeDto struct {
+	Type       string  `json:"type"` // subscribe_stats / unsubscribe_stats
+	ForwardIds []int64 `json:"forwardIds"`
+}
@@ -5,3 +5,67 @@ type LoginDto struct {
 	Password  string `json:"password" binding:"required"`
 	CaptchaId string `json:"captchaId"`
 }
+
+// TotpLoginDto 登录第二步：预认证 token 加验证码或恢复码
+type TotpLoginDto struct {
+	PreAuthToken string `json:"preAuthToken" binding:"required"`
+	Code         string `json:"code" binding:"required"`
+}
+
+// TotpSetupDto 绑定两步验证时返回，恢复码只展示这一次
+type TotpSetupDto struct {
+	Secret        string   `json:"secret"`
+	OtpauthUri    string   `json:"otpauthUri"`
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type TotpEnableDto struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TotpDisableDto struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type TotpResetDto struct {
+	ID int64 `json:"id" binding:"required"`
+}
+
+// ClientInfo 登录请求的来源，记录在会话中
+type ClientInfo struct {
+	Ip        string
+	UserAgent string
+}
+
+type RefreshTokenDto struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// SessionListDto 管理员可指定 userId 查看其他用户的会话，为 0 时查看自己的
+type SessionListDto struct {
+	UserId int64 `json:"userId"`
+}
+
+type SessionRevokeDto struct {
+	ID int64 `json:"id" binding:"required"`
+}
+
+type SessionRevokeAllDto struct {
+	UserId int64 `json:"userId" binding:"required"`
+}
+
+type SessionDto struct {
+	ID           int64  `json:"id"`
+	UserId       int64  `json:"userId"`
+	Ip           string `json:"ip"`
+	UserAgent    string `json:"userAgent"`
+	CreatedTime  int64  `json:"createdTime"`
+	LastSeenTime int64  `json:"lastSeenTime"`
+	ExpiresTime  int64  `json:"expiresTime"`
+	Current      bool   `json:"current"`
+}
+
+type LoginUnlockDto struct {
+	ID int64 `json:"id" binding:"required"`
+}
@@ -20,3 +20,20 @@ type NodeUpdateDto struct {
 	Tls        int    `json:"tls"`
 	Socks      int    `json:"socks"`
 }
+
+// NodeCommandQueryDto 离线命令队列查询
+type NodeCommandQueryDto struct {
+	NodeId *int64 `json:"nodeId"`
+	Status *int   `json:"status"`
+}
+
+// NodeReconcileQueryDto 查询最近一次配置对账结果
+type NodeReconcileQueryDto struct {
+	NodeId *int64 `json:"nodeId"`
+}
+
+// NodeCommandPurgeDto 清除待发送命令，按 ID 或按节点
+type NodeCommandPurgeDto struct {
+	Ids    []int64 `json:"ids"`
+	NodeId *int64  `json:"nodeId"`
+}
@@ -0,0 +1,19 @@
+package dto
+
+// NotifySettingDto 用户的通知偏好，Channels 为启用的渠道（email / telegram / webhook）
+type NotifySettingDto struct {
+	Channels       []string `json:"channels"`
+	Email          string   `json:"email"`
+	TelegramChatId string   `json:"telegramChatId"`
+	WebhookUrl     string   `json:"webhookUrl"`
+	FlowAlert      int      `json:"flowAlert"`
+	ExpiryAlert    int      `json:"expiryAlert"`
+}
+
+// NotifySettingInfoDto Available 为面板已配置、可以使用的渠道
+type NotifySettingInfoDto struct {
+	NotifySettingDto
+	Available      []string `json:"available"`
+	FlowThresholds []int    `json:"flowThresholds"`
+	ExpiryDays     []int    `json:"expiryDays"`
+}
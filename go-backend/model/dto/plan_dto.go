@@ -0,0 +1,42 @@
+package dto
+
+type PlanTunnelDto struct {
+	TunnelId    int64 `json:"tunnelId" binding:"required"`
+	Flow        int64 `json:"flow"`
+	SpeedId     int   `json:"speedId"`
+	ConnLimitId int   `json:"connLimitId"`
+}
+
+type PlanDto struct {
+	Name          string          `json:"name" binding:"required"`
+	Flow          int64           `json:"flow"`
+	Num           int             `json:"num"`
+	Duration      int             `json:"duration"`
+	FlowResetTime int64           `json:"flowResetTime"`
+	Status        *int            `json:"status"`
+	Tunnels       []PlanTunnelDto `json:"tunnels"`
+}
+
+// PlanUpdateDto Cascade 为 true 时同步到所有订阅用户（不改变到期时间和已用流量）
+type PlanUpdateDto struct {
+	ID int64 `json:"id" binding:"required"`
+	PlanDto
+	Cascade bool `json:"cascade"`
+}
+
+type PlanDeleteDto struct {
+	ID int64 `json:"id" binding:"required"`
+}
+
+// PlanAssignDto 为用户开通套餐，到期时间从当前开始计算，已用流量清零
+type PlanAssignDto struct {
+	UserId int64 `json:"userId" binding:"required"`
+	PlanId int64 `json:"planId" binding:"required"`
+}
+
+// PlanRenewDto 续费当前套餐，到期时间在原到期时间（已过期则为当前时间）基础上延长
+type PlanRenewDto struct {
+	UserId    int64 `json:"userId" binding:"required"`
+	Periods   int   `json:"periods"` // 续费周期数，默认 1
+	ResetFlow bool  `json:"resetFlow"`
+}
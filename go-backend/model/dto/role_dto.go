@@ -0,0 +1,17 @@
+package dto
+
+type RoleDto struct {
+	Name        string   `json:"name" binding:"required"`
+	Code        string   `json:"code" binding:"required"`
+	Permissions []string `json:"permissions"`
+}
+
+type RoleUpdateDto struct {
+	ID          int      `json:"id" binding:"required"`
+	Name        string   `json:"name" binding:"required"`
+	Permissions []string `json:"permissions"`
+}
+
+type RoleDeleteDto struct {
+	ID int `json:"id" binding:"required"`
+}
@@ -0,0 +1,32 @@
+package dto
+
+// TrafficQueryDto 流量时序查询，Start / End 为毫秒时间戳，Period 为空时按时间范围自动选择粒度
+type TrafficQueryDto struct {
+	Scope   string `json:"scope" binding:"required"`
+	ScopeId int64  `json:"scopeId"`
+	Start   int64  `json:"start"`
+	End     int64  `json:"end"`
+	Period  string `json:"period"`
+}
+
+type TrafficSeriesDto struct {
+	Scope   string            `json:"scope"`
+	ScopeId int64             `json:"scopeId"`
+	Period  string            `json:"period"`
+	Start   int64             `json:"start"`
+	End     int64             `json:"end"`
+	Points  []TrafficPointDto `json:"points"`
+}
+
+type TrafficPointDto struct {
+	Time    int64 `json:"time"`
+	InFlow  int64 `json:"inFlow"`
+	OutFlow int64 `json:"outFlow"`
+}
+
+// TrafficRangeDto 用户套餐信息 / 访客面板中流量历史的查询范围，均可为空
+type TrafficRangeDto struct {
+	Start  int64  `json:"start" form:"start"`
+	End    int64  `json:"end" form:"end"`
+	Period string `json:"period" form:"period"`
+}
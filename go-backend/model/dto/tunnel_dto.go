@@ -13,6 +13,14 @@ type TunnelDto struct {
 	TcpListenAddr string          `json:"tcpListenAddr"`
 	UdpListenAddr string          `json:"udpListenAddr"`
 	InterfaceName string          `json:"interfaceName"`
+	HopNodeIds    []int64         `json:"hopNodeIds"` // 中转节点，按顺序 (Type 2)
+	InNodeIds     []int64         `json:"inNodeIds"`  // 附加入口节点
+	OutNodeIds    []int64         `json:"outNodeIds"` // 附加出口节点 (Type 2)
+	Strategy      string          `json:"strategy"`
+	MaxFails      int             `json:"maxFails"`
+	FailTimeout   int             `json:"failTimeout"`
+	AllowCidrs    string          `json:"allowCidrs"` // 转发默认来源 IP 白名单
+	DenyCidrs     string          `json:"denyCidrs"`  // 转发默认来源 IP 黑名单
 }
 
 type TunnelUpdateDto struct {
@@ -24,6 +32,14 @@ type TunnelUpdateDto struct {
 	TcpListenAddr string          `json:"tcpListenAddr"`
 	UdpListenAddr string          `json:"udpListenAddr"`
 	InterfaceName string          `json:"interfaceName"`
+	HopNodeIds    *[]int64        `json:"hopNodeIds"`  // 为空表示不修改中转节点
+	InNodeIds     *[]int64        `json:"inNodeIds"`   // 为空表示不修改附加入口节点
+	OutNodeIds    *[]int64        `json:"outNodeIds"`  // 为空表示不修改附加出口节点
+	Strategy      *string         `json:"strategy"`    // 为空表示不修改
+	MaxFails      *int            `json:"maxFails"`    // 为空表示不修改
+	FailTimeout   *int            `json:"failTimeout"` // 为空表示不修改
+	AllowCidrs    *string         `json:"allowCidrs"`  // 为空表示不修改
+	DenyCidrs     *string         `json:"denyCidrs"`   // 为空表示不修改
 }
 
 type TunnelListDto struct {
@@ -9,6 +9,7 @@ type UserDto struct {
 	ExpTime       int64  `json:"expTime"`
 	FlowResetTime int64  `json:"flowResetTime"`
 	MaxFlow       int64  `json:"maxFlow"` // Not used directly in model?
+	RoleId        *int   `json:"roleId"`  // 仅超级管理员可指定，默认普通用户
 }
 
 type UserUpdateDto struct {
@@ -20,6 +21,7 @@ type UserUpdateDto struct {
 	Num           int    `json:"num"`
 	ExpTime       int64  `json:"expTime"`
 	FlowResetTime int64  `json:"flowResetTime"`
+	RoleId        *int   `json:"roleId"`
 }
 
 type ChangePasswordDto struct {
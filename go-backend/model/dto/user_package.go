@@ -5,6 +5,7 @@ type UserPackageDto struct {
 	TunnelPermissions []UserTunnelDetailDto  `json:"tunnelPermissions"`
 	Forwards          []UserForwardDetailDto `json:"forwards"`
 	StatisticsFlows   []StatisticsFlowDto    `json:"statisticsFlows"`
+	FlowHistory       *TrafficSeriesDto      `json:"flowHistory"`
 }
 
 type UserInfoDto struct {
@@ -41,15 +42,16 @@ type UserTunnelDetailDto struct {
 }
 
 type UserForwardDetailDto struct {
-	ID         int64  `json:"id"`
-	Name       string `json:"name"`
-	TunnelId   int64  `json:"tunnelId"`
-	TunnelName string `json:"tunnelName"`
-	InIP       string `json:"inIp"`
-	InPort     int    `json:"inPort"`
-	RemoteAddr string `json:"remoteAddr"`
-	InFlow     int64  `json:"inFlow"`
-	OutFlow    int64  `json:"outFlow"`
-	Status     int    `json:"status"`
-	CreatedAt  int64  `json:"createdTime"`
+	ID           int64    `json:"id"`
+	Name         string   `json:"name"`
+	TunnelId     int64    `json:"tunnelId"`
+	TunnelName   string   `json:"tunnelName"`
+	InIP         string   `json:"inIp"`
+	InPort       int      `json:"inPort"`
+	RemoteAddr   string   `json:"remoteAddr"`
+	InFlow       int64    `json:"inFlow"`
+	OutFlow      int64    `json:"outFlow"`
+	Status       int      `json:"status"`
+	PauseReasons []string `json:"pauseReasons"`
+	CreatedAt    int64    `json:"createdTime"`
 }
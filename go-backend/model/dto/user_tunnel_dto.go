@@ -2,9 +2,10 @@ package dto
 
 // UserTunnelDto 用户隧道权限分配 DTO
 type UserTunnelDto struct {
-	UserId   int64 `json:"userId" binding:"required"`
-	TunnelId int64 `json:"tunnelId" binding:"required"`
-	SpeedId  int   `json:"speedId"` // 0表示不限速
+	UserId      int64 `json:"userId" binding:"required"`
+	TunnelId    int64 `json:"tunnelId" binding:"required"`
+	SpeedId     int   `json:"speedId"`     // 0表示不限速
+	ConnLimitId int   `json:"connLimitId"` // 0表示不限制连接数
 }
 
 // UserTunnelQueryDto 用户隧道查询 DTO
@@ -15,7 +16,8 @@ type UserTunnelQueryDto struct {
 
 // UserTunnelUpdateDto 用户隧道更新 DTO
 type UserTunnelUpdateDto struct {
-	ID      int  `json:"id" binding:"required"`
-	SpeedId int  `json:"speedId"` // 0表示不限速
-	Status  *int `json:"status"`
+	ID          int  `json:"id" binding:"required"`
+	SpeedId     int  `json:"speedId"`     // 0表示不限速
+	ConnLimitId *int `json:"connLimitId"` // 0表示不限制连接数，为空表示不修改
+	Status      *int `json:"status"`
 }
@@ -0,0 +1,35 @@
+package dto
+
+// WebhookDto Events 为空或包含 * 时订阅全部事件；Secret 为空时自动生成
+type WebhookDto struct {
+	Name   string   `json:"name" binding:"required"`
+	Url    string   `json:"url" binding:"required"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+	Status *int     `json:"status"`
+}
+
+// WebhookUpdateDto Secret 为空时保持不变，RotateSecret 为 true 时重新生成
+type WebhookUpdateDto struct {
+	ID int64 `json:"id" binding:"required"`
+	WebhookDto
+	RotateSecret bool `json:"rotateSecret"`
+}
+
+type WebhookIdDto struct {
+	ID int64 `json:"id" binding:"required"`
+}
+
+// WebhookSecretDto 创建或更换密钥时返回一次，之后不再展示
+type WebhookSecretDto struct {
+	ID     int64  `json:"id"`
+	Secret string `json:"secret"`
+}
+
+type WebhookDeliveryQueryDto struct {
+	WebhookId int64  `json:"webhookId"`
+	Event     string `json:"event"`
+	Status    *int   `json:"status"`
+	Page      int    `json:"page"`
+	Size      int    `json:"size"`
+}
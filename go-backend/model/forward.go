@@ -1,5 +1,17 @@
 package model
 
+import "strings"
+
+// 转发暂停原因；同一转发可以因多个原因暂停，全部解除后才恢复
+const (
+	PauseManual      = "manual"       // 用户手动暂停
+	PauseUserQuota   = "user_quota"   // 账号流量超限
+	PauseTunnelQuota = "tunnel_quota" // 用户隧道流量超限
+	PauseExpired     = "expired"      // 账号或用户隧道到期
+	PauseAdmin       = "admin"        // 管理员暂停、停用账号或隧道权限
+	PauseNodeError   = "node_error"   // 节点上的服务创建失败
+)
+
 type Forward struct {
 	ID            int64  `gorm:"primaryKey;autoIncrement" json:"id"`
 	CreatedTime   int64  `json:"createdTime"`
@@ -17,8 +29,53 @@ type Forward struct {
 	InFlow        int64  `json:"inFlow"`
 	OutFlow       int64  `json:"outFlow"`
 	Inx           int    `json:"inx"`
+	ConnLimitId   int    `json:"connLimitId"`  // 非 0 时覆盖用户隧道的连接数限制
+	AllowCidrs    string `json:"allowCidrs"`   // 来源 IP 白名单，逗号分隔，非空时覆盖隧道默认值
+	DenyCidrs     string `json:"denyCidrs"`    // 来源 IP 黑名单，逗号分隔，与隧道默认值合并
+	PauseReasons  string `json:"pauseReasons"` // 暂停原因，逗号分隔；暂停但为空的是升级前的旧数据
 }
 
 func (Forward) TableName() string {
 	return "forward"
 }
+
+func (f *Forward) PauseReasonList() []string {
+	if f.PauseReasons == "" {
+		return []string{}
+	}
+	return strings.Split(f.PauseReasons, ",")
+}
+
+func (f *Forward) HasPauseReason(reason string) bool {
+	for _, r := range f.PauseReasonList() {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// AddPauseReason 记录暂停原因，已存在时返回 false
+func (f *Forward) AddPauseReason(reason string) bool {
+	if f.HasPauseReason(reason) {
+		return false
+	}
+	f.PauseReasons = strings.Join(append(f.PauseReasonList(), reason), ",")
+	return true
+}
+
+// ClearPauseReasons 移除指定的暂停原因，返回是否已没有其他原因（可以恢复）
+func (f *Forward) ClearPauseReasons(reasons ...string) bool {
+	remove := make(map[string]bool, len(reasons))
+	for _, r := range reasons {
+		remove[r] = true
+	}
+	kept := make([]string, 0)
+	for _, r := range f.PauseReasonList() {
+		if !remove[r] {
+			kept = append(kept, r)
+		}
+	}
+	f.PauseReasons = strings.Join(kept, ",")
+	return len(kept) == 0
+}
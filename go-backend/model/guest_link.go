@@ -1,12 +1,62 @@
 package model
 
+import (
+	"strconv"
+	"strings"
+)
+
+// GuestLink 访客面板链接，一个用户可以有多个，可分别设置有效期、范围并单独撤销
 type GuestLink struct {
 	ID          int64  `gorm:"primaryKey;autoIncrement" json:"id"`
 	UserID      int64  `gorm:"index;not null" json:"userId"`
 	Token       string `gorm:"uniqueIndex;not null;type:varchar(255)" json:"token"`
+	Name        string `json:"name"`
 	CreatedTime int64  `json:"createdTime"`
+	CreatedBy   int64  `json:"createdBy"`
+	ExpTime     int64  `json:"expTime"`     // 0 表示永不过期
+	RevokedTime int64  `json:"revokedTime"` // 0 表示未撤销
+
+	// 范围：ForwardIds / TunnelIds 为逗号分隔的 ID，均为空时展示全部转发
+	ForwardIds string `json:"forwardIds"`
+	TunnelIds  string `json:"tunnelIds"`
+	HideFlow   int    `json:"hideFlow"` // 1: 隐藏流量数据
+	HideIp     int    `json:"hideIp"`   // 1: 隐藏入口 IP
+
+	AccessCount    int64  `json:"accessCount"`
+	LastAccessTime int64  `json:"lastAccessTime"`
+	LastAccessIp   string `json:"lastAccessIp"`
 }
 
 func (GuestLink) TableName() string {
 	return "guest_link"
 }
+
+// Scoped 是否只展示指定的转发或隧道
+func (l *GuestLink) Scoped() bool {
+	return l.ForwardIds != "" || l.TunnelIds != ""
+}
+
+// Active 链接未撤销且未过期
+func (l *GuestLink) Active(now int64) bool {
+	return l.RevokedTime == 0 && (l.ExpTime == 0 || l.ExpTime > now)
+}
+
+// ParseIds 解析逗号分隔的 ID 列表
+func ParseIds(csv string) map[int64]bool {
+	ids := make(map[int64]bool)
+	for _, s := range strings.Split(csv, ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64); err == nil {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// JoinIds 将 ID 列表转换为逗号分隔的字符串
+func JoinIds(ids []int64) string {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,24 @@
+package model
+
+// 登录限制的维度
+const (
+	LoginScopeIp   = "ip"
+	LoginScopeUser = "user"
+)
+
+// LoginThrottle 按 IP 或账号统计连续登录失败次数，超过阈值后按指数退避临时锁定
+type LoginThrottle struct {
+	ID           int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	Scope        string `gorm:"uniqueIndex:idx_login_throttle_key;size:8" json:"scope"`
+	Target       string `gorm:"uniqueIndex:idx_login_throttle_key;size:128" json:"target"` // IP 或用户名
+	Failures     int    `json:"failures"`
+	LastFailTime int64  `gorm:"index" json:"lastFailTime"`
+	LockedUntil  int64  `json:"lockedUntil"` // 0 表示未锁定
+	LastIp       string `json:"lastIp"`
+	LastUsername string `json:"lastUsername"`
+	LastSource   string `json:"lastSource"` // login / sub_store
+}
+
+func (LoginThrottle) TableName() string {
+	return "login_throttle"
+}
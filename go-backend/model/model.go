@@ -0,0 +1,32 @@
+package model
+
+// All 返回需要自动建表的全部模型，启动和测试共用同一份列表
+func All() []interface{} {
+	return []interface{}{
+		&User{},
+		&Node{},
+		&Tunnel{},
+		&Forward{},
+		&SpeedLimit{},
+		&UserTunnel{},
+		&StatisticsFlow{},
+		&ViteConfig{},
+		&GuestLink{},
+		&NodeCommand{},
+		&TunnelHop{},
+		&TunnelNode{},
+		&ConnLimit{},
+		&TrafficSeries{},
+		&UserSession{},
+		&ApiKey{},
+		&Role{},
+		&AuditLog{},
+		&LoginThrottle{},
+		&Plan{},
+		&PlanTunnel{},
+		&NotifySetting{},
+		&NotifyMark{},
+		&Webhook{},
+		&WebhookDelivery{},
+	}
+}
@@ -0,0 +1,20 @@
+package model
+
+// NodeCommand 节点离线时持久化的下发命令，节点重连后按 ID 顺序补发
+type NodeCommand struct {
+	ID             int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	NodeId         int64  `gorm:"index:idx_node_command_node_status" json:"nodeId"`
+	Type           string `gorm:"size:50" json:"type"`
+	Data           string `gorm:"type:text" json:"data"`
+	IdempotencyKey string `gorm:"size:255;index" json:"idempotencyKey"`
+	Status         int    `gorm:"index:idx_node_command_node_status;comment:0待发送 1已完成 2失败 3已被取代" json:"status"`
+	Attempts       int    `json:"attempts"`
+	LastError      string `gorm:"size:500" json:"lastError"`
+	NextRetryTime  int64  `json:"nextRetryTime"`
+	CreatedTime    int64  `json:"createdTime"`
+	UpdatedTime    int64  `json:"updatedTime"`
+}
+
+func (NodeCommand) TableName() string {
+	return "node_command"
+}
@@ -0,0 +1,41 @@
+package model
+
+// 通知事件类型，同时作为去重记录的 kind
+const (
+	NotifyUserFlow     = "user_flow"     // 账号流量达到阈值
+	NotifyTunnelFlow   = "tunnel_flow"   // 用户隧道流量达到阈值
+	NotifyUserExpiry   = "user_expiry"   // 账号即将到期
+	NotifyTunnelExpiry = "tunnel_expiry" // 用户隧道即将到期
+)
+
+// NotifySetting 用户的通知渠道偏好，未设置时不发送任何通知
+type NotifySetting struct {
+	ID             int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserId         int64  `gorm:"uniqueIndex" json:"userId"`
+	Channels       string `json:"channels"` // 启用的渠道，逗号分隔：email,telegram,webhook
+	Email          string `json:"email"`
+	TelegramChatId string `json:"telegramChatId"`
+	WebhookUrl     string `json:"webhookUrl"`
+	FlowAlert      int    `json:"flowAlert"`   // 1 接收流量阈值通知
+	ExpiryAlert    int    `json:"expiryAlert"` // 1 接收到期提醒
+	UpdatedTime    int64  `json:"updatedTime"`
+}
+
+func (NotifySetting) TableName() string {
+	return "notify_setting"
+}
+
+// NotifyMark 已发送的阈值，保证每个周期只通知一次；
+// 流量阈值在重置流量时清除，到期提醒以到期时间作为周期（续费后重新提醒）
+type NotifyMark struct {
+	ID          int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	Kind        string `gorm:"uniqueIndex:idx_notify_mark;size:32" json:"kind"`
+	TargetId    int64  `gorm:"uniqueIndex:idx_notify_mark" json:"targetId"` // 用户或用户隧道 ID
+	Threshold   int    `gorm:"uniqueIndex:idx_notify_mark" json:"threshold"`
+	Cycle       int64  `gorm:"uniqueIndex:idx_notify_mark" json:"cycle"`
+	CreatedTime int64  `json:"createdTime"`
+}
+
+func (NotifyMark) TableName() string {
+	return "notify_mark"
+}
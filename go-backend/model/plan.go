@@ -0,0 +1,33 @@
+package model
+
+// Plan 套餐，打包流量、转发数、隧道（含各隧道限速）、有效期和流量重置日
+type Plan struct {
+	ID            int64        `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name          string       `gorm:"size:100" json:"name"`
+	Flow          int64        `json:"flow"`          // 账号流量(GB)，0 表示不限
+	Num           int          `json:"num"`           // 转发数量
+	Duration      int          `json:"duration"`      // 有效天数，0 表示永不过期
+	FlowResetTime int64        `json:"flowResetTime"` // 每月流量重置日，0 表示不重置
+	Status        int          `json:"status"`        // 1: 可分配, 0: 下架（已订阅的用户不受影响）
+	CreatedTime   int64        `json:"createdTime"`
+	UpdatedTime   int64        `json:"updatedTime"`
+	Tunnels       []PlanTunnel `gorm:"foreignKey:PlanId" json:"tunnels"`
+}
+
+func (Plan) TableName() string {
+	return "plan"
+}
+
+// PlanTunnel 套餐包含的隧道及该隧道的流量、限速和连接数限制
+type PlanTunnel struct {
+	ID          int64 `gorm:"primaryKey;autoIncrement" json:"id"`
+	PlanId      int64 `gorm:"index" json:"planId"`
+	TunnelId    int64 `json:"tunnelId"`
+	Flow        int64 `json:"flow"` // 隧道流量(GB)，0 表示沿用套餐流量
+	SpeedId     int   `json:"speedId"`
+	ConnLimitId int   `json:"connLimitId"`
+}
+
+func (PlanTunnel) TableName() string {
+	return "plan_tunnel"
+}
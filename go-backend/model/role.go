@@ -0,0 +1,77 @@
+package model
+
+import "strings"
+
+// 权限点，角色通过组合权限点授予后台管理能力
+const (
+	PermUserRead     = "user:read"     // 查看用户、会话
+	PermUserWrite    = "user:write"    // 创建、修改、删除用户，分配隧道，重置流量、两步验证和会话
+	PermNodeRead     = "node:read"     // 查看节点及节点状态
+	PermNodeWrite    = "node:write"    // 管理节点、安装、离线命令
+	PermTunnelRead   = "tunnel:read"   // 查看全部隧道及用户隧道权限
+	PermTunnelWrite  = "tunnel:write"  // 创建、修改、删除、诊断隧道
+	PermForwardRead  = "forward:read"  // 查看所有用户的转发
+	PermForwardWrite = "forward:write" // 管理所有用户的转发
+	PermLimitRead    = "limit:read"    // 查看限速、连接数规则
+	PermLimitWrite   = "limit:write"   // 管理限速、连接数规则
+	PermTrafficRead  = "traffic:read"  // 查看所有流量统计
+	PermConfigWrite  = "config:write"  // 修改系统配置
+	PermSubUserWrite = "subuser:write" // 创建和管理自己的下级用户，额度从自身套餐中划分
+	PermAuditRead    = "audit:read"    // 查看审计日志
+	PermPlanWrite    = "plan:write"    // 管理套餐目录
+	PermWebhookWrite = "webhook:write" // 管理 Webhook 订阅和投递记录
+)
+
+var Permissions = []string{
+	PermUserRead, PermUserWrite,
+	PermNodeRead, PermNodeWrite,
+	PermTunnelRead, PermTunnelWrite,
+	PermForwardRead, PermForwardWrite,
+	PermLimitRead, PermLimitWrite,
+	PermTrafficRead, PermConfigWrite,
+	PermSubUserWrite, PermAuditRead,
+	PermPlanWrite, PermWebhookWrite,
+}
+
+// 内置角色 ID；0 为超级管理员，拥有全部权限且不存库
+const (
+	RoleSuperAdmin   = 0
+	RoleUser         = 1
+	RoleNodeOperator = 2
+	RoleReseller     = 3
+	RoleSupport      = 4
+)
+
+// Role 角色，Permissions 为逗号分隔的权限点
+type Role struct {
+	ID          int    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string `json:"name"`
+	Code        string `gorm:"uniqueIndex;size:64" json:"code"`
+	Permissions string `json:"permissions"`
+	BuiltIn     int    `json:"builtIn"` // 1: 内置角色，不能删除
+	CreatedTime int64  `json:"createdTime"`
+	UpdatedTime int64  `json:"updatedTime"`
+}
+
+func (Role) TableName() string {
+	return "role"
+}
+
+func (r *Role) PermissionList() []string {
+	if r.Permissions == "" {
+		return nil
+	}
+	return strings.Split(r.Permissions, ",")
+}
+
+// BuiltinRoles 内置角色的默认权限，首次启动时写入 role 表
+var BuiltinRoles = []Role{
+	{ID: RoleUser, Name: "普通用户", Code: "user", BuiltIn: 1},
+	{ID: RoleNodeOperator, Name: "节点运维", Code: "node_operator", BuiltIn: 1, Permissions: strings.Join([]string{
+		PermNodeRead, PermNodeWrite, PermTunnelRead, PermTunnelWrite, PermForwardRead, PermLimitRead, PermLimitWrite,
+	}, ",")},
+	{ID: RoleReseller, Name: "分销商", Code: "reseller", BuiltIn: 1, Permissions: PermSubUserWrite},
+	{ID: RoleSupport, Name: "只读客服", Code: "support", BuiltIn: 1, Permissions: strings.Join([]string{
+		PermUserRead, PermNodeRead, PermTunnelRead, PermForwardRead, PermLimitRead, PermTrafficRead,
+	}, ",")},
+}
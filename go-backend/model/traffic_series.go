@@ -0,0 +1,31 @@
+package model
+
+// 流量时序的统计对象
+const (
+	TrafficScopeUser    = "user"
+	TrafficScopeForward = "forward"
+	TrafficScopeTunnel  = "tunnel"
+	TrafficScopeNode    = "node"
+)
+
+// 流量时序的粒度，每条流量同时累加到三个粒度，按各自的保留期清理
+const (
+	TrafficPeriod5Min = "5m" // 保留 2 天
+	TrafficPeriodHour = "1h" // 保留 60 天
+	TrafficPeriodDay  = "1d" // 保留 2 年
+)
+
+// TrafficSeries 流量时序，BucketTime 为统计区间起始时间（毫秒）
+type TrafficSeries struct {
+	ID         int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	Scope      string `gorm:"size:16;uniqueIndex:idx_traffic_series_bucket,priority:1" json:"scope"`
+	ScopeId    int64  `gorm:"uniqueIndex:idx_traffic_series_bucket,priority:2" json:"scopeId"`
+	Period     string `gorm:"size:8;uniqueIndex:idx_traffic_series_bucket,priority:3" json:"period"`
+	BucketTime int64  `gorm:"uniqueIndex:idx_traffic_series_bucket,priority:4;index" json:"bucketTime"`
+	InFlow     int64  `json:"inFlow"`
+	OutFlow    int64  `json:"outFlow"`
+}
+
+func (TrafficSeries) TableName() string {
+	return "traffic_series"
+}
@@ -17,7 +17,16 @@ type Tunnel struct {
 	TcpListenAddr string  `json:"tcpListenAddr"`
 	UdpListenAddr string  `json:"udpListenAddr"`
 	InterfaceName string  `json:"interfaceName"`
-	OutPort       int     `json:"outPort"` // 隧道共享出口端口 (Type 2)
+	OutPort       int     `json:"outPort"`     // 隧道共享出口端口 (Type 2)
+	Strategy      string  `json:"strategy"`    // 出口节点组负载策略: round/random/fifo/hash
+	MaxFails      int     `json:"maxFails"`    // 出口节点连续失败次数阈值
+	FailTimeout   int     `json:"failTimeout"` // 出口节点失败后摘除时长（秒）
+	AllowCidrs    string  `json:"allowCidrs"`  // 转发默认来源 IP 白名单，逗号分隔
+	DenyCidrs     string  `json:"denyCidrs"`   // 转发默认来源 IP 黑名单，逗号分隔
+
+	Hops     []TunnelHop  `gorm:"-" json:"hops,omitempty"`     // 中转节点，按顺序
+	InNodes  []TunnelNode `gorm:"-" json:"inNodes,omitempty"`  // 附加入口节点
+	OutNodes []TunnelNode `gorm:"-" json:"outNodes,omitempty"` // 附加出口节点
 }
 
 func (Tunnel) TableName() string {
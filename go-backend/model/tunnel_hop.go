@@ -0,0 +1,16 @@
+package model
+
+// TunnelHop 隧道转发的中转节点，按 Inx 顺序串联在入口与出口之间
+type TunnelHop struct {
+	ID          int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	TunnelId    int64  `gorm:"index" json:"tunnelId"`
+	Inx         int    `json:"inx"`
+	NodeId      int64  `gorm:"index" json:"nodeId"`
+	Ip          string `json:"ip"`   // 中转节点 ServerIp
+	Port        int    `json:"port"` // 中转节点 relay 端口
+	CreatedTime int64  `json:"createdTime"`
+}
+
+func (TunnelHop) TableName() string {
+	return "tunnel_hop"
+}
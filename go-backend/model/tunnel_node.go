@@ -0,0 +1,21 @@
+package model
+
+// TunnelNode 隧道的附加入口/出口节点，与 Tunnel.InNodeId / OutNodeId 一起组成节点组
+type TunnelNode struct {
+	ID          int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	TunnelId    int64  `gorm:"index" json:"tunnelId"`
+	NodeId      int64  `gorm:"index" json:"nodeId"`
+	Role        int    `json:"role"` // 1-入口，2-出口
+	Ip          string `json:"ip"`   // 入口为节点 Ip，出口为节点 ServerIp
+	Port        int    `json:"port"` // 出口节点 relay 端口 (Type 2)
+	CreatedTime int64  `json:"createdTime"`
+}
+
+const (
+	TunnelNodeEntry = 1
+	TunnelNodeExit  = 2
+)
+
+func (TunnelNode) TableName() string {
+	return "tunnel_node"
+}
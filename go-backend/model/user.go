@@ -1,20 +1,35 @@
 package model
 
 type User struct {
-	ID            int64      `gorm:"primaryKey;autoIncrement" json:"id"`
-	CreatedTime   int64      `json:"createdTime"`
-	UpdatedTime   int64      `json:"updatedTime"`
-	Status        int        `json:"status"` // 0: 正常, 1: 删除
-	User          string     `json:"user"`
-	Pwd           string     `json:"pwd"`
-	RoleId        int        `json:"roleId"`
-	ExpTime       int64      `json:"expTime"`
-	Flow          int64      `json:"flow"`
-	InFlow        int64      `json:"inFlow"`
-	OutFlow       int64      `json:"outFlow"`
-	Num           int        `json:"num"`
-	FlowResetTime int64      `json:"flowResetTime"`
-	GuestLink     *GuestLink `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	ID            int64       `gorm:"primaryKey;autoIncrement" json:"id"`
+	CreatedTime   int64       `json:"createdTime"`
+	UpdatedTime   int64       `json:"updatedTime"`
+	Status        int         `json:"status"` // 0: 正常, 1: 删除
+	User          string      `json:"user"`
+	Pwd           string      `json:"pwd"`
+	RoleId        int         `json:"roleId"`
+	ExpTime       int64       `json:"expTime"`
+	Flow          int64       `json:"flow"`
+	InFlow        int64       `json:"inFlow"`
+	OutFlow       int64       `json:"outFlow"`
+	Num           int         `json:"num"`
+	FlowResetTime int64       `json:"flowResetTime"`
+	GuestLinks    []GuestLink `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+
+	// 两步验证：密钥、上次使用的时间步（防重放）、恢复码哈希（逗号分隔）
+	TotpSecret      string `json:"-"`
+	TotpEnabled     int    `json:"totpEnabled"`
+	TotpLastCounter int64  `json:"-"`
+	RecoveryCodes   string `json:"-"`
+
+	// PwdChangedTime 最近一次修改密码的时间，早于该时间签发的 token 一律失效
+	PwdChangedTime int64 `json:"-"`
+
+	// ParentId 分销商（上级用户）ID，0 表示由管理员直接管理；下级的额度从上级套餐中划分
+	ParentId int64 `gorm:"index" json:"parentId"`
+
+	// PlanId 当前订阅的套餐，0 表示手动设置额度
+	PlanId int64 `gorm:"index" json:"planId"`
 }
 
 func (User) TableName() string {
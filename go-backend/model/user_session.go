@@ -0,0 +1,19 @@
+package model
+
+// UserSession 登录会话，access token 通过 jti 关联会话，会话撤销后 token 立即失效
+type UserSession struct {
+	ID           int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	Sid          string `gorm:"uniqueIndex;size:64" json:"-"`
+	UserId       int64  `gorm:"index" json:"userId"`
+	RefreshHash  string `json:"-"`
+	Ip           string `json:"ip"`
+	UserAgent    string `json:"userAgent"`
+	CreatedTime  int64  `json:"createdTime"`
+	LastSeenTime int64  `json:"lastSeenTime"`
+	ExpiresTime  int64  `json:"expiresTime"` // refresh token 过期时间
+	RevokedTime  int64  `json:"revokedTime"`
+}
+
+func (UserSession) TableName() string {
+	return "user_session"
+}
@@ -10,8 +10,10 @@ type UserTunnel struct {
 	FlowResetTime int64 `json:"flowResetTime"`
 	ExpTime       int64 `json:"expTime"`
 	SpeedId       int   `json:"speedId"`
+	ConnLimitId   int   `json:"connLimitId"`
 	Num           int   `json:"num"`
 	Status        int   `json:"status"`
+	PlanId        int64 `json:"planId"` // 由套餐分配的隧道权限，换套餐时随之调整
 }
 
 func (UserTunnel) TableName() string {
@@ -11,3 +11,9 @@ type ViteConfig struct {
 func (ViteConfig) TableName() string {
 	return "vite_config"
 }
+
+// SecretConfigs 保存凭据的配置项，不通过公开的配置接口返回，审计日志中脱敏
+var SecretConfigs = map[string]bool{
+	"notify_smtp_password":  true,
+	"notify_telegram_token": true,
+}
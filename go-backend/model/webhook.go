@@ -0,0 +1,79 @@
+package model
+
+import "strings"
+
+// Webhook 推送的事件
+const (
+	EventPing                 = "ping"
+	EventNodeOnline           = "node.online"
+	EventNodeOffline          = "node.offline"
+	EventForwardCreated       = "forward.created"
+	EventForwardPaused        = "forward.paused"
+	EventForwardResumed       = "forward.resumed"
+	EventForwardDeleted       = "forward.deleted"
+	EventUserExpired          = "user.expired"
+	EventTunnelDiagnoseFailed = "tunnel.diagnose_failed"
+)
+
+var WebhookEvents = []string{
+	EventNodeOnline, EventNodeOffline,
+	EventForwardCreated, EventForwardPaused, EventForwardResumed, EventForwardDeleted,
+	EventUserExpired, EventTunnelDiagnoseFailed,
+}
+
+// 投递状态
+const (
+	DeliveryPending = 0
+	DeliverySuccess = 1
+	DeliveryFailed  = 2
+)
+
+// Webhook 外部系统的事件订阅，请求体以 Secret 做 HMAC-SHA256 签名
+type Webhook struct {
+	ID          int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string `json:"name"`
+	Url         string `json:"url"`
+	Secret      string `json:"-"`
+	Events      string `json:"events"` // 订阅的事件，逗号分隔，* 表示全部
+	Status      int    `json:"status"` // 1 启用 0 停用
+	CreatedBy   int64  `json:"createdBy"`
+	CreatedTime int64  `json:"createdTime"`
+	UpdatedTime int64  `json:"updatedTime"`
+}
+
+func (Webhook) TableName() string {
+	return "webhook"
+}
+
+// Subscribed 是否订阅了该事件，ping 总是投递
+func (w *Webhook) Subscribed(event string) bool {
+	if event == EventPing {
+		return true
+	}
+	for _, e := range strings.Split(w.Events, ",") {
+		if e == "*" || e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery 投递记录，失败后按指数退避重试
+type WebhookDelivery struct {
+	ID            int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	WebhookId     int64  `gorm:"index" json:"webhookId"`
+	EventId       string `gorm:"size:64;index" json:"eventId"` // 同一事件投递给多个订阅时相同，接收方据此去重
+	Event         string `gorm:"size:64" json:"event"`
+	Payload       string `gorm:"type:text" json:"payload"`
+	Status        int    `gorm:"index" json:"status"`
+	Attempts      int    `json:"attempts"`
+	NextRetryTime int64  `gorm:"index" json:"nextRetryTime"`
+	ResponseCode  int    `json:"responseCode"`
+	LastError     string `json:"lastError"`
+	CreatedTime   int64  `gorm:"index" json:"createdTime"`
+	UpdatedTime   int64  `json:"updatedTime"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_delivery"
+}
@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+// Email 通过 SMTP 发送邮件，465 端口使用 SSL，其他端口在服务器支持时使用 STARTTLS
+type Email struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (e *Email) Send(target string, msg Message) error {
+	if e.Host == "" || e.From == "" {
+		return errors.New("未配置 SMTP 服务器")
+	}
+	addr := net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+
+	var client *smtp.Client
+	var err error
+	if e.Port == 465 {
+		conn, dialErr := tls.DialWithDialer(&net.Dialer{Timeout: httpClient.Timeout}, "tcp", addr, &tls.Config{ServerName: e.Host})
+		if dialErr != nil {
+			return dialErr
+		}
+		client, err = smtp.NewClient(conn, e.Host)
+	} else {
+		conn, dialErr := net.DialTimeout("tcp", addr, httpClient.Timeout)
+		if dialErr != nil {
+			return dialErr
+		}
+		client, err = smtp.NewClient(conn, e.Host)
+		if err == nil {
+			if ok, _ := client.Extension("STARTTLS"); ok {
+				err = client.StartTLS(&tls.Config{ServerName: e.Host})
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if e.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", e.Username, e.Password, e.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(e.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(target); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buildMail(e.From, target, msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func buildMail(from, to string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", msg.Title))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Content, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
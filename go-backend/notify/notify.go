@@ -0,0 +1,32 @@
+// Package notify 通知渠道（邮件、Telegram、Webhook），只负责投递；触发条件、去重和用户偏好由 service 处理
+package notify
+
+import (
+	"net/http"
+	"time"
+)
+
+// 通知渠道
+const (
+	ChannelEmail    = "email"
+	ChannelTelegram = "telegram"
+	ChannelWebhook  = "webhook"
+)
+
+var Channels = []string{ChannelEmail, ChannelTelegram, ChannelWebhook}
+
+// Message 一条通知，Webhook 以 JSON 原样推送，邮件和 Telegram 使用 Title 和 Content
+type Message struct {
+	Event   string                 `json:"event"`
+	Title   string                 `json:"title"`
+	Content string                 `json:"content"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+	Time    int64                  `json:"time"`
+}
+
+// Notifier 通知渠道，target 为收件地址、Chat ID 或 URL
+type Notifier interface {
+	Send(target string, msg Message) error
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
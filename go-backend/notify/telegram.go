@@ -0,0 +1,47 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+const telegramApi = "https://api.telegram.org"
+
+// Telegram 通过 Bot API 发送消息，target 为用户与 Bot 的 Chat ID
+type Telegram struct {
+	Token string
+	// ApiBase 为空时使用官方地址，可指向自建的 Bot API 反代
+	ApiBase string
+}
+
+func (t *Telegram) Send(target string, msg Message) error {
+	if t.Token == "" {
+		return errors.New("未配置 Telegram Bot Token")
+	}
+	base := strings.TrimRight(t.ApiBase, "/")
+	if base == "" {
+		base = telegramApi
+	}
+	resp, err := httpClient.PostForm(base+"/bot"+t.Token+"/sendMessage", url.Values{
+		"chat_id": {target},
+		"text":    {msg.Title + "\n\n" + msg.Content},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return err
+	}
+	if !body.Ok {
+		return errors.New("Telegram 发送失败: " + body.Description)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Webhook 以 JSON POST 推送通知，非 2xx 响应视为失败
+type Webhook struct{}
+
+func (w *Webhook) Send(target string, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Post(target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook 返回 %d", resp.StatusCode)
+	}
+	return nil
+}
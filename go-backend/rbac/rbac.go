@@ -0,0 +1,84 @@
+// Package rbac 角色权限校验，供 middleware、service 和 websocket 共用
+package rbac
+
+import (
+	"sync"
+
+	"go-backend/global"
+	"go-backend/model"
+)
+
+var (
+	mu     sync.RWMutex
+	loaded bool
+	roles  map[int]map[string]bool
+)
+
+// Has 判断角色是否拥有权限点，超级管理员拥有全部权限
+func Has(roleId int, perm string) bool {
+	if roleId == model.RoleSuperAdmin {
+		return true
+	}
+	ensureLoaded()
+	mu.RLock()
+	defer mu.RUnlock()
+	return roles[roleId][perm]
+}
+
+// IsStaff 超级管理员或拥有任一后台权限的角色
+func IsStaff(roleId int) bool {
+	if roleId == model.RoleSuperAdmin {
+		return true
+	}
+	ensureLoaded()
+	mu.RLock()
+	defer mu.RUnlock()
+	return len(roles[roleId]) > 0
+}
+
+// PermissionsOf 返回角色的权限点列表，供前端控制菜单
+func PermissionsOf(roleId int) []string {
+	var perms []string
+	for _, p := range model.Permissions {
+		if Has(roleId, p) {
+			perms = append(perms, p)
+		}
+	}
+	return perms
+}
+
+// Reload 重新从数据库加载角色，修改角色后调用
+func Reload() {
+	next := make(map[int]map[string]bool)
+	for _, r := range model.BuiltinRoles {
+		next[r.ID] = toSet(r.PermissionList())
+	}
+	var list []model.Role
+	if err := global.DB.Find(&list).Error; err == nil {
+		for _, r := range list {
+			next[r.ID] = toSet(r.PermissionList())
+		}
+	}
+
+	mu.Lock()
+	roles = next
+	loaded = true
+	mu.Unlock()
+}
+
+func ensureLoaded() {
+	mu.RLock()
+	ok := loaded
+	mu.RUnlock()
+	if !ok {
+		Reload()
+	}
+}
+
+func toSet(perms []string) map[string]bool {
+	set := make(map[string]bool, len(perms))
+	for _, p := range perms {
+		set[p] = true
+	}
+	return set
+}
@@ -1,8 +1,12 @@
 package router
 
 import (
+	"log"
+
+	"go-backend/config"
 	"go-backend/controller"
 	"go-backend/middleware"
+	"go-backend/model"
 	"go-backend/websocket"
 
 	"github.com/gin-gonic/gin"
@@ -11,6 +15,12 @@ import (
 func InitRouter() *gin.Engine {
 	r := gin.Default()
 
+	// 只信任配置的反向代理传入的 X-Forwarded-For，否则客户端可伪造来源 IP 绕过登录限制
+	if err := r.SetTrustedProxies(config.AppConfig.Server.TrustedProxies); err != nil {
+		log.Printf("trusted-proxies 配置无效，不信任任何代理: %v", err)
+		r.SetTrustedProxies(nil)
+	}
+
 	// CORS
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
@@ -31,29 +41,63 @@ func InitRouter() *gin.Engine {
 		forwardController := new(controller.ForwardController)
 		openAPIController := new(controller.OpenAPIController)
 		speedLimitController := new(controller.SpeedLimitController)
+		connLimitController := new(controller.ConnLimitController)
+		trafficController := new(controller.TrafficController)
+		apiKeyController := new(controller.ApiKeyController)
+		roleController := new(controller.RoleController)
+		auditController := new(controller.AuditController)
+		planController := new(controller.PlanController)
+		notifyController := new(controller.NotifyController)
+		webhookController := new(controller.WebhookController)
 
 		// Public Routes
-		api.POST("/user/login", userController.Login)
+		api.POST("/user/login", middleware.Audit(), userController.Login)
+		api.POST("/user/login/totp", middleware.Audit(), userController.LoginTotp)
+		api.POST("/user/token/refresh", userController.RefreshToken)
 
 		guestController := new(controller.GuestController)
 		api.GET("/guest/dashboard", guestController.GetDashboard)
 		api.GET("/guest/debug_crash", guestController.DebugCrash)
 
 		// Protected Routes
+		// 分销商（subuser:write）只能管理自己的下级，范围在 service 中限制
+		manageUsers := middleware.RequirePermission(model.PermUserWrite, model.PermSubUserWrite)
 		auth := api.Group("/")
-		auth.Use(middleware.Auth())
+		auth.Use(middleware.Auth(), middleware.Audit())
 		{
 			// User
 			user := auth.Group("/user")
 			{
-				user.POST("/create", middleware.RequireRole(0), userController.Create)
-				user.POST("/list", userController.List)
-				user.POST("/update", userController.Update) // Check permission inside controller/service
+				user.POST("/create", manageUsers, userController.Create)
+				user.POST("/list", middleware.RequirePermission(model.PermUserRead, model.PermSubUserWrite), userController.List)
+				user.POST("/update", manageUsers, userController.Update)
 				user.POST("/updatePassword", userController.UpdatePassword)
-				user.POST("/delete", middleware.RequireRole(0), userController.Delete)
+				user.POST("/delete", manageUsers, userController.Delete)
 				user.POST("/package", userController.Package)
-				user.POST("/reset", middleware.RequireRole(0), userController.Reset)
+				user.POST("/reset", manageUsers, userController.Reset)
 				user.GET("/guest_link", userController.GenerateGuestLink)
+				user.POST("/guest_link/list", userController.GuestLinks)
+				user.POST("/guest_link/create", userController.CreateGuestLink)
+				user.POST("/guest_link/update", userController.UpdateGuestLink)
+				user.POST("/guest_link/revoke", userController.RevokeGuestLink)
+				user.POST("/totp/setup", userController.TotpSetup)
+				user.POST("/totp/enable", userController.TotpEnable)
+				user.POST("/totp/disable", userController.TotpDisable)
+				user.POST("/totp/reset", middleware.RequirePermission(model.PermUserWrite), userController.TotpReset)
+				user.POST("/sessions", userController.Sessions)
+				user.POST("/sessions/revoke", userController.RevokeSession)
+				user.POST("/sessions/revoke_all", middleware.RequirePermission(model.PermUserWrite), userController.RevokeAllSessions)
+				user.POST("/logout", userController.Logout)
+				user.POST("/logout_all", userController.LogoutAll)
+
+				// 流量和到期通知偏好（仅本人）
+				user.POST("/notify", notifyController.Get)
+				user.POST("/notify/update", notifyController.Update)
+				user.POST("/notify/test", notifyController.Test)
+
+				// 登录失败记录与锁定
+				user.POST("/login_failures", middleware.RequirePermission(model.PermUserRead), userController.LoginFailures)
+				user.POST("/login_failures/unlock", middleware.RequirePermission(model.PermUserWrite), userController.UnlockLogin)
 			}
 
 			// Guest
@@ -65,32 +109,39 @@ func InitRouter() *gin.Engine {
 			// Node
 			node := auth.Group("/node")
 			{
-				node.POST("/create", middleware.RequireRole(0), nodeController.Create)
-				node.POST("/list", middleware.RequireRole(0), nodeController.List)
-				node.POST("/update", middleware.RequireRole(0), nodeController.Update)
-				node.POST("/delete", middleware.RequireRole(0), nodeController.Delete)
-				node.POST("/install", middleware.RequireRole(0), nodeController.Install)
+				node.POST("/create", middleware.RequirePermission(model.PermNodeWrite), nodeController.Create)
+				node.POST("/list", middleware.RequirePermission(model.PermNodeRead), nodeController.List)
+				node.POST("/update", middleware.RequirePermission(model.PermNodeWrite), nodeController.Update)
+				node.POST("/delete", middleware.RequirePermission(model.PermNodeWrite), nodeController.Delete)
+				node.POST("/install", middleware.RequirePermission(model.PermNodeWrite), nodeController.Install)
+
+				// 离线命令队列
+				node.POST("/commands", middleware.RequirePermission(model.PermNodeRead), nodeController.Commands)
+				node.POST("/commands/purge", middleware.RequirePermission(model.PermNodeWrite), nodeController.PurgeCommands)
+
+				// 最近一次配置对账结果
+				node.POST("/reconcile", middleware.RequirePermission(model.PermNodeRead), nodeController.Reconcile)
 			}
 
 			// Tunnel
 			tunnel := auth.Group("/tunnel")
 			{
-				tunnel.POST("/create", middleware.RequireRole(0), tunnelController.Create)
-				tunnel.POST("/list", tunnelController.List) // All for admin, authorized for user (impl in service)
-				tunnel.POST("/update", middleware.RequireRole(0), tunnelController.Update)
-				tunnel.POST("/delete", middleware.RequireRole(0), tunnelController.Delete)
+				tunnel.POST("/create", middleware.RequirePermission(model.PermTunnelWrite), tunnelController.Create)
+				tunnel.POST("/list", middleware.RequirePermission(model.PermTunnelRead), tunnelController.List)
+				tunnel.POST("/update", middleware.RequirePermission(model.PermTunnelWrite), tunnelController.Update)
+				tunnel.POST("/delete", middleware.RequirePermission(model.PermTunnelWrite), tunnelController.Delete)
 
-				// UserTunnel Management (Admin only)
-				tunnel.POST("/user/assign", middleware.RequireRole(0), tunnelController.AssignUserTunnel)
-				tunnel.POST("/user/list", middleware.RequireRole(0), tunnelController.ListUserTunnels)
-				tunnel.POST("/user/remove", middleware.RequireRole(0), tunnelController.RemoveUserTunnel)
-				tunnel.POST("/user/update", middleware.RequireRole(0), tunnelController.UpdateUserTunnel)
+				// UserTunnel Management（分配隧道属于用户管理）
+				tunnel.POST("/user/assign", manageUsers, tunnelController.AssignUserTunnel)
+				tunnel.POST("/user/list", middleware.RequirePermission(model.PermTunnelRead, model.PermSubUserWrite), tunnelController.ListUserTunnels)
+				tunnel.POST("/user/remove", manageUsers, tunnelController.RemoveUserTunnel)
+				tunnel.POST("/user/update", manageUsers, tunnelController.UpdateUserTunnel)
 
 				// User visible tunnels (All users)
 				tunnel.POST("/user/tunnel", tunnelController.GetUserTunnels)
 
-				// Tunnel Diagnose (Admin only)
-				tunnel.POST("/diagnose", middleware.RequireRole(0), tunnelController.DiagnoseTunnel)
+				// Tunnel Diagnose
+				tunnel.POST("/diagnose", middleware.RequirePermission(model.PermTunnelWrite), tunnelController.DiagnoseTunnel)
 			}
 
 			// Forward
@@ -107,20 +158,77 @@ func InitRouter() *gin.Engine {
 				forward.POST("/update-order", forwardController.UpdateOrder)
 			}
 
+			// Role（角色管理仅超级管理员）
+			role := auth.Group("/role")
+			{
+				role.POST("/list", middleware.RequirePermission(model.PermUserRead), roleController.List)
+				role.POST("/create", middleware.RequireRole(model.RoleSuperAdmin), roleController.Create)
+				role.POST("/update", middleware.RequireRole(model.RoleSuperAdmin), roleController.Update)
+				role.POST("/delete", middleware.RequireRole(model.RoleSuperAdmin), roleController.Delete)
+			}
+
+			// Traffic History (user / forward for owners, all scopes with traffic:read)
+			auth.POST("/traffic/series", trafficController.Series)
+
+			// Plan（套餐目录，开通 / 续费属于用户管理）
+			plan := auth.Group("/plan")
+			{
+				plan.POST("/list", middleware.RequirePermission(model.PermPlanWrite, model.PermUserWrite), planController.List)
+				plan.POST("/create", middleware.RequirePermission(model.PermPlanWrite), planController.Create)
+				plan.POST("/update", middleware.RequirePermission(model.PermPlanWrite), planController.Update)
+				plan.POST("/delete", middleware.RequirePermission(model.PermPlanWrite), planController.Delete)
+				plan.POST("/assign", middleware.RequirePermission(model.PermUserWrite), planController.Assign)
+				plan.POST("/renew", middleware.RequirePermission(model.PermUserWrite), planController.Renew)
+			}
+
+			// Audit Log
+			auth.POST("/audit/list", middleware.RequirePermission(model.PermAuditRead), auditController.List)
+
+			// Webhook（外部系统订阅面板事件）
+			webhook := auth.Group("/webhook", middleware.RequirePermission(model.PermWebhookWrite))
+			{
+				webhook.POST("/list", webhookController.List)
+				webhook.POST("/create", webhookController.Create)
+				webhook.POST("/update", webhookController.Update)
+				webhook.POST("/delete", webhookController.Delete)
+				webhook.POST("/test", webhookController.Test)
+				webhook.POST("/deliveries", webhookController.Deliveries)
+				webhook.POST("/redeliver", webhookController.Redeliver)
+			}
+
+			// API Key（自动化 / 计费系统）
+			apiKey := auth.Group("/api_key")
+			{
+				apiKey.POST("/create", apiKeyController.Create)
+				apiKey.POST("/list", apiKeyController.List)
+				apiKey.POST("/delete", apiKeyController.Delete)
+			}
+
 			// System Info (WebSocket) - Auth handled internally
 			// auth.GET("/system-info", websocket.HandleWebSocket)
 		}
 
-		// Speed Limit (Admin only)
+		// Speed Limit
+		limitRead := middleware.RequirePermission(model.PermLimitRead)
+		limitWrite := middleware.RequirePermission(model.PermLimitWrite)
 		speedLimit := api.Group("/speed-limit")
-		speedLimit.Use(middleware.Auth())
-		speedLimit.Use(middleware.RequireRole(0))
+		speedLimit.Use(middleware.Auth(), middleware.Audit())
+		{
+			speedLimit.POST("/create", limitWrite, speedLimitController.Create)
+			speedLimit.POST("/list", limitRead, speedLimitController.List)
+			speedLimit.POST("/update", limitWrite, speedLimitController.Update)
+			speedLimit.POST("/delete", limitWrite, speedLimitController.Delete)
+			speedLimit.POST("/tunnels", limitRead, speedLimitController.Tunnels)
+		}
+
+		// Conn Limit
+		connLimit := api.Group("/conn-limit")
+		connLimit.Use(middleware.Auth(), middleware.Audit())
 		{
-			speedLimit.POST("/create", speedLimitController.Create)
-			speedLimit.POST("/list", speedLimitController.List)
-			speedLimit.POST("/update", speedLimitController.Update)
-			speedLimit.POST("/delete", speedLimitController.Delete)
-			speedLimit.POST("/tunnels", speedLimitController.Tunnels)
+			connLimit.POST("/create", limitWrite, connLimitController.Create)
+			connLimit.POST("/list", limitRead, connLimitController.List)
+			connLimit.POST("/update", limitWrite, connLimitController.Update)
+			connLimit.POST("/delete", limitWrite, connLimitController.Delete)
 		}
 
 		// WebSocket (Public endpoint, auth inside)
@@ -137,9 +245,9 @@ func InitRouter() *gin.Engine {
 			configGroup.POST("/list", controller.ViteConfig.GetConfigs)
 			configGroup.POST("/get", controller.ViteConfig.GetConfigByName)
 
-			// Admin only
-			configGroup.POST("/update", middleware.Auth(), controller.ViteConfig.UpdateConfigs)
-			configGroup.POST("/update-single", middleware.Auth(), controller.ViteConfig.UpdateConfig)
+			configWrite := middleware.RequirePermission(model.PermConfigWrite)
+			configGroup.POST("/update", middleware.Auth(), middleware.Audit(), configWrite, controller.ViteConfig.UpdateConfigs)
+			configGroup.POST("/update-single", middleware.Auth(), middleware.Audit(), configWrite, controller.ViteConfig.UpdateConfig)
 		}
 
 		// Open API
@@ -164,6 +272,10 @@ func InitRouter() *gin.Engine {
 	r.POST("/flow/upload", flowController.Upload)
 	r.POST("/flow/test", flowController.Test)
 
+	// Prometheus 指标，使用 metrics-token 鉴权
+	metricsController := controller.MetricsController{}
+	r.GET("/metrics", metricsController.Metrics)
+
 	// WebSocket Routes (Compatible with both /system-info and /api/v1/system-info)
 	r.GET("/system-info", func(c *gin.Context) {
 		websocket.HandleWebSocket(c)
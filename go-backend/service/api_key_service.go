@@ -0,0 +1,148 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/rbac"
+	"go-backend/result"
+	"go-backend/session"
+	"go-backend/utils"
+)
+
+const maxApiKeysPerUser = 20
+
+// ApiKeyService 供自动化和计费系统使用的 API Key
+type ApiKeyService struct{}
+
+var ApiKey = new(ApiKeyService)
+
+// Create 签发 API Key，用户管理权限只能授予本身可以管理用户（含分销商）的账号
+func (s *ApiKeyService) Create(claims *utils.UserClaims, createDto dto.ApiKeyCreateDto) *result.Result {
+	userId := claims.GetUserId()
+	if createDto.UserId != 0 && createDto.UserId != userId {
+		if !rbac.Has(claims.RoleId, model.PermUserWrite) {
+			return result.Err(-1, "权限不足")
+		}
+		userId = createDto.UserId
+	}
+	var user model.User
+	if err := global.DB.First(&user, userId).Error; err != nil {
+		return result.Err(-1, "用户不存在")
+	}
+	if user.ID != claims.GetUserId() && !canManageUser(claims, &user) {
+		return result.Err(-1, "只有超级管理员可以操作后台账号")
+	}
+
+	name := strings.TrimSpace(createDto.Name)
+	if name == "" || len(name) > 64 {
+		return result.Err(-1, "名称不能为空且不超过64个字符")
+	}
+	scopes, err := normalizeScopes(createDto.Scopes)
+	if err != nil {
+		return result.Err(-1, err.Error())
+	}
+	for _, scope := range scopes {
+		if scope == model.ScopeUserWrite && !rbac.Has(user.RoleId, model.PermUserWrite) && !rbac.Has(user.RoleId, model.PermSubUserWrite) {
+			return result.Err(-1, "该账号没有用户管理权限")
+		}
+	}
+	now := time.Now().UnixMilli()
+	if createDto.ExpTime != 0 && createDto.ExpTime <= now {
+		return result.Err(-1, "过期时间不能早于当前时间")
+	}
+
+	var count int64
+	global.DB.Model(&model.ApiKey{}).Where("user_id = ?", userId).Count(&count)
+	if count >= maxApiKeysPerUser {
+		return result.Err(-1, "API Key 数量已达上限")
+	}
+
+	prefix, err := randomHex(6)
+	if err != nil {
+		return result.Err(-1, "API Key 生成失败")
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return result.Err(-1, "API Key 生成失败")
+	}
+	key := model.ApiKey{
+		UserId:      userId,
+		Name:        name,
+		Prefix:      prefix,
+		KeyHash:     session.HashApiKeySecret(secret),
+		Scopes:      strings.Join(scopes, ","),
+		ExpTime:     createDto.ExpTime,
+		CreatedBy:   claims.GetUserId(),
+		CreatedTime: now,
+	}
+	if err := global.DB.Create(&key).Error; err != nil {
+		return result.Err(-1, "API Key 创建失败")
+	}
+	return result.Ok(dto.ApiKeyCreatedDto{
+		ID:      key.ID,
+		Name:    key.Name,
+		Key:     session.ApiKeyPrefix + prefix + "_" + secret,
+		Scopes:  scopes,
+		ExpTime: key.ExpTime,
+	})
+}
+
+// List 列出 API Key（不含密钥本身），拥有 user:read 权限时可查看任意用户
+func (s *ApiKeyService) List(claims *utils.UserClaims, listDto dto.ApiKeyListDto) *result.Result {
+	query := global.DB.Order("id desc")
+	if !rbac.Has(claims.RoleId, model.PermUserRead) {
+		query = query.Where("user_id = ?", claims.GetUserId())
+	} else if listDto.UserId != 0 {
+		query = query.Where("user_id = ?", listDto.UserId)
+	}
+	var keys []model.ApiKey
+	query.Find(&keys)
+	return result.Ok(keys)
+}
+
+// Delete 吊销 API Key，没有用户管理权限时只能吊销自己的
+func (s *ApiKeyService) Delete(claims *utils.UserClaims, id int64) *result.Result {
+	var key model.ApiKey
+	if err := global.DB.First(&key, id).Error; err != nil {
+		return result.Err(-1, "API Key 不存在")
+	}
+	if key.UserId != claims.GetUserId() && !rbac.Has(claims.RoleId, model.PermUserWrite) {
+		return result.Err(-1, "权限不足")
+	}
+	if err := global.DB.Delete(&key).Error; err != nil {
+		return result.Err(-1, "删除失败")
+	}
+	return result.Ok("删除成功")
+}
+
+func normalizeScopes(scopes []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var out []string
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if seen[scope] {
+			continue
+		}
+		valid := false
+		for _, s := range model.ApiKeyScopes {
+			if s == scope {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("无效的权限范围: %s", scope)
+		}
+		seen[scope] = true
+		out = append(out, scope)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("至少选择一个权限范围")
+	}
+	return out, nil
+}
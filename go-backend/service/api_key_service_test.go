@@ -0,0 +1,96 @@
+package service_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/result"
+	"go-backend/router"
+	"go-backend/service"
+	"go-backend/testutil"
+	"go-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// apiKeyClient 用 API Key 通过完整路由发送请求
+func apiKeyClient(t *testing.T) func(key, path string) *result.Result {
+	gin.SetMode(gin.TestMode)
+	r := router.InitRouter()
+	return func(key, path string) *result.Result {
+		req := httptest.NewRequest("POST", path, strings.NewReader("{}"))
+		req.Header.Set("Authorization", "Bearer "+key)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var res result.Result
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		return &res
+	}
+}
+
+// createUsageKey 为新建的普通用户创建只读用量的 API Key
+func createUsageKey(t *testing.T, username string) (*model.User, *utils.UserClaims, dto.ApiKeyCreatedDto) {
+	user := testutil.CreateUser(username, 1, 1, 100, time.Now().Add(time.Hour).UnixMilli())
+	claims := &utils.UserClaims{RoleId: 1, RegisteredClaims: jwt.RegisteredClaims{Subject: strconv.FormatInt(user.ID, 10)}}
+	res := service.ApiKey.Create(claims, dto.ApiKeyCreateDto{Name: "usage", Scopes: []string{model.ScopeUsageRead}})
+	if !assert.Equal(t, 0, res.Code, res.Msg) {
+		t.FailNow()
+	}
+	return user, claims, res.Data.(dto.ApiKeyCreatedDto)
+}
+
+func TestApiKeyCreate(t *testing.T) {
+	_, claims, created := createUsageKey(t, "apikey_create")
+	assert.True(t, strings.HasPrefix(created.Key, "fpk_"))
+
+	var stored model.ApiKey
+	global.DB.First(&stored, created.ID)
+	assert.NotContains(t, created.Key, stored.KeyHash, "only the hash is stored")
+
+	// 普通用户不能给自己授予用户管理权限
+	res := service.ApiKey.Create(claims, dto.ApiKeyCreateDto{Name: "billing", Scopes: []string{model.ScopeUserWrite}})
+	assert.NotEqual(t, 0, res.Code)
+}
+
+func TestApiKeyScopes(t *testing.T) {
+	call := apiKeyClient(t)
+	_, _, created := createUsageKey(t, "apikey_scopes")
+
+	assert.Equal(t, 0, call(created.Key, "/api/v1/user/package").Code)
+	assert.Contains(t, call(created.Key, "/api/v1/forward/create").Msg, model.ScopeForwardWrite)
+	assert.NotEqual(t, 0, call(created.Key, "/api/v1/user/sessions").Code)
+	assert.NotEqual(t, 0, call(created.Key, "/api/v1/api_key/create").Code)
+	assert.NotEqual(t, 0, call(created.Key+"x", "/api/v1/user/package").Code)
+
+	var stored model.ApiKey
+	global.DB.First(&stored, created.ID)
+	assert.NotZero(t, stored.LastUsedTime)
+}
+
+func TestApiKeyInvalidation(t *testing.T) {
+	call := apiKeyClient(t)
+	user, claims, created := createUsageKey(t, "apikey_invalid")
+	var stored model.ApiKey
+	global.DB.First(&stored, created.ID)
+
+	// 过期、用户停用、吊销后均不可用
+	global.DB.Model(&stored).Update("exp_time", time.Now().Add(-time.Minute).UnixMilli())
+	assert.NotEqual(t, 0, call(created.Key, "/api/v1/user/package").Code)
+	global.DB.Model(&stored).Update("exp_time", 0)
+	global.DB.Model(user).Update("status", 0)
+	assert.NotEqual(t, 0, call(created.Key, "/api/v1/user/package").Code)
+	global.DB.Model(user).Update("status", 1)
+	assert.Equal(t, 0, call(created.Key, "/api/v1/user/package").Code)
+	assert.Equal(t, 0, service.ApiKey.Delete(claims, created.ID).Code)
+	assert.NotEqual(t, 0, call(created.Key, "/api/v1/user/package").Code)
+}
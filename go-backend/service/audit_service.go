@@ -0,0 +1,96 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"go-backend/audit"
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/result"
+)
+
+type AuditService struct{}
+
+var Audit = new(AuditService)
+
+const (
+	// auditRetentionConfig 审计日志保留天数配置项，0 表示永久保留
+	auditRetentionConfig = "audit_retention_days"
+	auditRetentionDays   = 90
+	auditMaxPageSize     = 200
+)
+
+// List 按条件分页查询审计日志，按时间倒序
+func (s *AuditService) List(query dto.AuditQueryDto) *result.Result {
+	db := global.DB.Model(&model.AuditLog{})
+	if query.ActorId != nil {
+		db = db.Where("actor_id = ?", *query.ActorId)
+	}
+	if query.ActorName != "" {
+		db = db.Where("actor_name = ?", query.ActorName)
+	}
+	if query.ActorType != "" {
+		db = db.Where("actor_type = ?", query.ActorType)
+	}
+	if query.Action != "" {
+		db = db.Where("action LIKE ?", strings.ReplaceAll(query.Action, "%", "")+"%")
+	}
+	if query.TargetType != "" {
+		db = db.Where("target_type = ?", query.TargetType)
+	}
+	if query.TargetId != "" {
+		db = db.Where("target_id = ?", query.TargetId)
+	}
+	if query.Ip != "" {
+		db = db.Where("ip = ?", query.Ip)
+	}
+	if query.Result != nil {
+		if *query.Result == 0 {
+			db = db.Where("result = 0")
+		} else {
+			db = db.Where("result != 0")
+		}
+	}
+	if query.StartTime > 0 {
+		db = db.Where("created_time >= ?", query.StartTime)
+	}
+	if query.EndTime > 0 {
+		db = db.Where("created_time <= ?", query.EndTime)
+	}
+
+	page, size := query.Page, query.Size
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > auditMaxPageSize {
+		size = 50
+	}
+
+	var total int64
+	db.Count(&total)
+	var logs []model.AuditLog
+	db.Order("id desc").Offset((page - 1) * size).Limit(size).Find(&logs)
+
+	return result.Ok(map[string]interface{}{
+		"list":          logs,
+		"total":         total,
+		"retentionDays": s.RetentionDays(),
+	})
+}
+
+// RetentionDays 审计日志保留天数，未配置时默认 90 天
+func (s *AuditService) RetentionDays() int {
+	days, err := strconv.Atoi(ViteConfig.GetValue(auditRetentionConfig))
+	if err != nil || days < 0 {
+		return auditRetentionDays
+	}
+	return days
+}
+
+// Prune 清理超过保留期限的审计日志
+func (s *AuditService) Prune(now time.Time) {
+	audit.Prune(now, s.RetentionDays())
+}
@@ -0,0 +1,140 @@
+package service_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/result"
+	"go-backend/router"
+	"go-backend/service"
+	"go-backend/testutil"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// auditClient 通过完整路由发送请求，user 为 nil 时匿名访问
+func auditClient(t *testing.T) func(user *model.User, path string, body string) *result.Result {
+	gin.SetMode(gin.TestMode)
+	r := router.InitRouter()
+	return func(user *model.User, path string, body string) *result.Result {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		if user != nil {
+			token, _, err := service.Session.Issue(user, dto.ClientInfo{}, false)
+			assert.NoError(t, err)
+			req.Header.Set("Authorization", token)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var res result.Result
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		return &res
+	}
+}
+
+func latestAudit(action, targetId string) *model.AuditLog {
+	var entry model.AuditLog
+	if err := global.DB.Where("action = ? AND target_id = ?", action, targetId).Order("id desc").First(&entry).Error; err != nil {
+		return nil
+	}
+	return &entry
+}
+
+func TestAuditUserUpdate(t *testing.T) {
+	call := auditClient(t)
+	exp := time.Now().Add(time.Hour).UnixMilli()
+	admin := testutil.CreateUser("audit_admin", model.RoleSuperAdmin, 0, 0, exp)
+	customer := testutil.CreateUser("audit_customer", model.RoleUser, 1, 10, exp)
+
+	// 修改用户：记录操作者、前后差异，密码脱敏
+	body := fmt.Sprintf(`{"id":%d,"user":"audit_customer","pwd":"new-password-1","flow":20,"num":1,"expTime":%d}`, customer.ID, exp)
+	res := call(admin, "/api/v1/user/update", body)
+	assert.Equal(t, 0, res.Code, res.Msg)
+	entry := latestAudit("user.update", strconv.FormatInt(customer.ID, 10))
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, admin.ID, entry.ActorId)
+		assert.Equal(t, model.AuditActorUser, entry.ActorType)
+		assert.Equal(t, 0, entry.Result)
+		assert.Contains(t, entry.Changes, `"flow":{"after":20,"before":10}`)
+		assert.Contains(t, entry.Changes, `"pwd":{"after":"***","before":"***"}`)
+		assert.NotContains(t, entry.Request, "new-password-1")
+	}
+}
+
+func TestAuditDenied(t *testing.T) {
+	call := auditClient(t)
+	exp := time.Now().Add(time.Hour).UnixMilli()
+	support := testutil.CreateUser("audit_denied_support", model.RoleSupport, 0, 0, exp)
+	customer := testutil.CreateUser("audit_denied_customer", model.RoleUser, 1, 10, exp)
+
+	// 权限不足的操作同样记录失败结果
+	call(support, "/api/v1/user/delete", fmt.Sprintf(`{"id":%d}`, customer.ID))
+	entry := latestAudit("user.delete", strconv.FormatInt(customer.ID, 10))
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, support.ID, entry.ActorId)
+		assert.Equal(t, -1, entry.Result)
+		assert.Equal(t, "权限不足", entry.Message)
+	}
+}
+
+func TestAuditLoginFailure(t *testing.T) {
+	call := auditClient(t)
+	testutil.CreateUser("audit_login", model.RoleUser, 1, 10, time.Now().Add(time.Hour).UnixMilli())
+
+	// 登录失败记录用户名，不记录密码
+	call(nil, "/api/v1/user/login", `{"username":"audit_login","password":"wrong-password"}`)
+	var login model.AuditLog
+	global.DB.Where("action = ? AND actor_name = ?", "user.login", "audit_login").Order("id desc").First(&login)
+	assert.Equal(t, model.AuditActorAnonymous, login.ActorType)
+	assert.NotEqual(t, 0, login.Result)
+	assert.NotContains(t, login.Request, "wrong-password")
+}
+
+func TestAuditSystemExpiry(t *testing.T) {
+	// 自动操作：到期禁用
+	expired := testutil.CreateUser("audit_expired", model.RoleUser, 1, 10, time.Now().Add(-time.Hour).UnixMilli())
+	service.Task.CheckExpiry()
+	entry := latestAudit("user.expire", strconv.FormatInt(expired.ID, 10))
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, model.AuditActorSystem, entry.ActorType)
+	}
+}
+
+func TestAuditList(t *testing.T) {
+	call := auditClient(t)
+	exp := time.Now().Add(time.Hour).UnixMilli()
+	admin := testutil.CreateUser("audit_list_admin", model.RoleSuperAdmin, 0, 0, exp)
+	support := testutil.CreateUser("audit_list_support", model.RoleSupport, 0, 0, exp)
+	customer := testutil.CreateUser("audit_list_customer", model.RoleUser, 1, 10, exp)
+	call(admin, "/api/v1/user/update", fmt.Sprintf(`{"id":%d,"user":"audit_list_customer","flow":20,"num":1,"expTime":%d}`, customer.ID, exp))
+	call(support, "/api/v1/user/delete", fmt.Sprintf(`{"id":%d}`, customer.ID))
+
+	// 查询接口需要 audit:read，支持按对象和操作前缀过滤
+	assert.Equal(t, "权限不足", call(support, "/api/v1/audit/list", "{}").Msg)
+	res := call(admin, "/api/v1/audit/list", fmt.Sprintf(`{"action":"user.","targetType":"user","targetId":"%d"}`, customer.ID))
+	assert.Equal(t, 0, res.Code, res.Msg)
+	data := res.Data.(map[string]interface{})
+	assert.Equal(t, float64(2), data["total"])
+	assert.Equal(t, float64(90), data["retentionDays"])
+}
+
+func TestAuditPrune(t *testing.T) {
+	// 超过保留期限的日志被清理，保留天数为 0 时永久保留
+	old := &model.AuditLog{Action: "audit.probe", CreatedTime: time.Now().AddDate(0, 0, -100).UnixMilli()}
+	global.DB.Create(old)
+	service.ViteConfig.UpdateConfig("audit_retention_days", "0")
+	service.Audit.Prune(time.Now())
+	assert.NoError(t, global.DB.First(&model.AuditLog{}, old.ID).Error)
+	service.ViteConfig.UpdateConfig("audit_retention_days", "30")
+	service.Audit.Prune(time.Now())
+	assert.Error(t, global.DB.First(&model.AuditLog{}, old.ID).Error)
+}
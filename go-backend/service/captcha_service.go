@@ -0,0 +1,105 @@
+package service
+
+import (
+	"log"
+	"sync"
+
+	"go-backend/captcha"
+	"go-backend/model/dto"
+	"go-backend/result"
+)
+
+// 滑块组件（tianai-captcha 前端）以 code 200 判断校验成功，4001 为校验不通过
+const (
+	captchaCodeOk   = 200
+	captchaCodeFail = 4001
+)
+
+// CaptchaService 滑块验证码：生成挑战、校验轨迹并签发登录时使用的一次性凭证
+type CaptchaService struct {
+	BgDir    string
+	SlideDir string
+
+	once      sync.Once
+	mu        sync.Mutex // Generator 内部的随机数源不是并发安全的
+	generator *captcha.Generator
+	store     *captcha.Store
+}
+
+var Captcha = &CaptchaService{
+	BgDir:    "assets/captcha/bgimages",
+	SlideDir: "assets/captcha/slide",
+	store:    captcha.NewStore(),
+}
+
+func (s *CaptchaService) loadGenerator() *captcha.Generator {
+	s.once.Do(func() {
+		generator, err := captcha.NewGenerator(s.BgDir, s.SlideDir, 600)
+		if err != nil {
+			log.Printf("⚠️ 加载验证码素材失败: %v", err)
+			return
+		}
+		s.generator = generator
+	})
+	return s.generator
+}
+
+// Generate 生成滑块验证码，缺口位置只保存在服务端
+func (s *CaptchaService) Generate(ip string) interface{} {
+	if !s.store.AllowRequest(ip) {
+		return result.Err(-1, "验证码请求过于频繁，请稍后再试")
+	}
+	generator := s.loadGenerator()
+	if generator == nil {
+		return result.Err(-1, "验证码不可用")
+	}
+
+	s.mu.Lock()
+	slider, err := generator.GenerateSlider()
+	s.mu.Unlock()
+	if err != nil {
+		return result.Err(-1, "生成验证码失败")
+	}
+
+	id := captcha.NewId()
+	s.store.PutChallenge(id, slider.TargetX, slider.Width)
+	return dto.CaptchaGenerateDto{
+		ID: id,
+		Captcha: dto.CaptchaImageDto{
+			Type:                  "SLIDER",
+			BackgroundImage:       slider.BackgroundImageBase64,
+			TemplateImage:         slider.TemplateImageBase64,
+			BackgroundImageWidth:  slider.Width,
+			BackgroundImageHeight: slider.Height,
+			TemplateImageWidth:    slider.TemplateWidth,
+			TemplateImageHeight:   slider.TemplateHeight,
+		},
+	}
+}
+
+// Verify 校验滑动轨迹，挑战无论成败都只能使用一次；通过后返回登录凭证
+func (s *CaptchaService) Verify(ip string, verifyDto dto.CaptchaVerifyDto) *result.Result {
+	if s.store.Blocked(ip) {
+		return result.Err(-1, "验证失败次数过多，请稍后再试")
+	}
+
+	targetX, width, ok := s.store.TakeChallenge(verifyDto.ID)
+	if !ok || !captcha.VerifySlider(targetX, width, verifyDto.Data) {
+		s.store.RecordFailure(ip)
+		return result.Err(captchaCodeFail, "验证失败")
+	}
+
+	res := result.Ok(dto.CaptchaTokenDto{ValidToken: s.store.IssueToken()})
+	res.Code = captchaCodeOk
+	return res
+}
+
+// ConsumeToken 登录时消费校验凭证
+func (s *CaptchaService) ConsumeToken(token string) bool {
+	return s.store.ConsumeToken(token)
+}
+
+// Enabled 是否开启了登录验证码
+func (s *CaptchaService) Enabled() bool {
+	return ViteConfig.GetValue("captcha_enabled") == "true"
+}
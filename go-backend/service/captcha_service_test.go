@@ -0,0 +1,77 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"go-backend/captcha"
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/service"
+	"go-backend/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sliderTrack 在 300px 宽的背景上用一秒滑到 x
+func sliderTrack(x float64) captcha.Track {
+	start := time.Now()
+	return captcha.Track{
+		BgImageWidth: 300,
+		StartTime:    start,
+		StopTime:     start.Add(time.Second),
+		TrackList:    []captcha.TrackPoint{{X: 0}, {X: x / 2}, {X: x}},
+	}
+}
+
+func TestVerifySlider(t *testing.T) {
+	// 轨迹终点按前端显示宽度换算后与缺口位置比较
+	assert.True(t, captcha.VerifySlider(200, 600, sliderTrack(100)))
+	assert.True(t, captcha.VerifySlider(200, 600, sliderTrack(105)))
+	assert.False(t, captcha.VerifySlider(200, 600, sliderTrack(120)))
+}
+
+func TestCaptchaStoreSingleUse(t *testing.T) {
+	store := captcha.NewStore()
+	store.PutChallenge("c1", 200, 600)
+	_, _, ok := store.TakeChallenge("c1")
+	assert.True(t, ok)
+	_, _, ok = store.TakeChallenge("c1")
+	assert.False(t, ok, "挑战只能使用一次")
+
+	token := store.IssueToken()
+	assert.True(t, store.ConsumeToken(token))
+	assert.False(t, store.ConsumeToken(token), "凭证只能使用一次")
+}
+
+func TestCaptchaStoreBlocksFailures(t *testing.T) {
+	store := captcha.NewStore()
+	store.MaxFailures = 2
+	store.RecordFailure("1.2.3.4")
+	store.RecordFailure("1.2.3.4")
+	assert.True(t, store.Blocked("1.2.3.4"))
+	assert.False(t, store.AllowRequest("1.2.3.4"))
+	assert.True(t, store.AllowRequest("5.6.7.8"))
+}
+
+func TestCaptchaGenerate(t *testing.T) {
+	service.Captcha.BgDir = "../assets/captcha/bgimages"
+	service.Captcha.SlideDir = "../assets/captcha/slide"
+	generated, ok := service.Captcha.Generate("10.0.0.1").(dto.CaptchaGenerateDto)
+	if assert.True(t, ok) {
+		assert.Equal(t, "SLIDER", generated.Captcha.Type)
+		res := service.Captcha.Verify("10.0.0.1", dto.CaptchaVerifyDto{ID: generated.ID, Data: sliderTrack(-300)})
+		assert.NotEqual(t, 200, res.Code)
+	}
+}
+
+func TestLoginRequiresCaptcha(t *testing.T) {
+	// 开启验证码后，未通过校验的登录被拒绝
+	testutil.CreateUser("captcha_user", 1, 1, 100, time.Now().Add(time.Hour).UnixMilli())
+	cfg := model.ViteConfig{Name: "captcha_enabled", Value: "true"}
+	global.DB.Create(&cfg)
+	defer global.DB.Delete(&cfg)
+	res := service.User.Login(dto.LoginDto{Username: "captcha_user", Password: "123456", CaptchaId: "mock_token"}, dto.ClientInfo{})
+	assert.Equal(t, "验证码校验失败", res.Msg)
+}
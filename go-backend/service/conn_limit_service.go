@@ -0,0 +1,204 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/result"
+	"go-backend/utils"
+	"go-backend/websocket"
+)
+
+type ConnLimitService struct{}
+
+var ConnLimit = new(ConnLimitService)
+
+// CreateConnLimit 创建连接数限制规则，规则在分配给转发时才下发到节点
+func (s *ConnLimitService) CreateConnLimit(dto dto.ConnLimitDto) *result.Result {
+	var count int64
+	global.DB.Model(&model.ConnLimit{}).Where("name = ?", dto.Name).Count(&count)
+	if count > 0 {
+		return result.Err(-1, "连接限制规则名称已存在")
+	}
+
+	connLimit := model.ConnLimit{
+		Name:        dto.Name,
+		Conns:       dto.Conns,
+		IpConns:     dto.IpConns,
+		Rate:        dto.Rate,
+		IpRate:      dto.IpRate,
+		Status:      1,
+		CreatedTime: time.Now().UnixMilli(),
+		UpdatedTime: time.Now().UnixMilli(),
+	}
+	if err := global.DB.Create(&connLimit).Error; err != nil {
+		return result.Err(-1, "创建连接限制规则失败: "+err.Error())
+	}
+
+	return result.Ok("连接限制规则创建成功")
+}
+
+// GetAllConnLimits 获取所有连接数限制规则
+func (s *ConnLimitService) GetAllConnLimits() *result.Result {
+	var connLimits []model.ConnLimit
+	global.DB.Find(&connLimits)
+	return result.Ok(connLimits)
+}
+
+// UpdateConnLimit 更新连接数限制规则，并同步到正在使用该规则的节点
+func (s *ConnLimitService) UpdateConnLimit(updateDto dto.ConnLimitUpdateDto) *result.Result {
+	var connLimit model.ConnLimit
+	if err := global.DB.First(&connLimit, updateDto.ID).Error; err != nil {
+		return result.Err(-1, "连接限制规则不存在")
+	}
+
+	if updateDto.Name != connLimit.Name {
+		var count int64
+		global.DB.Model(&model.ConnLimit{}).Where("name = ? AND id != ?", updateDto.Name, updateDto.ID).Count(&count)
+		if count > 0 {
+			return result.Err(-1, "连接限制规则名称已存在")
+		}
+	}
+
+	connLimit.Name = updateDto.Name
+	connLimit.Conns = updateDto.Conns
+	connLimit.IpConns = updateDto.IpConns
+	connLimit.Rate = updateDto.Rate
+	connLimit.IpRate = updateDto.IpRate
+	connLimit.UpdatedTime = time.Now().UnixMilli()
+
+	if err := global.DB.Save(&connLimit).Error; err != nil {
+		return result.Err(-1, "更新连接限制规则失败")
+	}
+
+	// 离线节点重连时由快照补齐，这里只推送在线节点
+	for _, nodeId := range s.usedNodeIds(connLimit.ID) {
+		if !websocket.IsNodeOnline(nodeId) {
+			continue
+		}
+		if err := s.syncNode(nodeId, &connLimit); err != nil {
+			return result.Err(-1, err.Error())
+		}
+	}
+
+	return result.Ok("连接限制规则更新成功")
+}
+
+// DeleteConnLimit 删除连接数限制规则，仍被用户隧道或转发引用时拒绝删除
+func (s *ConnLimitService) DeleteConnLimit(id int64) *result.Result {
+	var connLimit model.ConnLimit
+	if err := global.DB.First(&connLimit, id).Error; err != nil {
+		return result.Err(-1, "连接限制规则不存在")
+	}
+
+	var count int64
+	global.DB.Model(&model.UserTunnel{}).Where("conn_limit_id = ?", id).Count(&count)
+	if count > 0 {
+		return result.Err(-1, "该连接限制规则还有用户在使用 请先取消分配")
+	}
+	global.DB.Model(&model.Forward{}).Where("conn_limit_id = ?", id).Count(&count)
+	if count > 0 {
+		return result.Err(-1, "该连接限制规则还有转发在使用 请先取消分配")
+	}
+
+	if err := global.DB.Delete(&connLimit).Error; err != nil {
+		return result.Err(-1, "删除连接限制规则失败")
+	}
+
+	// 规则已无引用，节点上的残留由对账清理
+	return result.Ok("连接限制规则删除成功")
+}
+
+// EnsureOnNodes 确保规则对应的 climiter / rlimiter 已存在于指定节点
+func (s *ConnLimitService) EnsureOnNodes(id int, nodeIds []int64) error {
+	var connLimit model.ConnLimit
+	if err := global.DB.First(&connLimit, id).Error; err != nil {
+		return fmt.Errorf("连接限制规则不存在")
+	}
+	for _, nodeId := range nodeIds {
+		if err := s.syncNode(nodeId, &connLimit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncNode 先更新，节点上不存在时再创建
+func (s *ConnLimitService) syncNode(nodeId int64, connLimit *model.ConnLimit) error {
+	kinds := []struct {
+		kind string
+		name string
+		data map[string]interface{}
+	}{
+		{utils.ConnLimiterKind, utils.BuildConnLimiterName(connLimit.ID), utils.BuildConnLimiterConfig(*connLimit)},
+		{utils.RateLimiterKind, utils.BuildRateLimiterName(connLimit.ID), utils.BuildRateLimiterConfig(*connLimit)},
+	}
+	for _, k := range kinds {
+		res := utils.UpdateKindLimiter(nodeId, k.kind, k.name, k.data)
+		if res.Msg != "OK" && strings.Contains(res.Msg, "not found") {
+			res = utils.AddKindLimiter(nodeId, k.kind, k.data)
+		}
+		if res.Msg != "OK" {
+			return fmt.Errorf("同步连接限制失败: %s", res.Msg)
+		}
+	}
+	return nil
+}
+
+// usedNodeIds 返回生效规则为 id 的转发所在的入口节点
+func (s *ConnLimitService) usedNodeIds(id int64) []int64 {
+	var forwards []model.Forward
+	global.DB.Find(&forwards)
+
+	seen := make(map[int64]bool)
+	var nodeIds []int64
+	tunnels := make(map[int64]*model.Tunnel)
+	for i := range forwards {
+		forward := &forwards[i]
+		var userTunnel model.UserTunnel
+		global.DB.Where("user_id = ? AND tunnel_id = ?", forward.UserId, forward.TunnelId).First(&userTunnel)
+		limit := effectiveConnLimit(forward, &userTunnel)
+		if limit == nil || int64(*limit) != id {
+			continue
+		}
+
+		tunnel, ok := tunnels[forward.TunnelId]
+		if !ok {
+			var t model.Tunnel
+			if err := global.DB.First(&t, forward.TunnelId).Error; err != nil {
+				continue
+			}
+			tunnel = &t
+			tunnels[forward.TunnelId] = tunnel
+		}
+		for _, nodeId := range tunnelEntryNodeIds(tunnel) {
+			if !seen[nodeId] {
+				seen[nodeId] = true
+				nodeIds = append(nodeIds, nodeId)
+			}
+		}
+	}
+	return nodeIds
+}
+
+// effectiveConnLimit 转发自身的规则优先，否则使用用户隧道的规则
+func effectiveConnLimit(forward *model.Forward, userTunnel *model.UserTunnel) *int {
+	if forward.ConnLimitId != 0 {
+		return &forward.ConnLimitId
+	}
+	if userTunnel != nil && userTunnel.ConnLimitId != 0 {
+		return &userTunnel.ConnLimitId
+	}
+	return nil
+}
+
+// connLimitExists 连接限制规则是否存在
+func connLimitExists(id int) bool {
+	var count int64
+	global.DB.Model(&model.ConnLimit{}).Where("id = ?", id).Count(&count)
+	return count > 0
+}
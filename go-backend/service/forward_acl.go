@@ -0,0 +1,110 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"go-backend/model"
+	"go-backend/utils"
+)
+
+// 单个访问控制列表最多允许的条目数
+const maxAclEntries = 200
+
+// normalizeCidrList 校验并规范化用户输入的 IP / CIDR 列表，支持逗号、空白、换行分隔，返回逗号分隔的结果
+func normalizeCidrList(raw string) (string, error) {
+	seen := make(map[string]bool)
+	var entries []string
+	for _, item := range strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	}) {
+		entry, err := normalizeCidr(item)
+		if err != nil {
+			return "", err
+		}
+		if seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		entries = append(entries, entry)
+	}
+	if len(entries) > maxAclEntries {
+		return "", fmt.Errorf("访问控制列表最多 %d 条", maxAclEntries)
+	}
+	return strings.Join(entries, ","), nil
+}
+
+func normalizeCidr(item string) (string, error) {
+	if ip := net.ParseIP(item); ip != nil {
+		return ip.String(), nil
+	}
+	if _, inet, err := net.ParseCIDR(item); err == nil {
+		return inet.String(), nil
+	}
+	return "", fmt.Errorf("无效的 IP 或 CIDR: %s", item)
+}
+
+func splitCidrs(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
+// forwardAcl 计算转发生效的访问控制：白名单以转发为准，未设置时使用隧道默认值；黑名单合并两者
+func forwardAcl(forward *model.Forward, tunnel *model.Tunnel) *utils.ServiceAcl {
+	allow := splitCidrs(forward.AllowCidrs)
+	if len(allow) == 0 {
+		allow = splitCidrs(tunnel.AllowCidrs)
+	}
+
+	seen := make(map[string]bool)
+	var deny []string
+	for _, entry := range append(splitCidrs(tunnel.DenyCidrs), splitCidrs(forward.DenyCidrs)...) {
+		if !seen[entry] {
+			seen[entry] = true
+			deny = append(deny, entry)
+		}
+	}
+
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil
+	}
+	return &utils.ServiceAcl{Allow: allow, Deny: deny}
+}
+
+// syncAdmissions 下发转发服务引用的 admission，先更新，节点上不存在时再创建
+// service 引用不存在的 admission 会拒绝所有连接，因此必须先于 service 下发
+func (s *ForwardService) syncAdmissions(nodeIds []int64, serviceName string, acl *utils.ServiceAcl) error {
+	for _, cfg := range acl.BuildAdmissionConfigs(serviceName) {
+		name := cfg["name"].(string)
+		for _, nodeId := range nodeIds {
+			res := utils.UpdateAdmissionConfig(nodeId, name, cfg)
+			if res.Msg != "OK" && strings.Contains(res.Msg, "not found") {
+				res = utils.AddAdmissionConfig(nodeId, cfg)
+			}
+			if res.Msg != "OK" {
+				return fmt.Errorf("访问控制下发失败: %s", res.Msg)
+			}
+		}
+	}
+	return nil
+}
+
+// removeAdmissions 删除 acl 中已不再使用的 admission，acl 为 nil 时全部删除；节点上不存在时忽略
+func (s *ForwardService) removeAdmissions(nodeIds []int64, serviceName string, acl *utils.ServiceAcl) {
+	keep := make(map[string]bool)
+	for _, cfg := range acl.BuildAdmissionConfigs(serviceName) {
+		keep[cfg["name"].(string)] = true
+	}
+	for _, kind := range []string{"allow", "deny"} {
+		name := utils.BuildAdmissionName(serviceName, kind)
+		if keep[name] {
+			continue
+		}
+		for _, nodeId := range nodeIds {
+			utils.DeleteAdmissionByName(nodeId, name)
+		}
+	}
+}
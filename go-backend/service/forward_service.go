@@ -2,12 +2,14 @@ package service
 
 import (
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
 	"go-backend/global"
 	"go-backend/model"
 	"go-backend/model/dto"
+	"go-backend/rbac"
 	"go-backend/result"
 	"go-backend/utils"
 )
@@ -43,7 +45,7 @@ func (s *ForwardService) CreateForward(dto dto.ForwardDto, ctxUser *utils.UserCl
 	var targetUserName string
 	var targetUserRole int
 
-	if ctxUser.RoleId == 0 && dto.UserId != nil {
+	if rbac.Has(ctxUser.RoleId, model.PermForwardWrite) && dto.UserId != nil {
 		// Admin creating for specific user
 		targetUserId = *dto.UserId
 		var targetUser model.User
@@ -109,6 +111,29 @@ func (s *ForwardService) CreateForward(dto dto.ForwardDto, ctxUser *utils.UserCl
 		// Target is Admin (or Admin creating for themselves) - No limits
 	}
 
+	// 连接数限制需要 limit:write 权限才能指定
+	connLimitId := 0
+	if rbac.Has(ctxUser.RoleId, model.PermLimitWrite) && dto.ConnLimitId != nil {
+		if *dto.ConnLimitId != 0 && !connLimitExists(*dto.ConnLimitId) {
+			return result.Err(-1, "连接限制规则不存在")
+		}
+		connLimitId = *dto.ConnLimitId
+	}
+
+	// 来源 IP 访问控制
+	var allowCidrs, denyCidrs string
+	var err error
+	if dto.AllowCidrs != nil {
+		if allowCidrs, err = normalizeCidrList(*dto.AllowCidrs); err != nil {
+			return result.Err(-1, "白名单: "+err.Error())
+		}
+	}
+	if dto.DenyCidrs != nil {
+		if denyCidrs, err = normalizeCidrList(*dto.DenyCidrs); err != nil {
+			return result.Err(-1, "黑名单: "+err.Error())
+		}
+	}
+
 	// 3. Allocate Port
 	portAlloc, err := s.allocatePorts(&tunnel, dto.InPort, nil)
 	if err != nil {
@@ -131,6 +156,9 @@ func (s *ForwardService) CreateForward(dto dto.ForwardDto, ctxUser *utils.UserCl
 		RemoteAddr:    dto.RemoteAddr,
 		InterfaceName: dto.InterfaceName,
 		Strategy:      dto.Strategy,
+		ConnLimitId:   connLimitId,
+		AllowCidrs:    allowCidrs,
+		DenyCidrs:     denyCidrs,
 		Status:        1,
 		CreatedTime:   time.Now().UnixMilli(),
 		UpdatedTime:   time.Now().UnixMilli(),
@@ -149,6 +177,7 @@ func (s *ForwardService) CreateForward(dto dto.ForwardDto, ctxUser *utils.UserCl
 		}
 	}
 
+	Webhook.Emit(model.EventForwardCreated, forwardEventData(&forward))
 	return result.Ok("端口转发创建成功")
 }
 
@@ -159,7 +188,7 @@ func (s *ForwardService) UpdateForward(id int64, dto dto.ForwardDto, ctxUser *ut
 	}
 
 	// Permission Check
-	if ctxUser.RoleId != 0 && forward.UserId != ctxUser.GetUserId() {
+	if !rbac.Has(ctxUser.RoleId, model.PermForwardWrite) && forward.UserId != ctxUser.GetUserId() {
 		return result.Err(-1, "无权修改此转发")
 	}
 
@@ -175,7 +204,7 @@ func (s *ForwardService) UpdateForward(id int64, dto dto.ForwardDto, ctxUser *ut
 	tunnelChanged := forward.TunnelId != dto.TunnelId
 
 	// 权限检查：普通用户需要验证自己是否有新隧道的权限
-	if ctxUser.RoleId != 0 {
+	if !rbac.Has(ctxUser.RoleId, model.PermForwardWrite) {
 		var ut model.UserTunnel
 		if err := global.DB.Where("user_id = ? AND tunnel_id = ?", ctxUser.GetUserId(), dto.TunnelId).First(&ut).Error; err != nil {
 			return result.Err(-1, "你没有该隧道权限")
@@ -221,6 +250,22 @@ func (s *ForwardService) UpdateForward(id int64, dto dto.ForwardDto, ctxUser *ut
 	updatedForward.RemoteAddr = dto.RemoteAddr
 	updatedForward.InterfaceName = dto.InterfaceName
 	updatedForward.Strategy = dto.Strategy
+	if rbac.Has(ctxUser.RoleId, model.PermLimitWrite) && dto.ConnLimitId != nil {
+		if *dto.ConnLimitId != 0 && !connLimitExists(*dto.ConnLimitId) {
+			return result.Err(-1, "连接限制规则不存在")
+		}
+		updatedForward.ConnLimitId = *dto.ConnLimitId
+	}
+	if dto.AllowCidrs != nil {
+		if updatedForward.AllowCidrs, err = normalizeCidrList(*dto.AllowCidrs); err != nil {
+			return result.Err(-1, "白名单: "+err.Error())
+		}
+	}
+	if dto.DenyCidrs != nil {
+		if updatedForward.DenyCidrs, err = normalizeCidrList(*dto.DenyCidrs); err != nil {
+			return result.Err(-1, "黑名单: "+err.Error())
+		}
+	}
 	updatedForward.UpdatedTime = time.Now().UnixMilli()
 	updatedForward.Status = 1
 
@@ -232,8 +277,8 @@ func (s *ForwardService) UpdateForward(id int64, dto dto.ForwardDto, ctxUser *ut
 		var oldUT model.UserTunnel
 		global.DB.Where("user_id = ? AND tunnel_id = ?", forward.UserId, oldTunnel.ID).First(&oldUT)
 
-		if oldTunnel.InNodeId == tunnel.InNodeId {
-			// 入口节点相同：必须先删后创（否则监听同一端口会冲突）
+		if sharesEntryNode(&oldTunnel, &tunnel) {
+			// 入口节点有重叠：必须先删后创（否则监听同一端口会冲突）
 			// 风险：如果新服务创建失败，需要尝试恢复旧服务
 			s.deleteGostServices(&forward, &oldTunnel, &oldUT)
 
@@ -245,6 +290,9 @@ func (s *ForwardService) UpdateForward(id int64, dto dto.ForwardDto, ctxUser *ut
 				}
 				restoreErr := s.createGostServices(&forward, &oldTunnel, oldLimiter, &oldUT)
 				if restoreErr != nil {
+					// 节点上已没有该转发的服务，标记为暂停以便用户看到原因
+					forward.AddPauseReason(model.PauseNodeError)
+					global.DB.Model(&forward).Updates(map[string]interface{}{"status": 0, "pause_reasons": forward.PauseReasons})
 					return result.Err(-1, "新服务创建失败且无法恢复旧服务: "+err.Error()+"; 恢复错误: "+restoreErr.Error())
 				}
 				return result.Err(-1, "新服务创建失败(已恢复旧服务): "+err.Error())
@@ -266,7 +314,7 @@ func (s *ForwardService) UpdateForward(id int64, dto dto.ForwardDto, ctxUser *ut
 
 	// Save to DB
 	// Use map to update specific fields to avoid zero values if any
-	global.DB.Model(&forward).Updates(map[string]interface{}{
+	updates := map[string]interface{}{
 		"name":           updatedForward.Name,
 		"tunnel_id":      updatedForward.TunnelId,
 		"in_port":        updatedForward.InPort,
@@ -274,8 +322,19 @@ func (s *ForwardService) UpdateForward(id int64, dto dto.ForwardDto, ctxUser *ut
 		"remote_addr":    updatedForward.RemoteAddr,
 		"interface_name": updatedForward.InterfaceName,
 		"strategy":       updatedForward.Strategy,
+		"conn_limit_id":  updatedForward.ConnLimitId,
+		"allow_cidrs":    updatedForward.AllowCidrs,
+		"deny_cidrs":     updatedForward.DenyCidrs,
 		"updated_time":   updatedForward.UpdatedTime,
-	})
+	}
+	// 服务已重新创建，清除节点错误
+	if forward.HasPauseReason(model.PauseNodeError) {
+		if forward.ClearPauseReasons(model.PauseNodeError) {
+			updates["status"] = 1
+		}
+		updates["pause_reasons"] = forward.PauseReasons
+	}
+	global.DB.Model(&forward).Updates(updates)
 
 	return result.Ok("端口转发更新成功")
 }
@@ -287,7 +346,7 @@ func (s *ForwardService) DeleteForward(id int64, ctxUser *utils.UserClaims) *res
 	}
 
 	// Permission Check
-	if ctxUser.RoleId != 0 && forward.UserId != ctxUser.GetUserId() {
+	if !rbac.Has(ctxUser.RoleId, model.PermForwardWrite) && forward.UserId != ctxUser.GetUserId() {
 		return result.Err(-1, "无权删除此转发")
 	}
 
@@ -295,6 +354,7 @@ func (s *ForwardService) DeleteForward(id int64, ctxUser *utils.UserClaims) *res
 	if err := global.DB.First(&tunnel, forward.TunnelId).Error; err != nil {
 		// If tunnel deleted, still delete forward from DB but skip Gost
 		global.DB.Delete(&forward)
+		Webhook.Emit(model.EventForwardDeleted, forwardEventData(&forward))
 		return result.Ok("转发已删除")
 	}
 
@@ -308,12 +368,13 @@ func (s *ForwardService) DeleteForward(id int64, ctxUser *utils.UserClaims) *res
 	}
 
 	global.DB.Delete(&forward)
+	Webhook.Emit(model.EventForwardDeleted, forwardEventData(&forward))
 	return result.Ok("删除成功")
 }
 func (s *ForwardService) GetAllForwards(ctxUser *utils.UserClaims) *result.Result {
 	var forwards []model.Forward
 	tx := global.DB.Model(&model.Forward{})
-	if ctxUser.RoleId != 0 {
+	if !rbac.Has(ctxUser.RoleId, model.PermForwardRead) {
 		tx = tx.Where("user_id = ?", ctxUser.GetUserId())
 	}
 	tx.Find(&forwards)
@@ -347,6 +408,10 @@ func (s *ForwardService) GetAllForwards(ctxUser *utils.UserClaims) *result.Resul
 			Strategy:      f.Strategy,
 			Inx:           f.Inx,
 			InterfaceName: f.InterfaceName,
+			ConnLimitId:   f.ConnLimitId,
+			AllowCidrs:    f.AllowCidrs,
+			DenyCidrs:     f.DenyCidrs,
+			PauseReasons:  f.PauseReasonList(),
 		}
 		response = append(response, resDto)
 	}
@@ -358,8 +423,7 @@ func (s *ForwardService) GetAllForwards(ctxUser *utils.UserClaims) *result.Resul
 
 func (s *ForwardService) createGostServices(forward *model.Forward, tunnel *model.Tunnel, limiter *int, userTunnel *model.UserTunnel) error {
 	serviceName := s.buildServiceName(forward.ID, forward.UserId, userTunnel)
-	inNode, _, err := s.getRequiredNodes(tunnel)
-	if err != nil {
+	if _, _, err := s.getRequiredNodes(tunnel); err != nil {
 		return err
 	}
 
@@ -371,16 +435,33 @@ func (s *ForwardService) createGostServices(forward *model.Forward, tunnel *mode
 		interfaceName = forward.InterfaceName
 	}
 
-	if res := utils.AddService(inNode.ID, serviceName, forward.InPort, limiter, forward.RemoteAddr, tunnel.Type, *tunnel, forward.Strategy, interfaceName); res.Msg != "OK" {
-		return fmt.Errorf("Service Error: " + res.Msg)
+	// 转发在入口节点组的每个节点上发布，任一节点失败则回滚已创建的服务
+	nodeIds := tunnelEntryNodeIds(tunnel)
+	connLimit := effectiveConnLimit(forward, userTunnel)
+	if connLimit != nil {
+		if err := ConnLimit.EnsureOnNodes(*connLimit, nodeIds); err != nil {
+			return err
+		}
+	}
+	acl := forwardAcl(forward, tunnel)
+	if err := s.syncAdmissions(nodeIds, serviceName, acl); err != nil {
+		return err
+	}
+	for i, nodeId := range nodeIds {
+		if res := utils.AddService(nodeId, serviceName, forward.InPort, limiter, connLimit, acl, forward.RemoteAddr, tunnel.Type, *tunnel, forward.Strategy, interfaceName); res.Msg != "OK" {
+			for _, created := range nodeIds[:i] {
+				utils.DeleteService(created, serviceName)
+			}
+			s.removeAdmissions(nodeIds, serviceName, nil)
+			return fmt.Errorf("Service Error: %s", res.Msg)
+		}
 	}
 	return nil
 }
 
 func (s *ForwardService) updateGostServices(forward *model.Forward, tunnel *model.Tunnel, limiter *int, userTunnel *model.UserTunnel) error {
 	serviceName := s.buildServiceName(forward.ID, forward.UserId, userTunnel)
-	inNode, _, err := s.getRequiredNodes(tunnel)
-	if err != nil {
+	if _, _, err := s.getRequiredNodes(tunnel); err != nil {
 		return err
 	}
 
@@ -392,31 +473,48 @@ func (s *ForwardService) updateGostServices(forward *model.Forward, tunnel *mode
 		interfaceName = forward.InterfaceName
 	}
 
-	res := utils.UpdateService(inNode.ID, serviceName, forward.InPort, limiter, forward.RemoteAddr, tunnel.Type, *tunnel, forward.Strategy, interfaceName)
-	if res.Msg != "OK" {
-		if strings.Contains(res.Msg, "not found") {
-			utils.AddService(inNode.ID, serviceName, forward.InPort, limiter, forward.RemoteAddr, tunnel.Type, *tunnel, forward.Strategy, interfaceName)
-		} else {
-			return fmt.Errorf("Update Service Error: " + res.Msg)
+	nodeIds := tunnelEntryNodeIds(tunnel)
+	connLimit := effectiveConnLimit(forward, userTunnel)
+	if connLimit != nil {
+		if err := ConnLimit.EnsureOnNodes(*connLimit, nodeIds); err != nil {
+			return err
+		}
+	}
+	acl := forwardAcl(forward, tunnel)
+	if err := s.syncAdmissions(nodeIds, serviceName, acl); err != nil {
+		return err
+	}
+	for _, nodeId := range nodeIds {
+		res := utils.UpdateService(nodeId, serviceName, forward.InPort, limiter, connLimit, acl, forward.RemoteAddr, tunnel.Type, *tunnel, forward.Strategy, interfaceName)
+		if res.Msg != "OK" {
+			if strings.Contains(res.Msg, "not found") {
+				utils.AddService(nodeId, serviceName, forward.InPort, limiter, connLimit, acl, forward.RemoteAddr, tunnel.Type, *tunnel, forward.Strategy, interfaceName)
+			} else {
+				return fmt.Errorf("Update Service Error: %s", res.Msg)
+			}
 		}
 	}
+	// 服务已不再引用的 admission 在更新后清理
+	s.removeAdmissions(nodeIds, serviceName, acl)
 	return nil
 }
 
 func (s *ForwardService) deleteGostServices(forward *model.Forward, tunnel *model.Tunnel, userTunnel *model.UserTunnel) error {
 	serviceName := s.buildServiceName(forward.ID, forward.UserId, userTunnel)
-	inNode, _, _ := s.getRequiredNodes(tunnel)
 
-	// 只删除入口节点的 service
+	// 只删除入口节点组的 service
 	// Type 2 的共享 chain 和 relay service 由 tunnel 删除时清理
-	if inNode != nil {
-		res := utils.DeleteService(inNode.ID, serviceName)
-		if res.Msg != "OK" {
-			return fmt.Errorf(res.Msg)
+	var lastErr error
+	nodeIds := tunnelEntryNodeIds(tunnel)
+	for _, nodeId := range nodeIds {
+		if res := utils.DeleteService(nodeId, serviceName); res.Msg != "OK" {
+			lastErr = fmt.Errorf("%s", res.Msg)
 		}
 	}
-
-	return nil
+	if forwardAcl(forward, tunnel) != nil {
+		s.removeAdmissions(nodeIds, serviceName, nil)
+	}
+	return lastErr
 }
 
 // --- Helpers ---
@@ -428,14 +526,18 @@ type PortAllocResult struct {
 
 func (s *ForwardService) allocatePorts(tunnel *model.Tunnel, specifiedInPort *int, excludeForwardId *int64) (*PortAllocResult, error) {
 	// Allocate InPort
+	// 入口端口需要在入口节点组的每个节点上都可用
+	nodeIds := tunnelEntryNodeIds(tunnel)
 	var inPort int
 	if specifiedInPort != nil {
-		if err := s.checkPortAvailable(tunnel.InNodeId, *specifiedInPort, excludeForwardId); err != nil {
-			return nil, err
+		for _, nodeId := range nodeIds {
+			if err := s.checkPortAvailable(nodeId, *specifiedInPort, excludeForwardId); err != nil {
+				return nil, err
+			}
 		}
 		inPort = *specifiedInPort
 	} else {
-		p, err := s.findFreePort(tunnel.InNodeId, excludeForwardId)
+		p, err := s.findFreePort(nodeIds, excludeForwardId)
 		if err != nil {
 			return nil, fmt.Errorf("入口节点无可用端口")
 		}
@@ -475,20 +577,43 @@ func (s *ForwardService) checkPortAvailable(nodeId int64, port int, excludeForwa
 	return nil
 }
 
-func (s *ForwardService) findFreePort(nodeId int64, excludeForwardId *int64) (int, error) {
-	var node model.Node
-	if err := global.DB.First(&node, nodeId).Error; err != nil {
-		return 0, err
-	}
-	// 解析端口范围
-	ranges, err := utils.ParsePortRanges(node.PortRanges)
-	if err != nil {
-		return 0, fmt.Errorf("节点端口配置错误: %s", err.Error())
+// findFreePort 查找在所有节点上都空闲的端口
+func (s *ForwardService) findFreePort(nodeIds []int64, excludeForwardId *int64) (int, error) {
+	var candidates []int
+	used := make(map[int]bool)
+	allowed := make([][]utils.PortRange, 0, len(nodeIds))
+	for i, nodeId := range nodeIds {
+		var node model.Node
+		if err := global.DB.First(&node, nodeId).Error; err != nil {
+			return 0, err
+		}
+		// 解析端口范围
+		ranges, err := utils.ParsePortRanges(node.PortRanges)
+		if err != nil {
+			return 0, fmt.Errorf("节点端口配置错误: %s", err.Error())
+		}
+		if i == 0 {
+			candidates = utils.GetAllPorts(ranges)
+		} else {
+			allowed = append(allowed, ranges)
+		}
+		for p := range s.getUsedPorts(nodeId, excludeForwardId) {
+			used[p] = true
+		}
 	}
-	allPorts := utils.GetAllPorts(ranges)
-	used := s.getUsedPorts(nodeId, excludeForwardId)
-	for _, p := range allPorts {
-		if !used[p] {
+
+	for _, p := range candidates {
+		if used[p] {
+			continue
+		}
+		available := true
+		for _, ranges := range allowed {
+			if !utils.IsPortInRanges(p, ranges) {
+				available = false
+				break
+			}
+		}
+		if available {
 			return p, nil
 		}
 	}
@@ -500,6 +625,9 @@ func (s *ForwardService) getUsedPorts(nodeId int64, excludeForwardId *int64) map
 	// 1. InTunnels -> Forwards (InPort)
 	var inTunnels []int64
 	global.DB.Model(&model.Tunnel{}).Where("in_node_id = ?", nodeId).Pluck("id", &inTunnels)
+	var groupTunnels []int64
+	global.DB.Model(&model.TunnelNode{}).Where("node_id = ? AND role = ?", nodeId, model.TunnelNodeEntry).Pluck("tunnel_id", &groupTunnels)
+	inTunnels = append(inTunnels, groupTunnels...)
 	if len(inTunnels) > 0 {
 		var forwards []model.Forward
 		query := global.DB.Where("tunnel_id IN ?", inTunnels)
@@ -538,6 +666,11 @@ func (s *ForwardService) getUsedPorts(nodeId int64, excludeForwardId *int64) map
 		}
 	}
 
+	// 4. 作为中转节点或附加出口节点的 relay 端口
+	for p := range getUsedRelayPorts(nodeId, nil) {
+		used[p] = true
+	}
+
 	return used
 }
 
@@ -562,6 +695,17 @@ func (s *ForwardService) getRequiredNodes(tunnel *model.Tunnel) (*model.Node, *m
 	return &inNode, outNode, nil
 }
 
+// sharesEntryNode 两个隧道的入口节点组是否有重叠
+func sharesEntryNode(a, b *model.Tunnel) bool {
+	bIds := tunnelEntryNodeIds(b)
+	for _, id := range tunnelEntryNodeIds(a) {
+		if containsNodeId(bIds, id) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *ForwardService) buildServiceName(forwardId int64, userId int64, userTunnel *model.UserTunnel) string {
 	utId := int64(0)
 	if userTunnel != nil {
@@ -626,7 +770,7 @@ func (s *ForwardService) UpdateForwardOrder(params map[string]interface{}, ctxUs
 
 	var forwards []model.Forward
 	tx := global.DB.Where("id IN ?", ids)
-	if ctxUser.RoleId != 0 {
+	if !rbac.Has(ctxUser.RoleId, model.PermForwardWrite) {
 		tx = tx.Where("user_id = ?", ctxUser.GetUserId())
 	}
 	tx.Find(&forwards)
@@ -654,10 +798,21 @@ func (s *ForwardService) PauseForward(id int64, ctxUser *utils.UserClaims) *resu
 	}
 
 	// Permission Check
-	if ctxUser.RoleId != 0 && forward.UserId != ctxUser.GetUserId() {
+	if !rbac.Has(ctxUser.RoleId, model.PermForwardWrite) && forward.UserId != ctxUser.GetUserId() {
 		return result.Err(-1, "无权暂停此转发")
 	}
 
+	// 管理员暂停他人的转发，用户不能自行恢复
+	reason := model.PauseManual
+	if forward.UserId != ctxUser.GetUserId() {
+		reason = model.PauseAdmin
+	}
+	if forward.Status != 1 {
+		forward.AddPauseReason(reason)
+		global.DB.Model(&forward).Update("pause_reasons", forward.PauseReasons)
+		return result.Ok("服务已暂停")
+	}
+
 	var tunnel model.Tunnel
 	if err := global.DB.First(&tunnel, forward.TunnelId).Error; err != nil {
 		return result.Err(-1, "隧道不存在")
@@ -669,8 +824,12 @@ func (s *ForwardService) PauseForward(id int64, ctxUser *utils.UserClaims) *resu
 	serviceName := s.buildServiceName(forward.ID, forward.UserId, &userTunnel)
 
 	// 暂停入口服务（Type 1 和 Type 2 都需要）
-	if res := utils.PauseService(tunnel.InNodeId, serviceName); res.Msg != "OK" {
-		return result.Err(-1, "暂停服务失败: "+res.Msg)
+	if !s.SkipGostSync {
+		for _, nodeId := range tunnelEntryNodeIds(&tunnel) {
+			if res := utils.PauseService(nodeId, serviceName); res.Msg != "OK" {
+				return result.Err(-1, "暂停服务失败: "+res.Msg)
+			}
+		}
 	}
 
 	// Type 2 隧道不再需要暂停远程服务
@@ -678,12 +837,50 @@ func (s *ForwardService) PauseForward(id int64, ctxUser *utils.UserClaims) *resu
 
 	// 更新状态
 	forward.Status = 0
+	forward.AddPauseReason(reason)
 	forward.UpdatedTime = time.Now().UnixMilli()
 	global.DB.Save(&forward)
 
+	Webhook.Emit(model.EventForwardPaused, forwardEventData(&forward))
 	return result.Ok("服务已暂停")
 }
 
+// AutoPauseForward 系统自动暂停转发（流量超限、到期等），节点离线时暂停命令进入持久化队列；
+// 已暂停的转发只追加原因。返回是否新暂停了该转发
+func (s *ForwardService) AutoPauseForward(forward *model.Forward, reason string) bool {
+	if forward.Status != 1 {
+		if forward.AddPauseReason(reason) {
+			global.DB.Model(forward).Update("pause_reasons", forward.PauseReasons)
+		}
+		return false
+	}
+	forward.AddPauseReason(reason)
+
+	var tunnel model.Tunnel
+	if err := global.DB.First(&tunnel, forward.TunnelId).Error; err != nil {
+		global.DB.Model(forward).Update("pause_reasons", forward.PauseReasons)
+		return false
+	}
+
+	var userTunnel model.UserTunnel
+	global.DB.Where("user_id = ? AND tunnel_id = ?", forward.UserId, tunnel.ID).First(&userTunnel)
+
+	serviceName := s.buildServiceName(forward.ID, forward.UserId, &userTunnel)
+	if !s.SkipGostSync {
+		for _, nodeId := range tunnelEntryNodeIds(&tunnel) {
+			if res := utils.PauseService(nodeId, serviceName); res.Msg != "OK" {
+				log.Printf("⚠️ 暂停转发 %d 失败: %s", forward.ID, res.Msg)
+			}
+		}
+	}
+
+	forward.Status = 0
+	forward.UpdatedTime = time.Now().UnixMilli()
+	global.DB.Save(forward)
+	Webhook.Emit(model.EventForwardPaused, forwardEventData(forward))
+	return true
+}
+
 func (s *ForwardService) ResumeForward(id int64, ctxUser *utils.UserClaims) *result.Result {
 	var forward model.Forward
 	if err := global.DB.First(&forward, id).Error; err != nil {
@@ -691,9 +888,12 @@ func (s *ForwardService) ResumeForward(id int64, ctxUser *utils.UserClaims) *res
 	}
 
 	// Permission Check
-	if ctxUser.RoleId != 0 && forward.UserId != ctxUser.GetUserId() {
+	if !rbac.Has(ctxUser.RoleId, model.PermForwardWrite) && forward.UserId != ctxUser.GetUserId() {
 		return result.Err(-1, "无权恢复此转发")
 	}
+	if !rbac.Has(ctxUser.RoleId, model.PermForwardWrite) && forward.HasPauseReason(model.PauseAdmin) {
+		return result.Err(-1, "转发已被管理员暂停，请联系管理员")
+	}
 
 	var tunnel model.Tunnel
 	if err := global.DB.First(&tunnel, forward.TunnelId).Error; err != nil {
@@ -706,7 +906,7 @@ func (s *ForwardService) ResumeForward(id int64, ctxUser *utils.UserClaims) *res
 	}
 
 	// 普通用户需要检查流量和账户状态
-	if ctxUser.RoleId != 0 {
+	if !rbac.Has(ctxUser.RoleId, model.PermForwardWrite) {
 		var user model.User
 		global.DB.First(&user, ctxUser.GetUserId())
 
@@ -743,18 +943,24 @@ func (s *ForwardService) ResumeForward(id int64, ctxUser *utils.UserClaims) *res
 	serviceName := s.buildServiceName(forward.ID, forward.UserId, &userTunnel)
 
 	// 恢复入口服务（Type 1 和 Type 2 都需要）
-	if res := utils.ResumeService(tunnel.InNodeId, serviceName); res.Msg != "OK" {
-		return result.Err(-1, "恢复服务失败: "+res.Msg)
+	if !s.SkipGostSync {
+		for _, nodeId := range tunnelEntryNodeIds(&tunnel) {
+			if res := utils.ResumeService(nodeId, serviceName); res.Msg != "OK" {
+				return result.Err(-1, "恢复服务失败: "+res.Msg)
+			}
+		}
 	}
 
 	// Type 2 隧道不再需要恢复远程服务
 	// 共享的 relay service 由 tunnel 管理
 
-	// 更新状态
+	// 更新状态，手动恢复前已校验额度，清除全部暂停原因
 	forward.Status = 1
+	forward.PauseReasons = ""
 	forward.UpdatedTime = time.Now().UnixMilli()
 	global.DB.Save(&forward)
 
+	Webhook.Emit(model.EventForwardResumed, forwardEventData(&forward))
 	return result.Ok("服务已恢复")
 }
 
@@ -765,12 +971,13 @@ func (s *ForwardService) ForceDeleteForward(id int64, ctxUser *utils.UserClaims)
 	}
 
 	// Permission Check
-	if ctxUser.RoleId != 0 && forward.UserId != ctxUser.GetUserId() {
+	if !rbac.Has(ctxUser.RoleId, model.PermForwardWrite) && forward.UserId != ctxUser.GetUserId() {
 		return result.Err(-1, "无权删除此转发")
 	}
 
 	// 直接删除，跳过 Gost 服务删除
 	global.DB.Delete(&forward)
+	Webhook.Emit(model.EventForwardDeleted, forwardEventData(&forward))
 	return result.Ok("强制删除成功")
 }
 
@@ -780,7 +987,7 @@ func (s *ForwardService) DiagnoseForward(id int64, ctxUser *utils.UserClaims) *r
 		return result.Err(-1, "转发不存在")
 	}
 
-	if ctxUser.RoleId != 0 && forward.UserId != ctxUser.GetUserId() {
+	if !rbac.Has(ctxUser.RoleId, model.PermForwardRead) && forward.UserId != ctxUser.GetUserId() {
 		return result.Err(-1, "无权访问此转发")
 	}
 
@@ -789,39 +996,31 @@ func (s *ForwardService) DiagnoseForward(id int64, ctxUser *utils.UserClaims) *r
 		return result.Err(-1, "隧道不存在")
 	}
 
-	inNode, outNode, err := s.getRequiredNodes(&tunnel)
-	if err != nil {
+	if _, _, err := s.getRequiredNodes(&tunnel); err != nil {
 		return result.Err(-1, err.Error())
 	}
 
 	results := []map[string]interface{}{}
 	remoteAddrs := strings.Split(forward.RemoteAddr, ",")
 
-	if tunnel.Type == 1 {
-		// Port Forward: InNode performs TCP Ping to Targets
-		for _, addr := range remoteAddrs {
-			targetIp := utils.ExtractIp(addr)
-			targetPort := utils.ExtractPort(addr)
-			if targetIp == "" || targetPort == -1 {
-				continue
-			}
-			res := Tunnel.PerformTcpPing(inNode, targetIp, targetPort, "转发->目标")
-			results = append(results, res)
-		}
-	} else {
-		// Tunnel Forward: InNode -> OutNode, OutNode -> Targets
-		// In -> Out
-		resIn := Tunnel.PerformTcpPing(inNode, outNode.ServerIp, tunnel.OutPort, "入口->出口")
-		results = append(results, resIn)
+	// 最后一段由入口节点组（端口转发）或出口节点组（隧道转发）检测到目标
+	targetNodes := loadNodes(tunnelEntryNodeIds(&tunnel))
+	targetDesc := "转发->目标"
+	if tunnel.Type == 2 {
+		// Tunnel Forward: InNode -> (中转...) -> OutNode, OutNode -> Targets
+		results = append(results, Tunnel.DiagnoseTunnelLegs(&tunnel)...)
+		targetNodes = loadNodes(tunnelExitNodeIds(&tunnel))
+		targetDesc = "出口->目标"
+	}
 
-		// Out -> Targets
+	for i := range targetNodes {
 		for _, addr := range remoteAddrs {
 			targetIp := utils.ExtractIp(addr)
 			targetPort := utils.ExtractPort(addr)
 			if targetIp == "" || targetPort == -1 {
 				continue
 			}
-			res := Tunnel.PerformTcpPing(outNode, targetIp, targetPort, "出口->目标")
+			res := Tunnel.PerformTcpPing(&targetNodes[i], targetIp, targetPort, targetDesc)
 			results = append(results, res)
 		}
 	}
@@ -838,3 +1037,18 @@ func (s *ForwardService) DiagnoseForward(id int64, ctxUser *utils.UserClaims) *r
 	}
 	return result.Ok(report)
 }
+
+// forwardEventData 转发事件的 Webhook 数据
+func forwardEventData(forward *model.Forward) map[string]interface{} {
+	return map[string]interface{}{
+		"forwardId":    forward.ID,
+		"name":         forward.Name,
+		"userId":       forward.UserId,
+		"userName":     forward.UserName,
+		"tunnelId":     forward.TunnelId,
+		"inPort":       forward.InPort,
+		"remoteAddr":   forward.RemoteAddr,
+		"status":       forward.Status,
+		"pauseReasons": forward.PauseReasonList(),
+	}
+}
@@ -0,0 +1,85 @@
+package service_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/service"
+	"go-backend/testutil"
+	"go-backend/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// createPauseForwards 创建用户及其同一隧道下的两个运行中转发
+func createPauseForwards(prefix string, port int) (*model.User, *utils.UserClaims, *model.Forward, *model.Forward) {
+	service.Forward.SkipGostSync = true
+	exp := time.Now().Add(24 * time.Hour).UnixMilli()
+	tunnel := testutil.CreateTunnel(prefix + "_tunnel")
+	user := testutil.CreateUser(prefix+"_user", model.RoleUser, 5, 10, exp)
+	global.DB.Create(&model.UserTunnel{UserId: int(user.ID), TunnelId: int(tunnel.ID), ExpTime: exp, Status: 1})
+	owner := &utils.UserClaims{RoleId: model.RoleUser, RegisteredClaims: jwt.RegisteredClaims{Subject: strconv.FormatInt(user.ID, 10)}}
+	first := &model.Forward{UserId: user.ID, Name: prefix + "_a", TunnelId: tunnel.ID, InPort: port, Status: 1}
+	second := &model.Forward{UserId: user.ID, Name: prefix + "_b", TunnelId: tunnel.ID, InPort: port + 1, Status: 1}
+	global.DB.Create(first)
+	global.DB.Create(second)
+	return user, owner, first, second
+}
+
+func TestForwardPauseReasons(t *testing.T) {
+	_, owner, manual, quota := createPauseForwards("pr_reasons", 33001)
+
+	// 用户手动暂停后又因流量超限被系统暂停，两个原因都保留
+	assert.Equal(t, 0, service.Forward.PauseForward(manual.ID, owner).Code)
+	global.DB.First(manual, manual.ID)
+	assert.False(t, service.Forward.AutoPauseForward(manual, model.PauseUserQuota))
+	assert.True(t, service.Forward.AutoPauseForward(quota, model.PauseUserQuota))
+	global.DB.First(manual, manual.ID)
+	assert.Equal(t, []string{model.PauseManual, model.PauseUserQuota}, manual.PauseReasonList())
+
+	// 转发列表返回暂停原因
+	res := service.Forward.GetAllForwards(owner)
+	for _, f := range res.Data.([]dto.ForwardResponseDto) {
+		if f.ID == manual.ID {
+			assert.Equal(t, []string{model.PauseManual, model.PauseUserQuota}, f.PauseReasons)
+		}
+	}
+}
+
+func TestResetFlowKeepsManualPause(t *testing.T) {
+	gb := int64(1024 * 1024 * 1024)
+	user, owner, manual, quota := createPauseForwards("pr_reset", 33011)
+	assert.Equal(t, 0, service.Forward.PauseForward(manual.ID, owner).Code)
+	global.DB.First(manual, manual.ID)
+	service.Forward.AutoPauseForward(manual, model.PauseUserQuota)
+	service.Forward.AutoPauseForward(quota, model.PauseUserQuota)
+
+	// 重置流量只清除流量原因，手动暂停的转发保持暂停
+	global.DB.Model(user).Update("in_flow", 11*gb)
+	assert.Equal(t, 0, service.User.ResetFlow(dto.ResetFlowDto{ID: user.ID, Type: 1}, &utils.UserClaims{}).Code)
+	global.DB.First(manual, manual.ID)
+	global.DB.First(quota, quota.ID)
+	assert.Equal(t, 0, manual.Status)
+	assert.Equal(t, []string{model.PauseManual}, manual.PauseReasonList())
+	assert.Equal(t, 1, quota.Status)
+	assert.Empty(t, quota.PauseReasons)
+}
+
+func TestAdminPauseNeedsAdminResume(t *testing.T) {
+	_, owner, fwd, _ := createPauseForwards("pr_admin", 33021)
+
+	// 管理员暂停的转发，用户不能自行恢复
+	assert.Equal(t, 0, service.Forward.PauseForward(fwd.ID, &utils.UserClaims{}).Code)
+	global.DB.First(fwd, fwd.ID)
+	assert.Equal(t, []string{model.PauseAdmin}, fwd.PauseReasonList())
+	assert.NotEqual(t, 0, service.Forward.ResumeForward(fwd.ID, owner).Code)
+	assert.Equal(t, 0, service.Forward.ResumeForward(fwd.ID, &utils.UserClaims{}).Code)
+	global.DB.First(fwd, fwd.ID)
+	assert.Equal(t, 1, fwd.Status)
+	assert.Empty(t, fwd.PauseReasons)
+}
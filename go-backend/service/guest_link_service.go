@@ -0,0 +1,314 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/result"
+	"go-backend/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const maxGuestLinksPerUser = 20
+
+var (
+	errGuestLinkNotFound = errors.New("链接不存在")
+	errGuestLinkUser     = errors.New("用户不存在")
+	errGuestLinkDenied   = errors.New("权限不足")
+)
+
+// GuestLinkService 访客面板链接，分享给终端客户查看使用情况
+type GuestLinkService struct{}
+
+var GuestLink = new(GuestLinkService)
+
+// Default 兼容旧接口：返回用户的默认链接（全部转发、永不过期），不存在时创建
+func (s *GuestLinkService) Default(claims *utils.UserClaims, userId int64) *result.Result {
+	user, err := s.owner(claims, userId)
+	if err != nil {
+		return result.Err(-1, err.Error())
+	}
+
+	var link model.GuestLink
+	err = global.DB.Where("user_id = ? AND revoked_time = 0 AND exp_time = 0 AND forward_ids = '' AND tunnel_ids = '' AND hide_flow = 0 AND hide_ip = 0", user.ID).
+		Order("id asc").First(&link).Error
+	if err == nil {
+		return result.Ok(dto.GuestLinkDto{Token: link.Token})
+	}
+
+	link = model.GuestLink{
+		UserID:      user.ID,
+		Token:       uuid.New().String(),
+		Name:        "默认链接",
+		CreatedTime: time.Now().UnixMilli(),
+		CreatedBy:   claims.GetUserId(),
+	}
+	if err := global.DB.Create(&link).Error; err != nil {
+		return result.Err(-1, "Failed to create guest link")
+	}
+	return result.Ok(dto.GuestLinkDto{Token: link.Token})
+}
+
+// Create 创建访客链接，转发和隧道范围必须属于该用户
+func (s *GuestLinkService) Create(claims *utils.UserClaims, createDto dto.GuestLinkCreateDto) *result.Result {
+	user, err := s.owner(claims, createDto.UserId)
+	if err != nil {
+		return result.Err(-1, err.Error())
+	}
+
+	var count int64
+	global.DB.Model(&model.GuestLink{}).Where("user_id = ? AND revoked_time = 0", user.ID).Count(&count)
+	if count >= maxGuestLinksPerUser {
+		return result.Err(-1, "访客链接数量已达上限")
+	}
+
+	link := model.GuestLink{
+		UserID:      user.ID,
+		Token:       uuid.New().String(),
+		CreatedTime: time.Now().UnixMilli(),
+		CreatedBy:   claims.GetUserId(),
+	}
+	if msg := s.apply(&link, createDto.Name, createDto.ExpTime, createDto.ForwardIds, createDto.TunnelIds, createDto.HideFlow, createDto.HideIp); msg != "" {
+		return result.Err(-1, msg)
+	}
+	if err := global.DB.Create(&link).Error; err != nil {
+		return result.Err(-1, "创建访客链接失败")
+	}
+	return result.Ok(link)
+}
+
+// Update 修改名称、有效期和范围，token 不变
+func (s *GuestLinkService) Update(claims *utils.UserClaims, updateDto dto.GuestLinkUpdateDto) *result.Result {
+	link, err := s.find(claims, updateDto.ID)
+	if err != nil {
+		return result.Err(-1, err.Error())
+	}
+	if link.RevokedTime != 0 {
+		return result.Err(-1, "链接已撤销")
+	}
+	if msg := s.apply(link, updateDto.Name, updateDto.ExpTime, updateDto.ForwardIds, updateDto.TunnelIds, updateDto.HideFlow, updateDto.HideIp); msg != "" {
+		return result.Err(-1, msg)
+	}
+	if err := global.DB.Save(link).Error; err != nil {
+		return result.Err(-1, "修改访客链接失败")
+	}
+	return result.Ok(link)
+}
+
+// List 查看用户的访客链接（含已撤销的），userId 为 0 时查看自己的
+func (s *GuestLinkService) List(claims *utils.UserClaims, userId int64) *result.Result {
+	user, err := s.owner(claims, userId)
+	if err != nil {
+		return result.Err(-1, err.Error())
+	}
+	var links []model.GuestLink
+	global.DB.Where("user_id = ?", user.ID).Order("id desc").Find(&links)
+	return result.Ok(links)
+}
+
+// Revoke 撤销链接后立即失效，不影响用户的其他链接
+func (s *GuestLinkService) Revoke(claims *utils.UserClaims, id int64) *result.Result {
+	link, err := s.find(claims, id)
+	if err != nil {
+		return result.Err(-1, err.Error())
+	}
+	if link.RevokedTime == 0 {
+		global.DB.Model(link).Update("revoked_time", time.Now().UnixMilli())
+	}
+	return result.OkMsg("链接已撤销")
+}
+
+// Dashboard 访客面板数据，按链接的范围和隐藏选项裁剪，并记录访问次数
+func (s *GuestLinkService) Dashboard(token, ip string, rng dto.TrafficRangeDto) *result.Result {
+	var link model.GuestLink
+	if err := global.DB.Where("token = ?", token).First(&link).Error; err != nil {
+		return result.Err(401, "Invalid token")
+	}
+	now := time.Now().UnixMilli()
+	if !link.Active(now) {
+		return result.Err(401, "链接已失效")
+	}
+
+	var user model.User
+	if err := global.DB.First(&user, link.UserID).Error; err != nil {
+		return result.Err(404, "User not found")
+	}
+
+	global.DB.Model(&link).UpdateColumns(map[string]interface{}{
+		"access_count":     gorm.Expr("access_count + 1"),
+		"last_access_time": now,
+		"last_access_ip":   ip,
+	})
+
+	forwardIds := model.ParseIds(link.ForwardIds)
+	tunnelIds := model.ParseIds(link.TunnelIds)
+	hideFlow := link.HideFlow == 1
+
+	var forwards []model.Forward
+	global.DB.Where("user_id = ?", user.ID).Find(&forwards)
+
+	forwardDtos := make([]dto.UserForwardDetailDto, 0, len(forwards))
+	visibleTunnels := make(map[int64]bool)
+	for _, f := range forwards {
+		if link.Scoped() && !forwardIds[f.ID] && !tunnelIds[f.TunnelId] {
+			continue
+		}
+		visibleTunnels[f.TunnelId] = true
+
+		var tunnel model.Tunnel
+		// Best effort to find tunnel. If deleted, might be empty or error.
+		global.DB.First(&tunnel, f.TunnelId)
+
+		d := dto.UserForwardDetailDto{
+			ID:           f.ID,
+			Name:         f.Name,
+			TunnelId:     f.TunnelId,
+			TunnelName:   tunnel.Name,
+			InIP:         tunnel.InIp,
+			InPort:       f.InPort,
+			RemoteAddr:   "",
+			InFlow:       f.InFlow,
+			OutFlow:      f.OutFlow,
+			Status:       f.Status,
+			CreatedAt:    f.CreatedTime,
+			PauseReasons: f.PauseReasonList(),
+		}
+		if link.HideIp == 1 {
+			d.InIP = ""
+		}
+		if hideFlow {
+			d.InFlow, d.OutFlow = 0, 0
+		}
+		forwardDtos = append(forwardDtos, d)
+	}
+
+	permissions := make([]dto.UserTunnelDetailDto, 0)
+	for _, p := range User.GetTunnelPermissions(user.ID) {
+		if link.Scoped() && !tunnelIds[int64(p.TunnelId)] && !visibleTunnels[int64(p.TunnelId)] {
+			continue
+		}
+		if hideFlow {
+			p.Flow, p.InFlow, p.OutFlow, p.TunnelFlow = 0, 0, 0, 0
+		}
+		permissions = append(permissions, p)
+	}
+
+	resp := dto.GuestDashboardDto{
+		Link: dto.GuestLinkInfoDto{
+			Name:     link.Name,
+			ExpTime:  link.ExpTime,
+			Scoped:   link.Scoped(),
+			HideFlow: hideFlow,
+			HideIp:   link.HideIp == 1,
+		},
+		UserInfo: dto.GuestUserInfoDto{
+			Status:        user.Status,
+			Flow:          user.Flow,
+			InFlow:        user.InFlow,
+			OutFlow:       user.OutFlow,
+			Num:           user.Num,
+			FlowResetTime: user.FlowResetTime,
+			ExpTime:       user.ExpTime,
+		},
+		TunnelPermissions: permissions,
+		Forwards:          forwardDtos,
+		StatisticsFlows:   []dto.StatisticsFlowDto{},
+	}
+
+	// 账号级流量统计包含范围外的转发，限定范围或隐藏流量时不返回
+	if hideFlow || link.Scoped() {
+		resp.UserInfo.Flow, resp.UserInfo.InFlow, resp.UserInfo.OutFlow = 0, 0, 0
+		return result.Ok(resp)
+	}
+
+	resp.StatisticsFlows = User.GetLast24HoursFlowStatistics(user.ID)
+	history, err := Traffic.Series(model.TrafficScopeUser, user.ID, rng.Start, rng.End, rng.Period)
+	if err != nil {
+		return result.Err(-1, err.Error())
+	}
+	resp.FlowHistory = history
+	return result.Ok(resp)
+}
+
+// owner 解析链接所属用户：userId 为 0 或自己时为本人，否则需要能管理该用户
+func (s *GuestLinkService) owner(claims *utils.UserClaims, userId int64) (*model.User, error) {
+	if userId == 0 {
+		userId = claims.GetUserId()
+	}
+	var user model.User
+	if err := global.DB.First(&user, userId).Error; err != nil {
+		return nil, errGuestLinkUser
+	}
+	if user.ID != claims.GetUserId() && !ownsUser(claims, &user) {
+		return nil, errGuestLinkDenied
+	}
+	return &user, nil
+}
+
+// find 查找链接并校验操作者可以管理其所属用户
+func (s *GuestLinkService) find(claims *utils.UserClaims, id int64) (*model.GuestLink, error) {
+	var link model.GuestLink
+	if err := global.DB.First(&link, id).Error; err != nil {
+		return nil, errGuestLinkNotFound
+	}
+	if _, err := s.owner(claims, link.UserID); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// apply 校验并写入可修改的字段，返回错误信息
+func (s *GuestLinkService) apply(link *model.GuestLink, name string, expTime int64, forwardIds, tunnelIds []int64, hideFlow, hideIp bool) string {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 64 {
+		return "名称不能为空且不超过64个字符"
+	}
+	if expTime != 0 && expTime <= time.Now().UnixMilli() {
+		return "过期时间不能早于当前时间"
+	}
+	if len(forwardIds) > 0 {
+		var count int64
+		global.DB.Model(&model.Forward{}).Where("user_id = ? AND id IN ?", link.UserID, forwardIds).Count(&count)
+		if int(count) != len(uniqueIds(forwardIds)) {
+			return "转发不存在或不属于该用户"
+		}
+	}
+	if len(tunnelIds) > 0 {
+		var count int64
+		global.DB.Model(&model.UserTunnel{}).Where("user_id = ? AND tunnel_id IN ?", link.UserID, tunnelIds).Count(&count)
+		if int(count) != len(uniqueIds(tunnelIds)) {
+			return "隧道不存在或该用户没有权限"
+		}
+	}
+
+	link.Name = name
+	link.ExpTime = expTime
+	link.ForwardIds = model.JoinIds(uniqueIds(forwardIds))
+	link.TunnelIds = model.JoinIds(uniqueIds(tunnelIds))
+	link.HideFlow, link.HideIp = 0, 0
+	if hideFlow {
+		link.HideFlow = 1
+	}
+	if hideIp {
+		link.HideIp = 1
+	}
+	return ""
+}
+
+func uniqueIds(ids []int64) []int64 {
+	seen := make(map[int64]bool)
+	list := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			list = append(list, id)
+		}
+	}
+	return list
+}
@@ -0,0 +1,148 @@
+package service_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/service"
+	"go-backend/testutil"
+	"go-backend/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// guestFixture 拥有两条隧道各一个转发的用户，以及在隧道 A 上有转发的另一个用户
+type guestFixture struct {
+	owner          *model.User
+	fwdA           model.Forward
+	strangerFwd    model.Forward
+	claims         *utils.UserClaims
+	strangerClaims *utils.UserClaims
+	exp            int64
+}
+
+func newGuestFixture(prefix string, port int) *guestFixture {
+	exp := time.Now().Add(time.Hour).UnixMilli()
+	owner := testutil.CreateUser(prefix+"_owner", model.RoleUser, 5, 10, exp)
+	stranger := testutil.CreateUser(prefix+"_stranger", model.RoleUser, 5, 10, exp)
+	global.DB.Model(owner).Updates(map[string]interface{}{"in_flow": 100, "out_flow": 200})
+	tunnelA := testutil.CreateTunnel(prefix + "_tunnel_a")
+	tunnelB := testutil.CreateTunnel(prefix + "_tunnel_b")
+	global.DB.Model(tunnelA).Update("in_ip", "203.0.113.1")
+	for _, tn := range []*model.Tunnel{tunnelA, tunnelB} {
+		global.DB.Create(&model.UserTunnel{UserId: int(owner.ID), TunnelId: int(tn.ID), Status: 1})
+	}
+	fwdA := model.Forward{UserId: owner.ID, Name: prefix + "_fwd_a", TunnelId: tunnelA.ID, InPort: port, InFlow: 10, Status: 1}
+	fwdB := model.Forward{UserId: owner.ID, Name: prefix + "_fwd_b", TunnelId: tunnelB.ID, InPort: port + 1, InFlow: 20, Status: 1}
+	global.DB.Create(&fwdA)
+	global.DB.Create(&fwdB)
+	strangerFwd := model.Forward{UserId: stranger.ID, Name: prefix + "_fwd_x", TunnelId: tunnelA.ID, InPort: port + 2, Status: 1}
+	global.DB.Create(&strangerFwd)
+
+	return &guestFixture{
+		owner:          owner,
+		fwdA:           fwdA,
+		strangerFwd:    strangerFwd,
+		claims:         &utils.UserClaims{User: owner.User, RoleId: model.RoleUser, RegisteredClaims: jwt.RegisteredClaims{Subject: strconv.FormatInt(owner.ID, 10)}},
+		strangerClaims: &utils.UserClaims{User: stranger.User, RoleId: model.RoleUser, RegisteredClaims: jwt.RegisteredClaims{Subject: strconv.FormatInt(stranger.ID, 10)}},
+		exp:            exp,
+	}
+}
+
+func (f *guestFixture) create(t *testing.T, createDto dto.GuestLinkCreateDto) model.GuestLink {
+	res := service.GuestLink.Create(f.claims, createDto)
+	if !assert.Equal(t, 0, res.Code, res.Msg) {
+		t.FailNow()
+	}
+	return res.Data.(model.GuestLink)
+}
+
+func TestGuestLinkDefault(t *testing.T) {
+	f := newGuestFixture("guest_default", 30001)
+
+	// 旧接口返回同一个默认链接
+	first := service.GuestLink.Default(f.claims, 0).Data.(dto.GuestLinkDto).Token
+	assert.Equal(t, first, service.GuestLink.Default(f.claims, 0).Data.(dto.GuestLinkDto).Token)
+	assert.Equal(t, 0, service.GuestLink.Dashboard(first, "", dto.TrafficRangeDto{}).Code)
+}
+
+func TestGuestLinkCreateScope(t *testing.T) {
+	f := newGuestFixture("guest_scope", 30011)
+
+	// 只能分享自己的转发和账号
+	assert.NotEqual(t, 0, service.GuestLink.Create(f.claims, dto.GuestLinkCreateDto{Name: "越权", ForwardIds: []int64{f.strangerFwd.ID}}).Code)
+	assert.NotEqual(t, 0, service.GuestLink.Create(f.strangerClaims, dto.GuestLinkCreateDto{UserId: f.owner.ID, Name: "越权"}).Code)
+}
+
+func TestGuestDashboardAll(t *testing.T) {
+	f := newGuestFixture("guest_all", 30021)
+	all := f.create(t, dto.GuestLinkCreateDto{Name: "全部"})
+
+	// 全部范围：所有转发和账号流量
+	res := service.GuestLink.Dashboard(all.Token, "198.51.100.1", dto.TrafficRangeDto{})
+	assert.Equal(t, 0, res.Code, res.Msg)
+	dash := res.Data.(dto.GuestDashboardDto)
+	assert.Len(t, dash.Forwards, 2)
+	assert.Equal(t, int64(100), dash.UserInfo.InFlow)
+	assert.NotNil(t, dash.FlowHistory)
+}
+
+func TestGuestDashboardScoped(t *testing.T) {
+	f := newGuestFixture("guest_scoped", 30031)
+	scoped := f.create(t, dto.GuestLinkCreateDto{Name: "客户A", ForwardIds: []int64{f.fwdA.ID}, HideIp: true, ExpTime: f.exp})
+
+	// 指定转发并隐藏 IP：只返回该转发和对应隧道，不返回账号流量
+	res := service.GuestLink.Dashboard(scoped.Token, "198.51.100.2", dto.TrafficRangeDto{})
+	assert.Equal(t, 0, res.Code, res.Msg)
+	dash := res.Data.(dto.GuestDashboardDto)
+	if assert.Len(t, dash.Forwards, 1) {
+		assert.Equal(t, f.fwdA.ID, dash.Forwards[0].ID)
+		assert.Equal(t, "", dash.Forwards[0].InIP)
+		assert.Equal(t, int64(10), dash.Forwards[0].InFlow)
+	}
+	assert.Len(t, dash.TunnelPermissions, 1)
+	assert.Equal(t, int64(0), dash.UserInfo.InFlow)
+	assert.Nil(t, dash.FlowHistory)
+	assert.True(t, dash.Link.HideIp)
+
+	var counted model.GuestLink
+	global.DB.First(&counted, scoped.ID)
+	assert.Equal(t, int64(1), counted.AccessCount)
+	assert.Equal(t, "198.51.100.2", counted.LastAccessIp)
+}
+
+func TestGuestLinkHideFlow(t *testing.T) {
+	f := newGuestFixture("guest_hide_flow", 30041)
+	scoped := f.create(t, dto.GuestLinkCreateDto{Name: "客户A", ForwardIds: []int64{f.fwdA.ID}, HideIp: true})
+
+	// 改为隐藏流量、显示 IP
+	res := service.GuestLink.Update(f.claims, dto.GuestLinkUpdateDto{ID: scoped.ID, Name: "客户A", ForwardIds: []int64{f.fwdA.ID}, HideFlow: true})
+	assert.Equal(t, 0, res.Code, res.Msg)
+	dash := service.GuestLink.Dashboard(scoped.Token, "198.51.100.2", dto.TrafficRangeDto{}).Data.(dto.GuestDashboardDto)
+	if assert.Len(t, dash.Forwards, 1) {
+		assert.Equal(t, int64(0), dash.Forwards[0].InFlow)
+		assert.Equal(t, "203.0.113.1", dash.Forwards[0].InIP)
+	}
+}
+
+func TestGuestLinkRevokeAndExpiry(t *testing.T) {
+	f := newGuestFixture("guest_revoke", 30051)
+	all := f.create(t, dto.GuestLinkCreateDto{Name: "全部"})
+	scoped := f.create(t, dto.GuestLinkCreateDto{Name: "客户A", ForwardIds: []int64{f.fwdA.ID}, ExpTime: f.exp})
+
+	// 撤销一个链接不影响其他链接；过期链接失效
+	assert.NotEqual(t, 0, service.GuestLink.Revoke(f.strangerClaims, all.ID).Code)
+	assert.Equal(t, 0, service.GuestLink.Revoke(f.claims, all.ID).Code)
+	assert.Equal(t, "链接已失效", service.GuestLink.Dashboard(all.Token, "", dto.TrafficRangeDto{}).Msg)
+	assert.Equal(t, 0, service.GuestLink.Dashboard(scoped.Token, "", dto.TrafficRangeDto{}).Code)
+	global.DB.Model(&model.GuestLink{}).Where("id = ?", scoped.ID).Update("exp_time", time.Now().Add(-time.Minute).UnixMilli())
+	assert.Equal(t, "链接已失效", service.GuestLink.Dashboard(scoped.Token, "", dto.TrafficRangeDto{}).Msg)
+
+	links := service.GuestLink.List(f.claims, 0).Data.([]model.GuestLink)
+	assert.Len(t, links, 2)
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/result"
+)
+
+type LoginGuardService struct {
+	mu sync.Mutex
+}
+
+var LoginGuard = new(LoginGuardService)
+
+// 登录限制配置项（vite_config），未配置时使用默认值
+const (
+	loginUserMaxFailuresConfig = "login_user_max_failures"
+	loginIpMaxFailuresConfig   = "login_ip_max_failures"
+	loginLockoutConfig         = "login_lockout_seconds"
+	loginLockoutMaxConfig      = "login_lockout_max_seconds"
+)
+
+const (
+	defaultLoginUserMaxFailures = 5
+	defaultLoginIpMaxFailures   = 20
+	defaultLoginLockout         = 60
+	defaultLoginLockoutMax      = 3600
+	// 超过该时间没有新的失败，计数重新开始
+	loginFailureWindow = 24 * time.Hour
+)
+
+// LoginSource 登录入口，记录在失败统计中
+const (
+	LoginSourceLogin    = "login"
+	LoginSourceTotp     = "totp"
+	LoginSourceSubStore = "sub_store"
+)
+
+// Check 判断 IP 或账号是否处于锁定期，锁定时返回提示信息
+func (s *LoginGuardService) Check(ip, username string) error {
+	now := time.Now().UnixMilli()
+	var locked model.LoginThrottle
+	err := global.DB.Where("((scope = ? AND target = ?) OR (scope = ? AND target = ?)) AND locked_until > ?",
+		model.LoginScopeIp, ip, model.LoginScopeUser, username, now).
+		Order("locked_until desc").First(&locked).Error
+	if err != nil {
+		return nil
+	}
+	seconds := (locked.LockedUntil - now + 999) / 1000
+	return fmt.Errorf("登录失败次数过多，请 %d 秒后再试", seconds)
+}
+
+// Fail 记录一次失败；连续失败超过阈值后锁定，每多失败一次锁定时间翻倍
+func (s *LoginGuardService) Fail(ip, username, source string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fail(model.LoginScopeIp, ip, ip, username, source, s.configInt(loginIpMaxFailuresConfig, defaultLoginIpMaxFailures))
+	if username != "" {
+		s.fail(model.LoginScopeUser, username, ip, username, source, s.configInt(loginUserMaxFailuresConfig, defaultLoginUserMaxFailures))
+	}
+}
+
+// Succeed 登录成功后清除账号的失败计数；IP 计数不清除，避免用自己的账号重置对其他账号的猜测
+func (s *LoginGuardService) Succeed(username string) {
+	global.DB.Where("scope = ? AND target = ?", model.LoginScopeUser, username).Delete(&model.LoginThrottle{})
+}
+
+// List 查看登录失败记录，锁定中的排在前面
+func (s *LoginGuardService) List() *result.Result {
+	var list []model.LoginThrottle
+	global.DB.Order("locked_until desc, last_fail_time desc").Limit(500).Find(&list)
+	return result.Ok(list)
+}
+
+// Unlock 管理员解除锁定
+func (s *LoginGuardService) Unlock(id int64) *result.Result {
+	res := global.DB.Delete(&model.LoginThrottle{}, id)
+	if res.Error != nil {
+		return result.Err(-1, "解除锁定失败")
+	}
+	if res.RowsAffected == 0 {
+		return result.Err(-1, "记录不存在")
+	}
+	return result.OkMsg("已解除锁定")
+}
+
+// Prune 清理已过期且长期没有失败的记录
+func (s *LoginGuardService) Prune(now time.Time) {
+	cutoff := now.Add(-loginFailureWindow).UnixMilli()
+	global.DB.Where("last_fail_time < ? AND locked_until < ?", cutoff, now.UnixMilli()).Delete(&model.LoginThrottle{})
+}
+
+func (s *LoginGuardService) fail(scope, target, ip, username, source string, maxFailures int) {
+	now := time.Now()
+	var t model.LoginThrottle
+	if err := global.DB.Where("scope = ? AND target = ?", scope, target).First(&t).Error; err != nil {
+		t = model.LoginThrottle{Scope: scope, Target: target}
+	}
+	if t.LastFailTime < now.Add(-loginFailureWindow).UnixMilli() {
+		t.Failures = 0
+	}
+	t.Failures++
+	t.LastFailTime = now.UnixMilli()
+	t.LastIp = ip
+	t.LastUsername = username
+	t.LastSource = source
+
+	if maxFailures > 0 && t.Failures >= maxFailures {
+		t.LockedUntil = now.Add(s.lockout(t.Failures - maxFailures)).UnixMilli()
+	}
+	global.DB.Save(&t)
+}
+
+// lockout 第 n 次超限的锁定时间：base * 2^n，不超过上限
+func (s *LoginGuardService) lockout(n int) time.Duration {
+	base := s.configInt(loginLockoutConfig, defaultLoginLockout)
+	max := s.configInt(loginLockoutMaxConfig, defaultLoginLockoutMax)
+	seconds := base
+	for i := 0; i < n && seconds < max; i++ {
+		seconds *= 2
+	}
+	if seconds > max {
+		seconds = max
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func (s *LoginGuardService) configInt(name string, def int) int {
+	v, err := strconv.Atoi(ViteConfig.GetValue(name))
+	if err != nil || v < 0 {
+		return def
+	}
+	return v
+}
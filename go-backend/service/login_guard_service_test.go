@@ -0,0 +1,177 @@
+package service_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-backend/config"
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/result"
+	"go-backend/router"
+	"go-backend/service"
+	"go-backend/testutil"
+	"go-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// lowerLoginLimits 把账号失败上限调到 3 次、锁定 60 秒，测试结束时恢复默认
+func lowerLoginLimits(t *testing.T) {
+	service.ViteConfig.UpdateConfig("login_user_max_failures", "3")
+	service.ViteConfig.UpdateConfig("login_lockout_seconds", "60")
+	t.Cleanup(func() {
+		service.ViteConfig.UpdateConfig("login_user_max_failures", "")
+		service.ViteConfig.UpdateConfig("login_lockout_seconds", "")
+	})
+}
+
+func passwordLogin(user, pwd string, client dto.ClientInfo) *result.Result {
+	return service.User.Login(dto.LoginDto{Username: user, Password: pwd}, client)
+}
+
+// lockAccount 连续输错密码直到账号被锁定，返回账号的计数记录
+func lockAccount(t *testing.T, username string, client dto.ClientInfo) model.LoginThrottle {
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "账号或密码错误", passwordLogin(username, "wrong", client).Msg)
+	}
+	var locked model.LoginThrottle
+	assert.NoError(t, global.DB.Where("scope = ? AND target = ?", model.LoginScopeUser, username).First(&locked).Error)
+	return locked
+}
+
+func TestLoginThrottle(t *testing.T) {
+	lowerLoginLimits(t)
+	testutil.CreateUser("throttle_user", model.RoleUser, 1, 10, time.Now().Add(time.Hour).UnixMilli())
+	testutil.CreateUser("throttle_other", model.RoleUser, 1, 10, time.Now().Add(time.Hour).UnixMilli())
+	client := dto.ClientInfo{Ip: "198.51.100.7"}
+	locked := lockAccount(t, "throttle_user", client)
+	assert.Equal(t, 3, locked.Failures)
+	assert.InDelta(t, time.Now().Add(60*time.Second).UnixMilli(), locked.LockedUntil, 2000)
+
+	// 账号锁定后正确密码也不能登录，换 IP 同样受限；同一 IP 的其他账号不受影响
+	assert.Contains(t, passwordLogin("throttle_user", "123456", client).Msg, "登录失败次数过多")
+	assert.Contains(t, passwordLogin("throttle_user", "123456", dto.ClientInfo{Ip: "198.51.100.8"}).Msg, "登录失败次数过多")
+	assert.Equal(t, 0, passwordLogin("throttle_other", "123456", client).Code)
+}
+
+func TestLoginThrottleBackoff(t *testing.T) {
+	lowerLoginLimits(t)
+	testutil.CreateUser("throttle_backoff", model.RoleUser, 1, 10, time.Now().Add(time.Hour).UnixMilli())
+	client := dto.ClientInfo{Ip: "198.51.100.17"}
+	locked := lockAccount(t, "throttle_backoff", client)
+
+	// 锁定到期后再次失败，锁定时间翻倍
+	global.DB.Model(&locked).Update("locked_until", time.Now().Add(-time.Second).UnixMilli())
+	passwordLogin("throttle_backoff", "wrong", client)
+	global.DB.First(&locked, locked.ID)
+	assert.Equal(t, 4, locked.Failures)
+	assert.InDelta(t, time.Now().Add(120*time.Second).UnixMilli(), locked.LockedUntil, 2000)
+}
+
+func TestLoginFailuresUnlock(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	lowerLoginLimits(t)
+	testutil.CreateUser("throttle_unlock", model.RoleUser, 1, 10, time.Now().Add(time.Hour).UnixMilli())
+	client := dto.ClientInfo{Ip: "198.51.100.27"}
+	locked := lockAccount(t, "throttle_unlock", client)
+
+	// 管理员查看并解除锁定，登录成功后清除账号计数
+	r := router.InitRouter()
+	admin := testutil.CreateUser("throttle_admin", model.RoleSuperAdmin, 0, 0, time.Now().Add(time.Hour).UnixMilli())
+	token, _, _ := service.Session.Issue(admin, dto.ClientInfo{}, false)
+	call := func(path, body string) *result.Result {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Authorization", token)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var res result.Result
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		return &res
+	}
+	res := call("/api/v1/user/login_failures", "{}")
+	assert.Equal(t, 0, res.Code, res.Msg)
+	assert.Contains(t, fmt.Sprint(res.Data), "throttle_unlock")
+	assert.Equal(t, 0, call("/api/v1/user/login_failures/unlock", fmt.Sprintf(`{"id":%d}`, locked.ID)).Code)
+	assert.Equal(t, 0, passwordLogin("throttle_unlock", "123456", client).Code)
+	assert.Error(t, global.DB.First(&model.LoginThrottle{}, locked.ID).Error)
+}
+
+func TestLoginThrottleTrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testutil.CreateUser("throttle_proxy", model.RoleUser, 1, 10, time.Now().Add(time.Hour).UnixMilli())
+	defer func(proxies []string) { config.AppConfig.Server.TrustedProxies = proxies }(config.AppConfig.Server.TrustedProxies)
+	subStore := func(xff string) {
+		req := httptest.NewRequest("GET", "/api/v1/open_api/sub_store?user=throttle_proxy&pwd=wrong", nil)
+		req.RemoteAddr = "192.0.2.1:4000"
+		req.Header.Set("X-Forwarded-For", xff)
+		router.InitRouter().ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// 只信任配置的代理传入的 X-Forwarded-For
+	config.AppConfig.Server.TrustedProxies = []string{"192.0.2.0/24"}
+	subStore("203.0.113.9")
+	var viaProxy model.LoginThrottle
+	assert.NoError(t, global.DB.Where("scope = ? AND target = ?", model.LoginScopeIp, "203.0.113.9").First(&viaProxy).Error)
+	assert.Equal(t, service.LoginSourceSubStore, viaProxy.LastSource)
+
+	config.AppConfig.Server.TrustedProxies = nil
+	subStore("203.0.113.10")
+	assert.Error(t, global.DB.Where("scope = ? AND target = ?", model.LoginScopeIp, "203.0.113.10").First(&model.LoginThrottle{}).Error)
+	assert.NoError(t, global.DB.Where("scope = ? AND target = ?", model.LoginScopeIp, "192.0.2.1").First(&model.LoginThrottle{}).Error)
+}
+
+// TestLoginThrottleTotp verifies the second login step goes through the same guard:
+// wrong codes lock the account and IP, and pre-auth tokens issued earlier stop working.
+func TestLoginThrottleTotp(t *testing.T) {
+	user := testutil.CreateUser("throttle_totp", model.RoleUser, 1, 10, time.Now().Add(time.Hour).UnixMilli())
+	claims := &utils.UserClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: strconv.FormatInt(user.ID, 10)}}
+	setup := service.Totp.Setup(claims).Data.(dto.TotpSetupDto)
+	code, _ := utils.TotpCode(setup.Secret, utils.TotpCounter(time.Now()))
+	assert.Equal(t, 0, service.Totp.Enable(claims, dto.TotpEnableDto{Code: code}, dto.ClientInfo{}).Code)
+
+	service.ViteConfig.UpdateConfig("login_user_max_failures", "3")
+	defer service.ViteConfig.UpdateConfig("login_user_max_failures", "")
+	client := dto.ClientInfo{Ip: "198.51.100.40"}
+	preAuth := func() string {
+		res := service.User.Login(dto.LoginDto{Username: "throttle_totp", Password: "123456"}, client)
+		if !assert.Equal(t, 0, res.Code, res.Msg) {
+			t.FailNow()
+		}
+		return res.Data.(map[string]interface{})["preAuthToken"].(string)
+	}
+
+	// 先攒下一个预认证 token，再用另一个 token 连续猜错
+	spare := preAuth()
+	token := preAuth()
+	for i := 0; i < 3; i++ {
+		service.Totp.Login(dto.TotpLoginDto{PreAuthToken: token, Code: "bad-recovery"}, client)
+	}
+
+	var locked model.LoginThrottle
+	assert.NoError(t, global.DB.Where("scope = ? AND target = ?", model.LoginScopeUser, "throttle_totp").First(&locked).Error)
+	assert.Equal(t, 3, locked.Failures)
+	assert.Equal(t, service.LoginSourceTotp, locked.LastSource)
+	var byIp model.LoginThrottle
+	assert.NoError(t, global.DB.Where("scope = ? AND target = ?", model.LoginScopeIp, client.Ip).First(&byIp).Error)
+	assert.Equal(t, 3, byIp.Failures)
+
+	// 锁定期内即使恢复码正确也不能通过第二步
+	res := service.Totp.Login(dto.TotpLoginDto{PreAuthToken: spare, Code: setup.RecoveryCodes[0]}, client)
+	assert.Contains(t, res.Msg, "登录失败次数过多")
+
+	// 解锁后验证通过才清除账号计数
+	global.DB.Model(&locked).Update("locked_until", 0)
+	res = service.Totp.Login(dto.TotpLoginDto{PreAuthToken: spare, Code: setup.RecoveryCodes[0]}, client)
+	assert.Equal(t, 0, res.Code, res.Msg)
+	assert.Error(t, global.DB.First(&model.LoginThrottle{}, locked.ID).Error)
+}
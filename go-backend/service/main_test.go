@@ -0,0 +1,11 @@
+package service_test
+
+import (
+	"testing"
+
+	"go-backend/testutil"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
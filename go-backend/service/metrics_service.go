@@ -0,0 +1,74 @@
+package service
+
+import (
+	"io"
+	"strconv"
+
+	"go-backend/global"
+	"go-backend/metrics"
+	"go-backend/model"
+	"go-backend/websocket"
+)
+
+// MetricsService 输出 Prometheus 指标，节点状态和流量在抓取时从数据库读取
+type MetricsService struct{}
+
+var Metrics = new(MetricsService)
+
+// Write 以 Prometheus 文本格式输出全部指标
+func (s *MetricsService) Write(w io.Writer) {
+	var nodes []model.Node
+	global.DB.Order("id asc").Find(&nodes)
+	online := make([]metrics.Sample, 0, len(nodes))
+	for _, node := range nodes {
+		v := 0.0
+		if websocket.IsNodeOnline(node.ID) {
+			v = 1
+		}
+		online = append(online, metrics.Sample{
+			Labels: []string{"node_id", strconv.FormatInt(node.ID, 10), "node_name", node.Name},
+			Value:  v,
+		})
+	}
+	metrics.WriteMetric(w, "flux_node_online", "节点是否在线", "gauge", online)
+
+	metrics.WriteMetric(w, "flux_ws_pending_requests", "等待节点响应的 websocket 请求数", "gauge",
+		[]metrics.Sample{{Value: float64(websocket.PendingRequestCount())}})
+
+	var users []model.User
+	global.DB.Select("id", "user", "in_flow", "out_flow").Order("id asc").Find(&users)
+	userBytes := make([]metrics.Sample, 0, len(users)*2)
+	for _, user := range users {
+		id := strconv.FormatInt(user.ID, 10)
+		userBytes = append(userBytes,
+			metrics.Sample{Labels: []string{"user_id", id, "user", user.User, "direction", "in"}, Value: float64(user.InFlow)},
+			metrics.Sample{Labels: []string{"user_id", id, "user", user.User, "direction", "out"}, Value: float64(user.OutFlow)},
+		)
+	}
+	metrics.WriteMetric(w, "flux_user_bytes_total", "用户累计流量（字节，已按隧道倍率换算）", "counter", userBytes)
+
+	// 隧道流量由其下转发的累计流量汇总
+	var tunnelRows []struct {
+		TunnelId int64
+		Name     string
+		InFlow   int64
+		OutFlow  int64
+	}
+	global.DB.Model(&model.Forward{}).
+		Select("forward.tunnel_id, tunnel.name, SUM(forward.in_flow) AS in_flow, SUM(forward.out_flow) AS out_flow").
+		Joins("JOIN tunnel ON tunnel.id = forward.tunnel_id").
+		Group("forward.tunnel_id, tunnel.name").
+		Order("forward.tunnel_id asc").
+		Scan(&tunnelRows)
+	tunnelBytes := make([]metrics.Sample, 0, len(tunnelRows)*2)
+	for _, row := range tunnelRows {
+		id := strconv.FormatInt(row.TunnelId, 10)
+		tunnelBytes = append(tunnelBytes,
+			metrics.Sample{Labels: []string{"tunnel_id", id, "tunnel", row.Name, "direction", "in"}, Value: float64(row.InFlow)},
+			metrics.Sample{Labels: []string{"tunnel_id", id, "tunnel", row.Name, "direction", "out"}, Value: float64(row.OutFlow)},
+		)
+	}
+	metrics.WriteMetric(w, "flux_tunnel_bytes_total", "隧道累计流量（字节，已按隧道倍率换算）", "counter", tunnelBytes)
+
+	metrics.WriteAll(w)
+}
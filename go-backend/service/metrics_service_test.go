@@ -0,0 +1,29 @@
+package service_test
+
+import (
+	"bytes"
+	"testing"
+
+	"go-backend/metrics"
+	"go-backend/service"
+	"go-backend/testutil"
+	"go-backend/websocket"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsExposition(t *testing.T) {
+	testutil.CreateNode(960, "metrics \"node\"")
+	metrics.QuotaPauses.Add(2, "tunnel_flow")
+	websocket.SendMsg(960, nil, "AddService")
+
+	var buf bytes.Buffer
+	service.Metrics.Write(&buf)
+	out := buf.String()
+
+	assert.Contains(t, out, `flux_node_online{node_id="960",node_name="metrics \"node\""} 0`)
+	assert.Contains(t, out, "flux_ws_pending_requests 0")
+	assert.Contains(t, out, `flux_quota_pauses_total{reason="tunnel_flow"}`)
+	assert.Contains(t, out, `flux_ws_commands_total{type="AddService",result="offline"}`)
+	assert.Contains(t, out, "# TYPE flux_user_bytes_total counter")
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/result"
+	"go-backend/websocket"
+)
+
+// NodeCommandService 节点离线命令队列管理
+type NodeCommandService struct{}
+
+var NodeCommand = new(NodeCommandService)
+
+// ListCommands 查询队列中的命令，默认只返回待发送的命令
+func (s *NodeCommandService) ListCommands(query dto.NodeCommandQueryDto) *result.Result {
+	db := global.DB.Model(&model.NodeCommand{})
+	if query.NodeId != nil {
+		db = db.Where("node_id = ?", *query.NodeId)
+	}
+	if query.Status != nil {
+		db = db.Where("status = ?", *query.Status)
+	} else {
+		db = db.Where("status = ?", websocket.CommandPending)
+	}
+
+	var commands []model.NodeCommand
+	db.Order("id asc").Limit(1000).Find(&commands)
+	return result.Ok(commands)
+}
+
+// PurgeCommands 删除待发送的命令，已完成或失败的记录由队列定期清理
+func (s *NodeCommandService) PurgeCommands(req dto.NodeCommandPurgeDto) *result.Result {
+	if len(req.Ids) == 0 && req.NodeId == nil {
+		return result.Err(-1, "请指定命令ID或节点ID")
+	}
+
+	db := global.DB.Where("status = ?", websocket.CommandPending)
+	if len(req.Ids) > 0 {
+		db = db.Where("id IN ?", req.Ids)
+	}
+	if req.NodeId != nil {
+		db = db.Where("node_id = ?", *req.NodeId)
+	}
+
+	res := db.Delete(&model.NodeCommand{})
+	if res.Error != nil {
+		return result.Err(-1, "清除失败: "+res.Error.Error())
+	}
+	return result.Ok(res.RowsAffected)
+}
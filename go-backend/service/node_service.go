@@ -87,13 +87,19 @@ func (s *NodeService) UpdateNode(dto dto.NodeUpdateDto) *result.Result {
 			return err
 		}
 		// Update related Tunnels
-		if err := tx.Model(&model.Tunnel{}).Where("in_node_id = ?", node.ID).Update("in_ip", node.Ip).Error; err != nil {
+		if err := tx.Model(&model.Tunnel{}).Where("out_node_id = ?", node.ID).Update("out_ip", node.ServerIp).Error; err != nil {
 			return err
 		}
-		if err := tx.Model(&model.Tunnel{}).Where("out_node_id = ?", node.ID).Update("out_ip", node.ServerIp).Error; err != nil {
+		if err := tx.Model(&model.TunnelHop{}).Where("node_id = ?", node.ID).Update("ip", node.ServerIp).Error; err != nil {
 			return err
 		}
-		return nil
+		if err := tx.Model(&model.TunnelNode{}).Where("node_id = ? AND role = ?", node.ID, model.TunnelNodeEntry).Update("ip", node.Ip).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.TunnelNode{}).Where("node_id = ? AND role = ?", node.ID, model.TunnelNodeExit).Update("ip", node.ServerIp).Error; err != nil {
+			return err
+		}
+		return s.refreshTunnelInIps(tx, node.ID)
 	})
 
 	if err != nil {
@@ -108,13 +114,52 @@ func (s *NodeService) DeleteNode(id int64) *result.Result {
 	if count > 0 {
 		return result.Err(-1, fmt.Sprintf("该节点还有 %d 个隧道在使用，请先删除相关隧道", count))
 	}
+	global.DB.Model(&model.TunnelHop{}).Where("node_id = ?", id).Count(&count)
+	if count > 0 {
+		return result.Err(-1, fmt.Sprintf("该节点还是 %d 个隧道的中转节点，请先调整相关隧道", count))
+	}
+	global.DB.Model(&model.TunnelNode{}).Where("node_id = ?", id).Count(&count)
+	if count > 0 {
+		return result.Err(-1, fmt.Sprintf("该节点还在 %d 个隧道的节点组中，请先调整相关隧道", count))
+	}
 
 	if err := global.DB.Delete(&model.Node{}, id).Error; err != nil {
 		return result.Err(-1, "节点删除失败")
 	}
+	// 清理该节点积压的离线命令
+	global.DB.Where("node_id = ?", id).Delete(&model.NodeCommand{})
 	return result.Ok("节点删除成功")
 }
 
+// refreshTunnelInIps 重新计算节点所在入口节点组的隧道入口 IP
+func (s *NodeService) refreshTunnelInIps(tx *gorm.DB, nodeId int64) error {
+	var tunnelIds []int64
+	tx.Model(&model.Tunnel{}).Where("in_node_id = ?", nodeId).Pluck("id", &tunnelIds)
+	var groupIds []int64
+	tx.Model(&model.TunnelNode{}).Where("node_id = ? AND role = ?", nodeId, model.TunnelNodeEntry).Pluck("tunnel_id", &groupIds)
+
+	for _, tunnelId := range append(tunnelIds, groupIds...) {
+		var tunnel model.Tunnel
+		if err := tx.First(&tunnel, tunnelId).Error; err != nil {
+			continue
+		}
+		var inNode model.Node
+		if err := tx.First(&inNode, tunnel.InNodeId).Error; err != nil {
+			continue
+		}
+		ips := []string{inNode.Ip}
+		var extras []model.TunnelNode
+		tx.Where("tunnel_id = ? AND role = ?", tunnelId, model.TunnelNodeEntry).Order("id asc").Find(&extras)
+		for _, n := range extras {
+			ips = append(ips, n.Ip)
+		}
+		if err := tx.Model(&model.Tunnel{}).Where("id = ?", tunnelId).Update("in_ip", strings.Join(ips, ",")).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *NodeService) GetInstallCommand(id int64) *result.Result {
 	var node model.Node
 	if err := global.DB.First(&node, id).Error; err != nil {
@@ -0,0 +1,391 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/notify"
+	"go-backend/result"
+	"go-backend/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NotifyService 流量阈值和到期提醒，按用户的渠道偏好投递，每个阈值每个周期只发送一次
+type NotifyService struct{}
+
+var Notify = new(NotifyService)
+
+// 通知配置项（vite_config）
+const (
+	notifyFlowThresholdsConfig = "notify_flow_thresholds" // 流量百分比阈值，逗号分隔
+	notifyExpiryDaysConfig     = "notify_expiry_days"     // 到期前天数，逗号分隔
+	notifySmtpHostConfig       = "notify_smtp_host"
+	notifySmtpPortConfig       = "notify_smtp_port"
+	notifySmtpUsernameConfig   = "notify_smtp_username"
+	notifySmtpPasswordConfig   = "notify_smtp_password"
+	notifySmtpFromConfig       = "notify_smtp_from"
+	notifyTelegramTokenConfig  = "notify_telegram_token"
+	notifyTelegramApiConfig    = "notify_telegram_api"
+)
+
+const (
+	defaultNotifyFlowThresholds = "80,95,100"
+	defaultNotifyExpiryDays     = "7,3,1"
+	defaultSmtpPort             = 587
+)
+
+// GetSetting 当前用户的通知偏好
+func (s *NotifyService) GetSetting(claims *utils.UserClaims) *result.Result {
+	var setting model.NotifySetting
+	if err := global.DB.Where("user_id = ?", claims.GetUserId()).First(&setting).Error; err != nil {
+		setting = model.NotifySetting{FlowAlert: 1, ExpiryAlert: 1}
+	}
+	return result.Ok(dto.NotifySettingInfoDto{
+		NotifySettingDto: dto.NotifySettingDto{
+			Channels:       splitChannels(setting.Channels),
+			Email:          setting.Email,
+			TelegramChatId: setting.TelegramChatId,
+			WebhookUrl:     setting.WebhookUrl,
+			FlowAlert:      setting.FlowAlert,
+			ExpiryAlert:    setting.ExpiryAlert,
+		},
+		Available:      s.available(),
+		FlowThresholds: s.flowThresholds(),
+		ExpiryDays:     s.expiryDays(),
+	})
+}
+
+// UpdateSetting 保存当前用户的通知偏好，启用的渠道必须填写对应的地址
+func (s *NotifyService) UpdateSetting(claims *utils.UserClaims, settingDto dto.NotifySettingDto) *result.Result {
+	setting := model.NotifySetting{
+		UserId:         claims.GetUserId(),
+		Email:          strings.TrimSpace(settingDto.Email),
+		TelegramChatId: strings.TrimSpace(settingDto.TelegramChatId),
+		WebhookUrl:     strings.TrimSpace(settingDto.WebhookUrl),
+		FlowAlert:      settingDto.FlowAlert,
+		ExpiryAlert:    settingDto.ExpiryAlert,
+		UpdatedTime:    time.Now().UnixMilli(),
+	}
+	channels, err := validateNotifySetting(&setting, settingDto.Channels)
+	if err != nil {
+		return result.Err(-1, err.Error())
+	}
+	setting.Channels = strings.Join(channels, ",")
+
+	var existing model.NotifySetting
+	if global.DB.Where("user_id = ?", setting.UserId).First(&existing).Error == nil {
+		setting.ID = existing.ID
+	}
+	if err := global.DB.Save(&setting).Error; err != nil {
+		return result.Err(-1, "保存失败")
+	}
+	return result.OkMsg("保存成功")
+}
+
+// Test 向当前用户启用的渠道同步发送测试通知，返回各渠道的结果
+func (s *NotifyService) Test(claims *utils.UserClaims) *result.Result {
+	var setting model.NotifySetting
+	if err := global.DB.Where("user_id = ?", claims.GetUserId()).First(&setting).Error; err != nil || setting.Channels == "" {
+		return result.Err(-1, "未启用任何通知渠道")
+	}
+	msg := notify.Message{Event: "test", Title: "Flux Panel 通知测试", Content: "收到这条消息说明通知渠道配置正确。", Time: time.Now().UnixMilli()}
+	results := make(map[string]string)
+	for channel, send := range s.senders(&setting) {
+		if err := send(msg); err != nil {
+			results[channel] = err.Error()
+		} else {
+			results[channel] = "OK"
+		}
+	}
+	return result.Ok(results)
+}
+
+// CheckUserFlow 账号流量达到阈值时通知，在上报流量后调用
+func (s *NotifyService) CheckUserFlow(user *model.User) {
+	if user.Flow <= 0 {
+		return
+	}
+	used := user.InFlow + user.OutFlow
+	percent := int(used * 100 / (user.Flow * 1024 * 1024 * 1024))
+	s.checkFlow(model.NotifyUserFlow, user.ID, user.ID, percent, notify.Message{
+		Title:   fmt.Sprintf("账号流量已使用 %d%%", percent),
+		Content: fmt.Sprintf("账号 %s 已使用 %s / %d GB 流量，超出后所有转发将被暂停。", user.User, formatBytes(used), user.Flow),
+		Data:    map[string]interface{}{"userId": user.ID, "used": used, "flow": user.Flow, "percent": percent},
+	})
+}
+
+// CheckUserTunnelFlow 用户隧道流量达到阈值时通知
+func (s *NotifyService) CheckUserTunnelFlow(userTunnel *model.UserTunnel) {
+	if userTunnel.Flow <= 0 {
+		return
+	}
+	var tunnel model.Tunnel
+	global.DB.First(&tunnel, userTunnel.TunnelId)
+	used := userTunnel.InFlow + userTunnel.OutFlow
+	percent := int(used * 100 / (userTunnel.Flow * 1024 * 1024 * 1024))
+	s.checkFlow(model.NotifyTunnelFlow, int64(userTunnel.ID), int64(userTunnel.UserId), percent, notify.Message{
+		Title:   fmt.Sprintf("隧道 %s 流量已使用 %d%%", tunnel.Name, percent),
+		Content: fmt.Sprintf("隧道 %s 已使用 %s / %d GB 流量，超出后该隧道下的转发将被暂停。", tunnel.Name, formatBytes(used), userTunnel.Flow),
+		Data:    map[string]interface{}{"userId": userTunnel.UserId, "userTunnelId": userTunnel.ID, "tunnelId": userTunnel.TunnelId, "used": used, "flow": userTunnel.Flow, "percent": percent},
+	})
+}
+
+// CheckExpiry 账号和用户隧道到期前提醒，由每日任务调用
+func (s *NotifyService) CheckExpiry(now time.Time) {
+	days := s.expiryDays()
+	if len(days) == 0 {
+		return
+	}
+	nowMs := now.UnixMilli()
+	until := now.AddDate(0, 0, days[len(days)-1]).UnixMilli()
+
+	// 到期时间已过的提醒记录不再需要
+	global.DB.Where("kind IN ? AND cycle < ?", []string{model.NotifyUserExpiry, model.NotifyTunnelExpiry}, nowMs).Delete(&model.NotifyMark{})
+
+	var users []model.User
+	global.DB.Where("status = 1 AND exp_time > ? AND exp_time <= ?", nowMs, until).Find(&users)
+	for _, user := range users {
+		left := daysLeft(user.ExpTime, nowMs)
+		s.checkExpiry(model.NotifyUserExpiry, user.ID, user.ID, user.ExpTime, left, notify.Message{
+			Title:   fmt.Sprintf("账号将在 %d 天后到期", left),
+			Content: fmt.Sprintf("账号 %s 将于 %s 到期，到期后所有转发将被暂停，请及时续费。", user.User, formatTime(user.ExpTime)),
+			Data:    map[string]interface{}{"userId": user.ID, "expTime": user.ExpTime, "daysLeft": left},
+		})
+	}
+
+	var userTunnels []model.UserTunnel
+	global.DB.Where("status = 1 AND exp_time > ? AND exp_time <= ?", nowMs, until).Find(&userTunnels)
+	for _, ut := range userTunnels {
+		var tunnel model.Tunnel
+		global.DB.First(&tunnel, ut.TunnelId)
+		left := daysLeft(ut.ExpTime, nowMs)
+		s.checkExpiry(model.NotifyTunnelExpiry, int64(ut.ID), int64(ut.UserId), ut.ExpTime, left, notify.Message{
+			Title:   fmt.Sprintf("隧道 %s 将在 %d 天后到期", tunnel.Name, left),
+			Content: fmt.Sprintf("隧道 %s 的使用权限将于 %s 到期，到期后该隧道下的转发将被暂停。", tunnel.Name, formatTime(ut.ExpTime)),
+			Data:    map[string]interface{}{"userId": ut.UserId, "userTunnelId": ut.ID, "tunnelId": ut.TunnelId, "expTime": ut.ExpTime, "daysLeft": left},
+		})
+	}
+}
+
+// ClearFlowMarks 重置流量后开始新的周期，阈值可以再次通知
+func (s *NotifyService) ClearFlowMarks(tx *gorm.DB, kind string, targetId int64) error {
+	return tx.Where("kind = ? AND target_id = ?", kind, targetId).Delete(&model.NotifyMark{}).Error
+}
+
+// checkFlow 一次跨过多个阈值时只通知最高的一个，其余一并标记
+func (s *NotifyService) checkFlow(kind string, targetId, userId int64, percent int, msg notify.Message) {
+	var reached []int
+	for _, t := range s.flowThresholds() {
+		if percent >= t {
+			reached = append(reached, t)
+		}
+	}
+	if len(reached) == 0 {
+		return
+	}
+	msg.Data["threshold"] = reached[len(reached)-1]
+	if s.mark(kind, targetId, 0, reached, reached[len(reached)-1]) {
+		s.deliver(userId, kind, msg)
+	}
+}
+
+// checkExpiry 剩余天数不超过阈值时提醒，只通知最近的一个阈值
+func (s *NotifyService) checkExpiry(kind string, targetId, userId, expTime int64, left int, msg notify.Message) {
+	var reached []int
+	for _, d := range s.expiryDays() {
+		if left <= d {
+			reached = append(reached, d)
+		}
+	}
+	if len(reached) == 0 {
+		return
+	}
+	msg.Data["threshold"] = reached[0]
+	if s.mark(kind, targetId, expTime, reached, reached[0]) {
+		s.deliver(userId, kind, msg)
+	}
+}
+
+// mark 记录已达到的阈值，notifyAt 为本次首次记录时返回 true；唯一索引保证并发上报时也只通知一次
+func (s *NotifyService) mark(kind string, targetId, cycle int64, thresholds []int, notifyAt int) bool {
+	var count int64
+	global.DB.Model(&model.NotifyMark{}).Where("kind = ? AND target_id = ? AND threshold = ? AND cycle = ?", kind, targetId, notifyAt, cycle).Count(&count)
+	if count > 0 {
+		return false
+	}
+	first := false
+	now := time.Now().UnixMilli()
+	for _, t := range thresholds {
+		res := global.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.NotifyMark{
+			Kind: kind, TargetId: targetId, Threshold: t, Cycle: cycle, CreatedTime: now,
+		})
+		if t == notifyAt && res.Error == nil && res.RowsAffected == 1 {
+			first = true
+		}
+	}
+	return first
+}
+
+// deliver 按用户偏好异步投递，不阻塞流量上报和定时任务
+func (s *NotifyService) deliver(userId int64, kind string, msg notify.Message) {
+	var setting model.NotifySetting
+	if err := global.DB.Where("user_id = ?", userId).First(&setting).Error; err != nil {
+		return
+	}
+	isFlow := kind == model.NotifyUserFlow || kind == model.NotifyTunnelFlow
+	if (isFlow && setting.FlowAlert != 1) || (!isFlow && setting.ExpiryAlert != 1) {
+		return
+	}
+	msg.Event = kind
+	msg.Time = time.Now().UnixMilli()
+	for channel, send := range s.senders(&setting) {
+		go func(channel string, send func(notify.Message) error) {
+			if err := send(msg); err != nil {
+				log.Printf("⚠️ 发送 %s 通知给用户 %d 失败: %v", channel, userId, err)
+			}
+		}(channel, send)
+	}
+}
+
+// senders 用户启用且面板已配置的渠道
+func (s *NotifyService) senders(setting *model.NotifySetting) map[string]func(notify.Message) error {
+	targets := map[string]string{
+		notify.ChannelEmail:    setting.Email,
+		notify.ChannelTelegram: setting.TelegramChatId,
+		notify.ChannelWebhook:  setting.WebhookUrl,
+	}
+	notifiers := s.notifiers()
+	senders := make(map[string]func(notify.Message) error)
+	for _, channel := range splitChannels(setting.Channels) {
+		notifier, target := notifiers[channel], targets[channel]
+		if notifier == nil || target == "" {
+			continue
+		}
+		senders[channel] = func(msg notify.Message) error { return notifier.Send(target, msg) }
+	}
+	return senders
+}
+
+// notifiers 根据面板配置构造可用的渠道，SMTP 和 Telegram 未配置时不可用
+func (s *NotifyService) notifiers() map[string]notify.Notifier {
+	notifiers := map[string]notify.Notifier{notify.ChannelWebhook: &notify.Webhook{}}
+	if host := ViteConfig.GetValue(notifySmtpHostConfig); host != "" {
+		port, err := strconv.Atoi(ViteConfig.GetValue(notifySmtpPortConfig))
+		if err != nil || port <= 0 {
+			port = defaultSmtpPort
+		}
+		notifiers[notify.ChannelEmail] = &notify.Email{
+			Host:     host,
+			Port:     port,
+			Username: ViteConfig.GetValue(notifySmtpUsernameConfig),
+			Password: ViteConfig.GetValue(notifySmtpPasswordConfig),
+			From:     ViteConfig.GetValue(notifySmtpFromConfig),
+		}
+	}
+	if token := ViteConfig.GetValue(notifyTelegramTokenConfig); token != "" {
+		notifiers[notify.ChannelTelegram] = &notify.Telegram{Token: token, ApiBase: ViteConfig.GetValue(notifyTelegramApiConfig)}
+	}
+	return notifiers
+}
+
+func (s *NotifyService) available() []string {
+	notifiers := s.notifiers()
+	available := make([]string, 0, len(notifiers))
+	for _, channel := range notify.Channels {
+		if notifiers[channel] != nil {
+			available = append(available, channel)
+		}
+	}
+	return available
+}
+
+// flowThresholds 流量百分比阈值（升序，1-100）
+func (s *NotifyService) flowThresholds() []int {
+	return parseThresholds(ViteConfig.GetValue(notifyFlowThresholdsConfig), defaultNotifyFlowThresholds, 100)
+}
+
+// expiryDays 到期前提醒天数（升序，1-365）
+func (s *NotifyService) expiryDays() []int {
+	return parseThresholds(ViteConfig.GetValue(notifyExpiryDaysConfig), defaultNotifyExpiryDays, 365)
+}
+
+func parseThresholds(value, def string, max int) []int {
+	if strings.TrimSpace(value) == "" {
+		value = def
+	}
+	seen := make(map[int]bool)
+	out := []int{}
+	for _, part := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n <= 0 || n > max || seen[n] {
+			continue
+		}
+		seen[n] = true
+		out = append(out, n)
+	}
+	sort.Ints(out)
+	return out
+}
+
+func validateNotifySetting(setting *model.NotifySetting, channels []string) ([]string, error) {
+	seen := make(map[string]bool)
+	out := []string{}
+	for _, channel := range channels {
+		channel = strings.TrimSpace(channel)
+		if seen[channel] {
+			continue
+		}
+		switch channel {
+		case notify.ChannelEmail:
+			if _, err := mail.ParseAddress(setting.Email); err != nil {
+				return nil, errors.New("邮箱地址格式不正确")
+			}
+		case notify.ChannelTelegram:
+			if setting.TelegramChatId == "" {
+				return nil, errors.New("请填写 Telegram Chat ID")
+			}
+		case notify.ChannelWebhook:
+			u, err := url.Parse(setting.WebhookUrl)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, errors.New("Webhook 地址必须是 http(s) URL")
+			}
+		default:
+			return nil, fmt.Errorf("无效的通知渠道: %s", channel)
+		}
+		seen[channel] = true
+		out = append(out, channel)
+	}
+	return out, nil
+}
+
+func splitChannels(channels string) []string {
+	if channels == "" {
+		return []string{}
+	}
+	return strings.Split(channels, ",")
+}
+
+func daysLeft(expTime, now int64) int {
+	const day = int64(24 * time.Hour / time.Millisecond)
+	return int((expTime - now + day - 1) / day)
+}
+
+func formatBytes(n int64) string {
+	return fmt.Sprintf("%.2f GB", float64(n)/(1024*1024*1024))
+}
+
+func formatTime(ms int64) string {
+	return time.UnixMilli(ms).Format("2006-01-02 15:04")
+}
@@ -0,0 +1,155 @@
+package service_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/notify"
+	"go-backend/service"
+	"go-backend/testutil"
+	"go-backend/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// newNotifyReceiver 启动接收 webhook 通知的服务，返回地址和读取下一条通知的函数
+func newNotifyReceiver(t *testing.T) (string, func() *notify.Message) {
+	received := make(chan notify.Message, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg notify.Message
+		json.NewDecoder(r.Body).Decode(&msg)
+		received <- msg
+	}))
+	t.Cleanup(srv.Close)
+	next := func() *notify.Message {
+		select {
+		case msg := <-received:
+			return &msg
+		case <-time.After(500 * time.Millisecond):
+			return nil
+		}
+	}
+	return srv.URL, next
+}
+
+func notifyClaims(user *model.User) *utils.UserClaims {
+	return &utils.UserClaims{RoleId: model.RoleUser, RegisteredClaims: jwt.RegisteredClaims{Subject: strconv.FormatInt(user.ID, 10)}}
+}
+
+// createNotifyUser 创建 10GB 流量的用户并开启 webhook 流量和到期通知
+func createNotifyUser(t *testing.T, username string, url string, expTime int64) *model.User {
+	user := testutil.CreateUser(username, model.RoleUser, 5, 10, expTime)
+	res := service.Notify.UpdateSetting(notifyClaims(user), dto.NotifySettingDto{Channels: []string{"webhook"}, WebhookUrl: url, FlowAlert: 1, ExpiryAlert: 1})
+	if !assert.Equal(t, 0, res.Code, res.Msg) {
+		t.FailNow()
+	}
+	return user
+}
+
+func TestNotifySettingValidation(t *testing.T) {
+	user := testutil.CreateUser("notify_validate", model.RoleUser, 5, 10, time.Now().Add(24*time.Hour).UnixMilli())
+	claims := notifyClaims(user)
+
+	// 启用的渠道必须填写有效地址
+	assert.NotEqual(t, 0, service.Notify.UpdateSetting(claims, dto.NotifySettingDto{Channels: []string{"email"}, Email: "bad"}).Code)
+	assert.NotEqual(t, 0, service.Notify.UpdateSetting(claims, dto.NotifySettingDto{Channels: []string{"sms"}}).Code)
+}
+
+func TestFlowNotifyThresholds(t *testing.T) {
+	gb := int64(1024 * 1024 * 1024)
+	url, next := newNotifyReceiver(t)
+	user := createNotifyUser(t, "notify_flow", url, time.Now().Add(30*24*time.Hour).UnixMilli())
+
+	// 达到 80% 时通知一次，重复上报不再通知
+	user.InFlow = 8*gb + gb/2
+	service.Notify.CheckUserFlow(user)
+	msg := next()
+	if assert.NotNil(t, msg) {
+		assert.Equal(t, model.NotifyUserFlow, msg.Event)
+		assert.Equal(t, float64(80), msg.Data["threshold"])
+	}
+	service.Notify.CheckUserFlow(user)
+	assert.Nil(t, next())
+
+	// 一次跨过 95% 和 100% 只通知最高的阈值
+	user.InFlow = 10*gb + 1
+	service.Notify.CheckUserFlow(user)
+	msg = next()
+	if assert.NotNil(t, msg) {
+		assert.Equal(t, float64(100), msg.Data["threshold"])
+	}
+	service.Notify.CheckUserFlow(user)
+	assert.Nil(t, next())
+}
+
+func TestFlowNotifyAfterReset(t *testing.T) {
+	gb := int64(1024 * 1024 * 1024)
+	service.Forward.SkipGostSync = true
+	url, next := newNotifyReceiver(t)
+	user := createNotifyUser(t, "notify_reset", url, time.Now().Add(30*24*time.Hour).UnixMilli())
+
+	user.InFlow = 10*gb + 1
+	service.Notify.CheckUserFlow(user)
+	assert.NotNil(t, next())
+
+	// 重置流量后进入新周期，阈值可以再次通知
+	global.DB.Model(user).Update("in_flow", 10*gb+1)
+	assert.Equal(t, 0, service.User.ResetFlow(dto.ResetFlowDto{ID: user.ID, Type: 1}, &utils.UserClaims{}).Code)
+	user.InFlow = 9 * gb
+	service.Notify.CheckUserFlow(user)
+	msg := next()
+	if assert.NotNil(t, msg) {
+		assert.Equal(t, float64(80), msg.Data["threshold"])
+	}
+}
+
+func TestFlowNotifyDisabled(t *testing.T) {
+	gb := int64(1024 * 1024 * 1024)
+	url, next := newNotifyReceiver(t)
+	user := createNotifyUser(t, "notify_disabled", url, time.Now().Add(30*24*time.Hour).UnixMilli())
+
+	// 关闭流量通知后不再投递
+	service.Notify.UpdateSetting(notifyClaims(user), dto.NotifySettingDto{Channels: []string{"webhook"}, WebhookUrl: url, ExpiryAlert: 1})
+	user.InFlow = 10*gb + 1
+	service.Notify.CheckUserFlow(user)
+	assert.Nil(t, next())
+}
+
+func TestExpiryNotify(t *testing.T) {
+	now := time.Now()
+	url, next := newNotifyReceiver(t)
+	user := createNotifyUser(t, "notify_expiry", url, now.Add(60*time.Hour).UnixMilli())
+
+	// 到期前 3 天提醒，同一到期时间只提醒一次，续费后重新计算
+	service.Notify.CheckExpiry(now)
+	msg := next()
+	if assert.NotNil(t, msg) {
+		assert.Equal(t, model.NotifyUserExpiry, msg.Event)
+		assert.Equal(t, float64(3), msg.Data["threshold"])
+	}
+	service.Notify.CheckExpiry(now)
+	assert.Nil(t, next())
+	global.DB.Model(user).Update("exp_time", now.Add(20*time.Hour).UnixMilli())
+	service.Notify.CheckExpiry(now)
+	msg = next()
+	if assert.NotNil(t, msg) {
+		assert.Equal(t, float64(1), msg.Data["threshold"])
+	}
+}
+
+func TestNotifyCredentialsHidden(t *testing.T) {
+	// 通知凭据不通过公开配置接口返回
+	service.ViteConfig.UpdateConfig("notify_telegram_token", "secret-token")
+	configs := service.ViteConfig.GetConfigs().Data.(map[string]string)
+	_, exposed := configs["notify_telegram_token"]
+	assert.False(t, exposed)
+	assert.NotEqual(t, 0, service.ViteConfig.GetConfigByName("notify_telegram_token").Code)
+}
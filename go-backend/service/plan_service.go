@@ -0,0 +1,363 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/result"
+	"go-backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// PlanService 套餐目录，开通 / 续费时统一写入用户和用户隧道额度
+type PlanService struct{}
+
+var Plan = new(PlanService)
+
+const dayMillis = int64(24 * time.Hour / time.Millisecond)
+
+// List 套餐列表，附带隧道和订阅用户数
+func (s *PlanService) List() *result.Result {
+	var plans []model.Plan
+	global.DB.Preload("Tunnels").Order("id asc").Find(&plans)
+
+	type planDetail struct {
+		model.Plan
+		Subscribers int64 `json:"subscribers"`
+	}
+	list := make([]planDetail, 0, len(plans))
+	for _, p := range plans {
+		var count int64
+		global.DB.Model(&model.User{}).Where("plan_id = ?", p.ID).Count(&count)
+		list = append(list, planDetail{Plan: p, Subscribers: count})
+	}
+	return result.Ok(list)
+}
+
+func (s *PlanService) Create(planDto dto.PlanDto) *result.Result {
+	if err := s.validate(planDto); err != nil {
+		return result.Err(-1, err.Error())
+	}
+	now := time.Now().UnixMilli()
+	plan := model.Plan{Status: 1, CreatedTime: now}
+	s.fill(&plan, planDto, now)
+
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Tunnels").Create(&plan).Error; err != nil {
+			return err
+		}
+		return s.saveTunnels(tx, &plan, planDto.Tunnels)
+	})
+	if err != nil {
+		return result.Err(-1, "套餐创建失败: "+err.Error())
+	}
+	return result.Ok(plan)
+}
+
+// Update 修改套餐；Cascade 时同步到全部订阅用户，否则只影响之后开通或续费的用户
+func (s *PlanService) Update(updateDto dto.PlanUpdateDto) *result.Result {
+	var plan model.Plan
+	if err := global.DB.First(&plan, updateDto.ID).Error; err != nil {
+		return result.Err(-1, "套餐不存在")
+	}
+	if err := s.validate(updateDto.PlanDto); err != nil {
+		return result.Err(-1, err.Error())
+	}
+	s.fill(&plan, updateDto.PlanDto, time.Now().UnixMilli())
+
+	var subscribers []model.User
+	if updateDto.Cascade {
+		global.DB.Where("plan_id = ?", plan.ID).Find(&subscribers)
+	}
+
+	var changes []planChange
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Tunnels").Save(&plan).Error; err != nil {
+			return err
+		}
+		if err := s.saveTunnels(tx, &plan, updateDto.Tunnels); err != nil {
+			return err
+		}
+		for i := range subscribers {
+			change, err := s.apply(tx, &subscribers[i], &plan, subscribers[i].ExpTime, false)
+			if err != nil {
+				return err
+			}
+			changes = append(changes, change)
+		}
+		return nil
+	})
+	if err != nil {
+		return result.Err(-1, "套餐更新失败: "+err.Error())
+	}
+	for _, change := range changes {
+		s.sync(change)
+	}
+	return result.Ok(len(subscribers))
+}
+
+// Delete 删除套餐，仍有订阅用户时不能删除
+func (s *PlanService) Delete(id int64) *result.Result {
+	var count int64
+	global.DB.Model(&model.User{}).Where("plan_id = ?", id).Count(&count)
+	if count > 0 {
+		return result.Err(-1, "该套餐还有订阅用户，不能删除")
+	}
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("plan_id = ?", id).Delete(&model.PlanTunnel{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Plan{}, id).Error
+	})
+	if err != nil {
+		return result.Err(-1, "套餐删除失败")
+	}
+	return result.OkMsg("套餐删除成功")
+}
+
+// Assign 为用户开通套餐：额度按套餐重置，有效期从现在开始，已用流量清零并恢复暂停的转发
+func (s *PlanService) Assign(claims *utils.UserClaims, assignDto dto.PlanAssignDto) *result.Result {
+	user, err := s.subscriber(claims, assignDto.UserId)
+	if err != nil {
+		return result.Err(-1, err.Error())
+	}
+	var plan model.Plan
+	if err := global.DB.Preload("Tunnels").First(&plan, assignDto.PlanId).Error; err != nil {
+		return result.Err(-1, "套餐不存在")
+	}
+	if plan.Status != 1 {
+		return result.Err(-1, "套餐已下架")
+	}
+
+	expTime := int64(0)
+	if plan.Duration > 0 {
+		expTime = time.Now().UnixMilli() + int64(plan.Duration)*dayMillis
+	}
+	return s.subscribe(user, &plan, expTime, true, "套餐开通成功")
+}
+
+// Renew 续费当前套餐，额度同步为套餐最新设置
+func (s *PlanService) Renew(claims *utils.UserClaims, renewDto dto.PlanRenewDto) *result.Result {
+	user, err := s.subscriber(claims, renewDto.UserId)
+	if err != nil {
+		return result.Err(-1, err.Error())
+	}
+	if user.PlanId == 0 {
+		return result.Err(-1, "该用户未订阅套餐")
+	}
+	var plan model.Plan
+	if err := global.DB.Preload("Tunnels").First(&plan, user.PlanId).Error; err != nil {
+		return result.Err(-1, "套餐不存在")
+	}
+	periods := renewDto.Periods
+	if periods <= 0 {
+		periods = 1
+	}
+
+	expTime := int64(0)
+	if plan.Duration > 0 {
+		base := time.Now().UnixMilli()
+		if user.ExpTime > base {
+			base = user.ExpTime
+		}
+		expTime = base + int64(plan.Duration*periods)*dayMillis
+	}
+	return s.subscribe(user, &plan, expTime, renewDto.ResetFlow, "套餐续费成功")
+}
+
+// planChange 事务提交后需要同步到节点的变化
+type planChange struct {
+	userId        int64
+	speedChanged  []model.UserTunnel
+	disabled      []model.UserTunnel
+	resumeAllowed bool
+}
+
+func (s *PlanService) subscribe(user *model.User, plan *model.Plan, expTime int64, resetFlow bool, msg string) *result.Result {
+	var change planChange
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		change, err = s.apply(tx, user, plan, expTime, resetFlow)
+		return err
+	})
+	if err != nil {
+		return result.Err(-1, "套餐写入失败: "+err.Error())
+	}
+	s.sync(change)
+	return result.OkMsg(msg)
+}
+
+// apply 在事务中按套餐写入用户和用户隧道；套餐外、此前由套餐分配的隧道被停用
+func (s *PlanService) apply(tx *gorm.DB, user *model.User, plan *model.Plan, expTime int64, resetFlow bool) (planChange, error) {
+	change := planChange{userId: user.ID}
+	now := time.Now().UnixMilli()
+
+	user.PlanId = plan.ID
+	user.Flow = plan.Flow
+	user.Num = plan.Num
+	user.FlowResetTime = plan.FlowResetTime
+	user.ExpTime = expTime
+	user.Status = 1
+	user.UpdatedTime = now
+	if resetFlow {
+		user.InFlow, user.OutFlow = 0, 0
+	}
+	if err := tx.Save(user).Error; err != nil {
+		return change, err
+	}
+	if resetFlow {
+		if err := Notify.ClearFlowMarks(tx, model.NotifyUserFlow, user.ID); err != nil {
+			return change, err
+		}
+	}
+
+	inPlan := make(map[int]bool)
+	for _, pt := range plan.Tunnels {
+		inPlan[int(pt.TunnelId)] = true
+		flow := pt.Flow
+		if flow == 0 {
+			flow = plan.Flow
+		}
+
+		var ut model.UserTunnel
+		err := tx.Where("user_id = ? AND tunnel_id = ?", user.ID, pt.TunnelId).First(&ut).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return change, err
+		}
+		if err == nil && (ut.SpeedId != pt.SpeedId || ut.ConnLimitId != pt.ConnLimitId) {
+			change.speedChanged = append(change.speedChanged, ut)
+		}
+		ut.UserId = int(user.ID)
+		ut.TunnelId = int(pt.TunnelId)
+		ut.Flow = flow
+		ut.SpeedId = pt.SpeedId
+		ut.ConnLimitId = pt.ConnLimitId
+		ut.ExpTime = expTime
+		ut.FlowResetTime = plan.FlowResetTime
+		ut.Status = 1
+		ut.PlanId = plan.ID
+		if resetFlow {
+			ut.InFlow, ut.OutFlow = 0, 0
+		}
+		if err := tx.Save(&ut).Error; err != nil {
+			return change, err
+		}
+		if resetFlow {
+			if err := Notify.ClearFlowMarks(tx, model.NotifyTunnelFlow, int64(ut.ID)); err != nil {
+				return change, err
+			}
+		}
+	}
+
+	var stale []model.UserTunnel
+	tx.Where("user_id = ? AND plan_id != 0 AND status = 1", user.ID).Find(&stale)
+	for _, ut := range stale {
+		if inPlan[ut.TunnelId] {
+			continue
+		}
+		if err := tx.Model(&ut).Update("status", 0).Error; err != nil {
+			return change, err
+		}
+		change.disabled = append(change.disabled, ut)
+	}
+
+	change.resumeAllowed = user.Flow == 0 || user.InFlow+user.OutFlow < user.Flow*1024*1024*1024
+	return change, nil
+}
+
+// sync 事务提交后同步节点：更新限速、暂停被移出套餐的隧道下的转发、在额度内时恢复暂停的转发
+func (s *PlanService) sync(change planChange) {
+	for _, ut := range change.speedChanged {
+		var fresh model.UserTunnel
+		if global.DB.First(&fresh, ut.ID).Error == nil {
+			UserTunnel.updateUserTunnelForwardsSpeed(int64(fresh.UserId), int64(fresh.TunnelId), fresh.SpeedId)
+		}
+	}
+	for _, ut := range change.disabled {
+		var forwards []model.Forward
+		global.DB.Where("user_id = ? AND tunnel_id = ?", ut.UserId, ut.TunnelId).Find(&forwards)
+		for i := range forwards {
+			Forward.AutoPauseForward(&forwards[i], model.PauseAdmin)
+		}
+	}
+	if change.resumeAllowed {
+		User.resumeUserServices(change.userId, 0, model.PauseUserQuota, model.PauseTunnelQuota, model.PauseExpired)
+	}
+}
+
+// subscriber 开通套餐需要用户管理权限，且能管理目标用户
+func (s *PlanService) subscriber(claims *utils.UserClaims, userId int64) (*model.User, error) {
+	var user model.User
+	if err := global.DB.First(&user, userId).Error; err != nil {
+		return nil, errors.New("用户不存在")
+	}
+	if !canManageUser(claims, &user) {
+		return nil, errors.New("只有超级管理员可以操作后台账号")
+	}
+	return &user, nil
+}
+
+func (s *PlanService) validate(planDto dto.PlanDto) error {
+	if planDto.Flow < 0 || planDto.Num < 0 || planDto.Duration < 0 {
+		return errors.New("流量、转发数和有效天数不能为负数")
+	}
+	if planDto.FlowResetTime < 0 || planDto.FlowResetTime > 31 {
+		return errors.New("流量重置日必须在 1-31 之间，0 表示不重置")
+	}
+	seen := make(map[int64]bool)
+	for _, t := range planDto.Tunnels {
+		if seen[t.TunnelId] {
+			return errors.New("隧道不能重复")
+		}
+		seen[t.TunnelId] = true
+		var tunnel model.Tunnel
+		if err := global.DB.First(&tunnel, t.TunnelId).Error; err != nil {
+			return errors.New("隧道不存在")
+		}
+		if t.Flow < 0 {
+			return errors.New("隧道流量不能为负数")
+		}
+		if t.SpeedId != 0 {
+			var speed model.SpeedLimit
+			if err := global.DB.First(&speed, t.SpeedId).Error; err != nil || speed.TunnelId != t.TunnelId {
+				return errors.New("限速规则不存在或不属于该隧道")
+			}
+		}
+		if t.ConnLimitId != 0 && !connLimitExists(t.ConnLimitId) {
+			return errors.New("连接限制规则不存在")
+		}
+	}
+	return nil
+}
+
+func (s *PlanService) fill(plan *model.Plan, planDto dto.PlanDto, now int64) {
+	plan.Name = planDto.Name
+	plan.Flow = planDto.Flow
+	plan.Num = planDto.Num
+	plan.Duration = planDto.Duration
+	plan.FlowResetTime = planDto.FlowResetTime
+	if planDto.Status != nil {
+		plan.Status = *planDto.Status
+	}
+	plan.UpdatedTime = now
+}
+
+// saveTunnels 以提交的隧道列表整体替换套餐隧道
+func (s *PlanService) saveTunnels(tx *gorm.DB, plan *model.Plan, tunnels []dto.PlanTunnelDto) error {
+	if err := tx.Where("plan_id = ?", plan.ID).Delete(&model.PlanTunnel{}).Error; err != nil {
+		return err
+	}
+	plan.Tunnels = make([]model.PlanTunnel, 0, len(tunnels))
+	for _, t := range tunnels {
+		pt := model.PlanTunnel{PlanId: plan.ID, TunnelId: t.TunnelId, Flow: t.Flow, SpeedId: t.SpeedId, ConnLimitId: t.ConnLimitId}
+		if err := tx.Create(&pt).Error; err != nil {
+			return err
+		}
+		plan.Tunnels = append(plan.Tunnels, pt)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/service"
+	"go-backend/testutil"
+	"go-backend/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// planFixture 包含两条隧道的套餐：隧道 A 带限速，隧道 B 单独限 5GB
+type planFixture struct {
+	plan    model.Plan
+	tunnelA *model.Tunnel
+	tunnelB *model.Tunnel
+	speed   model.SpeedLimit
+}
+
+func newPlanFixture(t *testing.T, prefix string) *planFixture {
+	service.Forward.SkipGostSync = true
+	tunnelA := testutil.CreateTunnel(prefix + "_tunnel_a")
+	tunnelB := testutil.CreateTunnel(prefix + "_tunnel_b")
+	speed := model.SpeedLimit{Name: prefix + "_speed", Speed: 10, TunnelId: tunnelA.ID, Status: 1}
+	global.DB.Create(&speed)
+
+	res := service.Plan.Create(dto.PlanDto{Name: prefix, Flow: 10, Num: 3, Duration: 30, FlowResetTime: 1, Tunnels: []dto.PlanTunnelDto{
+		{TunnelId: tunnelA.ID, SpeedId: int(speed.ID)},
+		{TunnelId: tunnelB.ID, Flow: 5},
+	}})
+	if !assert.Equal(t, 0, res.Code, res.Msg) {
+		t.FailNow()
+	}
+	return &planFixture{plan: res.Data.(model.Plan), tunnelA: tunnelA, tunnelB: tunnelB, speed: speed}
+}
+
+// subscribe 创建已到期、超额并被暂停的用户并开通套餐，返回用户和两条隧道上的转发
+func (f *planFixture) subscribe(t *testing.T, username string, port int) (*model.User, model.Forward, model.Forward) {
+	gb := int64(1024 * 1024 * 1024)
+	user := testutil.CreateUser(username, model.RoleUser, 1, 1, time.Now().Add(-time.Hour).UnixMilli())
+	global.DB.Model(user).Updates(map[string]interface{}{"status": 0, "in_flow": 2 * gb})
+	fwdA := model.Forward{UserId: user.ID, Name: username + "_fwd_a", TunnelId: f.tunnelA.ID, InPort: port, Status: 0}
+	fwdB := model.Forward{UserId: user.ID, Name: username + "_fwd_b", TunnelId: f.tunnelB.ID, InPort: port + 1, Status: 0}
+	global.DB.Create(&fwdA)
+	global.DB.Create(&fwdB)
+
+	res := service.Plan.Assign(&utils.UserClaims{RoleId: model.RoleSuperAdmin}, dto.PlanAssignDto{UserId: user.ID, PlanId: f.plan.ID})
+	assert.Equal(t, 0, res.Code, res.Msg)
+	global.DB.First(user, user.ID)
+	return user, fwdA, fwdB
+}
+
+func TestPlanCreateValidation(t *testing.T) {
+	f := newPlanFixture(t, "plan_validate")
+
+	// 限速规则必须属于对应的隧道
+	assert.NotEqual(t, 0, service.Plan.Create(dto.PlanDto{Name: "错误限速", Tunnels: []dto.PlanTunnelDto{{TunnelId: f.tunnelB.ID, SpeedId: int(f.speed.ID)}}}).Code)
+}
+
+func TestPlanAssign(t *testing.T) {
+	f := newPlanFixture(t, "plan_assign")
+
+	// 已到期、超额并被暂停的用户开通套餐后恢复
+	user, fwdA, _ := f.subscribe(t, "plan_assign_user", 31001)
+	assert.Equal(t, f.plan.ID, user.PlanId)
+	assert.Equal(t, int64(10), user.Flow)
+	assert.Equal(t, 3, user.Num)
+	assert.Equal(t, 1, user.Status)
+	assert.Equal(t, int64(0), user.InFlow)
+	assert.InDelta(t, time.Now().Add(30*24*time.Hour).UnixMilli(), user.ExpTime, 5000)
+
+	var tunnels []model.UserTunnel
+	global.DB.Where("user_id = ?", user.ID).Order("tunnel_id").Find(&tunnels)
+	if assert.Len(t, tunnels, 2) {
+		assert.Equal(t, int(f.speed.ID), tunnels[0].SpeedId)
+		assert.Equal(t, int64(10), tunnels[0].Flow)
+		assert.Equal(t, int64(5), tunnels[1].Flow)
+		assert.Equal(t, user.ExpTime, tunnels[1].ExpTime)
+	}
+	global.DB.First(&fwdA, fwdA.ID)
+	assert.Equal(t, 1, fwdA.Status)
+}
+
+func TestPlanRenew(t *testing.T) {
+	f := newPlanFixture(t, "plan_renew")
+	user, _, _ := f.subscribe(t, "plan_renew_user", 31011)
+
+	// 续费从原到期时间顺延
+	res := service.Plan.Renew(&utils.UserClaims{RoleId: model.RoleSuperAdmin}, dto.PlanRenewDto{UserId: user.ID, Periods: 2})
+	assert.Equal(t, 0, res.Code, res.Msg)
+	var fresh model.User
+	global.DB.First(&fresh, user.ID)
+	assert.Equal(t, user.ExpTime+60*24*3600*1000, fresh.ExpTime)
+}
+
+func TestPlanUpdateCascade(t *testing.T) {
+	f := newPlanFixture(t, "plan_cascade")
+	user, _, fwdB := f.subscribe(t, "plan_cascade_user", 31021)
+
+	// 不级联时订阅用户不变；级联后额度同步，移出套餐的隧道停用并暂停其转发
+	update := dto.PlanUpdateDto{ID: f.plan.ID, PlanDto: dto.PlanDto{Name: "plan_cascade", Flow: 20, Num: 3, Duration: 30, FlowResetTime: 1, Tunnels: []dto.PlanTunnelDto{{TunnelId: f.tunnelA.ID}}}}
+	assert.Equal(t, 0, service.Plan.Update(update).Code)
+	var fresh model.User
+	global.DB.First(&fresh, user.ID)
+	assert.Equal(t, int64(10), fresh.Flow)
+
+	update.Cascade = true
+	res := service.Plan.Update(update)
+	assert.Equal(t, 0, res.Code, res.Msg)
+	assert.Equal(t, 1, res.Data)
+	global.DB.First(&fresh, user.ID)
+	assert.Equal(t, int64(20), fresh.Flow)
+	assert.Equal(t, user.ExpTime, fresh.ExpTime)
+	var tunnels []model.UserTunnel
+	global.DB.Where("user_id = ?", user.ID).Order("tunnel_id").Find(&tunnels)
+	if assert.Len(t, tunnels, 2) {
+		assert.Equal(t, 0, tunnels[0].SpeedId)
+		assert.Equal(t, 0, tunnels[1].Status)
+	}
+	global.DB.First(&fwdB, fwdB.ID)
+	assert.Equal(t, 0, fwdB.Status)
+}
+
+func TestPlanDeleteWithSubscribers(t *testing.T) {
+	f := newPlanFixture(t, "plan_delete")
+	f.subscribe(t, "plan_delete_user", 31031)
+
+	assert.Equal(t, "该套餐还有订阅用户，不能删除", service.Plan.Delete(f.plan.ID).Msg)
+}
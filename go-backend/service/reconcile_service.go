@@ -0,0 +1,561 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/result"
+	"go-backend/utils"
+	"go-backend/websocket"
+)
+
+// ReconcileService 节点配置对账
+// 以数据库为准计算每个节点期望的 service / chain / limiter，与节点上报的实际配置比对后只下发差异，
+// 节点离线期间丢失的命令会在下一次配置上报时自动补齐。
+type ReconcileService struct {
+	locks   sync.Map // nodeId -> *sync.Mutex
+	reports sync.Map // nodeId -> *ReconcileReport，最近一次对账结果
+}
+
+var Reconcile = new(ReconcileService)
+
+// 面板管理的资源命名规则，不匹配的资源（如 web_api）不会被对账删除
+var (
+	managedServicePattern   = regexp.MustCompile(`^(\d+_\d+_\d+_(tcp|udp|tls)|tunnel_\d+_relay(_\d+)?)$`)
+	managedChainPattern     = regexp.MustCompile(`^(\d+_\d+_\d+_chains|tunnel_\d+_chains)$`)
+	managedLimiterPattern   = regexp.MustCompile(`^\d+$`)
+	managedCLimiterPattern  = regexp.MustCompile(`^climit_\d+$`)
+	managedRLimiterPattern  = regexp.MustCompile(`^rlimit_\d+$`)
+	managedAdmissionPattern = regexp.MustCompile(`^\d+_\d+_\d+_(allow|deny)$`)
+)
+
+// NodeDesiredState 节点期望状态，key 均为 gost 中的完整名称
+type NodeDesiredState struct {
+	Services   map[string]map[string]interface{}
+	Paused     map[string]bool
+	Chains     map[string]map[string]interface{}
+	Limiters   map[string]map[string]interface{}
+	CLimiters  map[string]map[string]interface{}
+	RLimiters  map[string]map[string]interface{}
+	Admissions map[string]map[string]interface{}
+}
+
+// ReconcileReport 单次对账结果
+type ReconcileReport struct {
+	NodeId      int64    `json:"nodeId"`
+	Added       []string `json:"added"`
+	Updated     []string `json:"updated"`
+	Deleted     []string `json:"deleted"`
+	Paused      []string `json:"paused"`
+	Resumed     []string `json:"resumed"`
+	Errors      []string `json:"errors"`
+	CheckedTime int64    `json:"checkedTime"`
+}
+
+// Changed 是否有任何变更或错误
+func (r *ReconcileReport) Changed() bool {
+	return len(r.Added)+len(r.Updated)+len(r.Deleted)+len(r.Paused)+len(r.Resumed)+len(r.Errors) > 0
+}
+
+func (r *ReconcileReport) fail(action, name, msg string) {
+	r.Errors = append(r.Errors, fmt.Sprintf("%s %s: %s", action, name, msg))
+}
+
+// Start 注册节点上线回调，节点（重新）连接后立即下发完整配置快照
+func (s *ReconcileService) Start() {
+	websocket.OnNodeOnline(func(nodeId int64) {
+		s.PushSnapshot(nodeId)
+	})
+}
+
+// BuildDesiredState 根据数据库计算节点的期望状态
+func (s *ReconcileService) BuildDesiredState(nodeId int64) *NodeDesiredState {
+	state := &NodeDesiredState{
+		Services:   make(map[string]map[string]interface{}),
+		Paused:     make(map[string]bool),
+		Chains:     make(map[string]map[string]interface{}),
+		Limiters:   make(map[string]map[string]interface{}),
+		CLimiters:  make(map[string]map[string]interface{}),
+		RLimiters:  make(map[string]map[string]interface{}),
+		Admissions: make(map[string]map[string]interface{}),
+	}
+
+	// 节点作为主入口/出口或附加入口/出口所在的隧道
+	var groupNodes []model.TunnelNode
+	global.DB.Where("node_id = ?", nodeId).Find(&groupNodes)
+	groupRoles := make(map[int64]model.TunnelNode)
+	groupTunnelIds := []int64{}
+	for _, n := range groupNodes {
+		groupRoles[n.TunnelId] = n
+		groupTunnelIds = append(groupTunnelIds, n.TunnelId)
+	}
+
+	var tunnels []model.Tunnel
+	query := global.DB.Where("in_node_id = ? OR out_node_id = ?", nodeId, nodeId)
+	if len(groupTunnelIds) > 0 {
+		query = query.Or("id IN ?", groupTunnelIds)
+	}
+	query.Find(&tunnels)
+
+	// 转发所属用户是否可用，同一用户的多个转发只查询一次
+	activeUsers := make(map[int64]bool)
+	now := time.Now().UnixMilli()
+	userActive := func(userId int64) bool {
+		active, ok := activeUsers[userId]
+		if !ok {
+			var user model.User
+			active = global.DB.First(&user, userId).Error == nil && user.Status == 1 && (user.ExpTime <= 0 || user.ExpTime > now)
+			activeUsers[userId] = active
+		}
+		return active
+	}
+
+	for i := range tunnels {
+		tunnel := &tunnels[i]
+		group, inGroup := groupRoles[tunnel.ID]
+
+		if tunnel.InNodeId == nodeId || (inGroup && group.Role == model.TunnelNodeEntry) {
+			// 隧道转发的共享 chain
+			if tunnel.Type == 2 && tunnel.OutPort > 0 {
+				transits, exits := tunnelChainPath(tunnel)
+				state.Chains[utils.BuildTunnelChainName(tunnel.ID)] = utils.BuildTunnelChainConfig(*tunnel, transits, exits)
+			}
+
+			// 限速器部署在入口节点
+			var speedLimits []model.SpeedLimit
+			global.DB.Where("tunnel_id = ?", tunnel.ID).Find(&speedLimits)
+			for _, sl := range speedLimits {
+				state.Limiters[fmt.Sprintf("%d", sl.ID)] = utils.BuildLimiterConfig(sl.ID, speedToMBps(sl.Speed))
+			}
+
+			var forwards []model.Forward
+			global.DB.Where("tunnel_id = ?", tunnel.ID).Find(&forwards)
+			for j := range forwards {
+				paused := forwards[j].Status != 1 || tunnel.Status != 1 || !userActive(forwards[j].UserId)
+				s.addForwardServices(state, &forwards[j], tunnel, paused)
+			}
+		}
+
+		// 出口节点的共享 relay service
+		if tunnel.Type == 2 && tunnel.OutNodeId == nodeId && tunnel.OutPort > 0 {
+			state.Services[utils.BuildTunnelServiceName(tunnel.ID)] = utils.BuildTunnelRelayConfig(tunnel.ID, tunnel.OutPort, tunnel.Protocol, tunnel.InterfaceName)
+		}
+		if tunnel.Type == 2 && inGroup && group.Role == model.TunnelNodeExit && group.Port > 0 {
+			state.Services[utils.BuildTunnelServiceName(tunnel.ID)] = utils.BuildTunnelRelayConfig(tunnel.ID, group.Port, tunnel.Protocol, tunnel.InterfaceName)
+		}
+	}
+
+	// 作为中转节点的 relay service
+	var hops []model.TunnelHop
+	global.DB.Where("node_id = ?", nodeId).Find(&hops)
+	for _, h := range hops {
+		var tunnel model.Tunnel
+		if err := global.DB.First(&tunnel, h.TunnelId).Error; err != nil || tunnel.Type != 2 {
+			continue
+		}
+		state.Services[utils.BuildTunnelHopServiceName(h.TunnelId, h.Inx)] = utils.BuildTunnelHopRelayConfig(h.TunnelId, h.Inx, h.Port, tunnel.Protocol)
+	}
+
+	return state
+}
+
+// addForwardServices paused 为 true 时服务只注册不监听（转发、隧道或所属用户被停用）
+func (s *ReconcileService) addForwardServices(state *NodeDesiredState, forward *model.Forward, tunnel *model.Tunnel, paused bool) {
+	var userTunnel model.UserTunnel
+	global.DB.Where("user_id = ? AND tunnel_id = ?", forward.UserId, tunnel.ID).First(&userTunnel)
+
+	var limiter *int
+	if userTunnel.SpeedId != 0 {
+		limiter = &userTunnel.SpeedId
+	}
+
+	interfaceName := ""
+	if tunnel.Type == 1 {
+		interfaceName = forward.InterfaceName
+	}
+
+	// 连接数限制器随引用它的转发部署在入口节点
+	connLimit := effectiveConnLimit(forward, &userTunnel)
+	if connLimit != nil {
+		var cl model.ConnLimit
+		if err := global.DB.First(&cl, *connLimit).Error; err == nil {
+			state.CLimiters[utils.BuildConnLimiterName(cl.ID)] = utils.BuildConnLimiterConfig(cl)
+			state.RLimiters[utils.BuildRateLimiterName(cl.ID)] = utils.BuildRateLimiterConfig(cl)
+		} else {
+			connLimit = nil
+		}
+	}
+
+	serviceName := Forward.buildServiceName(forward.ID, forward.UserId, &userTunnel)
+	acl := forwardAcl(forward, tunnel)
+	for _, cfg := range acl.BuildAdmissionConfigs(serviceName) {
+		state.Admissions[cfg["name"].(string)] = cfg
+	}
+	for _, cfg := range utils.BuildServiceConfigs(serviceName, forward.InPort, limiter, connLimit, acl, forward.RemoteAddr, tunnel.Type, *tunnel, forward.Strategy, interfaceName) {
+		name := cfg["name"].(string)
+		state.Services[name] = cfg
+		if paused {
+			state.Paused[name] = true
+		}
+	}
+}
+
+// ReconcileNode 将节点上报的实际配置与期望状态对账，并下发差异
+// 同一节点同时只允许一个对账在执行，重叠的请求直接跳过
+func (s *ReconcileService) ReconcileNode(nodeId int64, actual *dto.GostConfigDto) *ReconcileReport {
+	lock := s.nodeLock(nodeId)
+	if !lock.TryLock() {
+		return nil
+	}
+	defer lock.Unlock()
+
+	desired := s.BuildDesiredState(nodeId)
+	report := &ReconcileReport{NodeId: nodeId, CheckedTime: time.Now().UnixMilli()}
+	defer s.reports.Store(nodeId, report)
+
+	actualServices := make(map[string]dto.GostService)
+	for _, svc := range actual.Services {
+		actualServices[svc.Name] = svc
+	}
+	actualChains := make(map[string]dto.GostChain)
+	for _, ch := range actual.Chains {
+		actualChains[ch.Name] = ch
+	}
+	actualLimiters := make(map[string]dto.GostLimiter)
+	for _, l := range actual.Limiters {
+		actualLimiters[l.Name] = l
+	}
+	actualAdmissions := make(map[string]dto.GostAdmission)
+	for _, a := range actual.Admissions {
+		actualAdmissions[a.Name] = a
+	}
+
+	// 1. 限速器、admission 和 chain 先于 service 下发，service 会引用它们
+	for _, name := range sortedKeys(desired.Limiters) {
+		cfg := desired.Limiters[name]
+		cur, ok := actualLimiters[name]
+		if !ok {
+			s.apply(report, &report.Added, "add limiter", name, utils.AddLimiterConfig(nodeId, cfg))
+		} else if limiterSignature(cur) != limiterSignature(toGostLimiter(cfg)) {
+			s.apply(report, &report.Updated, "update limiter", name, utils.UpdateLimiterConfig(nodeId, name, cfg))
+		}
+	}
+	s.reconcileKindLimiters(report, nodeId, utils.ConnLimiterKind, desired.CLimiters, actual.CLimiters)
+	s.reconcileKindLimiters(report, nodeId, utils.RateLimiterKind, desired.RLimiters, actual.RLimiters)
+	for _, name := range sortedKeys(desired.Admissions) {
+		cfg := desired.Admissions[name]
+		cur, ok := actualAdmissions[name]
+		if !ok {
+			s.apply(report, &report.Added, "add admission", name, utils.AddAdmissionConfig(nodeId, cfg))
+		} else if admissionSignature(cur) != admissionSignature(toGostAdmission(cfg)) {
+			s.apply(report, &report.Updated, "update admission", name, utils.UpdateAdmissionConfig(nodeId, name, cfg))
+		}
+	}
+	for _, name := range sortedKeys(desired.Chains) {
+		cfg := desired.Chains[name]
+		cur, ok := actualChains[name]
+		if !ok {
+			s.apply(report, &report.Added, "add chain", name, utils.AddChainConfig(nodeId, cfg))
+		} else if chainSignature(cur) != chainSignature(toGostChain(cfg)) {
+			s.apply(report, &report.Updated, "update chain", name, utils.UpdateChainConfig(nodeId, name, cfg))
+		}
+	}
+
+	// 2. service 新增 / 更新 / 暂停 / 恢复
+	for _, name := range sortedKeys(desired.Services) {
+		cfg := desired.Services[name]
+		wantPaused := desired.Paused[name]
+		cur, ok := actualServices[name]
+
+		if !ok {
+			if !s.apply(report, &report.Added, "add service", name, utils.SendServiceConfigs(nodeId, []map[string]interface{}{cfg}, "AddService")) {
+				continue
+			}
+			if wantPaused {
+				s.apply(report, &report.Paused, "pause service", name, utils.SendServiceNames(nodeId, []string{name}, "PauseService"))
+			}
+			continue
+		}
+
+		// UpdateService 会重新启动服务，更新后需要按期望状态重新暂停
+		isPaused := isServicePaused(cur)
+		if serviceSignature(cur) != serviceSignature(toGostService(cfg)) {
+			if !s.apply(report, &report.Updated, "update service", name, utils.SendServiceConfigs(nodeId, []map[string]interface{}{cfg}, "UpdateService")) {
+				continue
+			}
+			isPaused = false
+		}
+
+		if wantPaused && !isPaused {
+			s.apply(report, &report.Paused, "pause service", name, utils.SendServiceNames(nodeId, []string{name}, "PauseService"))
+		} else if !wantPaused && isPaused {
+			s.apply(report, &report.Resumed, "resume service", name, utils.SendServiceNames(nodeId, []string{name}, "ResumeService"))
+		}
+	}
+
+	// 3. 清理多余的资源，先 service 后 chain / limiter
+	var extraServices []string
+	for name := range actualServices {
+		if _, ok := desired.Services[name]; !ok && managedServicePattern.MatchString(name) {
+			extraServices = append(extraServices, name)
+		}
+	}
+	sort.Strings(extraServices)
+	if len(extraServices) > 0 {
+		res := utils.SendServiceNames(nodeId, extraServices, "DeleteService")
+		if res.Msg == "OK" {
+			report.Deleted = append(report.Deleted, extraServices...)
+		} else {
+			report.fail("delete service", strings.Join(extraServices, ","), res.Msg)
+		}
+	}
+	for _, name := range sortedKeys(actualChains) {
+		if _, ok := desired.Chains[name]; !ok && managedChainPattern.MatchString(name) {
+			s.apply(report, &report.Deleted, "delete chain", name, utils.DeleteChainByName(nodeId, name))
+		}
+	}
+	for _, name := range sortedKeys(actualLimiters) {
+		if _, ok := desired.Limiters[name]; !ok && managedLimiterPattern.MatchString(name) {
+			s.apply(report, &report.Deleted, "delete limiter", name, utils.DeleteLimiterByName(nodeId, name))
+		}
+	}
+	for _, name := range sortedKeys(actualAdmissions) {
+		if _, ok := desired.Admissions[name]; !ok && managedAdmissionPattern.MatchString(name) {
+			s.apply(report, &report.Deleted, "delete admission", name, utils.DeleteAdmissionByName(nodeId, name))
+		}
+	}
+	s.deleteExtraKindLimiters(report, nodeId, utils.ConnLimiterKind, desired.CLimiters, actual.CLimiters, managedCLimiterPattern)
+	s.deleteExtraKindLimiters(report, nodeId, utils.RateLimiterKind, desired.RLimiters, actual.RLimiters, managedRLimiterPattern)
+
+	if report.Changed() {
+		log.Printf("🔁 节点 %d 配置对账: 新增 %d, 更新 %d, 删除 %d, 暂停 %d, 恢复 %d, 失败 %d",
+			nodeId, len(report.Added), len(report.Updated), len(report.Deleted), len(report.Paused), len(report.Resumed), len(report.Errors))
+		for _, e := range report.Errors {
+			log.Printf("⚠️ 节点 %d 对账失败: %s", nodeId, e)
+		}
+	}
+	return report
+}
+
+// LastReports 各节点最近一次对账结果，nodeId 不为空时只返回该节点
+func (s *ReconcileService) LastReports(nodeId *int64) *result.Result {
+	reports := []*ReconcileReport{}
+	s.reports.Range(func(key, value interface{}) bool {
+		if nodeId == nil || key.(int64) == *nodeId {
+			reports = append(reports, value.(*ReconcileReport))
+		}
+		return true
+	})
+	sort.Slice(reports, func(i, j int) bool { return reports[i].NodeId < reports[j].NodeId })
+	return result.Ok(reports)
+}
+
+// reconcileKindLimiters 新增或更新 climiter / rlimiter
+func (s *ReconcileService) reconcileKindLimiters(report *ReconcileReport, nodeId int64, kind string, desired map[string]map[string]interface{}, actual []dto.GostLimiter) {
+	actualByName := make(map[string]dto.GostLimiter)
+	for _, l := range actual {
+		actualByName[l.Name] = l
+	}
+	for _, name := range sortedKeys(desired) {
+		cfg := desired[name]
+		cur, ok := actualByName[name]
+		if !ok {
+			s.apply(report, &report.Added, "add "+kind, name, utils.AddKindLimiter(nodeId, kind, cfg))
+		} else if limiterSignature(cur) != limiterSignature(toGostLimiter(cfg)) {
+			s.apply(report, &report.Updated, "update "+kind, name, utils.UpdateKindLimiter(nodeId, kind, name, cfg))
+		}
+	}
+}
+
+// deleteExtraKindLimiters 删除节点上不再被引用的 climiter / rlimiter
+func (s *ReconcileService) deleteExtraKindLimiters(report *ReconcileReport, nodeId int64, kind string, desired map[string]map[string]interface{}, actual []dto.GostLimiter, pattern *regexp.Regexp) {
+	for _, l := range actual {
+		if _, ok := desired[l.Name]; !ok && pattern.MatchString(l.Name) {
+			s.apply(report, &report.Deleted, "delete "+kind, l.Name, utils.DeleteKindLimiter(nodeId, kind, l.Name))
+		}
+	}
+}
+
+// PushSnapshot 向节点下发完整配置快照，节点据此整体替换 service / chain / limiter
+// 在节点（重新）连接时调用，保证重装节点或恢复镜像后配置与面板一致
+func (s *ReconcileService) PushSnapshot(nodeId int64) error {
+	lock := s.nodeLock(nodeId)
+	lock.Lock()
+	defer lock.Unlock()
+
+	state := s.BuildDesiredState(nodeId)
+	snapshot := map[string]interface{}{
+		"services":   state.snapshotServices(),
+		"chains":     snapshotList(state.Chains),
+		"limiters":   snapshotList(state.Limiters),
+		"climiters":  snapshotList(state.CLimiters),
+		"rlimiters":  snapshotList(state.RLimiters),
+		"admissions": snapshotList(state.Admissions),
+	}
+
+	res := utils.ApplySnapshot(nodeId, snapshot)
+	if res.Msg != "OK" {
+		log.Printf("⚠️ 节点 %d 配置快照下发失败: %s", nodeId, res.Msg)
+		return fmt.Errorf("配置快照下发失败: %s", res.Msg)
+	}
+	log.Printf("📦 节点 %d 配置快照已下发: services=%d chains=%d limiters=%d", nodeId, len(state.Services), len(state.Chains), len(state.Limiters))
+	return nil
+}
+
+// snapshotServices 暂停的服务通过 metadata.paused 标记，节点只注册不启动
+func (state *NodeDesiredState) snapshotServices() []map[string]interface{} {
+	services := snapshotList(state.Services)
+	for _, cfg := range services {
+		if !state.Paused[cfg["name"].(string)] {
+			continue
+		}
+		metadata, _ := cfg["metadata"].(map[string]interface{})
+		if metadata == nil {
+			metadata = make(map[string]interface{})
+		}
+		metadata["paused"] = true
+		cfg["metadata"] = metadata
+	}
+	return services
+}
+
+func snapshotList(m map[string]map[string]interface{}) []map[string]interface{} {
+	list := make([]map[string]interface{}, 0, len(m))
+	for _, name := range sortedKeys(m) {
+		list = append(list, m[name])
+	}
+	return list
+}
+
+// apply 记录命令结果，成功返回 true
+func (s *ReconcileService) apply(report *ReconcileReport, bucket *[]string, action, name string, res *dto.GostDto) bool {
+	if res.Msg != "OK" {
+		report.fail(action, name, res.Msg)
+		return false
+	}
+	*bucket = append(*bucket, name)
+	return true
+}
+
+func (s *ReconcileService) nodeLock(nodeId int64) *sync.Mutex {
+	lock, _ := s.locks.LoadOrStore(nodeId, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// --- 配置比对 ---
+
+// serviceSignature 只比较面板会下发的字段，忽略节点运行时补充的状态信息
+func serviceSignature(svc dto.GostService) string {
+	parts := []string{
+		svc.Addr,
+		mapString(svc.Handler, "type"),
+		mapString(svc.Handler, "chain"),
+		mapString(svc.Listener, "type"),
+		normalizeLimiterRef(svc.Limiter),
+		svc.CLimiter,
+		svc.RLimiter,
+		strings.Join(svc.Admissions, ","),
+		mapString(svc.Metadata, "interface"),
+	}
+	if svc.Forwarder != nil {
+		for _, node := range svc.Forwarder.Nodes {
+			parts = append(parts, node.Addr)
+		}
+		parts = append(parts, mapString(svc.Forwarder.Selector, "strategy"))
+	}
+	return strings.Join(parts, "|")
+}
+
+func chainSignature(ch dto.GostChain) string {
+	var parts []string
+	for _, hop := range ch.Hops {
+		// failTimeout 上报格式与下发不同，只比较策略和失败次数
+		parts = append(parts, mapString(hop.Selector, "strategy"), mapString(hop.Selector, "maxFails"))
+		for _, node := range hop.Nodes {
+			parts = append(parts, hop.Name, node.Addr, node.Interface, mapString(node.Connector, "type"), mapString(node.Dialer, "type"))
+		}
+	}
+	return strings.Join(parts, "|")
+}
+
+func limiterSignature(l dto.GostLimiter) string {
+	return strings.Join(l.Limits, "|")
+}
+
+func admissionSignature(a dto.GostAdmission) string {
+	return fmt.Sprintf("%v|%s", a.Whitelist, strings.Join(a.Matchers, ","))
+}
+
+func isServicePaused(svc dto.GostService) bool {
+	v, ok := svc.Metadata["paused"]
+	return ok && (v == true || v == "true")
+}
+
+// normalizeLimiterRef 历史数据中未限速的 service 可能引用了 "0"
+func normalizeLimiterRef(name string) string {
+	if name == "0" {
+		return ""
+	}
+	return name
+}
+
+func mapString(m map[string]interface{}, key string) string {
+	if m == nil {
+		return ""
+	}
+	if v, ok := m[key]; ok && v != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return ""
+}
+
+func toGostService(cfg map[string]interface{}) dto.GostService {
+	var svc dto.GostService
+	convertConfig(cfg, &svc)
+	return svc
+}
+
+func toGostChain(cfg map[string]interface{}) dto.GostChain {
+	var ch dto.GostChain
+	convertConfig(cfg, &ch)
+	return ch
+}
+
+func toGostAdmission(cfg map[string]interface{}) dto.GostAdmission {
+	var a dto.GostAdmission
+	convertConfig(cfg, &a)
+	return a
+}
+
+func toGostLimiter(cfg map[string]interface{}) dto.GostLimiter {
+	var l dto.GostLimiter
+	convertConfig(cfg, &l)
+	return l
+}
+
+// convertConfig 将下发用的 map 配置转换为与节点上报一致的结构
+func convertConfig(cfg map[string]interface{}, out interface{}) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return
+	}
+	json.Unmarshal(data, out)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,184 @@
+package service_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/service"
+	"go-backend/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReconcileDesiredState verifies the desired node state is derived from the DB,
+// including limiter references and paused forwards.
+func TestReconcileDesiredState(t *testing.T) {
+	user := testutil.CreateUser("user_reconcile", 1, 10, 999999, time.Now().Add(24*time.Hour).UnixMilli())
+	tunnel := testutil.CreateTunnel("tunnel_reconcile")
+
+	speed := model.SpeedLimit{Name: "10M", Speed: 80, TunnelId: tunnel.ID, Status: 1}
+	global.DB.Create(&speed)
+	ut := model.UserTunnel{UserId: int(user.ID), TunnelId: int(tunnel.ID), SpeedId: int(speed.ID), Status: 1}
+	global.DB.Create(&ut)
+
+	forward := model.Forward{UserId: user.ID, TunnelId: tunnel.ID, InPort: 10050, RemoteAddr: "1.1.1.1:80", Status: 0}
+	global.DB.Create(&forward)
+
+	state := service.Reconcile.BuildDesiredState(tunnel.InNodeId)
+
+	name := fmt.Sprintf("%d_%d_%d_tcp", forward.ID, user.ID, ut.ID)
+	svc, ok := state.Services[name]
+	assert.True(t, ok, "forward tcp service should be desired on the in node")
+	assert.Equal(t, fmt.Sprintf("%d", speed.ID), svc["limiter"])
+	assert.True(t, state.Paused[name], "paused forward should stay paused")
+
+	limiter, ok := state.Limiters[fmt.Sprintf("%d", speed.ID)]
+	assert.True(t, ok, "speed limit should be desired on the in node")
+	assert.Equal(t, []string{"$ 10.0MB 10.0MB"}, limiter["limits"])
+}
+
+// TestTunnelHopDesiredState verifies a multi-hop tunnel chains through every
+// transit node in order and deploys a relay service on each transit node.
+func TestTunnelHopDesiredState(t *testing.T) {
+	tunnel := model.Tunnel{Name: "tunnel_hops", Type: 2, Status: 1, InNodeId: 910, OutNodeId: 913, OutIp: "10.0.0.3", OutPort: 20000, Protocol: "tls"}
+	global.DB.Create(&tunnel)
+	global.DB.Create(&model.TunnelHop{TunnelId: tunnel.ID, Inx: 1, NodeId: 911, Ip: "10.0.0.1", Port: 20001})
+	global.DB.Create(&model.TunnelHop{TunnelId: tunnel.ID, Inx: 2, NodeId: 912, Ip: "10.0.0.2", Port: 20002})
+
+	chain, ok := service.Reconcile.BuildDesiredState(910).Chains[fmt.Sprintf("tunnel_%d_chains", tunnel.ID)]
+	assert.True(t, ok, "tunnel chain should be desired on the in node")
+	hops, _ := chain["hops"].([]map[string]interface{})
+	if assert.Len(t, hops, 3, "chain should have one hop per transit node plus the exit") {
+		addrs := []string{}
+		for _, h := range hops {
+			addrs = append(addrs, h["nodes"].([]map[string]interface{})[0]["addr"].(string))
+		}
+		assert.Equal(t, []string{"10.0.0.1:20001", "10.0.0.2:20002", "10.0.0.3:20000"}, addrs)
+	}
+
+	svc, ok := service.Reconcile.BuildDesiredState(912).Services[fmt.Sprintf("tunnel_%d_relay_2", tunnel.ID)]
+	assert.True(t, ok, "relay service should be desired on the transit node")
+	assert.Equal(t, ":20002", svc["addr"])
+}
+
+// TestTunnelNodeGroupDesiredState verifies forwards are published on every
+// entry node and the chain's last hop balances across the exit group.
+func TestTunnelNodeGroupDesiredState(t *testing.T) {
+	tunnel := model.Tunnel{Name: "tunnel_groups", Type: 2, Status: 1, InNodeId: 920, OutNodeId: 922, OutIp: "10.0.1.2", OutPort: 21000, Protocol: "tls", Strategy: "round"}
+	global.DB.Create(&tunnel)
+	global.DB.Create(&model.TunnelNode{TunnelId: tunnel.ID, NodeId: 921, Role: model.TunnelNodeEntry, Ip: "10.0.1.1"})
+	global.DB.Create(&model.TunnelNode{TunnelId: tunnel.ID, NodeId: 923, Role: model.TunnelNodeExit, Ip: "10.0.1.3", Port: 21001})
+
+	user := testutil.CreateUser("user_groups", 1, 10, 999999, time.Now().Add(24*time.Hour).UnixMilli())
+	forward := model.Forward{UserId: user.ID, TunnelId: tunnel.ID, InPort: 10060, RemoteAddr: "1.1.1.1:80", Status: 1}
+	global.DB.Create(&forward)
+
+	state := service.Reconcile.BuildDesiredState(921)
+	_, ok := state.Services[fmt.Sprintf("%d_%d_0_tcp", forward.ID, user.ID)]
+	assert.True(t, ok, "forward should be published on the extra entry node")
+
+	chain, ok := state.Chains[fmt.Sprintf("tunnel_%d_chains", tunnel.ID)]
+	if assert.True(t, ok, "tunnel chain should be desired on the extra entry node") {
+		hops := chain["hops"].([]map[string]interface{})
+		last := hops[len(hops)-1]
+		assert.Len(t, last["nodes"], 2, "last hop should contain every exit node")
+		assert.Equal(t, "round", last["selector"].(map[string]interface{})["strategy"])
+	}
+
+	svc, ok := service.Reconcile.BuildDesiredState(923).Services[fmt.Sprintf("tunnel_%d_relay", tunnel.ID)]
+	assert.True(t, ok, "relay service should be desired on the extra exit node")
+	assert.Equal(t, ":21001", svc["addr"])
+}
+
+func TestConnLimitDesiredState(t *testing.T) {
+	tunnel := model.Tunnel{Name: "tunnel_conn_limit", Type: 1, Status: 1, InNodeId: 930}
+	global.DB.Create(&tunnel)
+
+	limit := model.ConnLimit{Name: "scanner_guard", Conns: 200, IpConns: 20, Rate: 50, Status: 1}
+	global.DB.Create(&limit)
+
+	user := testutil.CreateUser("user_conn_limit", 1, 10, 999999, time.Now().Add(24*time.Hour).UnixMilli())
+	forward := model.Forward{UserId: user.ID, TunnelId: tunnel.ID, InPort: 10070, RemoteAddr: "1.1.1.1:80", Status: 1, ConnLimitId: int(limit.ID)}
+	global.DB.Create(&forward)
+
+	state := service.Reconcile.BuildDesiredState(930)
+	climiter, ok := state.CLimiters[fmt.Sprintf("climit_%d", limit.ID)]
+	if assert.True(t, ok, "conn limiter should be desired on the entry node") {
+		assert.Equal(t, []string{"$ 200", "$$ 20"}, climiter["limits"])
+	}
+	rlimiter, ok := state.RLimiters[fmt.Sprintf("rlimit_%d", limit.ID)]
+	if assert.True(t, ok, "rate limiter should be desired on the entry node") {
+		assert.Equal(t, []string{"$ 50"}, rlimiter["limits"])
+	}
+
+	svc := state.Services[fmt.Sprintf("%d_%d_0_tcp", forward.ID, user.ID)]
+	assert.Equal(t, fmt.Sprintf("climit_%d", limit.ID), svc["climiter"])
+	assert.Equal(t, fmt.Sprintf("rlimit_%d", limit.ID), svc["rlimiter"])
+}
+
+func TestForwardAclDesiredState(t *testing.T) {
+	tunnel := model.Tunnel{Name: "tunnel_acl", Type: 1, Status: 1, InNodeId: 940, DenyCidrs: "203.0.113.0/24"}
+	global.DB.Create(&tunnel)
+
+	user := testutil.CreateUser("user_acl", 1, 10, 999999, time.Now().Add(24*time.Hour).UnixMilli())
+	forward := model.Forward{UserId: user.ID, TunnelId: tunnel.ID, InPort: 10080, RemoteAddr: "1.1.1.1:80", Status: 1, AllowCidrs: "198.51.100.0/24,192.0.2.10"}
+	global.DB.Create(&forward)
+
+	state := service.Reconcile.BuildDesiredState(940)
+	base := fmt.Sprintf("%d_%d_0", forward.ID, user.ID)
+
+	allow, ok := state.Admissions[base+"_allow"]
+	if assert.True(t, ok, "allow list should be desired on the entry node") {
+		assert.Equal(t, true, allow["whitelist"])
+		assert.Equal(t, []string{"198.51.100.0/24", "192.0.2.10"}, allow["matchers"])
+	}
+	deny, ok := state.Admissions[base+"_deny"]
+	if assert.True(t, ok, "tunnel deny list should apply to the forward") {
+		assert.Equal(t, []string{"203.0.113.0/24"}, deny["matchers"])
+	}
+
+	svc := state.Services[base+"_tcp"]
+	assert.Equal(t, []string{base + "_allow", base + "_deny"}, svc["admissions"])
+}
+
+// TestReconcilePausedByOwner verifies forwards stay paused on the node while
+// their tunnel is disabled or their owner is disabled or expired.
+func TestReconcilePausedByOwner(t *testing.T) {
+	tunnel := model.Tunnel{Name: "tunnel_paused_owner", Type: 1, Status: 1, InNodeId: 945}
+	global.DB.Create(&tunnel)
+	exp := time.Now().Add(time.Hour).UnixMilli()
+	active := testutil.CreateUser("reconcile_active", 1, 10, 999999, exp)
+	expired := testutil.CreateUser("reconcile_expired", 1, 10, 999999, time.Now().Add(-time.Hour).UnixMilli())
+	disabled := testutil.CreateUser("reconcile_disabled", 1, 10, 999999, exp)
+	global.DB.Model(disabled).Update("status", 0)
+
+	name := func(user *model.User, port int) string {
+		forward := model.Forward{UserId: user.ID, TunnelId: tunnel.ID, InPort: port, RemoteAddr: "1.1.1.1:80", Status: 1}
+		global.DB.Create(&forward)
+		return fmt.Sprintf("%d_%d_0_tcp", forward.ID, user.ID)
+	}
+	activeName := name(active, 10091)
+	expiredName := name(expired, 10092)
+	disabledName := name(disabled, 10093)
+
+	state := service.Reconcile.BuildDesiredState(945)
+	assert.False(t, state.Paused[activeName])
+	assert.True(t, state.Paused[expiredName], "expired owner")
+	assert.True(t, state.Paused[disabledName], "disabled owner")
+
+	global.DB.Model(&tunnel).Update("status", 0)
+	assert.True(t, service.Reconcile.BuildDesiredState(945).Paused[activeName], "disabled tunnel")
+
+	// 对账结果保存供节点页面查看
+	nodeId := int64(946)
+	service.Reconcile.ReconcileNode(nodeId, &dto.GostConfigDto{})
+	reports := service.Reconcile.LastReports(&nodeId).Data.([]*service.ReconcileReport)
+	if assert.Len(t, reports, 1) {
+		assert.Equal(t, nodeId, reports[0].NodeId)
+		assert.NotZero(t, reports[0].CheckedTime)
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"fmt"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/rbac"
+	"go-backend/utils"
+)
+
+// isReseller 只有 subuser:write 没有 user:write 的角色，只能管理自己的下级用户
+func isReseller(claims *utils.UserClaims) bool {
+	return !rbac.Has(claims.RoleId, model.PermUserWrite) && rbac.Has(claims.RoleId, model.PermSubUserWrite)
+}
+
+// ownsUser 操作者能否管理目标用户：user:write 受后台账号保护规则限制，分销商只能管理自己的下级
+func ownsUser(claims *utils.UserClaims, target *model.User) bool {
+	if rbac.Has(claims.RoleId, model.PermUserWrite) {
+		return canManageUser(claims, target)
+	}
+	return rbac.Has(claims.RoleId, model.PermSubUserWrite) && target.ParentId == claims.GetUserId()
+}
+
+// checkSubUserPool 校验下级用户的流量、转发数、到期时间加上其他下级的分配不超过上级套餐；
+// 上级某项为 0（不限）时该项不限制。childId 为 0 表示新建
+func checkSubUserPool(parent *model.User, childId int64, flow int64, num int, expTime int64) error {
+	var siblings []model.User
+	global.DB.Where("parent_id = ? AND id != ?", parent.ID, childId).Find(&siblings)
+	var sumFlow int64
+	sumNum := 0
+	for _, u := range siblings {
+		sumFlow += u.Flow
+		sumNum += u.Num
+	}
+
+	if parent.Flow > 0 {
+		if flow <= 0 {
+			return fmt.Errorf("下级用户流量不能为不限")
+		}
+		if sumFlow+flow > parent.Flow {
+			return fmt.Errorf("流量超出可分配额度，剩余 %d GB", parent.Flow-sumFlow)
+		}
+	}
+	if parent.Num > 0 {
+		if num <= 0 {
+			return fmt.Errorf("下级用户转发数不能为不限")
+		}
+		if sumNum+num > parent.Num {
+			return fmt.Errorf("转发数超出可分配额度，剩余 %d 个", parent.Num-sumNum)
+		}
+	}
+	if parent.ExpTime > 0 && (expTime <= 0 || expTime > parent.ExpTime) {
+		return fmt.Errorf("到期时间不能晚于自身账号到期时间")
+	}
+
+	// 已分配的隧道同样要满足上级在该隧道上的额度
+	var tunnels []model.UserTunnel
+	global.DB.Where("user_id = ?", childId).Find(&tunnels)
+	for _, ut := range tunnels {
+		if err := checkSubUserTunnelPool(parent, childId, ut.TunnelId, flow, expTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSubUserTunnelPool 校验下级在某隧道上的流量分配之和不超过上级在该隧道的额度
+func checkSubUserTunnelPool(parent *model.User, childId int64, tunnelId int, flow int64, expTime int64) error {
+	var parentTunnel model.UserTunnel
+	if err := global.DB.Where("user_id = ? AND tunnel_id = ? AND status = 1", parent.ID, tunnelId).First(&parentTunnel).Error; err != nil {
+		return fmt.Errorf("自身没有该隧道权限，不能分配给下级")
+	}
+
+	if parentTunnel.Flow > 0 {
+		var sumFlow int64
+		global.DB.Model(&model.UserTunnel{}).
+			Where("tunnel_id = ? AND user_id != ? AND user_id IN (?)", tunnelId, childId,
+				global.DB.Model(&model.User{}).Select("id").Where("parent_id = ?", parent.ID)).
+			Select("COALESCE(SUM(flow), 0)").Scan(&sumFlow)
+		if flow <= 0 || sumFlow+flow > parentTunnel.Flow {
+			return fmt.Errorf("隧道流量超出可分配额度，剩余 %d GB", parentTunnel.Flow-sumFlow)
+		}
+	}
+	if parentTunnel.ExpTime > 0 && (expTime <= 0 || expTime > parentTunnel.ExpTime) {
+		return fmt.Errorf("到期时间不能晚于自身隧道权限到期时间")
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/rbac"
+	"go-backend/result"
+)
+
+// RoleService 角色与权限点管理（仅超级管理员）
+type RoleService struct{}
+
+var Role = new(RoleService)
+
+// List 返回全部角色（含内置角色的当前权限）和可选的权限点
+func (s *RoleService) List() *result.Result {
+	var roles []model.Role
+	global.DB.Order("id asc").Find(&roles)
+	return result.Ok(map[string]interface{}{
+		"roles":       roles,
+		"permissions": model.Permissions,
+	})
+}
+
+func (s *RoleService) Create(roleDto dto.RoleDto) *result.Result {
+	code := strings.TrimSpace(roleDto.Code)
+	var count int64
+	global.DB.Model(&model.Role{}).Where("code = ?", code).Count(&count)
+	if count > 0 {
+		return result.Err(-1, "角色标识已存在")
+	}
+	perms, err := normalizePermissions(roleDto.Permissions)
+	if err != nil {
+		return result.Err(-1, err.Error())
+	}
+
+	now := time.Now().UnixMilli()
+	role := model.Role{
+		Name:        strings.TrimSpace(roleDto.Name),
+		Code:        code,
+		Permissions: perms,
+		CreatedTime: now,
+		UpdatedTime: now,
+	}
+	if err := global.DB.Create(&role).Error; err != nil {
+		return result.Err(-1, "角色创建失败")
+	}
+	rbac.Reload()
+	return result.Ok(role)
+}
+
+// Update 修改角色名称和权限；普通用户角色固定为无后台权限
+func (s *RoleService) Update(updateDto dto.RoleUpdateDto) *result.Result {
+	var role model.Role
+	if err := global.DB.First(&role, updateDto.ID).Error; err != nil {
+		return result.Err(-1, "角色不存在")
+	}
+	perms, err := normalizePermissions(updateDto.Permissions)
+	if err != nil {
+		return result.Err(-1, err.Error())
+	}
+	if role.ID == model.RoleUser && perms != "" {
+		return result.Err(-1, "普通用户角色不能授予后台权限")
+	}
+
+	role.Name = strings.TrimSpace(updateDto.Name)
+	role.Permissions = perms
+	role.UpdatedTime = time.Now().UnixMilli()
+	if err := global.DB.Save(&role).Error; err != nil {
+		return result.Err(-1, "角色更新失败")
+	}
+	rbac.Reload()
+	return result.Ok("角色更新成功")
+}
+
+// Delete 删除自定义角色，仍有用户使用时不能删除
+func (s *RoleService) Delete(id int) *result.Result {
+	var role model.Role
+	if err := global.DB.First(&role, id).Error; err != nil {
+		return result.Err(-1, "角色不存在")
+	}
+	if role.BuiltIn == 1 {
+		return result.Err(-1, "内置角色不能删除")
+	}
+	var count int64
+	global.DB.Model(&model.User{}).Where("role_id = ?", id).Count(&count)
+	if count > 0 {
+		return result.Err(-1, fmt.Sprintf("仍有 %d 个用户使用该角色", count))
+	}
+	if err := global.DB.Delete(&role).Error; err != nil {
+		return result.Err(-1, "删除失败")
+	}
+	rbac.Reload()
+	return result.Ok("删除成功")
+}
+
+// roleExists 角色 ID 是否可分配给用户（超级管理员或已存在的角色）
+func roleExists(roleId int) bool {
+	if roleId == model.RoleSuperAdmin {
+		return true
+	}
+	for _, r := range model.BuiltinRoles {
+		if r.ID == roleId {
+			return true
+		}
+	}
+	var count int64
+	global.DB.Model(&model.Role{}).Where("id = ?", roleId).Count(&count)
+	return count > 0
+}
+
+func normalizePermissions(perms []string) (string, error) {
+	set := make(map[string]bool)
+	for _, p := range perms {
+		set[strings.TrimSpace(p)] = true
+	}
+	var out []string
+	for _, p := range model.Permissions {
+		if set[p] {
+			out = append(out, p)
+			delete(set, p)
+		}
+	}
+	for p := range set {
+		return "", fmt.Errorf("无效的权限点: %s", p)
+	}
+	return strings.Join(out, ","), nil
+}
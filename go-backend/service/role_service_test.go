@@ -0,0 +1,108 @@
+package service_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/result"
+	"go-backend/router"
+	"go-backend/service"
+	"go-backend/testutil"
+	"go-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// rbacClient 以指定用户的身份通过完整路由发送请求
+func rbacClient(t *testing.T) func(user *model.User, path string, body string) *result.Result {
+	gin.SetMode(gin.TestMode)
+	r := router.InitRouter()
+	return func(user *model.User, path string, body string) *result.Result {
+		token, _, err := service.Session.Issue(user, dto.ClientInfo{}, false)
+		assert.NoError(t, err)
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Authorization", token)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var res result.Result
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		return &res
+	}
+}
+
+func TestSupportRoleReadOnly(t *testing.T) {
+	call := rbacClient(t)
+	support := testutil.CreateUser("rbac_support", model.RoleSupport, 0, 0, time.Now().Add(time.Hour).UnixMilli())
+
+	// 只读客服：能查看，不能修改
+	assert.Equal(t, 0, call(support, "/api/v1/user/list", "{}").Code)
+	assert.Equal(t, 0, call(support, "/api/v1/node/list", "{}").Code)
+	assert.Equal(t, "权限不足", call(support, "/api/v1/user/create", `{"user":"x","pwd":"long-enough-1"}`).Msg)
+	assert.Equal(t, "权限不足", call(support, "/api/v1/node/delete", `{"id":1}`).Msg)
+}
+
+func TestUserAndOperatorRoles(t *testing.T) {
+	call := rbacClient(t)
+	exp := time.Now().Add(time.Hour).UnixMilli()
+	operator := testutil.CreateUser("rbac_operator", model.RoleNodeOperator, 0, 0, exp)
+	customer := testutil.CreateUser("rbac_customer", model.RoleUser, 1, 100, exp)
+
+	// 普通用户和运维都不能修改系统配置或用户
+	assert.Equal(t, "权限不足", call(customer, "/api/v1/config/update", `{"app_name":"hacked"}`).Msg)
+	assert.Equal(t, "权限不足", call(customer, "/api/v1/user/update", `{"id":1,"user":"x"}`).Msg)
+	assert.Equal(t, "权限不足", call(operator, "/api/v1/user/list", "{}").Msg)
+	assert.Equal(t, 0, call(operator, "/api/v1/tunnel/list", "{}").Code)
+}
+
+func TestResellerRole(t *testing.T) {
+	exp := time.Now().Add(time.Hour).UnixMilli()
+	support := testutil.CreateUser("rbac_reseller_support", model.RoleSupport, 0, 0, exp)
+	operator := testutil.CreateUser("rbac_reseller_operator", model.RoleNodeOperator, 0, 0, exp)
+	reseller := testutil.CreateUser("rbac_reseller", model.RoleReseller, 0, 0, exp)
+	customer := testutil.CreateUser("rbac_reseller_customer", model.RoleUser, 1, 100, exp)
+	global.DB.Model(customer).Update("parent_id", reseller.ID)
+	resellerClaims := &utils.UserClaims{RoleId: model.RoleReseller, RegisteredClaims: jwt.RegisteredClaims{Subject: strconv.FormatInt(reseller.ID, 10)}}
+
+	// 分销商只能管理自己的下级普通用户，不能分配角色
+	res := service.User.UpdateUser(dto.UserUpdateDto{ID: customer.ID, User: customer.User, Flow: 200, Num: 1, ExpTime: exp}, resellerClaims)
+	assert.Equal(t, 0, res.Code, res.Msg)
+	assert.NotEqual(t, 0, service.User.UpdateUser(dto.UserUpdateDto{ID: support.ID, User: support.User}, resellerClaims).Code)
+	roleId := model.RoleSupport
+	assert.NotEqual(t, 0, service.User.UpdateUser(dto.UserUpdateDto{ID: customer.ID, User: customer.User, RoleId: &roleId}, resellerClaims).Code)
+	assert.NotEqual(t, 0, service.User.DeleteUser(operator.ID, resellerClaims).Code)
+}
+
+func TestCustomRole(t *testing.T) {
+	call := rbacClient(t)
+	exp := time.Now().Add(time.Hour).UnixMilli()
+	customer := testutil.CreateUser("rbac_custom_user", model.RoleUser, 1, 100, exp)
+
+	// 超级管理员创建自定义角色并分配后立即生效
+	res := service.Role.Create(dto.RoleDto{Name: "配置维护", Code: "config_editor", Permissions: []string{model.PermConfigWrite}})
+	if !assert.Equal(t, 0, res.Code, res.Msg) {
+		return
+	}
+	custom := res.Data.(model.Role)
+	admin := &utils.UserClaims{RoleId: model.RoleSuperAdmin}
+	res = service.User.UpdateUser(dto.UserUpdateDto{ID: customer.ID, User: customer.User, Flow: 200, Num: 1, ExpTime: exp, RoleId: &custom.ID}, admin)
+	assert.Equal(t, 0, res.Code, res.Msg)
+	customer.RoleId = custom.ID
+	assert.Equal(t, 0, call(customer, "/api/v1/config/update-single", `{"name":"rbac_probe","value":"1"}`).Code)
+	assert.Equal(t, "权限不足", call(customer, "/api/v1/user/list", "{}").Msg)
+	assert.NotEqual(t, 0, service.Role.Delete(custom.ID).Code, "role still in use")
+}
+
+func TestBuiltinRoles(t *testing.T) {
+	assert.Equal(t, "内置角色不能删除", service.Role.Delete(model.RoleSupport).Msg)
+	assert.Equal(t, "普通用户角色不能授予后台权限", service.Role.Update(dto.RoleUpdateDto{ID: model.RoleUser, Name: "普通用户", Permissions: []string{model.PermUserRead}}).Msg)
+}
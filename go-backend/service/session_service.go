@@ -0,0 +1,251 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/rbac"
+	"go-backend/result"
+	"go-backend/utils"
+	"go-backend/websocket"
+
+	"github.com/google/uuid"
+)
+
+const (
+	refreshTokenTTL   = 30 * 24 * time.Hour
+	setupRefreshTTL   = 30 * time.Minute // 仅用于绑定两步验证的会话
+	sessionRetention  = 7 * 24 * time.Hour
+	maxUserAgentChars = 255
+)
+
+// SessionService 登录会话：签发与刷新 token、会话列表、撤销
+type SessionService struct{}
+
+var Session = new(SessionService)
+
+// Issue 创建会话并签发 access token 和 refresh token
+// setupOnly 为 true 时签发仅能访问两步验证绑定接口的短期 token
+func (s *SessionService) Issue(user *model.User, client dto.ClientInfo, setupOnly bool) (token string, refreshToken string, err error) {
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", "", err
+	}
+	ttl := refreshTokenTTL
+	if setupOnly {
+		ttl = setupRefreshTTL
+	}
+
+	now := time.Now()
+	sess := model.UserSession{
+		Sid:          uuid.New().String(),
+		UserId:       user.ID,
+		RefreshHash:  hashRefreshSecret(secret),
+		Ip:           client.Ip,
+		UserAgent:    truncateUserAgent(client.UserAgent),
+		CreatedTime:  now.UnixMilli(),
+		LastSeenTime: now.UnixMilli(),
+		ExpiresTime:  now.Add(ttl).UnixMilli(),
+	}
+	if err := global.DB.Create(&sess).Error; err != nil {
+		return "", "", err
+	}
+
+	token, err = signSessionToken(user, sess.Sid, setupOnly)
+	if err != nil {
+		return "", "", err
+	}
+	return token, sess.Sid + "." + secret, nil
+}
+
+// Refresh 使用 refresh token 换取新的 access token，refresh token 同时轮换
+// 已轮换的旧 refresh token 再次出现说明可能被盗用，直接撤销整个会话
+func (s *SessionService) Refresh(refreshDto dto.RefreshTokenDto, client dto.ClientInfo) *result.Result {
+	sid, secret, ok := strings.Cut(refreshDto.RefreshToken, ".")
+	if !ok || sid == "" || secret == "" {
+		return result.Err(-1, "会话已失效，请重新登录")
+	}
+
+	var sess model.UserSession
+	if err := global.DB.Where("sid = ?", sid).First(&sess).Error; err != nil {
+		return result.Err(-1, "会话已失效，请重新登录")
+	}
+	now := time.Now()
+	if sess.RevokedTime != 0 || sess.ExpiresTime <= now.UnixMilli() {
+		return result.Err(-1, "会话已失效，请重新登录")
+	}
+	if subtle.ConstantTimeCompare([]byte(hashRefreshSecret(secret)), []byte(sess.RefreshHash)) != 1 {
+		s.revoke(&sess)
+		return result.Err(-1, "会话已失效，请重新登录")
+	}
+
+	var user model.User
+	if err := global.DB.First(&user, sess.UserId).Error; err != nil || user.Status == 0 {
+		s.revoke(&sess)
+		return result.Err(-1, "会话已失效，请重新登录")
+	}
+
+	newSecret, err := randomHex(32)
+	if err != nil {
+		return result.Err(-1, "Token生成失败")
+	}
+	setupOnly := user.TotpEnabled != 1 && Totp.Required(&user)
+	ttl := refreshTokenTTL
+	if setupOnly {
+		ttl = setupRefreshTTL
+	}
+	updates := map[string]interface{}{
+		"refresh_hash":   hashRefreshSecret(newSecret),
+		"last_seen_time": now.UnixMilli(),
+		"expires_time":   now.Add(ttl).UnixMilli(),
+	}
+	if client.Ip != "" {
+		updates["ip"] = client.Ip
+	}
+	// 只有哈希仍是本次校验的值时才轮换，并发使用同一个旧 token 时只有一个请求成功，其余按重复使用处理
+	res := global.DB.Model(&model.UserSession{}).Where("id = ? AND refresh_hash = ?", sess.ID, sess.RefreshHash).Updates(updates)
+	if res.Error != nil {
+		return result.Err(-1, "Token生成失败")
+	}
+	if res.RowsAffected == 0 {
+		s.revoke(&sess)
+		return result.Err(-1, "会话已失效，请重新登录")
+	}
+
+	token, err := signSessionToken(&user, sess.Sid, setupOnly)
+	if err != nil {
+		return result.Err(-1, "Token生成失败")
+	}
+	return result.Ok(map[string]interface{}{
+		"token":            token,
+		"refreshToken":     sess.Sid + "." + newSecret,
+		"requireTotpSetup": setupOnly,
+	})
+}
+
+// List 列出有效会话，拥有 user:read 权限时可查看其他用户
+func (s *SessionService) List(claims *utils.UserClaims, listDto dto.SessionListDto) *result.Result {
+	userId := claims.GetUserId()
+	if listDto.UserId != 0 && listDto.UserId != userId {
+		if !rbac.Has(claims.RoleId, model.PermUserRead) {
+			return result.Err(-1, "权限不足")
+		}
+		userId = listDto.UserId
+	}
+
+	var sessions []model.UserSession
+	global.DB.Where("user_id = ? AND revoked_time = 0 AND expires_time > ?", userId, time.Now().UnixMilli()).
+		Order("last_seen_time desc").Find(&sessions)
+
+	list := make([]dto.SessionDto, 0, len(sessions))
+	for _, sess := range sessions {
+		list = append(list, dto.SessionDto{
+			ID:           sess.ID,
+			UserId:       sess.UserId,
+			Ip:           sess.Ip,
+			UserAgent:    sess.UserAgent,
+			CreatedTime:  sess.CreatedTime,
+			LastSeenTime: sess.LastSeenTime,
+			ExpiresTime:  sess.ExpiresTime,
+			Current:      sess.Sid == claims.ID,
+		})
+	}
+	return result.Ok(list)
+}
+
+// Revoke 撤销单个会话，没有用户管理权限时只能撤销自己的会话
+func (s *SessionService) Revoke(claims *utils.UserClaims, id int64) *result.Result {
+	var sess model.UserSession
+	if err := global.DB.First(&sess, id).Error; err != nil {
+		return result.Err(-1, "会话不存在")
+	}
+	if sess.UserId != claims.GetUserId() {
+		var owner model.User
+		global.DB.First(&owner, sess.UserId)
+		if !rbac.Has(claims.RoleId, model.PermUserWrite) || !canManageUser(claims, &owner) {
+			return result.Err(-1, "权限不足")
+		}
+	}
+	s.revoke(&sess)
+	return result.Ok("会话已注销")
+}
+
+// Logout 注销当前会话
+func (s *SessionService) Logout(claims *utils.UserClaims) *result.Result {
+	var sess model.UserSession
+	if err := global.DB.Where("sid = ?", claims.ID).First(&sess).Error; err == nil {
+		s.revoke(&sess)
+	}
+	return result.Ok("已退出登录")
+}
+
+// RevokeAll 撤销用户的全部会话，返回撤销的数量
+func (s *SessionService) RevokeAll(userId int64) int {
+	var sessions []model.UserSession
+	global.DB.Where("user_id = ? AND revoked_time = 0", userId).Find(&sessions)
+	for i := range sessions {
+		s.revoke(&sessions[i])
+	}
+	return len(sessions)
+}
+
+// RevokeUser 管理员注销指定用户的全部会话，只能操作有权管理的用户
+func (s *SessionService) RevokeUser(claims *utils.UserClaims, userId int64) *result.Result {
+	var user model.User
+	if err := global.DB.First(&user, userId).Error; err != nil {
+		return result.Err(-1, "用户不存在")
+	}
+	if user.ID != claims.GetUserId() && !ownsUser(claims, &user) {
+		return result.Err(-1, "权限不足")
+	}
+	return result.Ok(s.RevokeAll(user.ID))
+}
+
+// Prune 清理过期或已撤销一段时间的会话
+func (s *SessionService) Prune(now time.Time) {
+	cutoff := now.Add(-sessionRetention).UnixMilli()
+	global.DB.Where("expires_time < ? OR (revoked_time != 0 AND revoked_time < ?)", cutoff, cutoff).Delete(&model.UserSession{})
+}
+
+// revoke 标记会话撤销，并断开该会话建立的 websocket 连接
+func (s *SessionService) revoke(sess *model.UserSession) {
+	if sess.RevokedTime == 0 {
+		sess.RevokedTime = time.Now().UnixMilli()
+		global.DB.Model(sess).Update("revoked_time", sess.RevokedTime)
+	}
+	websocket.CloseSessions(sess.Sid)
+}
+
+func signSessionToken(user *model.User, sid string, setupOnly bool) (string, error) {
+	if setupOnly {
+		return utils.GenerateTotpSetupToken(user, sid)
+	}
+	return utils.GenerateToken(user, sid)
+}
+
+func hashRefreshSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func truncateUserAgent(ua string) string {
+	if len(ua) > maxUserAgentChars {
+		return ua[:maxUserAgentChars]
+	}
+	return ua
+}
@@ -0,0 +1,163 @@
+package service_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/service"
+	"go-backend/session"
+	"go-backend/testutil"
+	"go-backend/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var sessionClient = dto.ClientInfo{Ip: "203.0.113.7", UserAgent: "scenario-test"}
+
+// sessionLogin 登录并返回 access token、refresh token 和解析后的 claims
+func sessionLogin(t *testing.T, username, password string) (string, string, *utils.UserClaims) {
+	res := service.User.Login(dto.LoginDto{Username: username, Password: password}, sessionClient)
+	if !assert.Equal(t, 0, res.Code, res.Msg) {
+		t.FailNow()
+	}
+	data := res.Data.(map[string]interface{})
+	claims := testutil.ParseClaims(t, data["token"].(string))
+	return data["token"].(string), data["refreshToken"].(string), claims
+}
+
+func sessionValid(claims *utils.UserClaims) bool {
+	return session.Validate(claims.ID, claims.GetUserId(), claims.IssuedAt.Time, sessionClient.Ip) == nil
+}
+
+// TestSessionList verifies a login creates a session listed as the current one.
+func TestSessionList(t *testing.T) {
+	testutil.CreateUser("session_list", 1, 1, 100, time.Now().Add(time.Hour).UnixMilli())
+	_, _, claims := sessionLogin(t, "session_list", "123456")
+	assert.True(t, sessionValid(claims))
+	list := service.Session.List(claims, dto.SessionListDto{}).Data.([]dto.SessionDto)
+	if assert.Len(t, list, 1) {
+		assert.True(t, list[0].Current)
+		assert.Equal(t, "scenario-test", list[0].UserAgent)
+	}
+}
+
+// TestRefreshRotation verifies refresh tokens rotate on use and reusing an old
+// one revokes the whole session.
+func TestRefreshRotation(t *testing.T) {
+	testutil.CreateUser("session_refresh", 1, 1, 100, time.Now().Add(time.Hour).UnixMilli())
+	_, refresh, claims := sessionLogin(t, "session_refresh", "123456")
+
+	res := service.Session.Refresh(dto.RefreshTokenDto{RefreshToken: refresh}, sessionClient)
+	assert.Equal(t, 0, res.Code, res.Msg)
+	rotated := res.Data.(map[string]interface{})["refreshToken"].(string)
+	assert.NotEqual(t, refresh, rotated)
+	assert.NotEqual(t, 0, service.Session.Refresh(dto.RefreshTokenDto{RefreshToken: refresh}, sessionClient).Code)
+	assert.NotEqual(t, 0, service.Session.Refresh(dto.RefreshTokenDto{RefreshToken: rotated}, sessionClient).Code)
+	assert.False(t, sessionValid(claims))
+}
+
+// TestSessionLogout verifies logging out revokes the current session and
+// RevokeAll revokes every device.
+func TestSessionLogout(t *testing.T) {
+	user := testutil.CreateUser("session_logout", 1, 1, 100, time.Now().Add(time.Hour).UnixMilli())
+	_, _, claims := sessionLogin(t, "session_logout", "123456")
+	assert.Equal(t, 0, service.Session.Logout(claims).Code)
+	assert.False(t, sessionValid(claims))
+
+	_, _, first := sessionLogin(t, "session_logout", "123456")
+	_, _, second := sessionLogin(t, "session_logout", "123456")
+	assert.Equal(t, 2, service.Session.RevokeAll(user.ID))
+	assert.False(t, sessionValid(first))
+	assert.False(t, sessionValid(second))
+}
+
+// TestPasswordChangeRevokesSessions verifies tokens issued before a password
+// change stay invalid even if their session row is restored.
+func TestPasswordChangeRevokesSessions(t *testing.T) {
+	testutil.CreateUser("session_password", 1, 1, 100, time.Now().Add(time.Hour).UnixMilli())
+	_, _, claims := sessionLogin(t, "session_password", "123456")
+	res := service.User.UpdatePassword(dto.ChangePasswordDto{
+		CurrentPassword: "123456",
+		NewPassword:     "session-pass-2",
+		ConfirmPassword: "session-pass-2",
+	}, claims)
+	assert.Equal(t, 0, res.Code, res.Msg)
+	assert.False(t, sessionValid(claims))
+	global.DB.Model(&model.UserSession{}).Where("sid = ?", claims.ID).Update("revoked_time", 0)
+	assert.False(t, sessionValid(claims), "token issued before the password change must stay invalid")
+}
+
+// TestDisabledUserSessions verifies disabling an account invalidates its sessions.
+func TestDisabledUserSessions(t *testing.T) {
+	user := testutil.CreateUser("session_disabled", 1, 1, 100, time.Now().Add(time.Hour).UnixMilli())
+	_, _, claims := sessionLogin(t, "session_disabled", "123456")
+	global.DB.Model(user).Update("status", 0)
+	assert.False(t, sessionValid(claims))
+}
+
+// TestRevokeUserSessions verifies user admins can only revoke sessions of users they may manage
+func TestRevokeUserSessions(t *testing.T) {
+	exp := time.Now().Add(time.Hour).UnixMilli()
+	res := service.Role.Create(dto.RoleDto{Name: "用户维护", Code: "session_user_admin", Permissions: []string{model.PermUserWrite}})
+	if !assert.Equal(t, 0, res.Code, res.Msg) {
+		return
+	}
+	role := res.Data.(model.Role)
+	admin := testutil.CreateUser("revoke_admin", role.ID, 0, 0, exp)
+	support := testutil.CreateUser("revoke_support", model.RoleSupport, 0, 0, exp)
+	customer := testutil.CreateUser("revoke_customer", model.RoleUser, 1, 100, exp)
+	claims := &utils.UserClaims{RoleId: role.ID, RegisteredClaims: jwt.RegisteredClaims{Subject: strconv.FormatInt(admin.ID, 10)}}
+
+	// 后台账号受保护，会话保持有效
+	_, _, err := service.Session.Issue(support, dto.ClientInfo{}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "权限不足", service.Session.RevokeUser(claims, support.ID).Msg)
+	var active int64
+	global.DB.Model(&model.UserSession{}).Where("user_id = ? AND revoked_time = 0", support.ID).Count(&active)
+	assert.Equal(t, int64(1), active)
+
+	_, _, err = service.Session.Issue(customer, dto.ClientInfo{}, false)
+	assert.NoError(t, err)
+	res = service.Session.RevokeUser(claims, customer.ID)
+	assert.Equal(t, 0, res.Code, res.Msg)
+	assert.Equal(t, 1, res.Data)
+	assert.NotEqual(t, 0, service.Session.RevokeUser(claims, 999999).Code)
+}
+
+// TestRefreshConcurrentReuse verifies a refresh whose token was rotated by a
+// concurrent request after it was read is treated as reuse.
+func TestRefreshConcurrentReuse(t *testing.T) {
+	user := testutil.CreateUser("refresh_race", 1, 1, 100, time.Now().Add(time.Hour).UnixMilli())
+	_, refresh, err := service.Session.Issue(user, dto.ClientInfo{}, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	sid, _, _ := strings.Cut(refresh, ".")
+
+	// 在校验通过之后、写入之前模拟另一个请求先完成了轮换
+	raced := false
+	assert.NoError(t, global.DB.Callback().Update().Before("gorm:update").Register("test:refresh_race", func(db *gorm.DB) {
+		if raced || db.Statement.Table != "user_session" {
+			return
+		}
+		raced = true
+		db.Session(&gorm.Session{NewDB: true}).Exec("UPDATE user_session SET refresh_hash = ? WHERE sid = ?", "rotated-elsewhere", sid)
+	}))
+	t.Cleanup(func() { global.DB.Callback().Update().Remove("test:refresh_race") })
+
+	res := service.Session.Refresh(dto.RefreshTokenDto{RefreshToken: refresh}, dto.ClientInfo{})
+	assert.NotEqual(t, 0, res.Code)
+	assert.True(t, raced)
+
+	var sess model.UserSession
+	global.DB.Where("sid = ?", sid).First(&sess)
+	assert.NotZero(t, sess.RevokedTime, "session must be revoked when the rotation loses the race")
+	assert.Equal(t, "rotated-elsewhere", sess.RefreshHash)
+}
@@ -154,45 +154,53 @@ func (s *SpeedLimitService) DeleteSpeedLimit(id int64) *result.Result {
 
 // --- Private Helper Methods ---
 
+// speedToMBps 将 Mbps 转换为 gost 限速器使用的 MB 值
+func speedToMBps(speed int) string {
+	return fmt.Sprintf("%.1f", float64(speed)/8.0)
+}
+
 func (s *SpeedLimitService) addGostLimiter(speedLimit *model.SpeedLimit, tunnel *model.Tunnel) error {
-	speedMBps := fmt.Sprintf("%.1f", float64(speedLimit.Speed)/8.0)
+	speedMBps := speedToMBps(speedLimit.Speed)
 	var node model.Node
 	if err := global.DB.First(&node, tunnel.InNodeId).Error; err != nil {
 		return fmt.Errorf("入口节点不存在")
 	}
 
-	res := utils.AddLimiters(node.ID, speedLimit.ID, speedMBps)
-	if res.Msg != "OK" {
-		return fmt.Errorf(res.Msg)
+	// 限速器部署在入口节点组的每个节点上
+	for _, nodeId := range tunnelEntryNodeIds(tunnel) {
+		res := utils.AddLimiters(nodeId, speedLimit.ID, speedMBps)
+		if res.Msg != "OK" {
+			return fmt.Errorf("%s", res.Msg)
+		}
 	}
 	return nil
 }
 
 func (s *SpeedLimitService) updateGostLimiter(speedLimit *model.SpeedLimit, tunnel *model.Tunnel) error {
-	speedMBps := fmt.Sprintf("%.1f", float64(speedLimit.Speed)/8.0)
+	speedMBps := speedToMBps(speedLimit.Speed)
 	var node model.Node
 	if err := global.DB.First(&node, tunnel.InNodeId).Error; err != nil {
 		return fmt.Errorf("入口节点不存在")
 	}
 
-	res := utils.UpdateLimiters(node.ID, speedLimit.ID, speedMBps)
-	if res.Msg != "OK" {
-		if len(res.Msg) > 0 && (res.Msg == "not found" || strings.Contains(res.Msg, "not found")) {
-			res = utils.AddLimiters(node.ID, speedLimit.ID, speedMBps)
-			if res.Msg != "OK" {
-				return fmt.Errorf(res.Msg)
+	for _, nodeId := range tunnelEntryNodeIds(tunnel) {
+		res := utils.UpdateLimiters(nodeId, speedLimit.ID, speedMBps)
+		if res.Msg != "OK" {
+			if len(res.Msg) > 0 && (res.Msg == "not found" || strings.Contains(res.Msg, "not found")) {
+				res = utils.AddLimiters(nodeId, speedLimit.ID, speedMBps)
+				if res.Msg != "OK" {
+					return fmt.Errorf("%s", res.Msg)
+				}
+			} else {
+				return fmt.Errorf("%s", res.Msg)
 			}
-		} else {
-			return fmt.Errorf(res.Msg)
 		}
 	}
 	return nil
 }
 
 func (s *SpeedLimitService) deleteGostLimiter(speedId int64, tunnel *model.Tunnel) {
-	var node model.Node
-	if err := global.DB.First(&node, tunnel.InNodeId).Error; err != nil {
-		return
+	for _, nodeId := range tunnelEntryNodeIds(tunnel) {
+		utils.DeleteLimiters(nodeId, speedId)
 	}
-	utils.DeleteLimiters(node.ID, speedId)
 }
@@ -37,6 +37,10 @@ func (s *StatisticsFlowService) StartScheduledTask() {
 }
 
 func (s *StatisticsFlowService) RunStatistics() {
+	// 0. 写入流量时序并清理过期数据
+	Traffic.Flush()
+	Traffic.Prune(time.Now())
+
 	// 1. Delete data older than 48 hours
 	cutoff := time.Now().Add(-48 * time.Hour).UnixMilli()
 	global.DB.Where("created_time < ?", cutoff).Delete(&model.StatisticsFlow{})
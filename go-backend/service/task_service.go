@@ -4,13 +4,10 @@ import (
 	"fmt"
 	"time"
 
+	"go-backend/audit"
 	"go-backend/global"
+	"go-backend/metrics"
 	"go-backend/model"
-	"go-backend/utils"
-	// Implicitly used via Gost sync or simple logic?
-	// Need GostUtil logic, which is in utils.
-	// But GostUtil in Go seems to be in utils/gost_util.go?
-	// Wait, we implemented it as utils.GostUtil... functions.
 )
 
 type TaskService struct{}
@@ -34,29 +31,46 @@ func (s *TaskService) RunDailyTasks() {
 	fmt.Println("开始执行每日定时任务...")
 	s.ResetFlow()
 	s.CheckExpiry()
+	Notify.CheckExpiry(time.Now())
+	Session.Prune(time.Now())
+	Audit.Prune(time.Now())
+	LoginGuard.Prune(time.Now())
+	Webhook.Prune(time.Now())
 	fmt.Println("每日定时任务执行完成")
 }
 
 func (s *TaskService) ResetFlow() {
-	currentDay := time.Now().Day()
-	lastDayOfMonth := time.Date(time.Now().Year(), time.Now().Month()+1, 0, 0, 0, 0, 0, time.Now().Location()).Day()
+	now := time.Now()
 
 	// 1. Reset User Flow
 	var users []model.User
 	global.DB.Where("flow_reset_time != 0").Find(&users)
-	for _, user := range users {
-		shouldReset := int(user.FlowResetTime) == currentDay
-		if currentDay == lastDayOfMonth && int(user.FlowResetTime) > lastDayOfMonth {
-			shouldReset = true
+	for i := range users {
+		if isFlowResetDay(users[i].FlowResetTime, now) {
+			User.resetUserFlow(&users[i])
 		}
-		if shouldReset {
-			user.InFlow = 0
-			user.OutFlow = 0
-			global.DB.Save(&user)
+	}
+
+	// 2. 用户隧道按各自的重置日重置
+	var userTunnels []model.UserTunnel
+	global.DB.Where("flow_reset_time != 0").Find(&userTunnels)
+	for i := range userTunnels {
+		if isFlowResetDay(userTunnels[i].FlowResetTime, now) {
+			User.resetUserTunnelFlow(&userTunnels[i])
 		}
 	}
 }
 
+// isFlowResetDay 今天是否为每月重置日；重置日大于当月天数时在月末重置
+func isFlowResetDay(resetDay int64, now time.Time) bool {
+	currentDay := now.Day()
+	lastDayOfMonth := time.Date(now.Year(), now.Month()+1, 0, 0, 0, 0, 0, now.Location()).Day()
+	if int(resetDay) == currentDay {
+		return true
+	}
+	return currentDay == lastDayOfMonth && int(resetDay) > lastDayOfMonth
+}
+
 func (s *TaskService) CheckExpiry() {
 	now := time.Now().UnixMilli()
 
@@ -66,36 +80,19 @@ func (s *TaskService) CheckExpiry() {
 	for _, user := range users {
 		// Pause all forwards
 		var forwards []model.Forward
-		global.DB.Where("user_id = ? AND status = 1", user.ID).Find(&forwards)
-		for _, forward := range forwards {
-			s.pauseForward(&forward)
-			forward.Status = 0
-			global.DB.Save(&forward)
+		global.DB.Where("user_id = ?", user.ID).Find(&forwards)
+		paused := 0
+		for i := range forwards {
+			if Forward.AutoPauseForward(&forwards[i], model.PauseExpired) {
+				paused++
+			}
 		}
+		metrics.QuotaPauses.Add(float64(paused), "user_expired")
 		user.Status = 0
 		global.DB.Save(&user)
-	}
-}
-
-func (s *TaskService) pauseForward(forward *model.Forward) {
-	var tunnel model.Tunnel
-	if err := global.DB.First(&tunnel, forward.TunnelId).Error; err != nil {
-		return
-	}
-
-	// We need UserTunnel ID to build service name
-	var userTunnel model.UserTunnel
-	if err := global.DB.Where("user_id = ? AND tunnel_id = ?", forward.UserId, forward.TunnelId).First(&userTunnel).Error; err != nil {
-		return
-	}
-
-	serviceName := fmt.Sprintf("%d_%d_%d", forward.ID, forward.UserId, userTunnel.ID)
-
-	// Pause Service on InNode
-	utils.PauseService(tunnel.InNodeId, serviceName)
-
-	// Pause Remote Service if Type 2
-	if tunnel.Type == 2 && tunnel.OutNodeId != 0 {
-		utils.PauseRemoteService(tunnel.OutNodeId, serviceName)
+		audit.System("user.expire", "user", user.ID, fmt.Sprintf("用户已到期，禁用账号并暂停 %d 条转发", paused))
+		Webhook.Emit(model.EventUserExpired, map[string]interface{}{
+			"userId": user.ID, "user": user.User, "expTime": user.ExpTime, "pausedForwards": paused,
+		})
 	}
 }
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/rbac"
+	"go-backend/result"
+	"go-backend/utils"
+
+	"github.com/google/uuid"
+)
+
+const (
+	preAuthTTL         = 5 * time.Minute
+	preAuthMaxAttempts = 5
+	recoveryCodeCount  = 10
+)
+
+// TotpService 两步验证：绑定、登录第二步校验、关闭与管理员重置
+type TotpService struct {
+	mu      sync.Mutex
+	preAuth map[string]*preAuthEntry
+}
+
+var Totp = &TotpService{preAuth: make(map[string]*preAuthEntry)}
+
+// preAuthEntry 密码校验通过、等待输入验证码的登录
+type preAuthEntry struct {
+	userId                int64
+	requirePasswordChange bool
+	expires               time.Time
+	attempts              int
+}
+
+// Required 系统配置 totp_required 为 all 时所有账号必须启用，为 admin 时超级管理员和后台角色必须启用
+func (s *TotpService) Required(user *model.User) bool {
+	switch ViteConfig.GetValue("totp_required") {
+	case "all":
+		return true
+	case "admin":
+		return rbac.IsStaff(user.RoleId)
+	}
+	return false
+}
+
+// issuePreAuth 签发登录第二步使用的一次性预认证 token
+func (s *TotpService) issuePreAuth(userId int64, requirePasswordChange bool) string {
+	token := uuid.New().String()
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, e := range s.preAuth {
+		if now.After(e.expires) {
+			delete(s.preAuth, k)
+		}
+	}
+	s.preAuth[token] = &preAuthEntry{userId: userId, requirePasswordChange: requirePasswordChange, expires: now.Add(preAuthTTL)}
+	return token
+}
+
+// Login 登录第二步，验证码错误次数过多时预认证 token 作废，需要重新输入密码
+func (s *TotpService) Login(loginDto dto.TotpLoginDto, client dto.ClientInfo) *result.Result {
+	s.mu.Lock()
+	entry, ok := s.preAuth[loginDto.PreAuthToken]
+	if ok && time.Now().After(entry.expires) {
+		delete(s.preAuth, loginDto.PreAuthToken)
+		ok = false
+	}
+	s.mu.Unlock()
+	if !ok {
+		return result.Err(-1, "登录已过期，请重新登录")
+	}
+
+	var user model.User
+	if err := global.DB.First(&user, entry.userId).Error; err != nil || user.TotpEnabled != 1 {
+		return result.Err(-1, "登录已过期，请重新登录")
+	}
+	// 锁定前拿到的预认证 token 也不能继续尝试
+	if err := LoginGuard.Check(client.Ip, user.User); err != nil {
+		return result.Err(-1, err.Error())
+	}
+
+	if !s.checkCode(&user, loginDto.Code) {
+		// 验证码和恢复码错误同样计入登录失败，避免重复获取预认证 token 暴力猜测
+		LoginGuard.Fail(client.Ip, user.User, LoginSourceTotp)
+		s.mu.Lock()
+		entry.attempts++
+		if entry.attempts >= preAuthMaxAttempts {
+			delete(s.preAuth, loginDto.PreAuthToken)
+		}
+		s.mu.Unlock()
+		return result.Err(-1, "验证码错误")
+	}
+
+	s.mu.Lock()
+	delete(s.preAuth, loginDto.PreAuthToken)
+	s.mu.Unlock()
+	LoginGuard.Succeed(user.User)
+	if user.Status == 0 {
+		return result.Err(-1, "账户停用")
+	}
+	return User.loginResult(&user, entry.requirePasswordChange, client)
+}
+
+// Setup 生成新的密钥和恢复码，验证码校验通过（Enable）后才生效
+func (s *TotpService) Setup(claims *utils.UserClaims) *result.Result {
+	var user model.User
+	if err := global.DB.First(&user, claims.GetUserId()).Error; err != nil {
+		return result.Err(-1, "用户不存在")
+	}
+	if user.TotpEnabled == 1 {
+		return result.Err(-1, "已启用两步验证，如需更换请先关闭")
+	}
+
+	secret, err := utils.GenerateTotpSecret()
+	if err != nil {
+		return result.Err(-1, "生成密钥失败")
+	}
+	codes, hashes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return result.Err(-1, "生成恢复码失败")
+	}
+
+	err = global.DB.Model(&user).Updates(map[string]interface{}{
+		"totp_secret":       secret,
+		"totp_last_counter": 0,
+		"recovery_codes":    strings.Join(hashes, ","),
+	}).Error
+	if err != nil {
+		return result.Err(-1, "保存两步验证信息失败")
+	}
+
+	issuer := ViteConfig.GetValue("app_name")
+	if issuer == "" {
+		issuer = "flux-panel"
+	}
+	return result.Ok(dto.TotpSetupDto{
+		Secret:        secret,
+		OtpauthUri:    utils.TotpURI(issuer, user.User, secret),
+		RecoveryCodes: codes,
+	})
+}
+
+// Enable 校验身份验证器上的验证码后启用，返回新的登录信息（替换仅用于绑定的 token）
+func (s *TotpService) Enable(claims *utils.UserClaims, enableDto dto.TotpEnableDto, client dto.ClientInfo) *result.Result {
+	var user model.User
+	if err := global.DB.First(&user, claims.GetUserId()).Error; err != nil {
+		return result.Err(-1, "用户不存在")
+	}
+	if user.TotpEnabled == 1 {
+		return result.Err(-1, "已启用两步验证")
+	}
+	if user.TotpSecret == "" {
+		return result.Err(-1, "请先获取两步验证密钥")
+	}
+
+	counter, ok := utils.VerifyTotp(user.TotpSecret, enableDto.Code, time.Now(), 0)
+	if !ok {
+		return result.Err(-1, "验证码错误")
+	}
+	user.TotpEnabled = 1
+	user.TotpLastCounter = counter
+	err := global.DB.Model(&user).Updates(map[string]interface{}{
+		"totp_enabled":      1,
+		"totp_last_counter": counter,
+	}).Error
+	if err != nil {
+		return result.Err(-1, "启用两步验证失败")
+	}
+	if claims.TotpSetup {
+		Session.Logout(claims)
+	}
+	return User.loginResult(&user, false, client)
+}
+
+// Disable 用户自行关闭，需要密码和验证码（或恢复码）；被强制要求时不能关闭
+func (s *TotpService) Disable(claims *utils.UserClaims, disableDto dto.TotpDisableDto) *result.Result {
+	var user model.User
+	if err := global.DB.First(&user, claims.GetUserId()).Error; err != nil {
+		return result.Err(-1, "用户不存在")
+	}
+	if user.TotpEnabled != 1 {
+		return result.Err(-1, "未启用两步验证")
+	}
+	if s.Required(&user) {
+		return result.Err(-1, "系统要求启用两步验证，不能关闭")
+	}
+	if !User.CheckPassword(&user, disableDto.Password) {
+		return result.Err(-1, "密码错误")
+	}
+	if !s.checkCode(&user, disableDto.Code) {
+		return result.Err(-1, "验证码错误")
+	}
+
+	if err := s.clear(user.ID); err != nil {
+		return result.Err(-1, "关闭两步验证失败")
+	}
+	return result.Ok("两步验证已关闭")
+}
+
+// Reset 管理员重置用户的两步验证（用户丢失设备且恢复码用尽时）
+func (s *TotpService) Reset(claims *utils.UserClaims, id int64) *result.Result {
+	var user model.User
+	if err := global.DB.First(&user, id).Error; err != nil {
+		return result.Err(-1, "用户不存在")
+	}
+	if !canManageUser(claims, &user) {
+		return result.Err(-1, "只有超级管理员可以操作后台账号")
+	}
+	if err := s.clear(user.ID); err != nil {
+		return result.Err(-1, "重置两步验证失败")
+	}
+	return result.Ok("两步验证已重置")
+}
+
+func (s *TotpService) clear(userId int64) error {
+	return global.DB.Model(&model.User{}).Where("id = ?", userId).Updates(map[string]interface{}{
+		"totp_secret":       "",
+		"totp_enabled":      0,
+		"totp_last_counter": 0,
+		"recovery_codes":    "",
+	}).Error
+}
+
+// checkCode 校验 6 位验证码或恢复码，成功后记录时间步或作废该恢复码
+func (s *TotpService) checkCode(user *model.User, code string) bool {
+	if counter, ok := utils.VerifyTotp(user.TotpSecret, code, time.Now(), user.TotpLastCounter); ok {
+		user.TotpLastCounter = counter
+		global.DB.Model(user).Update("totp_last_counter", counter)
+		return true
+	}
+
+	hash := utils.HashRecoveryCode(code)
+	var remaining []string
+	found := false
+	for _, h := range strings.Split(user.RecoveryCodes, ",") {
+		if h == "" {
+			continue
+		}
+		if !found && h == hash {
+			found = true
+			continue
+		}
+		remaining = append(remaining, h)
+	}
+	if !found {
+		return false
+	}
+	user.RecoveryCodes = strings.Join(remaining, ",")
+	global.DB.Model(user).Update("recovery_codes", user.RecoveryCodes)
+	return true
+}
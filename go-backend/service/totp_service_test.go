@@ -0,0 +1,80 @@
+package service_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"go-backend/model/dto"
+	"go-backend/service"
+	"go-backend/testutil"
+	"go-backend/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTotpLogin(t *testing.T) {
+	user := testutil.CreateUser("totp_user", 1, 1, 100, time.Now().Add(time.Hour).UnixMilli())
+	claims := &utils.UserClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: strconv.FormatInt(user.ID, 10)}}
+
+	res := service.Totp.Setup(claims)
+	if !assert.Equal(t, 0, res.Code, res.Msg) {
+		return
+	}
+	setup := res.Data.(dto.TotpSetupDto)
+	assert.Contains(t, setup.OtpauthUri, "otpauth://totp/")
+	assert.Len(t, setup.RecoveryCodes, 10)
+
+	code, _ := utils.TotpCode(setup.Secret, utils.TotpCounter(time.Now()))
+	assert.Equal(t, 0, service.Totp.Enable(claims, dto.TotpEnableDto{Code: code}, dto.ClientInfo{}).Code)
+
+	// 启用后密码登录只返回预认证 token
+	res = service.User.Login(dto.LoginDto{Username: "totp_user", Password: "123456"}, dto.ClientInfo{})
+	data := res.Data.(map[string]interface{})
+	assert.Equal(t, true, data["requireTotp"])
+	assert.Nil(t, data["token"])
+	preAuth := data["preAuthToken"].(string)
+
+	// 同一时间步的验证码不能重复使用
+	assert.NotEqual(t, 0, service.Totp.Login(dto.TotpLoginDto{PreAuthToken: preAuth, Code: code}, dto.ClientInfo{}).Code)
+	res = service.Totp.Login(dto.TotpLoginDto{PreAuthToken: preAuth, Code: setup.RecoveryCodes[0]}, dto.ClientInfo{})
+	assert.Equal(t, 0, res.Code, res.Msg)
+	assert.NotEmpty(t, res.Data.(map[string]interface{})["token"])
+
+	// 预认证 token 和恢复码都只能使用一次
+	assert.NotEqual(t, 0, service.Totp.Login(dto.TotpLoginDto{PreAuthToken: preAuth, Code: setup.RecoveryCodes[1]}, dto.ClientInfo{}).Code)
+	preAuth = service.User.Login(dto.LoginDto{Username: "totp_user", Password: "123456"}, dto.ClientInfo{}).Data.(map[string]interface{})["preAuthToken"].(string)
+	assert.NotEqual(t, 0, service.Totp.Login(dto.TotpLoginDto{PreAuthToken: preAuth, Code: setup.RecoveryCodes[0]}, dto.ClientInfo{}).Code)
+
+	// 管理员重置后恢复为仅密码登录
+	assert.Equal(t, 0, service.Totp.Reset(&utils.UserClaims{}, user.ID).Code)
+	res = service.User.Login(dto.LoginDto{Username: "totp_user", Password: "123456"}, dto.ClientInfo{})
+	assert.NotEmpty(t, res.Data.(map[string]interface{})["token"])
+}
+
+// TestTotpLoginLockout verifies wrong second-factor codes count as login failures,
+// so fetching a fresh pre-auth token after each guess still ends in a lockout.
+func TestTotpLoginLockout(t *testing.T) {
+	user := testutil.CreateUser("totp_lockout", 1, 1, 100, time.Now().Add(time.Hour).UnixMilli())
+	claims := &utils.UserClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: strconv.FormatInt(user.ID, 10)}}
+	setup := service.Totp.Setup(claims).Data.(dto.TotpSetupDto)
+	code, _ := utils.TotpCode(setup.Secret, utils.TotpCounter(time.Now()))
+	assert.Equal(t, 0, service.Totp.Enable(claims, dto.TotpEnableDto{Code: code}, dto.ClientInfo{}).Code)
+
+	service.ViteConfig.UpdateConfig("login_user_max_failures", "3")
+	defer service.ViteConfig.UpdateConfig("login_user_max_failures", "")
+	client := dto.ClientInfo{Ip: "198.51.100.30"}
+
+	for i := 0; i < 3; i++ {
+		res := service.User.Login(dto.LoginDto{Username: "totp_lockout", Password: "123456"}, client)
+		if !assert.Equal(t, 0, res.Code, res.Msg) {
+			return
+		}
+		preAuth := res.Data.(map[string]interface{})["preAuthToken"].(string)
+		assert.Equal(t, "验证码错误", service.Totp.Login(dto.TotpLoginDto{PreAuthToken: preAuth, Code: "bad-recovery"}, client).Msg)
+	}
+
+	// 密码正确也不再签发预认证 token
+	assert.Contains(t, service.User.Login(dto.LoginDto{Username: "totp_lockout", Password: "123456"}, client).Msg, "登录失败次数过多")
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/rbac"
+	"go-backend/result"
+	"go-backend/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TrafficService 转发 / 隧道 / 节点 / 用户的流量时序
+// 节点上报的流量先在内存中按 5 分钟区间累加，由统计任务定期写库，写库时同时累加到三个粒度
+type TrafficService struct {
+	mu      sync.Mutex
+	pending map[trafficKey]*trafficDelta
+}
+
+var Traffic = new(TrafficService)
+
+type trafficKey struct {
+	scope   string
+	scopeId int64
+	bucket  int64
+}
+
+type trafficDelta struct {
+	in  int64
+	out int64
+}
+
+// 各粒度的保留期和单次查询最多返回的点数
+var (
+	trafficRetention = map[string]time.Duration{
+		model.TrafficPeriod5Min: 2 * 24 * time.Hour,
+		model.TrafficPeriodHour: 60 * 24 * time.Hour,
+		model.TrafficPeriodDay:  2 * 365 * 24 * time.Hour,
+	}
+	maxTrafficPoints = 2000
+)
+
+// Record 记录一次流量上报（已按隧道倍率换算），id 为 0 的维度跳过
+func (s *TrafficService) Record(forwardId, tunnelId, nodeId, userId int64, inFlow, outFlow int64) {
+	if inFlow == 0 && outFlow == 0 {
+		return
+	}
+	bucket := time.Now().Truncate(5 * time.Minute).UnixMilli()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		s.pending = make(map[trafficKey]*trafficDelta)
+	}
+	for scope, id := range map[string]int64{
+		model.TrafficScopeForward: forwardId,
+		model.TrafficScopeTunnel:  tunnelId,
+		model.TrafficScopeNode:    nodeId,
+		model.TrafficScopeUser:    userId,
+	} {
+		if id == 0 {
+			continue
+		}
+		key := trafficKey{scope: scope, scopeId: id, bucket: bucket}
+		d := s.pending[key]
+		if d == nil {
+			d = &trafficDelta{}
+			s.pending[key] = d
+		}
+		d.in += inFlow
+		d.out += outFlow
+	}
+}
+
+// Flush 将内存中累加的流量写入三个粒度的时序
+func (s *TrafficService) Flush() {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	for key, d := range pending {
+		bucketTime := time.UnixMilli(key.bucket)
+		for _, period := range []string{model.TrafficPeriod5Min, model.TrafficPeriodHour, model.TrafficPeriodDay} {
+			row := model.TrafficSeries{
+				Scope:      key.scope,
+				ScopeId:    key.scopeId,
+				Period:     period,
+				BucketTime: trafficBucket(bucketTime, period).UnixMilli(),
+				InFlow:     d.in,
+				OutFlow:    d.out,
+			}
+			err := global.DB.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "scope"}, {Name: "scope_id"}, {Name: "period"}, {Name: "bucket_time"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"in_flow":  gorm.Expr("in_flow + ?", d.in),
+					"out_flow": gorm.Expr("out_flow + ?", d.out),
+				}),
+			}).Create(&row).Error
+			if err != nil {
+				log.Printf("⚠️ 写入流量时序失败 %s:%d: %v", key.scope, key.scopeId, err)
+			}
+		}
+	}
+}
+
+// Prune 按各粒度的保留期清理过期数据
+func (s *TrafficService) Prune(now time.Time) {
+	for period, retention := range trafficRetention {
+		cutoff := now.Add(-retention).UnixMilli()
+		global.DB.Where("period = ? AND bucket_time < ?", period, cutoff).Delete(&model.TrafficSeries{})
+	}
+}
+
+// QuerySeries 查询流量时序，没有 traffic:read 权限时只能查看自己及自己转发的流量
+func (s *TrafficService) QuerySeries(req dto.TrafficQueryDto, claims *utils.UserClaims) *result.Result {
+	if !rbac.Has(claims.RoleId, model.PermTrafficRead) {
+		switch req.Scope {
+		case model.TrafficScopeUser:
+			if req.ScopeId != 0 && req.ScopeId != claims.GetUserId() {
+				return result.Err(-1, "无权查看该流量统计")
+			}
+			req.ScopeId = claims.GetUserId()
+		case model.TrafficScopeForward:
+			var forward model.Forward
+			if err := global.DB.First(&forward, req.ScopeId).Error; err != nil || forward.UserId != claims.GetUserId() {
+				return result.Err(-1, "无权查看该流量统计")
+			}
+		default:
+			return result.Err(-1, "无权查看该流量统计")
+		}
+	}
+
+	series, err := s.Series(req.Scope, req.ScopeId, req.Start, req.End, req.Period)
+	if err != nil {
+		return result.Err(-1, err.Error())
+	}
+	return result.Ok(series)
+}
+
+// Series 查询 [start, end) 范围内的流量时序，缺失的区间补 0
+// start / end 为 0 时默认最近 24 小时；period 为空时选择能覆盖该范围的最细粒度
+func (s *TrafficService) Series(scope string, scopeId int64, start, end int64, period string) (*dto.TrafficSeriesDto, error) {
+	switch scope {
+	case model.TrafficScopeUser, model.TrafficScopeForward, model.TrafficScopeTunnel, model.TrafficScopeNode:
+	default:
+		return nil, fmt.Errorf("不支持的统计对象: %s", scope)
+	}
+
+	now := time.Now()
+	if end <= 0 {
+		end = now.UnixMilli()
+	}
+	if start <= 0 {
+		start = end - 24*time.Hour.Milliseconds()
+	}
+	if start >= end {
+		return nil, fmt.Errorf("开始时间必须早于结束时间")
+	}
+
+	if period == "" {
+		period = pickTrafficPeriod(now, start)
+	} else if _, ok := trafficRetention[period]; !ok {
+		return nil, fmt.Errorf("不支持的统计粒度: %s", period)
+	}
+
+	var buckets []int64
+	for t := trafficBucket(time.UnixMilli(start), period); t.UnixMilli() < end; t = nextTrafficBucket(t, period) {
+		buckets = append(buckets, t.UnixMilli())
+		if len(buckets) > maxTrafficPoints {
+			return nil, fmt.Errorf("时间范围过大，请选择更粗的统计粒度")
+		}
+	}
+
+	var rows []model.TrafficSeries
+	if len(buckets) > 0 {
+		global.DB.Where("scope = ? AND scope_id = ? AND period = ? AND bucket_time >= ? AND bucket_time < ?",
+			scope, scopeId, period, buckets[0], end).Order("bucket_time asc").Find(&rows)
+	}
+	byBucket := make(map[int64]model.TrafficSeries, len(rows))
+	for _, r := range rows {
+		byBucket[r.BucketTime] = r
+	}
+
+	points := make([]dto.TrafficPointDto, 0, len(buckets))
+	for _, b := range buckets {
+		r := byBucket[b]
+		points = append(points, dto.TrafficPointDto{Time: b, InFlow: r.InFlow, OutFlow: r.OutFlow})
+	}
+
+	return &dto.TrafficSeriesDto{
+		Scope:   scope,
+		ScopeId: scopeId,
+		Period:  period,
+		Start:   start,
+		End:     end,
+		Points:  points,
+	}, nil
+}
+
+// pickTrafficPeriod 选择保留期仍覆盖 start 的最细粒度
+func pickTrafficPeriod(now time.Time, start int64) string {
+	for _, period := range []string{model.TrafficPeriod5Min, model.TrafficPeriodHour} {
+		if start >= now.Add(-trafficRetention[period]).UnixMilli() {
+			return period
+		}
+	}
+	return model.TrafficPeriodDay
+}
+
+// trafficBucket 返回 t 所在区间的起始时间，按天统计时以本地零点为界
+func trafficBucket(t time.Time, period string) time.Time {
+	switch period {
+	case model.TrafficPeriodHour:
+		return t.Truncate(time.Hour)
+	case model.TrafficPeriodDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	default:
+		return t.Truncate(5 * time.Minute)
+	}
+}
+
+func nextTrafficBucket(t time.Time, period string) time.Time {
+	switch period {
+	case model.TrafficPeriodHour:
+		return t.Add(time.Hour)
+	case model.TrafficPeriodDay:
+		return t.AddDate(0, 0, 1)
+	default:
+		return t.Add(5 * time.Minute)
+	}
+}
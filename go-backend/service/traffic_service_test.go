@@ -0,0 +1,45 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrafficSeriesTiers(t *testing.T) {
+	const forwardId = 950001
+	service.Traffic.Record(forwardId, 0, 0, 0, 100, 200)
+	service.Traffic.Record(forwardId, 0, 0, 0, 10, 20)
+	service.Traffic.Flush()
+	service.Traffic.Record(forwardId, 0, 0, 0, 1, 2)
+	service.Traffic.Flush()
+
+	for _, period := range []string{model.TrafficPeriod5Min, model.TrafficPeriodHour, model.TrafficPeriodDay} {
+		series, err := service.Traffic.Series(model.TrafficScopeForward, forwardId, time.Now().Add(-time.Hour).UnixMilli(), time.Now().Add(time.Minute).UnixMilli(), period)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		var in, out int64
+		for _, p := range series.Points {
+			in += p.InFlow
+			out += p.OutFlow
+		}
+		assert.Equal(t, int64(111), in, "period %s", period)
+		assert.Equal(t, int64(222), out, "period %s", period)
+	}
+
+	// 超过 2 天的 5 分钟数据被清理，小时数据保留
+	old := time.Now().Add(-72 * time.Hour).Truncate(time.Hour).UnixMilli()
+	global.DB.Create(&model.TrafficSeries{Scope: model.TrafficScopeForward, ScopeId: forwardId, Period: model.TrafficPeriod5Min, BucketTime: old, InFlow: 1})
+	global.DB.Create(&model.TrafficSeries{Scope: model.TrafficScopeForward, ScopeId: forwardId, Period: model.TrafficPeriodHour, BucketTime: old, InFlow: 1})
+	service.Traffic.Prune(time.Now())
+
+	var count int64
+	global.DB.Model(&model.TrafficSeries{}).Where("scope_id = ? AND bucket_time = ?", forwardId, old).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
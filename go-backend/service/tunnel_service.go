@@ -14,6 +14,7 @@ import (
 	"go-backend/websocket"
 
 	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
 )
 
 type TunnelService struct{}
@@ -63,6 +64,15 @@ func (s *TunnelService) CreateTunnel(dto dto.TunnelDto) *result.Result {
 		tunnel.UdpListenAddr = dto.UdpListenAddr
 	}
 
+	// 转发默认来源 IP 访问控制
+	var err error
+	if tunnel.AllowCidrs, err = normalizeCidrList(dto.AllowCidrs); err != nil {
+		return result.Err(-1, "白名单: "+err.Error())
+	}
+	if tunnel.DenyCidrs, err = normalizeCidrList(dto.DenyCidrs); err != nil {
+		return result.Err(-1, "黑名单: "+err.Error())
+	}
+
 	// Traffic Ratio
 	if dto.TrafficRatio.IsZero() {
 		tunnel.TrafficRatio = 1.0
@@ -98,6 +108,32 @@ func (s *TunnelService) CreateTunnel(dto dto.TunnelDto) *result.Result {
 		tunnel.OutIp = outNode.ServerIp
 	}
 
+	// 节点组和中转节点校验
+	if dto.Type != 2 && (len(dto.HopNodeIds) > 0 || len(dto.OutNodeIds) > 0) {
+		return result.Err(-1, "端口转发不支持中转节点和出口节点组")
+	}
+	if !validTunnelStrategy(dto.Strategy) {
+		return result.Err(-1, "不支持的负载策略: "+dto.Strategy)
+	}
+	tunnel.Strategy = dto.Strategy
+	tunnel.MaxFails = dto.MaxFails
+	tunnel.FailTimeout = dto.FailTimeout
+
+	seen := map[int64]bool{tunnel.InNodeId: true, tunnel.OutNodeId: true}
+	entryNodes, err := s.validateGroupNodes("入口节点", dto.InNodeIds, seen)
+	if err != nil {
+		return result.Err(-1, err.Error())
+	}
+	exitNodes, err := s.validateGroupNodes("出口节点", dto.OutNodeIds, seen)
+	if err != nil {
+		return result.Err(-1, err.Error())
+	}
+	hopNodes, err := s.validateGroupNodes("中转节点", dto.HopNodeIds, seen)
+	if err != nil {
+		return result.Err(-1, err.Error())
+	}
+	tunnel.InIp = joinEntryIps(&inNode, entryNodes)
+
 	// Defaults
 	tunnel.Status = 1
 	tunnel.CreatedTime = time.Now().UnixMilli()
@@ -112,7 +148,33 @@ func (s *TunnelService) CreateTunnel(dto dto.TunnelDto) *result.Result {
 		tunnel.OutPort = outPort
 	}
 
-	if err := global.DB.Create(&tunnel).Error; err != nil {
+	// 为每个附加出口和中转节点分配 relay 端口
+	entryRows, err := s.buildTunnelNodes(0, model.TunnelNodeEntry, entryNodes)
+	if err != nil {
+		return result.Err(-1, "入口节点保存失败: "+err.Error())
+	}
+	exitRows, err := s.buildTunnelNodes(0, model.TunnelNodeExit, exitNodes)
+	if err != nil {
+		return result.Err(-1, "出口端口分配失败: "+err.Error())
+	}
+	hopRows, err := s.buildTunnelHops(hopNodes, nil)
+	if err != nil {
+		return result.Err(-1, "中转端口分配失败: "+err.Error())
+	}
+
+	err = global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&tunnel).Error; err != nil {
+			return err
+		}
+		if err := replaceTunnelNodes(tx, tunnel.ID, model.TunnelNodeEntry, entryRows); err != nil {
+			return err
+		}
+		if err := replaceTunnelNodes(tx, tunnel.ID, model.TunnelNodeExit, exitRows); err != nil {
+			return err
+		}
+		return replaceTunnelHops(tx, tunnel.ID, hopRows)
+	})
+	if err != nil {
 		return result.Err(-1, "隧道创建失败: "+err.Error())
 	}
 
@@ -120,6 +182,7 @@ func (s *TunnelService) CreateTunnel(dto dto.TunnelDto) *result.Result {
 	if tunnel.Type == 2 {
 		if err := s.createTunnelSharedServices(&tunnel); err != nil {
 			// 回滚：删除数据库记录
+			s.purgeTunnelNodes(tunnel.ID)
 			global.DB.Delete(&tunnel)
 			return result.Err(-1, "共享服务创建失败: "+err.Error())
 		}
@@ -180,6 +243,30 @@ func (s *TunnelService) UserTunnel(userId int64) *result.Result {
 func (s *TunnelService) GetAllTunnels() *result.Result {
 	var tunnels []model.Tunnel
 	global.DB.Find(&tunnels)
+
+	var hops []model.TunnelHop
+	global.DB.Order("tunnel_id asc, inx asc").Find(&hops)
+	hopMap := make(map[int64][]model.TunnelHop)
+	for _, h := range hops {
+		hopMap[h.TunnelId] = append(hopMap[h.TunnelId], h)
+	}
+	var groupNodes []model.TunnelNode
+	global.DB.Order("id asc").Find(&groupNodes)
+	inMap := make(map[int64][]model.TunnelNode)
+	outMap := make(map[int64][]model.TunnelNode)
+	for _, n := range groupNodes {
+		if n.Role == model.TunnelNodeEntry {
+			inMap[n.TunnelId] = append(inMap[n.TunnelId], n)
+		} else {
+			outMap[n.TunnelId] = append(outMap[n.TunnelId], n)
+		}
+	}
+
+	for i := range tunnels {
+		tunnels[i].Hops = hopMap[tunnels[i].ID]
+		tunnels[i].InNodes = inMap[tunnels[i].ID]
+		tunnels[i].OutNodes = outMap[tunnels[i].ID]
+	}
 	return result.Ok(tunnels)
 }
 
@@ -195,6 +282,10 @@ func (s *TunnelService) UpdateTunnel(req dto.TunnelUpdateDto) *result.Result {
 		return result.Err(-1, "隧道名称已存在")
 	}
 
+	if req.Strategy != nil && !validTunnelStrategy(*req.Strategy) {
+		return result.Err(-1, "不支持的负载策略: "+*req.Strategy)
+	}
+
 	// Check for critical changes
 	criticalChange := false
 	if tunnel.TcpListenAddr != req.TcpListenAddr ||
@@ -203,6 +294,39 @@ func (s *TunnelService) UpdateTunnel(req dto.TunnelUpdateDto) *result.Result {
 		tunnel.InterfaceName != req.InterfaceName {
 		criticalChange = true
 	}
+	// 出口选择策略只在请求携带且确有变化时更新
+	selectorChanged := false
+	if req.Strategy != nil && *req.Strategy != tunnel.Strategy {
+		selectorChanged = true
+		tunnel.Strategy = *req.Strategy
+	}
+	if req.MaxFails != nil && *req.MaxFails != tunnel.MaxFails {
+		selectorChanged = true
+		tunnel.MaxFails = *req.MaxFails
+	}
+	if req.FailTimeout != nil && *req.FailTimeout != tunnel.FailTimeout {
+		selectorChanged = true
+		tunnel.FailTimeout = *req.FailTimeout
+	}
+
+	// 默认访问控制变化时需要同步隧道下所有转发
+	aclChanged := false
+	if req.AllowCidrs != nil {
+		allow, err := normalizeCidrList(*req.AllowCidrs)
+		if err != nil {
+			return result.Err(-1, "白名单: "+err.Error())
+		}
+		aclChanged = aclChanged || allow != tunnel.AllowCidrs
+		tunnel.AllowCidrs = allow
+	}
+	if req.DenyCidrs != nil {
+		deny, err := normalizeCidrList(*req.DenyCidrs)
+		if err != nil {
+			return result.Err(-1, "黑名单: "+err.Error())
+		}
+		aclChanged = aclChanged || deny != tunnel.DenyCidrs
+		tunnel.DenyCidrs = deny
+	}
 
 	tunnel.Name = req.Name
 	tunnel.Flow = req.Flow
@@ -216,20 +340,44 @@ func (s *TunnelService) UpdateTunnel(req dto.TunnelUpdateDto) *result.Result {
 	}
 	tunnel.UpdatedTime = time.Now().UnixMilli()
 
-	// Update DB
-	if err := global.DB.Save(&tunnel).Error; err != nil {
+	// 节点组变更先校验并分配端口，全部通过后再写库
+	plan, err := s.planNodeGroupChanges(&tunnel, req)
+	if err != nil {
+		return result.Err(-1, err.Error())
+	}
+	if plan.entryChanged {
+		var inNode model.Node
+		global.DB.First(&inNode, tunnel.InNodeId)
+		tunnel.InIp = joinEntryIps(&inNode, plan.entries)
+	}
+
+	// 隧道和节点组在同一事务中保存，失败时不会留下部分更新
+	err = global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&tunnel).Error; err != nil {
+			return err
+		}
+		return s.saveNodeGroupChanges(tx, &tunnel, plan)
+	})
+	if err != nil {
 		return result.Err(-1, "隧道更新失败: "+err.Error())
 	}
 
+	// 节点组变更：新增或移除的节点整体下发配置快照，其余节点更新共享服务
+	if plan.changed() {
+		if err := s.pushNodeGroupChanges(plan); err != nil {
+			return result.Err(-1, err.Error())
+		}
+	}
+
 	// 如果是 Type 2 隧道且有关键变更，先更新共享服务
-	if tunnel.Type == 2 && criticalChange {
+	if tunnel.Type == 2 && (criticalChange || selectorChanged || plan.changed()) {
 		if err := s.updateTunnelSharedServices(&tunnel); err != nil {
 			return result.Err(-1, "更新隧道共享服务失败: "+err.Error())
 		}
 	}
 
 	// Sync Forwards if needed
-	if criticalChange {
+	if criticalChange || aclChanged {
 		var forwards []model.Forward
 		global.DB.Where("tunnel_id = ?", tunnel.ID).Find(&forwards)
 		for _, f := range forwards {
@@ -267,8 +415,10 @@ func (s *TunnelService) DiagnoseTunnel(tunnelId int64) *result.Result {
 	if tunnel.Type == 1 {
 		// Port Forward: Check connect to google? Or just ping self?
 		// Java: tcp ping www.google.com:443 from InNode
-		res := s.PerformTcpPing(&inNode, "www.google.com", 443, "入口->外网")
-		results = append(results, res)
+		for _, node := range loadNodes(tunnelEntryNodeIds(&tunnel)) {
+			res := s.PerformTcpPing(&node, "www.google.com", 443, "入口->外网")
+			results = append(results, res)
+		}
 	} else {
 		// Tunnel Forward
 		var outNode model.Node
@@ -276,13 +426,14 @@ func (s *TunnelService) DiagnoseTunnel(tunnelId int64) *result.Result {
 			return result.Err(-1, "出口节点不存在")
 		}
 
-		// In -> Out
-		res1 := s.PerformTcpPing(&inNode, outNode.ServerIp, tunnel.OutPort, "入口->出口")
-		results = append(results, res1)
+		// 入口 -> 中转... -> 出口，逐段检测
+		results = append(results, s.DiagnoseTunnelLegs(&tunnel)...)
 
 		// Out -> External
-		res2 := s.PerformTcpPing(&outNode, "www.google.com", 443, "出口->外网")
-		results = append(results, res2)
+		for _, node := range loadNodes(tunnelExitNodeIds(&tunnel)) {
+			res2 := s.PerformTcpPing(&node, "www.google.com", 443, "出口->外网")
+			results = append(results, res2)
+		}
 	}
 
 	report := map[string]interface{}{
@@ -296,6 +447,19 @@ func (s *TunnelService) DiagnoseTunnel(tunnelId int64) *result.Result {
 		report["tunnelType"] = "隧道转发"
 	}
 
+	// 任一检测项失败时通知外部系统
+	var failed []map[string]interface{}
+	for _, r := range results {
+		if ok, _ := r["success"].(bool); !ok {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) > 0 {
+		Webhook.Emit(model.EventTunnelDiagnoseFailed, map[string]interface{}{
+			"tunnelId": tunnel.ID, "tunnelName": tunnel.Name, "failures": failed,
+		})
+	}
+
 	return result.Ok(report)
 }
 
@@ -372,6 +536,7 @@ func (s *TunnelService) DeleteTunnel(id int64) *result.Result {
 	if err := global.DB.Delete(&model.Tunnel{}, id).Error; err != nil {
 		return result.Err(-1, "隧道删除失败")
 	}
+	s.purgeTunnelNodes(id)
 	return result.Ok("隧道删除成功")
 }
 
@@ -418,7 +583,12 @@ func (s *TunnelService) getUsedTunnelOutPorts(outNodeId int64, excludeTunnelId *
 		}
 	}
 
-	// 2. Forward 使用的出口端口（包括旧数据和 Type 1 转发）
+	// 2. 中转节点和附加出口节点的 relay 端口
+	for p := range getUsedRelayPorts(outNodeId, excludeTunnelId) {
+		used[p] = true
+	}
+
+	// 3. Forward 使用的出口端口（包括旧数据和 Type 1 转发）
 	var forwardOutPorts []int
 	global.DB.Model(&model.Forward{}).
 		Joins("JOIN tunnel ON forward.tunnel_id = tunnel.id").
@@ -433,9 +603,498 @@ func (s *TunnelService) getUsedTunnelOutPorts(outNodeId int64, excludeTunnelId *
 	return used
 }
 
+// tunnelRelayAddr 构建入口节点 chain 指向出口 relay 的地址
+func tunnelRelayAddr(tunnel *model.Tunnel) string {
+	return formatHostPort(tunnel.OutIp, tunnel.OutPort)
+}
+
+// tunnelExit 出口节点组成员的 relay 地址
+type tunnelExit struct {
+	NodeId int64
+	Ip     string
+	Port   int
+}
+
+// tunnelExits 出口节点组：主出口节点在前，附加出口节点按添加顺序
+func tunnelExits(tunnel *model.Tunnel) []tunnelExit {
+	exits := []tunnelExit{{NodeId: tunnel.OutNodeId, Ip: tunnel.OutIp, Port: tunnel.OutPort}}
+	for _, n := range loadTunnelNodes(tunnel.ID, model.TunnelNodeExit) {
+		exits = append(exits, tunnelExit{NodeId: n.NodeId, Ip: n.Ip, Port: n.Port})
+	}
+	return exits
+}
+
+// tunnelChainPath 入口 chain 的路径：依次经过的中转节点地址，以及出口节点组地址
+func tunnelChainPath(tunnel *model.Tunnel) ([]string, []string) {
+	transits := []string{}
+	for _, h := range loadTunnelHops(tunnel.ID) {
+		transits = append(transits, formatHostPort(h.Ip, h.Port))
+	}
+	exits := []string{}
+	for _, e := range tunnelExits(tunnel) {
+		exits = append(exits, formatHostPort(e.Ip, e.Port))
+	}
+	return transits, exits
+}
+
+// tunnelEntryNodeIds 入口节点组：主入口节点和附加入口节点，转发服务在每个入口节点上发布
+func tunnelEntryNodeIds(tunnel *model.Tunnel) []int64 {
+	ids := []int64{tunnel.InNodeId}
+	for _, n := range loadTunnelNodes(tunnel.ID, model.TunnelNodeEntry) {
+		ids = append(ids, n.NodeId)
+	}
+	return ids
+}
+
+// tunnelExitNodeIds 出口节点组的节点 ID
+func tunnelExitNodeIds(tunnel *model.Tunnel) []int64 {
+	ids := []int64{}
+	for _, e := range tunnelExits(tunnel) {
+		ids = append(ids, e.NodeId)
+	}
+	return ids
+}
+
+func formatHostPort(ip string, port int) string {
+	if strings.Contains(ip, ":") {
+		return fmt.Sprintf("[%s]:%d", ip, port)
+	}
+	return fmt.Sprintf("%s:%d", ip, port)
+}
+
+// joinEntryIps 隧道入口 IP，多个入口节点时逗号分隔
+func joinEntryIps(inNode *model.Node, extras []model.Node) string {
+	ips := []string{inNode.Ip}
+	for _, n := range extras {
+		ips = append(ips, n.Ip)
+	}
+	return strings.Join(ips, ",")
+}
+
+func validTunnelStrategy(strategy string) bool {
+	switch strategy {
+	case "", "round", "random", "rand", "fifo", "hash":
+		return true
+	}
+	return false
+}
+
+// loadTunnelHops 按顺序加载隧道的中转节点
+func loadTunnelHops(tunnelId int64) []model.TunnelHop {
+	var hops []model.TunnelHop
+	global.DB.Where("tunnel_id = ?", tunnelId).Order("inx asc").Find(&hops)
+	return hops
+}
+
+// loadTunnelNodes 加载隧道的附加入口或出口节点
+func loadTunnelNodes(tunnelId int64, role int) []model.TunnelNode {
+	var nodes []model.TunnelNode
+	global.DB.Where("tunnel_id = ? AND role = ?", tunnelId, role).Order("id asc").Find(&nodes)
+	return nodes
+}
+
+// loadNodes 按给定顺序加载节点，不存在的节点跳过
+func loadNodes(ids []int64) []model.Node {
+	nodes := make([]model.Node, 0, len(ids))
+	for _, id := range ids {
+		var node model.Node
+		if err := global.DB.First(&node, id).Error; err == nil {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+func (s *TunnelService) purgeTunnelNodes(tunnelId int64) {
+	global.DB.Where("tunnel_id = ?", tunnelId).Delete(&model.TunnelHop{})
+	global.DB.Where("tunnel_id = ?", tunnelId).Delete(&model.TunnelNode{})
+}
+
+// getUsedRelayPorts 获取节点上中转 relay 和附加出口 relay 已占用的端口
+func getUsedRelayPorts(nodeId int64, excludeTunnelId *int64) map[int]bool {
+	used := make(map[int]bool)
+	var ports []int
+	query := global.DB.Model(&model.TunnelHop{}).Where("node_id = ?", nodeId)
+	if excludeTunnelId != nil {
+		query = query.Where("tunnel_id != ?", *excludeTunnelId)
+	}
+	query.Pluck("port", &ports)
+
+	var exitPorts []int
+	query = global.DB.Model(&model.TunnelNode{}).Where("node_id = ? AND role = ?", nodeId, model.TunnelNodeExit)
+	if excludeTunnelId != nil {
+		query = query.Where("tunnel_id != ?", *excludeTunnelId)
+	}
+	query.Pluck("port", &exitPorts)
+
+	for _, p := range append(ports, exitPorts...) {
+		used[p] = true
+	}
+	return used
+}
+
+func sameNodeIds(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// validateGroupNodes 校验附加节点：存在、在线、不与隧道中已有节点重复
+// seen 记录已占用的节点，校验通过的节点会加入其中
+func (s *TunnelService) validateGroupNodes(label string, nodeIds []int64, seen map[int64]bool) ([]model.Node, error) {
+	nodes := make([]model.Node, 0, len(nodeIds))
+	for i, id := range nodeIds {
+		if seen[id] {
+			return nil, fmt.Errorf("%s %d 与隧道中的其他节点重复", label, i+1)
+		}
+		seen[id] = true
+
+		var node model.Node
+		if err := global.DB.First(&node, id).Error; err != nil {
+			return nil, fmt.Errorf("%s %d 不存在", label, i+1)
+		}
+		if node.Status != 1 {
+			return nil, fmt.Errorf("%s %s 当前离线，请确保节点正常运行", label, node.Name)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// buildTunnelHops 按顺序为中转节点分配 relay 端口，只生成记录不写库
+// excludeTunnelId 不为空时不计入该隧道自身占用的端口，其旧记录会在写库时整体替换
+func (s *TunnelService) buildTunnelHops(nodes []model.Node, excludeTunnelId *int64) ([]model.TunnelHop, error) {
+	hops := make([]model.TunnelHop, 0, len(nodes))
+	for i, node := range nodes {
+		port, err := s.allocateNodePort(&node, excludeTunnelId)
+		if err != nil {
+			return nil, err
+		}
+		hops = append(hops, model.TunnelHop{
+			Inx:         i + 1,
+			NodeId:      node.ID,
+			Ip:          node.ServerIp,
+			Port:        port,
+			CreatedTime: time.Now().UnixMilli(),
+		})
+	}
+	return hops, nil
+}
+
+// buildTunnelNodes 生成附加入口或出口节点记录，保留的出口节点沿用原 relay 端口
+// tunnelId 为 0 表示新建隧道
+func (s *TunnelService) buildTunnelNodes(tunnelId int64, role int, nodes []model.Node) ([]model.TunnelNode, error) {
+	var exclude *int64
+	oldPorts := make(map[int64]int)
+	if tunnelId != 0 {
+		exclude = &tunnelId
+		for _, n := range loadTunnelNodes(tunnelId, role) {
+			oldPorts[n.NodeId] = n.Port
+		}
+	}
+
+	rows := make([]model.TunnelNode, 0, len(nodes))
+	for _, node := range nodes {
+		row := model.TunnelNode{
+			NodeId:      node.ID,
+			Role:        role,
+			Ip:          node.Ip,
+			CreatedTime: time.Now().UnixMilli(),
+		}
+		if role == model.TunnelNodeExit {
+			row.Ip = node.ServerIp
+			port, ok := oldPorts[node.ID]
+			if !ok {
+				var err error
+				if port, err = s.allocateNodePort(&node, exclude); err != nil {
+					return nil, err
+				}
+			}
+			row.Port = port
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// replaceTunnelHops 用 hops 替换隧道的中转节点
+func replaceTunnelHops(tx *gorm.DB, tunnelId int64, hops []model.TunnelHop) error {
+	if err := tx.Where("tunnel_id = ?", tunnelId).Delete(&model.TunnelHop{}).Error; err != nil {
+		return err
+	}
+	for i := range hops {
+		hops[i].ID = 0
+		hops[i].TunnelId = tunnelId
+		if err := tx.Create(&hops[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceTunnelNodes 用 rows 替换隧道的附加入口或出口节点
+func replaceTunnelNodes(tx *gorm.DB, tunnelId int64, role int, rows []model.TunnelNode) error {
+	if err := tx.Where("tunnel_id = ? AND role = ?", tunnelId, role).Delete(&model.TunnelNode{}).Error; err != nil {
+		return err
+	}
+	for i := range rows {
+		rows[i].ID = 0
+		rows[i].TunnelId = tunnelId
+		if err := tx.Create(&rows[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allocateNodePort 在中转或附加出口节点上分配 relay 端口，避开转发入口和其他 relay 占用的端口
+func (s *TunnelService) allocateNodePort(node *model.Node, excludeTunnelId *int64) (int, error) {
+	ranges, err := utils.ParsePortRanges(node.PortRanges)
+	if err != nil {
+		return 0, fmt.Errorf("节点 %s 端口配置错误: %s", node.Name, err.Error())
+	}
+	used := Forward.getUsedPorts(node.ID, nil)
+	for p := range s.getUsedTunnelOutPorts(node.ID, excludeTunnelId) {
+		used[p] = true
+	}
+	for _, p := range utils.GetAllPorts(ranges) {
+		if !used[p] {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("节点 %s 无可用端口", node.Name)
+}
+
+// nodeGroupPlan 隧道节点组的变更计划
+type nodeGroupPlan struct {
+	entries      []model.Node
+	exits        []model.Node
+	hops         []model.Node
+	entryRows    []model.TunnelNode
+	exitRows     []model.TunnelNode
+	hopRows      []model.TunnelHop
+	entryChanged bool
+	exitChanged  bool
+	hopChanged   bool
+	affected     []int64 // 新增或移除的节点，变更后整体下发配置快照
+}
+
+func (p *nodeGroupPlan) changed() bool {
+	return p.entryChanged || p.exitChanged || p.hopChanged
+}
+
+// planNodeGroupChanges 校验请求中的入口、出口和中转节点变更，并预先分配 relay 端口
+// 只读不写，任何校验或端口分配失败都不会留下部分更新
+func (s *TunnelService) planNodeGroupChanges(tunnel *model.Tunnel, req dto.TunnelUpdateDto) (*nodeGroupPlan, error) {
+	oldEntries := []int64{}
+	for _, n := range loadTunnelNodes(tunnel.ID, model.TunnelNodeEntry) {
+		oldEntries = append(oldEntries, n.NodeId)
+	}
+	oldExits := []int64{}
+	for _, n := range loadTunnelNodes(tunnel.ID, model.TunnelNodeExit) {
+		oldExits = append(oldExits, n.NodeId)
+	}
+	oldHops := []int64{}
+	for _, h := range loadTunnelHops(tunnel.ID) {
+		oldHops = append(oldHops, h.NodeId)
+	}
+
+	newEntries, newExits, newHops := oldEntries, oldExits, oldHops
+	if req.InNodeIds != nil {
+		newEntries = *req.InNodeIds
+	}
+	if req.OutNodeIds != nil {
+		newExits = *req.OutNodeIds
+	}
+	if req.HopNodeIds != nil {
+		newHops = *req.HopNodeIds
+	}
+
+	plan := &nodeGroupPlan{
+		entryChanged: !sameNodeIds(oldEntries, newEntries),
+		exitChanged:  !sameNodeIds(oldExits, newExits),
+		hopChanged:   !sameNodeIds(oldHops, newHops),
+	}
+	if !plan.changed() {
+		return plan, nil
+	}
+	if tunnel.Type != 2 && (len(newHops) > 0 || len(newExits) > 0) {
+		return nil, fmt.Errorf("端口转发不支持中转节点和出口节点组")
+	}
+
+	var err error
+	seen := map[int64]bool{tunnel.InNodeId: true, tunnel.OutNodeId: true}
+	if plan.entries, err = s.validateGroupNodes("入口节点", newEntries, seen); err != nil {
+		return nil, err
+	}
+	if plan.exits, err = s.validateGroupNodes("出口节点", newExits, seen); err != nil {
+		return nil, err
+	}
+	if plan.hops, err = s.validateGroupNodes("中转节点", newHops, seen); err != nil {
+		return nil, err
+	}
+
+	// 新增的入口节点需要能监听隧道下所有转发的端口
+	if plan.entryChanged {
+		var forwards []model.Forward
+		global.DB.Where("tunnel_id = ?", tunnel.ID).Find(&forwards)
+		for _, node := range plan.entries {
+			if containsNodeId(oldEntries, node.ID) {
+				continue
+			}
+			for _, f := range forwards {
+				if err := Forward.checkPortAvailable(node.ID, f.InPort, nil); err != nil {
+					return nil, fmt.Errorf("入口节点 %s 无法发布转发 %s: %s", node.Name, f.Name, err.Error())
+				}
+			}
+		}
+	}
+
+	// 预先生成要写入的记录，端口不足等错误在写库前返回
+	if plan.entryChanged {
+		if plan.entryRows, err = s.buildTunnelNodes(tunnel.ID, model.TunnelNodeEntry, plan.entries); err != nil {
+			return nil, fmt.Errorf("入口节点保存失败: %s", err.Error())
+		}
+	}
+	if plan.exitChanged {
+		if plan.exitRows, err = s.buildTunnelNodes(tunnel.ID, model.TunnelNodeExit, plan.exits); err != nil {
+			return nil, fmt.Errorf("出口端口分配失败: %s", err.Error())
+		}
+	}
+	if plan.hopChanged {
+		if plan.hopRows, err = s.buildTunnelHops(plan.hops, &tunnel.ID); err != nil {
+			return nil, fmt.Errorf("中转端口分配失败: %s", err.Error())
+		}
+	}
+
+	// 中转顺序变化会改变 relay 服务名，新旧中转节点都需要重新下发
+	if plan.entryChanged {
+		plan.affected = append(plan.affected, symmetricDiff(oldEntries, newEntries)...)
+	}
+	if plan.exitChanged {
+		plan.affected = append(plan.affected, symmetricDiff(oldExits, newExits)...)
+	}
+	if plan.hopChanged {
+		plan.affected = append(plan.affected, oldHops...)
+		plan.affected = append(plan.affected, newHops...)
+	}
+	return plan, nil
+}
+
+// saveNodeGroupChanges 在事务中写入节点组变更
+func (s *TunnelService) saveNodeGroupChanges(tx *gorm.DB, tunnel *model.Tunnel, plan *nodeGroupPlan) error {
+	if plan.entryChanged {
+		if err := replaceTunnelNodes(tx, tunnel.ID, model.TunnelNodeEntry, plan.entryRows); err != nil {
+			return err
+		}
+	}
+	if plan.exitChanged {
+		if err := replaceTunnelNodes(tx, tunnel.ID, model.TunnelNodeExit, plan.exitRows); err != nil {
+			return err
+		}
+	}
+	if plan.hopChanged {
+		if err := replaceTunnelHops(tx, tunnel.ID, plan.hopRows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pushNodeGroupChanges 向新增或移除的在线节点下发配置快照
+// 离线节点在重新连接时会自动收到完整快照
+func (s *TunnelService) pushNodeGroupChanges(plan *nodeGroupPlan) error {
+	pushed := make(map[int64]bool)
+	var failed []string
+	for _, nodeId := range plan.affected {
+		if pushed[nodeId] || !websocket.IsNodeOnline(nodeId) {
+			continue
+		}
+		pushed[nodeId] = true
+		if err := Reconcile.PushSnapshot(nodeId); err != nil {
+			failed = append(failed, fmt.Sprintf("节点 %d: %s", nodeId, err.Error()))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("节点组已更新，但部分节点配置同步失败: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func containsNodeId(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// symmetricDiff 返回只出现在其中一个列表中的节点
+func symmetricDiff(a, b []int64) []int64 {
+	var diff []int64
+	for _, id := range a {
+		if !containsNodeId(b, id) {
+			diff = append(diff, id)
+		}
+	}
+	for _, id := range b {
+		if !containsNodeId(a, id) {
+			diff = append(diff, id)
+		}
+	}
+	return diff
+}
+
+// DiagnoseTunnelLegs 逐段检测隧道链路：各入口 -> 中转1 -> ... -> 各出口
+func (s *TunnelService) DiagnoseTunnelLegs(tunnel *model.Tunnel) []map[string]interface{} {
+	results := []map[string]interface{}{}
+
+	from := loadNodes(tunnelEntryNodeIds(tunnel))
+	fromDesc := "入口"
+	for _, h := range loadTunnelHops(tunnel.ID) {
+		desc := fmt.Sprintf("中转%d", h.Inx)
+		for i := range from {
+			results = append(results, s.PerformTcpPing(&from[i], h.Ip, h.Port, fromDesc+"->"+desc))
+		}
+
+		var hopNode model.Node
+		if err := global.DB.First(&hopNode, h.NodeId).Error; err != nil {
+			results = append(results, map[string]interface{}{
+				"nodeId":      h.NodeId,
+				"description": desc + "->下一跳",
+				"success":     false,
+				"message":     "中转节点不存在",
+				"timestamp":   time.Now().UnixMilli(),
+			})
+			return results
+		}
+		from = []model.Node{hopNode}
+		fromDesc = desc
+	}
+
+	exits := tunnelExits(tunnel)
+	for i := range from {
+		for j, e := range exits {
+			desc := fromDesc + "->出口"
+			if len(exits) > 1 {
+				desc = fmt.Sprintf("%s%d", desc, j+1)
+			}
+			results = append(results, s.PerformTcpPing(&from[i], e.Ip, e.Port, desc))
+		}
+	}
+	return results
+}
+
 // createTunnelSharedServices 为 Type 2 隧道创建共享的 chain 和 relay service
 func (s *TunnelService) createTunnelSharedServices(tunnel *model.Tunnel) error {
-	// 获取入口和出口节点
+	// 校验入口和出口节点
 	var inNode, outNode model.Node
 	if err := global.DB.First(&inNode, tunnel.InNodeId).Error; err != nil {
 		return fmt.Errorf("入口节点不存在")
@@ -443,23 +1102,31 @@ func (s *TunnelService) createTunnelSharedServices(tunnel *model.Tunnel) error {
 	if err := global.DB.First(&outNode, tunnel.OutNodeId).Error; err != nil {
 		return fmt.Errorf("出口节点不存在")
 	}
-
-	// 构建出口节点远程地址
-	remoteAddr := fmt.Sprintf("%s:%d", tunnel.OutIp, tunnel.OutPort)
-	if strings.Contains(tunnel.OutIp, ":") {
-		remoteAddr = fmt.Sprintf("[%s]:%d", tunnel.OutIp, tunnel.OutPort)
+	transits, exitAddrs := tunnelChainPath(tunnel)
+
+	// 1. 在每个入口节点创建共享 chain（经过所有中转节点到出口节点组）
+	for _, nodeId := range tunnelEntryNodeIds(tunnel) {
+		if res := utils.AddTunnelChain(nodeId, *tunnel, transits, exitAddrs); res.Msg != "OK" {
+			// 回滚：删除已创建的共享服务
+			s.deleteTunnelSharedServices(tunnel)
+			return fmt.Errorf("创建共享 Chain 失败: %s", res.Msg)
+		}
 	}
 
-	// 1. 在入口节点创建共享 chain
-	if res := utils.AddTunnelChain(inNode.ID, tunnel.ID, remoteAddr, tunnel.Protocol, tunnel.InterfaceName); res.Msg != "OK" {
-		return fmt.Errorf("创建共享 Chain 失败: %s", res.Msg)
+	// 2. 在每个出口节点创建共享 relay service
+	for _, e := range tunnelExits(tunnel) {
+		if res := utils.AddTunnelRelayService(e.NodeId, tunnel.ID, e.Port, tunnel.Protocol, tunnel.InterfaceName); res.Msg != "OK" {
+			s.deleteTunnelSharedServices(tunnel)
+			return fmt.Errorf("创建共享 Relay Service 失败: %s", res.Msg)
+		}
 	}
 
-	// 2. 在出口节点创建共享 relay service
-	if res := utils.AddTunnelRelayService(outNode.ID, tunnel.ID, tunnel.OutPort, tunnel.Protocol, tunnel.InterfaceName); res.Msg != "OK" {
-		// 回滚：删除已创建的 chain
-		utils.DeleteTunnelChain(inNode.ID, tunnel.ID)
-		return fmt.Errorf("创建共享 Relay Service 失败: %s", res.Msg)
+	// 3. 在各中转节点创建 relay service
+	for _, h := range loadTunnelHops(tunnel.ID) {
+		if res := utils.AddTunnelHopService(h.NodeId, tunnel.ID, h.Inx, h.Port, tunnel.Protocol); res.Msg != "OK" {
+			s.deleteTunnelSharedServices(tunnel)
+			return fmt.Errorf("创建中转 %d Relay Service 失败: %s", h.Inx, res.Msg)
+		}
 	}
 
 	return nil
@@ -467,24 +1134,25 @@ func (s *TunnelService) createTunnelSharedServices(tunnel *model.Tunnel) error {
 
 // deleteTunnelSharedServices 删除 Type 2 隧道的共享 chain 和 relay service
 func (s *TunnelService) deleteTunnelSharedServices(tunnel *model.Tunnel) error {
-	var inNode, outNode model.Node
-	global.DB.First(&inNode, tunnel.InNodeId)
-	global.DB.First(&outNode, tunnel.OutNodeId)
-
 	// 删除入口节点的共享 chain
-	if inNode.ID != 0 {
-		utils.DeleteTunnelChain(inNode.ID, tunnel.ID)
+	for _, nodeId := range tunnelEntryNodeIds(tunnel) {
+		utils.DeleteTunnelChain(nodeId, tunnel.ID)
 	}
 
 	// 删除出口节点的共享 relay service
-	if outNode.ID != 0 {
-		utils.DeleteTunnelRelayService(outNode.ID, tunnel.ID)
+	for _, nodeId := range tunnelExitNodeIds(tunnel) {
+		utils.DeleteTunnelRelayService(nodeId, tunnel.ID)
+	}
+
+	// 删除中转节点的 relay service
+	for _, h := range loadTunnelHops(tunnel.ID) {
+		utils.DeleteTunnelHopService(h.NodeId, tunnel.ID, h.Inx)
 	}
 
 	return nil
 }
 
-// updateTunnelSharedServices 更新 Type 2 隧道的共享服务配置
+// updateTunnelSharedServices 更新 Type 2 隧道的共享服务配置，节点上不存在的服务直接创建
 func (s *TunnelService) updateTunnelSharedServices(tunnel *model.Tunnel) error {
 	var inNode, outNode model.Node
 	if err := global.DB.First(&inNode, tunnel.InNodeId).Error; err != nil {
@@ -493,21 +1161,39 @@ func (s *TunnelService) updateTunnelSharedServices(tunnel *model.Tunnel) error {
 	if err := global.DB.First(&outNode, tunnel.OutNodeId).Error; err != nil {
 		return fmt.Errorf("出口节点不存在")
 	}
-
-	// 构建远程地址
-	remoteAddr := fmt.Sprintf("%s:%d", tunnel.OutIp, tunnel.OutPort)
-	if strings.Contains(tunnel.OutIp, ":") {
-		remoteAddr = fmt.Sprintf("[%s]:%d", tunnel.OutIp, tunnel.OutPort)
-	}
+	transits, exitAddrs := tunnelChainPath(tunnel)
 
 	// 1. 更新入口节点的共享 chain
-	if res := utils.UpdateTunnelChain(inNode.ID, tunnel.ID, remoteAddr, tunnel.Protocol, tunnel.InterfaceName); res.Msg != "OK" {
-		return fmt.Errorf("更新共享 Chain 失败: %s", res.Msg)
+	for _, nodeId := range tunnelEntryNodeIds(tunnel) {
+		res := utils.UpdateTunnelChain(nodeId, *tunnel, transits, exitAddrs)
+		if res.Msg != "OK" && strings.Contains(res.Msg, "not found") {
+			res = utils.AddTunnelChain(nodeId, *tunnel, transits, exitAddrs)
+		}
+		if res.Msg != "OK" {
+			return fmt.Errorf("更新共享 Chain 失败: %s", res.Msg)
+		}
 	}
 
 	// 2. 更新出口节点的共享 relay service
-	if res := utils.UpdateTunnelRelayService(outNode.ID, tunnel.ID, tunnel.OutPort, tunnel.Protocol, tunnel.InterfaceName); res.Msg != "OK" {
-		return fmt.Errorf("更新共享 Relay Service 失败: %s", res.Msg)
+	for _, e := range tunnelExits(tunnel) {
+		res := utils.UpdateTunnelRelayService(e.NodeId, tunnel.ID, e.Port, tunnel.Protocol, tunnel.InterfaceName)
+		if res.Msg != "OK" && strings.Contains(res.Msg, "not found") {
+			res = utils.AddTunnelRelayService(e.NodeId, tunnel.ID, e.Port, tunnel.Protocol, tunnel.InterfaceName)
+		}
+		if res.Msg != "OK" {
+			return fmt.Errorf("更新共享 Relay Service 失败: %s", res.Msg)
+		}
+	}
+
+	// 3. 更新中转节点的 relay service
+	for _, h := range loadTunnelHops(tunnel.ID) {
+		res := utils.UpdateTunnelHopService(h.NodeId, tunnel.ID, h.Inx, h.Port, tunnel.Protocol)
+		if res.Msg != "OK" && strings.Contains(res.Msg, "not found") {
+			res = utils.AddTunnelHopService(h.NodeId, tunnel.ID, h.Inx, h.Port, tunnel.Protocol)
+		}
+		if res.Msg != "OK" {
+			return fmt.Errorf("更新中转 %d Relay Service 失败: %s", h.Inx, res.Msg)
+		}
 	}
 
 	return nil
@@ -0,0 +1,63 @@
+package service_test
+
+import (
+	"testing"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/service"
+	"go-backend/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestUpdateTunnelAtomic verifies a tunnel update that fails while allocating
+// relay ports leaves both the tunnel and its hops unchanged.
+func TestUpdateTunnelAtomic(t *testing.T) {
+	hop := testutil.CreateNode(1001, "atomic_hop")
+	global.DB.Model(hop).Update("port_ranges", "20000-20010")
+	full := testutil.CreateNode(1002, "atomic_full")
+
+	tunnel := model.Tunnel{Name: "tunnel_atomic", Type: 2, Status: 1, InNodeId: 1, OutNodeId: 1003, OutPort: 21000, Protocol: "tls"}
+	global.DB.Create(&tunnel)
+	global.DB.Create(&model.TunnelHop{TunnelId: tunnel.ID, Inx: 1, NodeId: hop.ID, Ip: "10.0.2.1", Port: 20001})
+
+	// 第二个中转节点没有可用端口，整个更新失败
+	hops := []int64{hop.ID, full.ID}
+	res := service.Tunnel.UpdateTunnel(dto.TunnelUpdateDto{ID: tunnel.ID, Name: "tunnel_atomic_renamed", Protocol: "tls", HopNodeIds: &hops})
+	assert.NotEqual(t, 0, res.Code)
+
+	var saved model.Tunnel
+	global.DB.First(&saved, tunnel.ID)
+	assert.Equal(t, "tunnel_atomic", saved.Name)
+	var savedHops []model.TunnelHop
+	global.DB.Where("tunnel_id = ?", tunnel.ID).Find(&savedHops)
+	if assert.Len(t, savedHops, 1) {
+		assert.Equal(t, 20001, savedHops[0].Port)
+	}
+}
+
+// TestUpdateTunnelKeepsSelector verifies an update without strategy fields
+// keeps the stored exit selector settings.
+func TestUpdateTunnelKeepsSelector(t *testing.T) {
+	tunnel := testutil.CreateTunnel("tunnel_selector")
+	global.DB.Model(tunnel).Updates(map[string]interface{}{"strategy": "fifo", "max_fails": 3, "fail_timeout": 30})
+
+	res := service.Tunnel.UpdateTunnel(dto.TunnelUpdateDto{ID: tunnel.ID, Name: "tunnel_selector_renamed"})
+	assert.Equal(t, 0, res.Code, res.Msg)
+
+	var saved model.Tunnel
+	global.DB.First(&saved, tunnel.ID)
+	assert.Equal(t, "tunnel_selector_renamed", saved.Name)
+	assert.Equal(t, "fifo", saved.Strategy)
+	assert.Equal(t, 3, saved.MaxFails)
+	assert.Equal(t, 30, saved.FailTimeout)
+
+	strategy := "hash"
+	res = service.Tunnel.UpdateTunnel(dto.TunnelUpdateDto{ID: tunnel.ID, Name: "tunnel_selector_renamed", Strategy: &strategy})
+	assert.Equal(t, 0, res.Code, res.Msg)
+	global.DB.First(&saved, tunnel.ID)
+	assert.Equal(t, "hash", saved.Strategy)
+	assert.Equal(t, 3, saved.MaxFails)
+}
@@ -9,6 +9,7 @@ import (
 	"go-backend/global"
 	"go-backend/model"
 	"go-backend/model/dto"
+	"go-backend/rbac"
 	"go-backend/result"
 	"go-backend/utils"
 )
@@ -83,46 +84,68 @@ func (s *UserService) MigrateUserData() {
 	fmt.Println("[Migration] 用户数据迁移完成")
 }
 
-func (s *UserService) Login(loginDto dto.LoginDto) *result.Result {
+func (s *UserService) Login(loginDto dto.LoginDto, client dto.ClientInfo) *result.Result {
+	// 失败次数过多的 IP 或账号暂时禁止登录
+	if err := LoginGuard.Check(client.Ip, loginDto.Username); err != nil {
+		return result.Err(-1, err.Error())
+	}
+
 	// 1. Verify Captcha
-	captchaEnabled := ViteConfig.GetValue("captcha_enabled")
-	if captchaEnabled == "true" {
-		if loginDto.CaptchaId == "" {
-			return result.Err(-1, "验证码校验失败")
-		}
-		// Mock verification for now as per previous phase decision
-		// In real implementation, call Captcha service verify
-		// if loginDto.CaptchaId != "mock_token" { return result.Err(-1, "验证码校验失败") }
+	if Captcha.Enabled() && !Captcha.ConsumeToken(loginDto.CaptchaId) {
+		return result.Err(-1, "验证码校验失败")
 	}
 
 	// 2. Verify User Credentials
 	var user model.User
 	if err := global.DB.Where("user = ?", loginDto.Username).First(&user).Error; err != nil {
+		// 账号不存在时同样计算一次哈希，避免通过响应时间枚举账号
+		utils.DummyVerifyPassword(loginDto.Password)
+		LoginGuard.Fail(client.Ip, loginDto.Username, LoginSourceLogin)
 		return result.Err(-1, "账号或密码错误")
 	}
-	if user.Pwd != utils.Md5(loginDto.Password) {
+	if !s.CheckPassword(&user, loginDto.Password) {
+		LoginGuard.Fail(client.Ip, loginDto.Username, LoginSourceLogin)
 		return result.Err(-1, "账号或密码错误")
 	}
 	if user.Status == 0 {
 		return result.Err(-1, "账户停用")
 	}
 
-	token, err := utils.GenerateToken(&user)
+	requirePasswordChange := isDefaultCredentials(loginDto.Username, loginDto.Password)
+
+	// 3. 已启用两步验证时先返回预认证 token，验证码通过后再签发正式 token，失败计数也在那时清除
+	if user.TotpEnabled == 1 {
+		return result.Ok(map[string]interface{}{
+			"requireTotp":  true,
+			"preAuthToken": Totp.issuePreAuth(user.ID, requirePasswordChange),
+		})
+	}
+
+	LoginGuard.Succeed(loginDto.Username)
+	return s.loginResult(&user, requirePasswordChange, client)
+}
+
+// loginResult 创建会话并签发 token；系统要求两步验证但尚未绑定时，只签发用于绑定的短期 token
+func (s *UserService) loginResult(user *model.User, requirePasswordChange bool, client dto.ClientInfo) *result.Result {
+	requireTotpSetup := user.TotpEnabled != 1 && Totp.Required(user)
+
+	token, refreshToken, err := Session.Issue(user, client, requireTotpSetup)
 	if err != nil {
 		return result.Err(-1, "Token生成失败")
 	}
 
-	requirePasswordChange := isDefaultCredentials(loginDto.Username, loginDto.Password)
-
 	return result.Ok(map[string]interface{}{
 		"token":                 token,
+		"refreshToken":          refreshToken,
 		"name":                  user.User,
 		"role_id":               user.RoleId,
+		"permissions":           rbac.PermissionsOf(user.RoleId),
 		"requirePasswordChange": requirePasswordChange,
+		"requireTotpSetup":      requireTotpSetup,
 	})
 }
 
-func (s *UserService) CreateUser(dto dto.UserDto) *result.Result {
+func (s *UserService) CreateUser(dto dto.UserDto, claims *utils.UserClaims) *result.Result {
 	fmt.Printf("[Debug] CreateUser: User=%s\n", dto.User)
 	var count int64
 	global.DB.Model(&model.User{}).Where("user = ?", dto.User).Count(&count)
@@ -131,15 +154,23 @@ func (s *UserService) CreateUser(dto dto.UserDto) *result.Result {
 		return result.Err(-1, "用户名已存在")
 	}
 
+	if err := utils.CheckPasswordPolicy(dto.User, dto.Pwd); err != nil {
+		return result.Err(-1, err.Error())
+	}
+	pwd, err := utils.HashPassword(dto.Pwd)
+	if err != nil {
+		return result.Err(-1, "密码加密失败")
+	}
+
 	// Default values matching Java behavior
 	// ExpTime: ~10 days
 	// Flow: 100
 	// Num: 10
 	user := model.User{
 		User:          dto.User,
-		Pwd:           "", // Removed password concept
-		Status:        1,  // Active
-		RoleId:        1,  // Normal User
+		Pwd:           pwd,
+		Status:        1, // Active
+		RoleId:        1, // Normal User
 		CreatedTime:   time.Now().UnixMilli(),
 		UpdatedTime:   time.Now().UnixMilli(),
 		ExpTime:       dto.ExpTime,
@@ -151,6 +182,26 @@ func (s *UserService) CreateUser(dto dto.UserDto) *result.Result {
 	if dto.Status != nil {
 		user.Status = *dto.Status
 	}
+	if dto.RoleId != nil {
+		if msg := checkAssignRole(claims, *dto.RoleId); msg != "" {
+			return result.Err(-1, msg)
+		}
+		user.RoleId = *dto.RoleId
+	}
+	// 分销商创建的用户挂在自己名下，额度从自身套餐中划分
+	if isReseller(claims) {
+		var parent model.User
+		if err := global.DB.First(&parent, claims.GetUserId()).Error; err != nil {
+			return result.Err(-1, "用户不存在")
+		}
+		if parent.ParentId != 0 {
+			return result.Err(-1, "下级用户不能再创建下级")
+		}
+		if err := checkSubUserPool(&parent, 0, user.Flow, user.Num, user.ExpTime); err != nil {
+			return result.Err(-1, err.Error())
+		}
+		user.ParentId = parent.ID
+	}
 
 	if err := global.DB.Create(&user).Error; err != nil {
 		fmt.Printf("[Debug] CreateUser: DB Create error: %v\n", err)
@@ -160,13 +211,21 @@ func (s *UserService) CreateUser(dto dto.UserDto) *result.Result {
 	return result.Ok("用户创建成功")
 }
 
-func (s *UserService) GetAllUsers() *result.Result {
+// GetAllUsers 没有 user:read 权限的分销商只能看到自己的下级
+func (s *UserService) GetAllUsers(claims *utils.UserClaims) *result.Result {
 	var users []model.User
-	global.DB.Where("role_id != ?", 0).Find(&users) // List non-admin
+	query := global.DB.Where("role_id != ?", 0) // List non-admin
+	if !rbac.Has(claims.RoleId, model.PermUserRead) {
+		query = query.Where("parent_id = ?", claims.GetUserId())
+	}
+	query.Find(&users)
+	for i := range users {
+		users[i].Pwd = "" // 只读角色也能查看列表，不返回密码哈希
+	}
 	return result.Ok(users)
 }
 
-func (s *UserService) UpdateUser(dto dto.UserUpdateDto) *result.Result {
+func (s *UserService) UpdateUser(dto dto.UserUpdateDto, claims *utils.UserClaims) *result.Result {
 	fmt.Printf("[Debug] UpdateUser: ID=%d\n", dto.ID)
 	var user model.User
 	if err := global.DB.First(&user, dto.ID).Error; err != nil {
@@ -176,6 +235,16 @@ func (s *UserService) UpdateUser(dto dto.UserUpdateDto) *result.Result {
 	if user.RoleId == 0 {
 		return result.Err(-1, "不能修改管理员")
 	}
+	if !ownsUser(claims, &user) {
+		return result.Err(-1, "无权管理该用户")
+	}
+	roleChanged := dto.RoleId != nil && *dto.RoleId != user.RoleId
+	if roleChanged {
+		if msg := checkAssignRole(claims, *dto.RoleId); msg != "" {
+			return result.Err(-1, msg)
+		}
+		user.RoleId = *dto.RoleId
+	}
 
 	// Check name unique
 	var count int64
@@ -186,7 +255,15 @@ func (s *UserService) UpdateUser(dto dto.UserUpdateDto) *result.Result {
 
 	user.User = dto.User
 	if dto.Pwd != "" {
-		user.Pwd = utils.Md5(dto.Pwd)
+		if err := utils.CheckPasswordPolicy(dto.User, dto.Pwd); err != nil {
+			return result.Err(-1, err.Error())
+		}
+		pwd, err := utils.HashPassword(dto.Pwd)
+		if err != nil {
+			return result.Err(-1, "密码加密失败")
+		}
+		user.Pwd = pwd
+		user.PwdChangedTime = time.Now().UnixMilli()
 	}
 	if dto.Status != nil {
 		user.Status = *dto.Status
@@ -198,6 +275,14 @@ func (s *UserService) UpdateUser(dto dto.UserUpdateDto) *result.Result {
 	// For safer partial updates, pointers would be better, but let's stick to the convention of the existing codebase
 	// or what the frontend sends. The curl sends all fields.
 
+	if user.ParentId != 0 {
+		var parent model.User
+		if err := global.DB.First(&parent, user.ParentId).Error; err == nil {
+			if err := checkSubUserPool(&parent, user.ID, dto.Flow, dto.Num, dto.ExpTime); err != nil {
+				return result.Err(-1, err.Error())
+			}
+		}
+	}
 	user.Flow = dto.Flow
 	user.Num = dto.Num
 	user.ExpTime = dto.ExpTime
@@ -208,6 +293,10 @@ func (s *UserService) UpdateUser(dto dto.UserUpdateDto) *result.Result {
 	if err := global.DB.Save(&user).Error; err != nil {
 		return result.Err(-1, "更新失败")
 	}
+	// 修改密码、角色或停用账户后，已登录的会话全部失效（token 中携带角色）
+	if dto.Pwd != "" || user.Status == 0 || roleChanged {
+		Session.RevokeAll(user.ID)
+	}
 
 	// Sync limits to UserTunnel
 	go s.SyncLimits(user.ID)
@@ -215,7 +304,7 @@ func (s *UserService) UpdateUser(dto dto.UserUpdateDto) *result.Result {
 	return result.Ok("更新成功")
 }
 
-func (s *UserService) DeleteUser(id int64) *result.Result {
+func (s *UserService) DeleteUser(id int64, claims *utils.UserClaims) *result.Result {
 	var user model.User
 	if err := global.DB.First(&user, id).Error; err != nil {
 		return result.Err(-1, "用户不存在")
@@ -223,6 +312,9 @@ func (s *UserService) DeleteUser(id int64) *result.Result {
 	if user.RoleId == 0 {
 		return result.Err(-1, "不能删除管理员")
 	}
+	if !ownsUser(claims, &user) {
+		return result.Err(-1, "无权管理该用户")
+	}
 
 	if err := s.deleteUserRelatedData(&user); err != nil {
 		return result.Err(-1, err.Error())
@@ -231,6 +323,7 @@ func (s *UserService) DeleteUser(id int64) *result.Result {
 	if err := global.DB.Delete(&user).Error; err != nil {
 		return result.Err(-1, "删除失败")
 	}
+	Session.RevokeAll(user.ID)
 	return result.Ok("删除成功")
 }
 
@@ -241,7 +334,7 @@ func (s *UserService) UpdatePassword(dto dto.ChangePasswordDto, ctxUser *utils.U
 	}
 
 	// Verify Current Password
-	if user.Pwd != utils.Md5(dto.CurrentPassword) {
+	if !s.CheckPassword(&user, dto.CurrentPassword) {
 		return result.Err(-1, "当前密码错误")
 	}
 
@@ -261,25 +354,55 @@ func (s *UserService) UpdatePassword(dto dto.ChangePasswordDto, ctxUser *utils.U
 		user.User = dto.NewUsername
 	}
 
-	user.Pwd = utils.Md5(dto.NewPassword)
+	if err := utils.CheckPasswordPolicy(user.User, dto.NewPassword); err != nil {
+		return result.Err(-1, err.Error())
+	}
+	pwd, err := utils.HashPassword(dto.NewPassword)
+	if err != nil {
+		return result.Err(-1, "密码加密失败")
+	}
+	user.Pwd = pwd
 	user.UpdatedTime = time.Now().UnixMilli()
+	user.PwdChangedTime = user.UpdatedTime
 
 	if err := global.DB.Save(&user).Error; err != nil {
 		return result.Err(-1, "密码修改失败")
 	}
+	Session.RevokeAll(user.ID)
 	return result.Ok(nil)
 }
 
+// CheckPassword 校验用户密码，旧格式（MD5 等）的哈希在校验通过后自动升级为当前格式
+func (s *UserService) CheckPassword(user *model.User, password string) bool {
+	ok, needsRehash := utils.VerifyPassword(user.Pwd, password)
+	if !ok {
+		return false
+	}
+	if needsRehash {
+		if pwd, err := utils.HashPassword(password); err == nil {
+			user.Pwd = pwd
+			global.DB.Model(user).Update("pwd", pwd)
+		}
+	}
+	return true
+}
+
 func isDefaultCredentials(username, password string) bool {
 	return username == "admin_user" && password == "admin_user"
 }
 
-func (s *UserService) GetUserPackageInfo(claims *utils.UserClaims) *result.Result {
+func (s *UserService) GetUserPackageInfo(claims *utils.UserClaims, rng dto.TrafficRangeDto) *result.Result {
 	var user model.User
 	if err := global.DB.First(&user, claims.GetUserId()).Error; err != nil {
 		return result.Err(-1, "用户不存在")
 	}
 
+	// 流量历史，默认最近 24 小时
+	history, err := Traffic.Series(model.TrafficScopeUser, user.ID, rng.Start, rng.End, rng.Period)
+	if err != nil {
+		return result.Err(-1, err.Error())
+	}
+
 	// Fetch additional data
 	permissions := s.GetTunnelPermissions(user.ID)
 	forwards := s.GetForwardDetails(user.ID)
@@ -290,53 +413,109 @@ func (s *UserService) GetUserPackageInfo(claims *utils.UserClaims) *result.Resul
 		TunnelPermissions: permissions,
 		Forwards:          forwards,
 		StatisticsFlows:   flowList,
+		FlowHistory:       history,
 	})
 }
 
-func (s *UserService) ResetFlow(req dto.ResetFlowDto) *result.Result {
+func (s *UserService) ResetFlow(req dto.ResetFlowDto, claims *utils.UserClaims) *result.Result {
 	if req.Type == 1 {
 		var user model.User
 		if err := global.DB.First(&user, req.ID).Error; err != nil {
 			return result.Err(-1, "用户不存在")
 		}
-		user.InFlow = 0
-		user.OutFlow = 0
-		user.UpdatedTime = time.Now().UnixMilli()
-		if err := global.DB.Save(&user).Error; err != nil {
+		if !ownsUser(claims, &user) {
+			return result.Err(-1, "无权管理该用户")
+		}
+		if err := s.resetUserFlow(&user); err != nil {
 			return result.Err(-1, "重置失败")
 		}
+		return result.Ok("账号流量已重置")
+	}
 
-		// Auto-resume services if user is active and not expired
-		if user.Status == 1 && (user.ExpTime == 0 || user.ExpTime > time.Now().UnixMilli()) {
-			s.resumeUserServices(user.ID, 0)
+	if req.Type == 2 {
+		var userTunnel model.UserTunnel
+		if err := global.DB.First(&userTunnel, req.ID).Error; err != nil {
+			return result.Err(-1, "用户隧道权限不存在")
+		}
+		if !UserTunnel.ownsUserTunnel(claims, &userTunnel) {
+			return result.Err(-1, "无权管理该用户")
+		}
+		if err := s.resetUserTunnelFlow(&userTunnel); err != nil {
+			return result.Err(-1, "重置失败")
 		}
+		return result.Ok("隧道流量已重置")
+	}
 
-		return result.Ok("账号流量已重置")
+	return result.Err(-1, "不支持的重置类型")
+}
+
+// resetUserFlow 清零账号流量，账号可用时恢复暂停的转发
+func (s *UserService) resetUserFlow(user *model.User) error {
+	user.InFlow = 0
+	user.OutFlow = 0
+	user.UpdatedTime = time.Now().UnixMilli()
+	if err := global.DB.Save(user).Error; err != nil {
+		return err
+	}
+	Notify.ClearFlowMarks(global.DB, model.NotifyUserFlow, user.ID)
+
+	// Auto-resume services if user is active and not expired
+	if userUsable(user) {
+		s.resumeUserServices(user.ID, 0, model.PauseUserQuota)
+	}
+	return nil
+}
+
+// resetUserTunnelFlow 清零用户隧道流量，账号和该隧道权限都可用时恢复隧道下暂停的转发
+func (s *UserService) resetUserTunnelFlow(userTunnel *model.UserTunnel) error {
+	userTunnel.InFlow = 0
+	userTunnel.OutFlow = 0
+	if err := global.DB.Model(userTunnel).Updates(map[string]interface{}{"in_flow": 0, "out_flow": 0}).Error; err != nil {
+		return err
+	}
+	Notify.ClearFlowMarks(global.DB, model.NotifyTunnelFlow, int64(userTunnel.ID))
+
+	var user model.User
+	if err := global.DB.First(&user, userTunnel.UserId).Error; err != nil {
+		return nil
+	}
+	if userUsable(&user) {
+		s.resumeUserServices(user.ID, userTunnel.TunnelId, model.PauseTunnelQuota)
+	}
+	return nil
+}
+
+// userUsable 账号启用、未到期且流量未超限
+func userUsable(user *model.User) bool {
+	now := time.Now().UnixMilli()
+	if user.Status != 1 || (user.ExpTime > 0 && user.ExpTime <= now) {
+		return false
 	}
+	return user.Flow == 0 || user.InFlow+user.OutFlow < user.Flow*1024*1024*1024
+}
 
-	return result.Err(-1, "不支持隧道流量重置，请重置用户流量")
+// userTunnelUsable 隧道权限启用、未到期且流量未超限
+func userTunnelUsable(userTunnel *model.UserTunnel) bool {
+	now := time.Now().UnixMilli()
+	if userTunnel.Status != 1 || (userTunnel.ExpTime > 0 && userTunnel.ExpTime <= now) {
+		return false
+	}
+	return userTunnel.Flow == 0 || userTunnel.InFlow+userTunnel.OutFlow < userTunnel.Flow*1024*1024*1024
 }
 
 // resumeUserServices resumes paused services for a user.
 // If tunnelId is 0, resumes all services for the user.
 // If tunnelId is specific, only resumes services for that tunnel.
-func (s *UserService) resumeUserServices(userId int64, tunnelId int) {
+// 只清除 reasons 中的暂停原因，仍有其他原因（如手动暂停）的转发保持暂停
+func (s *UserService) resumeUserServices(userId int64, tunnelId int, reasons ...string) {
 	var forwards []model.Forward
-	query := global.DB.Where("user_id = ?", userId)
+	query := global.DB.Where("user_id = ? AND status <> 1", userId)
 	if tunnelId != 0 {
 		query = query.Where("tunnel_id = ?", tunnelId)
 	}
 	query.Find(&forwards)
 
 	for _, forward := range forwards {
-		// Only resume if currently paused (Status 0) - or just force resume?
-		// Logic suggests if we reset flow, we want to ensure it's running.
-		// Taking safely: Check if status is 0, update to 1, and send Resume command.
-		// But wait, if it was manually paused by user, should we resume?
-		// User request says "automatic restore related account/node forwarding on reset".
-		// Usually implies "if it was paused due to flow limit/expiry".
-		// Since we don't track *why* it was paused, we assume reset implies "try to enable".
-
 		var tunnel model.Tunnel
 		if err := global.DB.First(&tunnel, forward.TunnelId).Error; err != nil {
 			continue
@@ -348,25 +527,29 @@ func (s *UserService) resumeUserServices(userId int64, tunnelId int) {
 			continue
 		}
 
-		// Double check tunnel specific limits if we were coming from a User reset
-		if tunnelId == 0 {
-			if userTunnel.Status != 1 {
-				continue // Skip this specific tunnel if it's invalid
-			}
+		// 隧道权限停用、到期或超额时不恢复
+		if !userTunnelUsable(&userTunnel) {
+			continue
+		}
+
+		// 升级前暂停的转发没有记录原因，视为可以自动恢复
+		if !forward.ClearPauseReasons(reasons...) {
+			global.DB.Model(&forward).Update("pause_reasons", forward.PauseReasons)
+			continue
 		}
 
 		// Proceed to resume
 		serviceName := fmt.Sprintf("%d_%d_%d", forward.ID, userId, userTunnel.ID)
 
-		// 1. Send Resume Command to Node
-		utils.ResumeService(tunnel.InNodeId, serviceName)
-		if tunnel.Type == 2 && tunnel.OutNodeId != 0 {
-			utils.ResumeRemoteService(tunnel.OutNodeId, serviceName)
+		// 1. Send Resume Command to Node (节点离线时进入队列，覆盖尚未送达的暂停命令)
+		for _, nodeId := range tunnelEntryNodeIds(&tunnel) {
+			utils.ResumeService(nodeId, serviceName)
 		}
 
 		// 2. Update DB Status
 		forward.Status = 1
 		global.DB.Save(&forward)
+		Webhook.Emit(model.EventForwardResumed, forwardEventData(&forward))
 	}
 }
 
@@ -428,17 +611,18 @@ func (s *UserService) GetForwardDetails(userId int64) []dto.UserForwardDetailDto
 		global.DB.First(&tunnel, forward.TunnelId)
 
 		resultList = append(resultList, dto.UserForwardDetailDto{
-			ID:         forward.ID,
-			Name:       forward.Name,
-			TunnelId:   forward.TunnelId,
-			TunnelName: tunnel.Name,
-			InIP:       tunnel.InIp,
-			InPort:     forward.InPort,
-			RemoteAddr: forward.RemoteAddr,
-			InFlow:     forward.InFlow,
-			OutFlow:    forward.OutFlow,
-			Status:     forward.Status,
-			CreatedAt:  forward.CreatedTime,
+			ID:           forward.ID,
+			Name:         forward.Name,
+			TunnelId:     forward.TunnelId,
+			TunnelName:   tunnel.Name,
+			InIP:         tunnel.InIp,
+			InPort:       forward.InPort,
+			RemoteAddr:   forward.RemoteAddr,
+			InFlow:       forward.InFlow,
+			OutFlow:      forward.OutFlow,
+			Status:       forward.Status,
+			CreatedAt:    forward.CreatedTime,
+			PauseReasons: forward.PauseReasonList(),
 		})
 	}
 
@@ -532,6 +716,28 @@ func parseTime5Min(timeStr string, now time.Time) time.Time {
 	return time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
 }
 
+// canManageUser 后台账号（超级管理员或拥有后台权限的角色）只能由超级管理员管理，防止越权提升
+func canManageUser(claims *utils.UserClaims, target *model.User) bool {
+	return claims.RoleId == model.RoleSuperAdmin || !rbac.IsStaff(target.RoleId)
+}
+
+// checkAssignRole 只有超级管理员可以分配角色，且不能通过接口设置超级管理员
+func checkAssignRole(claims *utils.UserClaims, roleId int) string {
+	if roleId == model.RoleUser {
+		return ""
+	}
+	if claims.RoleId != model.RoleSuperAdmin {
+		return "只有超级管理员可以分配角色"
+	}
+	if roleId == model.RoleSuperAdmin {
+		return "不能设置为超级管理员"
+	}
+	if !roleExists(roleId) {
+		return "角色不存在"
+	}
+	return ""
+}
+
 func (s *UserService) deleteUserRelatedData(user *model.User) error {
 	var forwards []model.Forward
 	global.DB.Where("user_id = ?", user.ID).Find(&forwards)
@@ -547,6 +753,7 @@ func (s *UserService) deleteUserRelatedData(user *model.User) error {
 		if err := global.DB.Delete(&model.Forward{}, forward.ID).Error; err != nil {
 			return fmt.Errorf("删除转发失败: %w", err)
 		}
+		Webhook.Emit(model.EventForwardDeleted, forwardEventData(&forward))
 	}
 
 	if err := global.DB.Where("user_id = ?", user.ID).Delete(&model.UserTunnel{}).Error; err != nil {
@@ -557,6 +764,23 @@ func (s *UserService) deleteUserRelatedData(user *model.User) error {
 		return fmt.Errorf("删除流量统计失败: %w", err)
 	}
 
+	if err := global.DB.Where("user_id = ?", user.ID).Delete(&model.ApiKey{}).Error; err != nil {
+		return fmt.Errorf("删除 API Key 失败: %w", err)
+	}
+
+	if err := global.DB.Where("user_id = ?", user.ID).Delete(&model.GuestLink{}).Error; err != nil {
+		return fmt.Errorf("删除访客链接失败: %w", err)
+	}
+
+	if err := global.DB.Where("user_id = ?", user.ID).Delete(&model.NotifySetting{}).Error; err != nil {
+		return fmt.Errorf("删除通知设置失败: %w", err)
+	}
+
+	// 删除分销商后，其下级改由管理员直接管理
+	if err := global.DB.Model(&model.User{}).Where("parent_id = ?", user.ID).Update("parent_id", 0).Error; err != nil {
+		return fmt.Errorf("转移下级用户失败: %w", err)
+	}
+
 	return nil
 }
 
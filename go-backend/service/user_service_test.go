@@ -0,0 +1,248 @@
+package service_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/result"
+	"go-backend/router"
+	"go-backend/service"
+	"go-backend/testutil"
+	"go-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordHashMigration(t *testing.T) {
+	// 旧的 MD5 哈希登录成功后升级为 argon2id
+	user := testutil.CreateUser("legacy_md5", 1, 1, 100, time.Now().Add(time.Hour).UnixMilli())
+	res := service.User.Login(dto.LoginDto{Username: "legacy_md5", Password: "123456"}, dto.ClientInfo{})
+	assert.Equal(t, 0, res.Code, res.Msg)
+
+	var saved model.User
+	global.DB.First(&saved, user.ID)
+	assert.True(t, strings.HasPrefix(saved.Pwd, "$argon2id$"))
+	assert.Equal(t, 0, service.User.Login(dto.LoginDto{Username: "legacy_md5", Password: "123456"}, dto.ClientInfo{}).Code)
+	assert.NotEqual(t, 0, service.User.Login(dto.LoginDto{Username: "legacy_md5", Password: "1234567"}, dto.ClientInfo{}).Code)
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := utils.HashPassword("s3cret-pass")
+	assert.NoError(t, err)
+	ok, rehash := utils.VerifyPassword(hash, "s3cret-pass")
+	assert.True(t, ok)
+	assert.False(t, rehash)
+	ok, _ = utils.VerifyPassword(hash, "s3cret-pass2")
+	assert.False(t, ok)
+}
+
+func TestLoginUnknownUser(t *testing.T) {
+	// 账号不存在与密码错误返回相同结果
+	testutil.CreateUser("known_user", 1, 1, 100, time.Now().Add(time.Hour).UnixMilli())
+	wrong := service.User.Login(dto.LoginDto{Username: "known_user", Password: "1234567"}, dto.ClientInfo{})
+	missing := service.User.Login(dto.LoginDto{Username: "no_such_user", Password: "1234567"}, dto.ClientInfo{})
+	assert.NotEqual(t, 0, wrong.Code)
+	assert.Equal(t, wrong.Code, missing.Code)
+	assert.Equal(t, wrong.Msg, missing.Msg)
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	assert.NotEqual(t, 0, service.User.CreateUser(dto.UserDto{User: "weak_pwd", Pwd: "short"}, &utils.UserClaims{}).Code)
+	assert.NotEqual(t, 0, service.User.CreateUser(dto.UserDto{User: "weak_pwd", Pwd: "admin_user"}, &utils.UserClaims{}).Code)
+	assert.Equal(t, 0, service.User.CreateUser(dto.UserDto{User: "strong_pwd", Pwd: "long-enough-1"}, &utils.UserClaims{}).Code)
+	assert.Equal(t, 0, service.User.Login(dto.LoginDto{Username: "strong_pwd", Password: "long-enough-1"}, dto.ClientInfo{}).Code)
+}
+
+// resellerFixture 分销商及其拥有的隧道（2GB 流量、限速 7），foreign 为未分配给分销商的隧道
+type resellerFixture struct {
+	reseller *model.User
+	tunnel   *model.Tunnel
+	foreign  *model.Tunnel
+	claims   *utils.UserClaims
+	exp      int64
+}
+
+func newResellerFixture(prefix string) *resellerFixture {
+	exp := time.Now().Add(30 * 24 * time.Hour).UnixMilli()
+	reseller := testutil.CreateUser(prefix+"_pool", model.RoleReseller, 10, 2, exp)
+	tunnel := testutil.CreateTunnel(prefix + "_tunnel")
+	global.DB.Model(tunnel).Updates(map[string]interface{}{"traffic_ratio": 1, "flow": 2})
+	foreign := testutil.CreateTunnel(prefix + "_foreign")
+	global.DB.Create(&model.UserTunnel{UserId: int(reseller.ID), TunnelId: int(tunnel.ID), Flow: 2, ExpTime: exp, SpeedId: 7, Status: 1})
+	claims := &utils.UserClaims{RoleId: model.RoleReseller, RegisteredClaims: jwt.RegisteredClaims{Subject: strconv.FormatInt(reseller.ID, 10)}}
+	return &resellerFixture{reseller: reseller, tunnel: tunnel, foreign: foreign, claims: claims, exp: exp}
+}
+
+func (f *resellerFixture) createChild(name string, flow int64, num int, expTime int64) *result.Result {
+	return service.User.CreateUser(dto.UserDto{User: name, Pwd: "long-enough-1", Flow: flow, Num: num, ExpTime: expTime}, f.claims)
+}
+
+// child 创建 1GB 流量的下级并分配分销商的隧道
+func (f *resellerFixture) child(t *testing.T, name string) model.User {
+	res := f.createChild(name, 1, 5, f.exp)
+	if !assert.Equal(t, 0, res.Code, res.Msg) {
+		t.FailNow()
+	}
+	var child model.User
+	global.DB.Where("user = ?", name).First(&child)
+	res = service.UserTunnel.AssignUserTunnel(dto.UserTunnelDto{UserId: child.ID, TunnelId: f.tunnel.ID}, f.claims)
+	assert.Equal(t, 0, res.Code, res.Msg)
+	return child
+}
+
+func TestResellerCreateLimits(t *testing.T) {
+	f := newResellerFixture("reseller_limits")
+
+	// 下级额度不能超出分销商自身的额度
+	assert.Equal(t, 0, f.createChild("reseller_limits_child1", 1, 5, f.exp-1000).Code)
+	assert.Contains(t, f.createChild("reseller_limits_child2", 2, 5, f.exp).Msg, "流量超出")
+	assert.Contains(t, f.createChild("reseller_limits_child2", 1, 6, f.exp).Msg, "转发数超出")
+	assert.Contains(t, f.createChild("reseller_limits_child2", 1, 5, f.exp+1000).Msg, "到期时间")
+	assert.Contains(t, f.createChild("reseller_limits_child2", 0, 5, f.exp).Msg, "不能为不限")
+	assert.Equal(t, 0, f.createChild("reseller_limits_child2", 1, 5, f.exp).Code)
+
+	var child1 model.User
+	global.DB.Where("user = ?", "reseller_limits_child1").First(&child1)
+	assert.Equal(t, f.reseller.ID, child1.ParentId)
+	assert.Contains(t, service.User.UpdateUser(dto.UserUpdateDto{ID: child1.ID, User: child1.User, Flow: 2, Num: 5, ExpTime: f.exp}, f.claims).Msg, "流量超出")
+}
+
+func TestResellerScope(t *testing.T) {
+	f := newResellerFixture("reseller_scope")
+	other := testutil.CreateUser("reseller_scope_outsider", model.RoleUser, 1, 1, f.exp)
+	f.child(t, "reseller_scope_child1")
+	f.child(t, "reseller_scope_child2")
+
+	// 只能看到和管理自己的下级
+	users := service.User.GetAllUsers(f.claims).Data.([]model.User)
+	assert.Len(t, users, 2)
+	assert.NotEqual(t, 0, service.User.UpdateUser(dto.UserUpdateDto{ID: other.ID, User: other.User, Flow: 1, Num: 1, ExpTime: f.exp}, f.claims).Code)
+	assert.NotEqual(t, 0, service.User.ResetFlow(dto.ResetFlowDto{ID: other.ID, Type: 1}, f.claims).Code)
+	assert.NotEqual(t, 0, service.UserTunnel.AssignUserTunnel(dto.UserTunnelDto{UserId: other.ID, TunnelId: f.tunnel.ID}, f.claims).Code)
+}
+
+func TestResellerAssignTunnel(t *testing.T) {
+	f := newResellerFixture("reseller_assign")
+	assert.Equal(t, 0, f.createChild("reseller_assign_child", 1, 5, f.exp).Code)
+	var child model.User
+	global.DB.Where("user = ?", "reseller_assign_child").First(&child)
+
+	// 只能分配自己拥有的隧道，限速沿用上级
+	assert.NotEqual(t, 0, service.UserTunnel.AssignUserTunnel(dto.UserTunnelDto{UserId: child.ID, TunnelId: f.foreign.ID}, f.claims).Code)
+	res := service.UserTunnel.AssignUserTunnel(dto.UserTunnelDto{UserId: child.ID, TunnelId: f.tunnel.ID}, f.claims)
+	assert.Equal(t, 0, res.Code, res.Msg)
+	var childTunnel model.UserTunnel
+	global.DB.Where("user_id = ? AND tunnel_id = ?", child.ID, f.tunnel.ID).First(&childTunnel)
+	assert.Equal(t, 7, childTunnel.SpeedId)
+	assert.EqualValues(t, 1, childTunnel.Flow)
+}
+
+func TestResellerFlowPool(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := router.InitRouter()
+	f := newResellerFixture("reseller_flow")
+	child1 := f.child(t, "reseller_flow_child1")
+	child2 := f.child(t, "reseller_flow_child2")
+	node := testutil.CreateNode(980, "reseller")
+	global.DB.Model(node).Update("secret", "reseller-node")
+
+	// 下级流量计入上级，上级额度用尽时上级和下级的转发一起暂停
+	newForward := func(user model.User, port int) (model.Forward, model.UserTunnel) {
+		var ut model.UserTunnel
+		global.DB.Where("user_id = ? AND tunnel_id = ?", user.ID, f.tunnel.ID).First(&ut)
+		fwd := model.Forward{UserId: user.ID, TunnelId: f.tunnel.ID, InPort: port, RemoteAddr: "1.1.1.1:80", Status: 1}
+		global.DB.Create(&fwd)
+		return fwd, ut
+	}
+	f1, ut1 := newForward(child1, 10081)
+	f2, ut2 := newForward(child2, 10082)
+	fp, parentTunnel := newForward(*f.reseller, 10083)
+	upload := func(fwd model.Forward, ut model.UserTunnel, bytes int64) {
+		body := fmt.Sprintf(`{"n":"%d_%d_%d","u":%d,"v":1}`, fwd.ID, fwd.UserId, ut.ID, bytes)
+		req := httptest.NewRequest("POST", "/flow/upload?secret=reseller-node", strings.NewReader(body))
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	gb := int64(1024 * 1024 * 1024)
+	upload(f1, ut1, gb*9/10)
+	upload(f2, ut2, gb*9/10)
+	reseller := f.reseller
+	global.DB.First(reseller, reseller.ID)
+	assert.Equal(t, 2*(gb*9/10), reseller.InFlow+reseller.OutFlow)
+	global.DB.First(&parentTunnel, parentTunnel.ID)
+	assert.Equal(t, 2*(gb*9/10), parentTunnel.InFlow+parentTunnel.OutFlow)
+
+	upload(fp, parentTunnel, gb*3/10)
+	for _, id := range []int64{f1.ID, f2.ID, fp.ID} {
+		var fwd model.Forward
+		global.DB.First(&fwd, id)
+		assert.Equal(t, 0, fwd.Status, "forward %d should be paused when the reseller pool is exhausted", id)
+	}
+}
+
+// createOverFlowUserTunnel 创建 1GB 额度但已用 3GB 的用户隧道，以及其下被暂停的转发
+func createOverFlowUserTunnel(name string, userInFlow int64, resetDay int64) (*model.User, *model.UserTunnel, *model.Forward) {
+	service.Forward.SkipGostSync = true
+	gb := int64(1024 * 1024 * 1024)
+	exp := time.Now().Add(24 * time.Hour).UnixMilli()
+	tunnel := testutil.CreateTunnel(name + "_tunnel")
+	user := testutil.CreateUser(name, model.RoleUser, 5, 10, exp)
+	global.DB.Model(user).Update("in_flow", userInFlow)
+	ut := &model.UserTunnel{UserId: int(user.ID), TunnelId: int(tunnel.ID), Flow: 1, InFlow: 2 * gb, OutFlow: gb, FlowResetTime: resetDay, ExpTime: exp, Status: 1}
+	global.DB.Create(ut)
+	fwd := &model.Forward{UserId: user.ID, Name: name, TunnelId: tunnel.ID, InPort: 32000 + int(user.ID%1000), Status: 0}
+	global.DB.Create(fwd)
+	return user, ut, fwd
+}
+
+func TestUserTunnelFlowReset(t *testing.T) {
+	// 管理员重置单个用户隧道，隧道下被暂停的转发恢复
+	_, ut, fwd := createOverFlowUserTunnel("ut_reset_ok", 0, 0)
+	res := service.User.ResetFlow(dto.ResetFlowDto{ID: int64(ut.ID), Type: 2}, &utils.UserClaims{})
+	assert.Equal(t, 0, res.Code, res.Msg)
+	global.DB.First(ut, ut.ID)
+	assert.Equal(t, int64(0), ut.InFlow+ut.OutFlow)
+	global.DB.First(fwd, fwd.ID)
+	assert.Equal(t, 1, fwd.Status)
+}
+
+func TestUserTunnelFlowResetUserOverLimit(t *testing.T) {
+	// 账号流量仍超限时不恢复
+	gb := int64(1024 * 1024 * 1024)
+	_, ut, fwd := createOverFlowUserTunnel("ut_reset_user_over", 11*gb, 0)
+	assert.Equal(t, 0, service.User.ResetFlow(dto.ResetFlowDto{ID: int64(ut.ID), Type: 2}, &utils.UserClaims{}).Code)
+	global.DB.First(fwd, fwd.ID)
+	assert.Equal(t, 0, fwd.Status)
+}
+
+func TestUserTunnelFlowResetRequiresPermission(t *testing.T) {
+	// 普通用户不能重置
+	_, ut, _ := createOverFlowUserTunnel("ut_reset_denied", 0, 0)
+	other := testutil.CreateUser("ut_reset_other", model.RoleUser, 1, 1, time.Now().Add(24*time.Hour).UnixMilli())
+	otherClaims := &utils.UserClaims{RoleId: model.RoleUser, RegisteredClaims: jwt.RegisteredClaims{Subject: strconv.FormatInt(other.ID, 10)}}
+	assert.NotEqual(t, 0, service.User.ResetFlow(dto.ResetFlowDto{ID: int64(ut.ID), Type: 2}, otherClaims).Code)
+}
+
+func TestUserTunnelScheduledFlowReset(t *testing.T) {
+	// 定时任务按用户隧道各自的重置日重置
+	gb := int64(1024 * 1024 * 1024)
+	today := int64(time.Now().Day())
+	otherDay := today%28 + 1
+	_, due, dueFwd := createOverFlowUserTunnel("ut_reset_due", 0, today)
+	_, notDue, _ := createOverFlowUserTunnel("ut_reset_not_due", 0, otherDay)
+	service.Task.ResetFlow()
+	global.DB.First(due, due.ID)
+	global.DB.First(notDue, notDue.ID)
+	assert.Equal(t, int64(0), due.InFlow)
+	assert.Equal(t, 2*gb, notDue.InFlow)
+	global.DB.First(dueFwd, dueFwd.ID)
+	assert.Equal(t, 1, dueFwd.Status)
+}
@@ -6,6 +6,7 @@ import (
 	"go-backend/global"
 	"go-backend/model"
 	"go-backend/model/dto"
+	"go-backend/rbac"
 	"go-backend/result"
 	"go-backend/utils"
 )
@@ -15,7 +16,16 @@ type UserTunnelService struct{}
 var UserTunnel = new(UserTunnelService)
 
 // AssignUserTunnel 分配用户隧道权限
-func (s *UserTunnelService) AssignUserTunnel(userTunnelDto dto.UserTunnelDto) *result.Result {
+// 分销商只能把自己拥有的隧道分配给下级，限速和连接限制沿用自身设置，流量和到期时间随下级账号
+func (s *UserTunnelService) AssignUserTunnel(userTunnelDto dto.UserTunnelDto, claims *utils.UserClaims) *result.Result {
+	var user model.User
+	if err := global.DB.First(&user, userTunnelDto.UserId).Error; err != nil {
+		return result.Err(-1, "用户不存在")
+	}
+	if !ownsUser(claims, &user) {
+		return result.Err(-1, "无权管理该用户")
+	}
+
 	// 检查权限是否已存在
 	var count int64
 	global.DB.Model(&model.UserTunnel{}).Where("user_id = ? AND tunnel_id = ?", userTunnelDto.UserId, userTunnelDto.TunnelId).Count(&count)
@@ -23,12 +33,38 @@ func (s *UserTunnelService) AssignUserTunnel(userTunnelDto dto.UserTunnelDto) *r
 		return result.Err(-1, "该用户已拥有此隧道权限")
 	}
 
+	// 连接数限制需要 limit:write 权限才能指定
+	connLimitId := 0
+	if rbac.Has(claims.RoleId, model.PermLimitWrite) {
+		if userTunnelDto.ConnLimitId != 0 && !connLimitExists(userTunnelDto.ConnLimitId) {
+			return result.Err(-1, "连接限制规则不存在")
+		}
+		connLimitId = userTunnelDto.ConnLimitId
+	}
+
 	// 创建权限记录
 	userTunnel := model.UserTunnel{
-		UserId:   int(userTunnelDto.UserId),
-		TunnelId: int(userTunnelDto.TunnelId),
-		SpeedId:  userTunnelDto.SpeedId,
-		Status:   1, // 默认启用
+		UserId:      int(userTunnelDto.UserId),
+		TunnelId:    int(userTunnelDto.TunnelId),
+		SpeedId:     userTunnelDto.SpeedId,
+		ConnLimitId: connLimitId,
+		Status:      1, // 默认启用
+	}
+	if isReseller(claims) {
+		var parent model.User
+		if err := global.DB.First(&parent, user.ParentId).Error; err != nil {
+			return result.Err(-1, "用户不存在")
+		}
+		if err := checkSubUserTunnelPool(&parent, user.ID, userTunnel.TunnelId, user.Flow, user.ExpTime); err != nil {
+			return result.Err(-1, err.Error())
+		}
+		var parentTunnel model.UserTunnel
+		global.DB.Where("user_id = ? AND tunnel_id = ?", parent.ID, userTunnel.TunnelId).First(&parentTunnel)
+		userTunnel.SpeedId = parentTunnel.SpeedId
+		userTunnel.ConnLimitId = parentTunnel.ConnLimitId
+		userTunnel.Flow = user.Flow
+		userTunnel.ExpTime = user.ExpTime
+		userTunnel.FlowResetTime = user.FlowResetTime
 	}
 
 	if err := global.DB.Create(&userTunnel).Error; err != nil {
@@ -38,10 +74,13 @@ func (s *UserTunnelService) AssignUserTunnel(userTunnelDto dto.UserTunnelDto) *r
 	return result.Ok("用户隧道权限分配成功")
 }
 
-// GetUserTunnelList 获取用户隧道权限列表
-func (s *UserTunnelService) GetUserTunnelList(queryDto dto.UserTunnelQueryDto) *result.Result {
+// GetUserTunnelList 获取用户隧道权限列表，分销商只能查看下级的
+func (s *UserTunnelService) GetUserTunnelList(queryDto dto.UserTunnelQueryDto, claims *utils.UserClaims) *result.Result {
 	var userTunnels []model.UserTunnel
 	query := global.DB.Model(&model.UserTunnel{})
+	if !rbac.Has(claims.RoleId, model.PermTunnelRead) {
+		query = query.Where("user_id IN (?)", global.DB.Model(&model.User{}).Select("id").Where("parent_id = ?", claims.GetUserId()))
+	}
 
 	if queryDto.UserId != nil {
 		query = query.Where("user_id = ?", *queryDto.UserId)
@@ -72,11 +111,14 @@ func (s *UserTunnelService) GetUserTunnelList(queryDto dto.UserTunnelQueryDto) *
 }
 
 // RemoveUserTunnel 删除用户隧道权限
-func (s *UserTunnelService) RemoveUserTunnel(id int) *result.Result {
+func (s *UserTunnelService) RemoveUserTunnel(id int, claims *utils.UserClaims) *result.Result {
 	var userTunnel model.UserTunnel
 	if err := global.DB.First(&userTunnel, id).Error; err != nil {
 		return result.Err(-1, "未找到对应的用户隧道权限记录")
 	}
+	if !s.ownsUserTunnel(claims, &userTunnel) {
+		return result.Err(-1, "无权管理该用户")
+	}
 
 	// 删除该用户在该隧道下的所有转发
 	s.removeUserTunnelForwards(int64(userTunnel.UserId), int64(userTunnel.TunnelId))
@@ -89,12 +131,28 @@ func (s *UserTunnelService) RemoveUserTunnel(id int) *result.Result {
 	return result.Ok("用户隧道权限删除成功")
 }
 
-// UpdateUserTunnel 更新用户隧道权限
-func (s *UserTunnelService) UpdateUserTunnel(updateDto dto.UserTunnelUpdateDto) *result.Result {
+// UpdateUserTunnel 更新用户隧道权限，分销商只能启用/停用，不能修改限速和连接限制
+func (s *UserTunnelService) UpdateUserTunnel(updateDto dto.UserTunnelUpdateDto, claims *utils.UserClaims) *result.Result {
 	var userTunnel model.UserTunnel
 	if err := global.DB.First(&userTunnel, updateDto.ID).Error; err != nil {
 		return result.Err(-1, "用户隧道权限不存在")
 	}
+	if !s.ownsUserTunnel(claims, &userTunnel) {
+		return result.Err(-1, "无权管理该用户")
+	}
+	if isReseller(claims) {
+		updateDto.SpeedId = userTunnel.SpeedId
+	}
+
+	// 连接数限制与创建转发一样需要 limit:write 权限才能修改
+	connLimitChanged := false
+	if rbac.Has(claims.RoleId, model.PermLimitWrite) && updateDto.ConnLimitId != nil {
+		if *updateDto.ConnLimitId != 0 && !connLimitExists(*updateDto.ConnLimitId) {
+			return result.Err(-1, "连接限制规则不存在")
+		}
+		connLimitChanged = userTunnel.ConnLimitId != *updateDto.ConnLimitId
+		userTunnel.ConnLimitId = *updateDto.ConnLimitId
+	}
 
 	// 检查限速是否变化
 	oldSpeedId := userTunnel.SpeedId
@@ -110,8 +168,8 @@ func (s *UserTunnelService) UpdateUserTunnel(updateDto dto.UserTunnelUpdateDto)
 		return result.Err(-1, "用户隧道权限更新失败")
 	}
 
-	// 如果限速变化，更新所有转发的限速
-	if speedChanged {
+	// 如果限速或连接限制变化，更新所有转发的服务配置
+	if speedChanged || connLimitChanged {
 		s.updateUserTunnelForwardsSpeed(int64(userTunnel.UserId), int64(userTunnel.TunnelId), updateDto.SpeedId)
 	}
 
@@ -127,6 +185,15 @@ func (s *UserTunnelService) CountUserTunnelsByTunnelId(tunnelId int64) int64 {
 
 // --- Private Helper Methods ---
 
+func (s *UserTunnelService) ownsUserTunnel(claims *utils.UserClaims, userTunnel *model.UserTunnel) bool {
+	var user model.User
+	if err := global.DB.First(&user, userTunnel.UserId).Error; err != nil {
+		// 用户已不存在的残留记录只允许用户管理员清理
+		return rbac.Has(claims.RoleId, model.PermUserWrite)
+	}
+	return ownsUser(claims, &user)
+}
+
 // removeUserTunnelForwards 删除用户在指定隧道下的所有转发
 func (s *UserTunnelService) removeUserTunnelForwards(userId int64, tunnelId int64) {
 	var forwards []model.Forward
@@ -154,8 +221,10 @@ func (s *UserTunnelService) stopForwardService(forward *model.Forward, userId in
 
 	serviceName := fmt.Sprintf("%d_%d_%d", forward.ID, userId, userTunnelId)
 
-	// 删除主服务
-	utils.DeleteService(tunnel.InNodeId, serviceName)
+	// 删除入口节点组上的主服务
+	for _, nodeId := range tunnelEntryNodeIds(&tunnel) {
+		utils.DeleteService(nodeId, serviceName)
+	}
 
 	// 如果是隧道转发，删除远程服务和链
 	if tunnel.Type == 2 {
@@ -164,7 +233,7 @@ func (s *UserTunnelService) stopForwardService(forward *model.Forward, userId in
 	}
 }
 
-// updateUserTunnelForwardsSpeed 更新用户隧道下所有转发的限速
+// updateUserTunnelForwardsSpeed 更新用户隧道下所有转发的限速和连接限制
 func (s *UserTunnelService) updateUserTunnelForwardsSpeed(userId int64, tunnelId int64, speedId int) {
 	var forwards []model.Forward
 	global.DB.Where("user_id = ? AND tunnel_id = ?", userId, tunnelId).Find(&forwards)
@@ -191,6 +260,13 @@ func (s *UserTunnelService) updateUserTunnelForwardsSpeed(userId int64, tunnelId
 		if speedId == 0 {
 			speedIdPtr = nil
 		}
-		utils.UpdateService(tunnel.InNodeId, serviceName, forward.InPort, speedIdPtr, forward.RemoteAddr, tunnel.Type, tunnel, forward.Strategy, interfaceName)
+		nodeIds := tunnelEntryNodeIds(&tunnel)
+		connLimit := effectiveConnLimit(&forward, &userTunnel)
+		if connLimit != nil {
+			ConnLimit.EnsureOnNodes(*connLimit, nodeIds)
+		}
+		for _, nodeId := range nodeIds {
+			utils.UpdateService(nodeId, serviceName, forward.InPort, speedIdPtr, connLimit, forwardAcl(&forward, &tunnel), forward.RemoteAddr, tunnel.Type, tunnel, forward.Strategy, interfaceName)
+		}
 	}
 }
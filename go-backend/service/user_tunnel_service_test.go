@@ -0,0 +1,56 @@
+package service_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/service"
+	"go-backend/testutil"
+	"go-backend/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestUpdateUserTunnelConnLimit verifies the connection limit is only changed when
+// the request carries it and the caller holds limit:write.
+func TestUpdateUserTunnelConnLimit(t *testing.T) {
+	exp := time.Now().Add(time.Hour).UnixMilli()
+	user := testutil.CreateUser("ut_conn_user", model.RoleUser, 1, 100, exp)
+	tunnel := testutil.CreateTunnel("ut_conn_tunnel")
+	limit := model.ConnLimit{Name: "ut_conn_limit", Conns: 10, Status: 1}
+	global.DB.Create(&limit)
+	ut := model.UserTunnel{UserId: int(user.ID), TunnelId: int(tunnel.ID), Status: 1}
+	global.DB.Create(&ut)
+
+	admin := &utils.UserClaims{}
+	limitId := int(limit.ID)
+	res := service.UserTunnel.UpdateUserTunnel(dto.UserTunnelUpdateDto{ID: ut.ID, ConnLimitId: &limitId}, admin)
+	assert.Equal(t, 0, res.Code, res.Msg)
+
+	// 不携带连接限制时保持原值
+	status := 1
+	res = service.UserTunnel.UpdateUserTunnel(dto.UserTunnelUpdateDto{ID: ut.ID, Status: &status}, admin)
+	assert.Equal(t, 0, res.Code, res.Msg)
+	var saved model.UserTunnel
+	global.DB.First(&saved, ut.ID)
+	assert.Equal(t, limitId, saved.ConnLimitId)
+
+	// 只有 user:write 的角色不能修改连接限制
+	res = service.Role.Create(dto.RoleDto{Name: "隧道授权", Code: "ut_conn_user_admin", Permissions: []string{model.PermUserWrite}})
+	if !assert.Equal(t, 0, res.Code, res.Msg) {
+		return
+	}
+	role := res.Data.(model.Role)
+	operator := testutil.CreateUser("ut_conn_operator", role.ID, 0, 0, exp)
+	claims := &utils.UserClaims{RoleId: role.ID, RegisteredClaims: jwt.RegisteredClaims{Subject: strconv.FormatInt(operator.ID, 10)}}
+	none := 0
+	res = service.UserTunnel.UpdateUserTunnel(dto.UserTunnelUpdateDto{ID: ut.ID, ConnLimitId: &none}, claims)
+	assert.Equal(t, 0, res.Code, res.Msg)
+	global.DB.First(&saved, ut.ID)
+	assert.Equal(t, limitId, saved.ConnLimitId)
+}
@@ -24,6 +24,9 @@ func (s *ViteConfigService) GetConfigs() *result.Result {
 	global.DB.Find(&configs)
 	configMap := make(map[string]string)
 	for _, c := range configs {
+		if model.SecretConfigs[c.Name] {
+			continue
+		}
 		configMap[c.Name] = c.Value
 	}
 	return result.Ok(configMap)
@@ -33,6 +36,9 @@ func (s *ViteConfigService) GetConfigByName(name string) *result.Result {
 	if name == "" {
 		return result.Err(-1, "配置名称不能为空")
 	}
+	if model.SecretConfigs[name] {
+		return result.Err(-1, "配置不存在")
+	}
 	var config model.ViteConfig
 	if err := global.DB.Where("name = ?", name).First(&config).Error; err != nil {
 		return result.Err(-1, "配置不存在")
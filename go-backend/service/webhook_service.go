@@ -0,0 +1,406 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/result"
+	"go-backend/utils"
+	"go-backend/websocket"
+
+	"github.com/google/uuid"
+)
+
+// WebhookService 将面板事件推送给订阅的外部系统，失败后按指数退避重试并保留投递记录
+type WebhookService struct{}
+
+var Webhook = new(WebhookService)
+
+const (
+	webhookMaxAttempts  = 8
+	webhookRetryBase    = 30 * time.Second
+	webhookRetryMax     = time.Hour
+	webhookPollInterval = 30 * time.Second
+	// 投递中的记录在租期内不会被其他协程重复发送
+	webhookLease         = time.Minute
+	webhookRetention     = 30 * 24 * time.Hour
+	webhookMaxPageSize   = 200
+	webhookMaxErrorChars = 512
+)
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// webhookPayload 推送的请求体
+type webhookPayload struct {
+	Id    string                 `json:"id"`
+	Event string                 `json:"event"`
+	Time  int64                  `json:"time"`
+	Data  map[string]interface{} `json:"data"`
+}
+
+// Start 注册节点上下线回调并启动重试任务
+func (s *WebhookService) Start() {
+	websocket.OnNodeOnline(func(nodeId int64) {
+		s.Emit(model.EventNodeOnline, map[string]interface{}{"nodeId": nodeId})
+	})
+	websocket.OnNodeOffline(func(nodeId int64) {
+		s.Emit(model.EventNodeOffline, map[string]interface{}{"nodeId": nodeId})
+	})
+	go func() {
+		ticker := time.NewTicker(webhookPollInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.RetryDue(time.Now())
+		}
+	}()
+}
+
+// Emit 为订阅了该事件的 Webhook 写入投递记录并立即异步发送，不阻塞调用方
+func (s *WebhookService) Emit(event string, data map[string]interface{}) {
+	var hooks []model.Webhook
+	global.DB.Where("status = 1").Find(&hooks)
+	var targets []model.Webhook
+	for _, h := range hooks {
+		if h.Subscribed(event) {
+			targets = append(targets, h)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	for _, d := range s.enqueue(targets, event, data) {
+		go s.attempt(d.ID, time.Now())
+	}
+}
+
+// RetryDue 发送到期的待重试记录，由后台任务定时调用
+func (s *WebhookService) RetryDue(now time.Time) {
+	var ids []int64
+	global.DB.Model(&model.WebhookDelivery{}).
+		Where("status = ? AND next_retry_time <= ?", model.DeliveryPending, now.UnixMilli()).
+		Order("id").Limit(100).Pluck("id", &ids)
+	for _, id := range ids {
+		s.attempt(id, now)
+	}
+}
+
+// Prune 清理超过保留期限的投递记录
+func (s *WebhookService) Prune(now time.Time) {
+	cutoff := now.Add(-webhookRetention).UnixMilli()
+	global.DB.Where("status != ? AND created_time < ?", model.DeliveryPending, cutoff).Delete(&model.WebhookDelivery{})
+}
+
+func (s *WebhookService) List() *result.Result {
+	var hooks []model.Webhook
+	global.DB.Order("id desc").Find(&hooks)
+	return result.Ok(hooks)
+}
+
+func (s *WebhookService) Create(claims *utils.UserClaims, webhookDto dto.WebhookDto) *result.Result {
+	events, err := normalizeWebhook(&webhookDto)
+	if err != nil {
+		return result.Err(-1, err.Error())
+	}
+	secret := webhookDto.Secret
+	if secret == "" {
+		if secret, err = randomHex(24); err != nil {
+			return result.Err(-1, "密钥生成失败")
+		}
+	}
+	now := time.Now().UnixMilli()
+	hook := model.Webhook{
+		Name:        webhookDto.Name,
+		Url:         webhookDto.Url,
+		Secret:      secret,
+		Events:      events,
+		Status:      1,
+		CreatedBy:   claims.GetUserId(),
+		CreatedTime: now,
+		UpdatedTime: now,
+	}
+	if webhookDto.Status != nil {
+		hook.Status = *webhookDto.Status
+	}
+	if err := global.DB.Create(&hook).Error; err != nil {
+		return result.Err(-1, "Webhook 创建失败")
+	}
+	return result.Ok(dto.WebhookSecretDto{ID: hook.ID, Secret: secret})
+}
+
+func (s *WebhookService) Update(updateDto dto.WebhookUpdateDto) *result.Result {
+	var hook model.Webhook
+	if err := global.DB.First(&hook, updateDto.ID).Error; err != nil {
+		return result.Err(-1, "Webhook 不存在")
+	}
+	events, err := normalizeWebhook(&updateDto.WebhookDto)
+	if err != nil {
+		return result.Err(-1, err.Error())
+	}
+	hook.Name = updateDto.Name
+	hook.Url = updateDto.Url
+	hook.Events = events
+	if updateDto.Status != nil {
+		hook.Status = *updateDto.Status
+	}
+	rotated := ""
+	if updateDto.Secret != "" {
+		hook.Secret = updateDto.Secret
+	} else if updateDto.RotateSecret {
+		if rotated, err = randomHex(24); err != nil {
+			return result.Err(-1, "密钥生成失败")
+		}
+		hook.Secret = rotated
+	}
+	hook.UpdatedTime = time.Now().UnixMilli()
+	if err := global.DB.Save(&hook).Error; err != nil {
+		return result.Err(-1, "Webhook 更新失败")
+	}
+	if rotated != "" {
+		return result.Ok(dto.WebhookSecretDto{ID: hook.ID, Secret: rotated})
+	}
+	return result.OkMsg("Webhook 更新成功")
+}
+
+// Delete 删除订阅，未完成的投递随之取消
+func (s *WebhookService) Delete(id int64) *result.Result {
+	if err := global.DB.Delete(&model.Webhook{}, id).Error; err != nil {
+		return result.Err(-1, "删除失败")
+	}
+	global.DB.Where("webhook_id = ?", id).Delete(&model.WebhookDelivery{})
+	return result.OkMsg("删除成功")
+}
+
+// Test 同步发送一条 ping 事件，返回投递结果
+func (s *WebhookService) Test(id int64) *result.Result {
+	var hook model.Webhook
+	if err := global.DB.First(&hook, id).Error; err != nil {
+		return result.Err(-1, "Webhook 不存在")
+	}
+	deliveries := s.enqueue([]model.Webhook{hook}, model.EventPing, map[string]interface{}{"webhookId": hook.ID})
+	if len(deliveries) == 0 {
+		return result.Err(-1, "投递记录写入失败")
+	}
+	return result.Ok(s.attempt(deliveries[0].ID, time.Now()))
+}
+
+// Deliveries 分页查询投递记录
+func (s *WebhookService) Deliveries(query dto.WebhookDeliveryQueryDto) *result.Result {
+	db := global.DB.Model(&model.WebhookDelivery{})
+	if query.WebhookId != 0 {
+		db = db.Where("webhook_id = ?", query.WebhookId)
+	}
+	if query.Event != "" {
+		db = db.Where("event = ?", query.Event)
+	}
+	if query.Status != nil {
+		db = db.Where("status = ?", *query.Status)
+	}
+	page, size := query.Page, query.Size
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > webhookMaxPageSize {
+		size = 50
+	}
+
+	var total int64
+	db.Count(&total)
+	var list []model.WebhookDelivery
+	db.Order("id desc").Offset((page - 1) * size).Limit(size).Find(&list)
+	return result.Ok(map[string]interface{}{"list": list, "total": total})
+}
+
+// Redeliver 以相同的事件 ID 和内容重新投递一次，返回新的投递记录
+func (s *WebhookService) Redeliver(id int64) *result.Result {
+	var old model.WebhookDelivery
+	if err := global.DB.First(&old, id).Error; err != nil {
+		return result.Err(-1, "投递记录不存在")
+	}
+	now := time.Now().UnixMilli()
+	d := model.WebhookDelivery{
+		WebhookId:     old.WebhookId,
+		EventId:       old.EventId,
+		Event:         old.Event,
+		Payload:       old.Payload,
+		Status:        model.DeliveryPending,
+		NextRetryTime: now,
+		CreatedTime:   now,
+		UpdatedTime:   now,
+	}
+	if err := global.DB.Create(&d).Error; err != nil {
+		return result.Err(-1, "投递记录写入失败")
+	}
+	return result.Ok(s.attempt(d.ID, time.Now()))
+}
+
+// enqueue 同一事件对所有订阅使用相同的事件 ID 和请求体
+func (s *WebhookService) enqueue(hooks []model.Webhook, event string, data map[string]interface{}) []model.WebhookDelivery {
+	now := time.Now().UnixMilli()
+	eventId := uuid.NewString()
+	payload, err := json.Marshal(webhookPayload{Id: eventId, Event: event, Time: now, Data: data})
+	if err != nil {
+		log.Printf("⚠️ Webhook 事件 %s 序列化失败: %v", event, err)
+		return nil
+	}
+
+	var deliveries []model.WebhookDelivery
+	for _, h := range hooks {
+		d := model.WebhookDelivery{
+			WebhookId:     h.ID,
+			EventId:       eventId,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        model.DeliveryPending,
+			NextRetryTime: now,
+			CreatedTime:   now,
+			UpdatedTime:   now,
+		}
+		if err := global.DB.Create(&d).Error; err != nil {
+			log.Printf("⚠️ Webhook 投递记录写入失败: %v", err)
+			continue
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries
+}
+
+// attempt 抢占到期的投递记录并发送一次，返回更新后的记录；已被抢占或已完成时不发送
+func (s *WebhookService) attempt(id int64, now time.Time) *model.WebhookDelivery {
+	claim := global.DB.Model(&model.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_retry_time <= ?", id, model.DeliveryPending, now.UnixMilli()).
+		Update("next_retry_time", now.Add(webhookLease).UnixMilli())
+	var d model.WebhookDelivery
+	if err := global.DB.First(&d, id).Error; err != nil || claim.RowsAffected == 0 {
+		return &d
+	}
+
+	var hook model.Webhook
+	if err := global.DB.First(&hook, d.WebhookId).Error; err != nil || hook.Status != 1 {
+		d.Status = model.DeliveryFailed
+		d.LastError = "订阅已删除或停用"
+	} else {
+		d.Attempts++
+		d.ResponseCode, d.LastError = s.post(&hook, &d)
+		switch {
+		case d.LastError == "":
+			d.Status = model.DeliverySuccess
+		case d.Attempts >= webhookMaxAttempts:
+			d.Status = model.DeliveryFailed
+		default:
+			d.NextRetryTime = now.Add(webhookRetryDelay(d.Attempts)).UnixMilli()
+		}
+	}
+	d.UpdatedTime = time.Now().UnixMilli()
+	global.DB.Model(&model.WebhookDelivery{}).Where("id = ?", d.ID).Updates(map[string]interface{}{
+		"status":          d.Status,
+		"attempts":        d.Attempts,
+		"next_retry_time": d.NextRetryTime,
+		"response_code":   d.ResponseCode,
+		"last_error":      d.LastError,
+		"updated_time":    d.UpdatedTime,
+	})
+	return &d
+}
+
+// post 发送请求，签名为 HMAC-SHA256(secret, 时间戳 + "." + 请求体)，接收方应校验签名和时间戳
+func (s *WebhookService) post(hook *model.Webhook, d *model.WebhookDelivery) (int, string) {
+	req, err := http.NewRequest(http.MethodPost, hook.Url, bytes.NewReader([]byte(d.Payload)))
+	if err != nil {
+		return 0, err.Error()
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "flux-panel-webhook")
+	req.Header.Set("X-Flux-Event", d.Event)
+	req.Header.Set("X-Flux-Delivery", d.EventId)
+	req.Header.Set("X-Flux-Timestamp", timestamp)
+	req.Header.Set("X-Flux-Signature", "sha256="+WebhookSignature(hook.Secret, timestamp, []byte(d.Payload)))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, truncateError(err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Sprintf("HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, ""
+}
+
+// WebhookSignature 计算请求签名（十六进制）
+func WebhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookRetryDelay(attempts int) time.Duration {
+	d := webhookRetryBase << uint(attempts-1)
+	if d <= 0 || d > webhookRetryMax {
+		return webhookRetryMax
+	}
+	return d
+}
+
+// normalizeWebhook 校验地址和事件，返回逗号分隔的事件列表
+func normalizeWebhook(webhookDto *dto.WebhookDto) (string, error) {
+	webhookDto.Name = strings.TrimSpace(webhookDto.Name)
+	webhookDto.Url = strings.TrimSpace(webhookDto.Url)
+	if webhookDto.Name == "" || len(webhookDto.Name) > 64 {
+		return "", fmt.Errorf("名称不能为空且不超过64个字符")
+	}
+	u, err := url.Parse(webhookDto.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("Webhook 地址必须是 http(s) URL")
+	}
+
+	seen := make(map[string]bool)
+	var events []string
+	for _, e := range webhookDto.Events {
+		e = strings.TrimSpace(e)
+		if e == "*" {
+			return "*", nil
+		}
+		if seen[e] {
+			continue
+		}
+		valid := false
+		for _, known := range model.WebhookEvents {
+			if known == e {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return "", fmt.Errorf("无效的事件: %s", e)
+		}
+		seen[e] = true
+		events = append(events, e)
+	}
+	if len(events) == 0 {
+		return "*", nil
+	}
+	return strings.Join(events, ","), nil
+}
+
+func truncateError(msg string) string {
+	if len(msg) > webhookMaxErrorChars {
+		return msg[:webhookMaxErrorChars]
+	}
+	return msg
+}
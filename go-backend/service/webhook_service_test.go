@@ -0,0 +1,182 @@
+package service_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/result"
+	"go-backend/router"
+	"go-backend/service"
+	"go-backend/testutil"
+	"go-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// webhookReceiver 记录收到的推送，failing 为 true 时返回 500
+type webhookReceiver struct {
+	url      string
+	received chan webhookRequest
+	failing  atomic.Bool
+}
+
+func newWebhookReceiver(t *testing.T) *webhookReceiver {
+	rcv := &webhookReceiver{received: make(chan webhookRequest, 10)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rcv.received <- webhookRequest{header: r.Header, body: body}
+		if rcv.failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	rcv.url = srv.URL
+	return rcv
+}
+
+func (r *webhookReceiver) next() *webhookRequest {
+	select {
+	case c := <-r.received:
+		return &c
+	case <-time.After(time.Second):
+		return nil
+	}
+}
+
+// createWebhook 创建订阅转发暂停/恢复事件的 webhook，测试结束时删除
+func createWebhook(t *testing.T, url string) dto.WebhookSecretDto {
+	res := service.Webhook.Create(&utils.UserClaims{}, dto.WebhookDto{Name: "billing", Url: url, Events: []string{model.EventForwardPaused, model.EventForwardResumed}})
+	if !assert.Equal(t, 0, res.Code, res.Msg) {
+		t.FailNow()
+	}
+	created := res.Data.(dto.WebhookSecretDto)
+	t.Cleanup(func() { service.Webhook.Delete(created.ID) })
+	return created
+}
+
+func createWebhookForward(username string, inPort int) (*model.User, *model.Forward) {
+	service.Forward.SkipGostSync = true
+	user := testutil.CreateUser(username, model.RoleUser, 5, 10, time.Now().Add(24*time.Hour).UnixMilli())
+	tunnel := testutil.CreateTunnel(username + "_tunnel")
+	global.DB.Create(&model.UserTunnel{UserId: int(user.ID), TunnelId: int(tunnel.ID), Status: 1})
+	fwd := &model.Forward{UserId: user.ID, Name: username + "_fwd", TunnelId: tunnel.ID, InPort: inPort, Status: 1}
+	global.DB.Create(fwd)
+	return user, fwd
+}
+
+func TestWebhookCreateValidation(t *testing.T) {
+	admin := &utils.UserClaims{}
+	assert.NotEqual(t, 0, service.Webhook.Create(admin, dto.WebhookDto{Name: "bad", Url: "http://example.com", Events: []string{"forward.exploded"}}).Code)
+	assert.NotEqual(t, 0, service.Webhook.Create(admin, dto.WebhookDto{Name: "bad", Url: "ftp://example.com"}).Code)
+}
+
+func TestWebhookSignedDelivery(t *testing.T) {
+	rcv := newWebhookReceiver(t)
+	created := createWebhook(t, rcv.url)
+	user, fwd := createWebhookForward("webhook_signed", 34001)
+
+	// 暂停转发推送签名的事件
+	assert.Equal(t, 0, service.Forward.PauseForward(fwd.ID, &utils.UserClaims{}).Code)
+	got := rcv.next()
+	if assert.NotNil(t, got) {
+		assert.Equal(t, model.EventForwardPaused, got.header.Get("X-Flux-Event"))
+		sig := service.WebhookSignature(created.Secret, got.header.Get("X-Flux-Timestamp"), got.body)
+		assert.Equal(t, "sha256="+sig, got.header.Get("X-Flux-Signature"))
+		var payload struct {
+			Id    string                 `json:"id"`
+			Event string                 `json:"event"`
+			Data  map[string]interface{} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(got.body, &payload))
+		assert.Equal(t, got.header.Get("X-Flux-Delivery"), payload.Id)
+		assert.Equal(t, float64(fwd.ID), payload.Data["forwardId"])
+	}
+
+	// 未订阅的事件不投递
+	service.Webhook.Emit(model.EventUserExpired, map[string]interface{}{"userId": user.ID})
+	assert.Nil(t, rcv.next())
+}
+
+func TestWebhookRetry(t *testing.T) {
+	rcv := newWebhookReceiver(t)
+	created := createWebhook(t, rcv.url)
+	_, fwd := createWebhookForward("webhook_retry", 34002)
+	global.DB.Model(fwd).Update("status", 0)
+
+	// 接收方失败时保留记录并按退避重试
+	rcv.failing.Store(true)
+	assert.Equal(t, 0, service.Forward.ResumeForward(fwd.ID, &utils.UserClaims{}).Code)
+	assert.NotNil(t, rcv.next())
+	var delivery model.WebhookDelivery
+	assert.Eventually(t, func() bool {
+		global.DB.Where("webhook_id = ? AND event = ?", created.ID, model.EventForwardResumed).First(&delivery)
+		return delivery.Attempts == 1
+	}, time.Second, 20*time.Millisecond)
+	assert.Equal(t, model.DeliveryPending, delivery.Status)
+	assert.Equal(t, 500, delivery.ResponseCode)
+	assert.Greater(t, delivery.NextRetryTime, time.Now().UnixMilli())
+
+	// 未到重试时间不发送，到期后重试成功
+	rcv.failing.Store(false)
+	service.Webhook.RetryDue(time.Now())
+	assert.Nil(t, rcv.next())
+	service.Webhook.RetryDue(time.Now().Add(time.Hour))
+	assert.NotNil(t, rcv.next())
+	global.DB.First(&delivery, delivery.ID)
+	assert.Equal(t, model.DeliverySuccess, delivery.Status)
+	assert.Equal(t, 2, delivery.Attempts)
+}
+
+func TestWebhookRedeliver(t *testing.T) {
+	rcv := newWebhookReceiver(t)
+	created := createWebhook(t, rcv.url)
+	_, fwd := createWebhookForward("webhook_redeliver", 34003)
+
+	assert.Equal(t, 0, service.Forward.PauseForward(fwd.ID, &utils.UserClaims{}).Code)
+	assert.NotNil(t, rcv.next())
+	var delivery model.WebhookDelivery
+	assert.Eventually(t, func() bool {
+		global.DB.Where("webhook_id = ?", created.ID).First(&delivery)
+		return delivery.Status == model.DeliverySuccess
+	}, time.Second, 20*time.Millisecond)
+
+	// 投递记录和手动重发（事件 ID 不变）
+	list := service.Webhook.Deliveries(dto.WebhookDeliveryQueryDto{WebhookId: created.ID}).Data.(map[string]interface{})
+	assert.Equal(t, int64(1), list["total"])
+	redo := service.Webhook.Redeliver(delivery.ID)
+	assert.Equal(t, 0, redo.Code)
+	assert.Equal(t, model.DeliverySuccess, redo.Data.(*model.WebhookDelivery).Status)
+	assert.Equal(t, delivery.EventId, redo.Data.(*model.WebhookDelivery).EventId)
+	assert.NotNil(t, rcv.next())
+}
+
+func TestWebhookRoutesRequirePermission(t *testing.T) {
+	// 管理接口需要 webhook:write 权限
+	user := testutil.CreateUser("webhook_plain", model.RoleUser, 5, 10, time.Now().Add(24*time.Hour).UnixMilli())
+	gin.SetMode(gin.TestMode)
+	r := router.InitRouter()
+	token, _, _ := service.Session.Issue(user, dto.ClientInfo{}, false)
+	req := httptest.NewRequest("POST", "/api/v1/webhook/list", strings.NewReader("{}"))
+	req.Header.Set("Authorization", token)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var resp result.Result
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, "权限不足", resp.Msg)
+}
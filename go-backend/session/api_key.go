@@ -0,0 +1,56 @@
+package session
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+)
+
+// ApiKeyPrefix 以此开头的 Authorization 按 API Key 处理
+const ApiKeyPrefix = "fpk_"
+
+var ErrInvalidApiKey = errors.New("API Key 无效或已过期")
+
+// HashApiKeySecret 密钥为高熵随机串，使用 SHA-256 保存即可
+func HashApiKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidateApiKey 校验 API Key，返回密钥记录和所属用户；用户停用后密钥随之失效
+func ValidateApiKey(raw string, ip string) (*model.ApiKey, *model.User, error) {
+	prefix, secret, ok := strings.Cut(strings.TrimPrefix(raw, ApiKeyPrefix), "_")
+	if !strings.HasPrefix(raw, ApiKeyPrefix) || !ok || prefix == "" || secret == "" {
+		return nil, nil, ErrInvalidApiKey
+	}
+
+	var key model.ApiKey
+	if err := global.DB.Where("prefix = ?", prefix).First(&key).Error; err != nil {
+		return nil, nil, ErrInvalidApiKey
+	}
+	if subtle.ConstantTimeCompare([]byte(HashApiKeySecret(secret)), []byte(key.KeyHash)) != 1 {
+		return nil, nil, ErrInvalidApiKey
+	}
+	now := time.Now()
+	if key.ExpTime != 0 && key.ExpTime <= now.UnixMilli() {
+		return nil, nil, ErrInvalidApiKey
+	}
+
+	var user model.User
+	if err := global.DB.First(&user, key.UserId).Error; err != nil || user.Status == 0 {
+		return nil, nil, ErrInvalidApiKey
+	}
+
+	if now.UnixMilli()-key.LastUsedTime >= touchInterval.Milliseconds() || (ip != "" && ip != key.LastUsedIp) {
+		key.LastUsedTime = now.UnixMilli()
+		key.LastUsedIp = ip
+		global.DB.Model(&key).Updates(map[string]interface{}{"last_used_time": key.LastUsedTime, "last_used_ip": ip})
+	}
+	return &key, &user, nil
+}
@@ -0,0 +1,49 @@
+// Package session 校验登录会话，供 middleware 和 websocket 共用（二者都不能依赖 service）
+package session
+
+import (
+	"errors"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+)
+
+var ErrInvalid = errors.New("会话已失效，请重新登录")
+
+// 最近访问时间的刷新间隔，避免每个请求都写库
+const touchInterval = time.Minute
+
+// Validate 校验 token 对应的会话未撤销、未过期，且签发时间不早于用户最近一次修改密码
+func Validate(sid string, userId int64, issuedAt time.Time, ip string) error {
+	if sid == "" {
+		return ErrInvalid
+	}
+
+	var sess model.UserSession
+	if err := global.DB.Where("sid = ?", sid).First(&sess).Error; err != nil {
+		return ErrInvalid
+	}
+	now := time.Now()
+	if sess.UserId != userId || sess.RevokedTime != 0 || sess.ExpiresTime <= now.UnixMilli() {
+		return ErrInvalid
+	}
+
+	var user model.User
+	if err := global.DB.Select("id", "status", "pwd_changed_time").First(&user, userId).Error; err != nil {
+		return ErrInvalid
+	}
+	// iat 只精确到秒，同时比较会话创建时间（毫秒）
+	if user.Status == 0 || issuedAt.Unix() < user.PwdChangedTime/1000 || sess.CreatedTime < user.PwdChangedTime {
+		return ErrInvalid
+	}
+
+	if now.UnixMilli()-sess.LastSeenTime >= touchInterval.Milliseconds() || (ip != "" && ip != sess.Ip) {
+		updates := map[string]interface{}{"last_seen_time": now.UnixMilli()}
+		if ip != "" {
+			updates["ip"] = ip
+		}
+		global.DB.Model(&sess).Updates(updates)
+	}
+	return nil
+}
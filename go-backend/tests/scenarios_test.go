@@ -123,7 +123,7 @@ func TestFlowReset(t *testing.T) {
 
 	// 2. Reset Flow (Type 1 = User Flow)
 	req := dto.ResetFlowDto{ID: user.ID, Type: 1}
-	res := service.User.ResetFlow(req)
+	res := service.User.ResetFlow(req, &utils.UserClaims{})
 	assert.Equal(t, 0, res.Code, "Reset flow should succeed")
 
 	// 3. Verify Reset
@@ -2,58 +2,26 @@ package tests
 
 import (
 	"fmt"
-	"go-backend/config"
 	"go-backend/global"
 	"go-backend/model"
+	"go-backend/testutil"
 	"go-backend/utils"
-	"io"
 	"os"
 	"path/filepath"
 	"testing"
-
-	"github.com/glebarez/sqlite"
-	"gorm.io/gorm"
 )
 
 const (
-	SourceDBPath = "../../data/flux.db"
-	TestDBPath   = "./flux_test.db"
+	TestDBPath = "./flux_test.db"
 )
 
-// SetupTestDB initializes the test DB by copying the real DB
+// SetupTestDB initializes the test DB from the current schema
+// data/flux.db 不在仓库中，且缺少后续新增的表，改为按模型建表并执行数据迁移
 func SetupTestDB() {
-	// 1. Copy real DB to test DB
-	sourceFile, err := os.Open(SourceDBPath)
-	if err != nil {
-		panic(fmt.Sprintf("Failed to open source DB at %s: %v", SourceDBPath, err))
-	}
-	defer sourceFile.Close()
-
-	destFile, err := os.Create(TestDBPath)
-	if err != nil {
-		panic(fmt.Sprintf("Failed to create test DB at %s: %v", TestDBPath, err))
-	}
-	defer destFile.Close()
-
-	_, err = io.Copy(destFile, sourceFile)
-	if err != nil {
-		panic(fmt.Sprintf("Failed to copy DB: %v", err))
-	}
-
-	// 2. Configure App to use the test DB
 	absPath, _ := filepath.Abs(TestDBPath)
-	config.AppConfig.Database.Type = "sqlite"
-	config.AppConfig.Database.Name = absPath
-	config.AppConfig.Server.Port = 8888
-
-	// 3. Initialize GORM with the test DB
-	global.DB, err = gorm.Open(sqlite.Open(config.AppConfig.Database.Name), &gorm.Config{})
-	if err != nil {
-		panic(fmt.Sprintf("Failed to connect to test DB: %v", err))
-	}
-
-	// No AutoMigrate - we are using the real schema
-	fmt.Println("✅ Test DB Initialized from data/flux.db")
+	os.Remove(absPath)
+	testutil.SetupDB(absPath)
+	fmt.Println("✅ Test DB Initialized from schema")
 
 	// Verify or Create Default Node (ID: 1) for testing
 	var node model.Node
@@ -0,0 +1,105 @@
+// Package testutil provides the shared database fixture for package tests.
+package testutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-backend/config"
+	"go-backend/global"
+	"go-backend/migration"
+	"go-backend/model"
+	"go-backend/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Main builds a fresh sqlite DB in a temp dir, runs the tests and removes it.
+// Call it from each package's TestMain.
+func Main(m *testing.M) {
+	dir, err := os.MkdirTemp("", "flux-test-")
+	if err != nil {
+		panic(fmt.Sprintf("Failed to create temp dir: %v", err))
+	}
+	SetupDB(filepath.Join(dir, "flux_test.db"))
+
+	code := m.Run()
+
+	if sqlDB, err := global.DB.DB(); err == nil {
+		sqlDB.Close()
+	}
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// SetupDB opens the sqlite DB at path and creates the schema the same way main does
+func SetupDB(path string) {
+	config.AppConfig.Database.Type = "sqlite"
+	config.AppConfig.Database.Name = path
+	config.AppConfig.Server.Port = 8888
+	global.InitDB()
+
+	if err := migration.EnsureSchema(global.DB, model.All()...); err != nil {
+		panic(fmt.Sprintf("Schema init failed: %v", err))
+	}
+	if err := migration.RunMigrations(global.DB); err != nil {
+		panic(fmt.Sprintf("Migration failed: %v", err))
+	}
+
+	// Default node (ID: 1) used by CreateTunnel
+	global.DB.Create(&model.Node{ID: 1, Name: "Test Node", Status: 1, Ip: "127.0.0.1", ServerIp: "127.0.0.1", PortRanges: "10000-40000"})
+}
+
+// CreateUser creates a user with password 123456 (legacy MD5 hash)
+func CreateUser(username string, roleId int, num int, flow int64, expTime int64) *model.User {
+	user := model.User{
+		User:          username,
+		Pwd:           utils.Md5("123456"),
+		RoleId:        roleId,
+		Status:        1,
+		Num:           num,
+		Flow:          flow,
+		ExpTime:       expTime,
+		FlowResetTime: 1,
+		InFlow:        0,
+		OutFlow:       0,
+	}
+	global.DB.Create(&user)
+	return &user
+}
+
+// CreateTunnel creates a TCP tunnel on node 1
+func CreateTunnel(name string) *model.Tunnel {
+	tunnel := model.Tunnel{
+		Name:      name,
+		Type:      1, // 1-TCP
+		Status:    1,
+		InNodeId:  1,
+		OutNodeId: 1,
+	}
+	global.DB.Create(&tunnel)
+	return &tunnel
+}
+
+// CreateNode creates a node with the given ID
+func CreateNode(id int64, name string) *model.Node {
+	node := model.Node{
+		ID:     id,
+		Name:   name,
+		Status: 1,
+		Ip:     "127.0.0.1",
+	}
+	global.DB.Create(&node)
+	return &node
+}
+
+// ParseClaims reads the claims of a token issued by the panel without verifying it
+func ParseClaims(t *testing.T, token string) *utils.UserClaims {
+	claims := &utils.UserClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	return claims
+}
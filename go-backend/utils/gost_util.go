@@ -9,6 +9,35 @@ import (
 	"go-backend/websocket"
 )
 
+// sendOrQueue 下发修改节点配置的命令，节点离线或超时时写入持久化队列，重连后按顺序补发
+func sendOrQueue(nodeId int64, data interface{}, msgType string, names ...string) *dto.GostDto {
+	return websocket.SendOrQueue(nodeId, data, msgType, commandKey(msgType, names))
+}
+
+// commandKey 所有排队命令共用的幂等键：对象名称加命令类别，同一键只保留最后一条待发送命令。
+// 暂停和恢复都是设置服务的运行状态，归为同一类别，后发的取代先发的；
+// 新增、更新、删除各自成类，新增后再更新都会保留，补发时按原顺序执行
+func commandKey(msgType string, names []string) string {
+	if msgType == "PauseService" || msgType == "ResumeService" {
+		msgType = "ServiceState"
+	}
+	return msgType + ":" + strings.Join(names, ",")
+}
+
+// configName 取单个配置的 name 字段
+func configName(data map[string]interface{}) string {
+	return fmt.Sprint(data["name"])
+}
+
+// configNames 取配置列表的 name 字段
+func configNames(configs []map[string]interface{}) []string {
+	names := make([]string, 0, len(configs))
+	for _, c := range configs {
+		names = append(names, configName(c))
+	}
+	return names
+}
+
 // Helper to wrap list in map, matching Java's JSONObject structure
 func createLimiterData(name string, speed string) map[string]interface{} {
 	return map[string]interface{}{
@@ -17,9 +46,14 @@ func createLimiterData(name string, speed string) map[string]interface{} {
 	}
 }
 
+// BuildLimiterConfig 生成限速器配置，name 为 speed_limit ID
+func BuildLimiterConfig(name int64, speed string) map[string]interface{} {
+	return createLimiterData(fmt.Sprintf("%d", name), speed)
+}
+
 func AddLimiters(nodeId int64, name int64, speed string) *dto.GostDto {
 	data := createLimiterData(fmt.Sprintf("%d", name), speed)
-	return websocket.SendMsg(nodeId, data, "AddLimiters")
+	return sendOrQueue(nodeId, data, "AddLimiters", fmt.Sprintf("%d", name))
 }
 
 func UpdateLimiters(nodeId int64, name int64, speed string) *dto.GostDto {
@@ -28,51 +62,168 @@ func UpdateLimiters(nodeId int64, name int64, speed string) *dto.GostDto {
 		"limiter": fmt.Sprintf("%d", name),
 		"data":    data,
 	}
-	return websocket.SendMsg(nodeId, req, "UpdateLimiters")
+	return sendOrQueue(nodeId, req, "UpdateLimiters", fmt.Sprintf("%d", name))
 }
 
 func DeleteLimiters(nodeId int64, name int64) *dto.GostDto {
 	req := map[string]interface{}{
 		"limiter": fmt.Sprintf("%d", name),
 	}
-	return websocket.SendMsg(nodeId, req, "DeleteLimiters")
+	return sendOrQueue(nodeId, req, "DeleteLimiters", fmt.Sprintf("%d", name))
+}
+
+// 连接数限制器类型，对应节点的 climiter / rlimiter 命令
+const (
+	ConnLimiterKind = "CLimiters"
+	RateLimiterKind = "RLimiters"
+)
+
+// BuildConnLimiterName 连接数限制规则在节点上的 climiter 名称
+func BuildConnLimiterName(id int64) string {
+	return fmt.Sprintf("climit_%d", id)
+}
+
+// BuildRateLimiterName 连接数限制规则在节点上的 rlimiter 名称
+func BuildRateLimiterName(id int64) string {
+	return fmt.Sprintf("rlimit_%d", id)
+}
+
+// BuildConnLimiterConfig 并发连接数限制，"$" 为服务总量，"$$" 为每个客户端 IP
+func BuildConnLimiterConfig(limit model.ConnLimit) map[string]interface{} {
+	return map[string]interface{}{
+		"name":   BuildConnLimiterName(limit.ID),
+		"limits": connLimitLines(limit.Conns, limit.IpConns),
+	}
+}
+
+// BuildRateLimiterConfig 每秒新建连接数限制，格式同 BuildConnLimiterConfig
+func BuildRateLimiterConfig(limit model.ConnLimit) map[string]interface{} {
+	return map[string]interface{}{
+		"name":   BuildRateLimiterName(limit.ID),
+		"limits": connLimitLines(limit.Rate, limit.IpRate),
+	}
 }
 
-func AddService(nodeId int64, name string, inPort int, limiter *int, remoteAddr string, fowType int, tunnel model.Tunnel, strategy, interfaceName string) *dto.GostDto {
-	services := []map[string]interface{}{
-		createServiceConfig(name, inPort, limiter, remoteAddr, "tcp", fowType, tunnel, strategy, interfaceName),
-		createServiceConfig(name, inPort, limiter, remoteAddr, "udp", fowType, tunnel, strategy, interfaceName),
+func connLimitLines(total, perIp int) []string {
+	limits := []string{}
+	if total > 0 {
+		limits = append(limits, fmt.Sprintf("$ %d", total))
+	}
+	if perIp > 0 {
+		limits = append(limits, fmt.Sprintf("$$ %d", perIp))
 	}
-	return websocket.SendMsg(nodeId, services, "AddService")
+	return limits
+}
+
+// AddKindLimiter 创建 climiter / rlimiter
+func AddKindLimiter(nodeId int64, kind string, data map[string]interface{}) *dto.GostDto {
+	return sendOrQueue(nodeId, data, "Add"+kind, configName(data))
+}
+
+// UpdateKindLimiter 按名称更新 climiter / rlimiter
+func UpdateKindLimiter(nodeId int64, kind string, name string, data map[string]interface{}) *dto.GostDto {
+	req := map[string]interface{}{
+		"limiter": name,
+		"data":    data,
+	}
+	return sendOrQueue(nodeId, req, "Update"+kind, name)
+}
+
+// DeleteKindLimiter 按名称删除 climiter / rlimiter
+func DeleteKindLimiter(nodeId int64, kind string, name string) *dto.GostDto {
+	req := map[string]interface{}{
+		"limiter": name,
+	}
+	return sendOrQueue(nodeId, req, "Delete"+kind, name)
+}
+
+// ServiceAcl 转发的来源 IP 访问控制，Allow 非空时只放行列表内的地址，Deny 中的地址始终拒绝
+type ServiceAcl struct {
+	Allow []string
+	Deny  []string
+}
+
+// BuildAdmissionName 转发服务的 admission 名称，kind 为 allow / deny
+func BuildAdmissionName(serviceName, kind string) string {
+	return serviceName + "_" + kind
 }
 
-func UpdateService(nodeId int64, name string, inPort int, limiter *int, remoteAddr string, fowType int, tunnel model.Tunnel, strategy, interfaceName string) *dto.GostDto {
-	services := []map[string]interface{}{
-		createServiceConfig(name, inPort, limiter, remoteAddr, "tcp", fowType, tunnel, strategy, interfaceName),
-		createServiceConfig(name, inPort, limiter, remoteAddr, "udp", fowType, tunnel, strategy, interfaceName),
+func (acl *ServiceAcl) admissionNames(serviceName string) []string {
+	var names []string
+	for _, cfg := range acl.BuildAdmissionConfigs(serviceName) {
+		names = append(names, cfg["name"].(string))
 	}
-	return websocket.SendMsg(nodeId, services, "UpdateService")
+	return names
+}
+
+// BuildAdmissionConfigs 生成转发服务引用的 admission 配置，空列表不生成
+func (acl *ServiceAcl) BuildAdmissionConfigs(serviceName string) []map[string]interface{} {
+	if acl == nil {
+		return nil
+	}
+	var configs []map[string]interface{}
+	if len(acl.Allow) > 0 {
+		configs = append(configs, map[string]interface{}{
+			"name":      BuildAdmissionName(serviceName, "allow"),
+			"whitelist": true,
+			"matchers":  acl.Allow,
+		})
+	}
+	if len(acl.Deny) > 0 {
+		configs = append(configs, map[string]interface{}{
+			"name":     BuildAdmissionName(serviceName, "deny"),
+			"matchers": acl.Deny,
+		})
+	}
+	return configs
+}
+
+// AddAdmissionConfig 下发完整的 admission 配置
+func AddAdmissionConfig(nodeId int64, data map[string]interface{}) *dto.GostDto {
+	return sendOrQueue(nodeId, data, "AddAdmissions", configName(data))
+}
+
+// UpdateAdmissionConfig 按名称更新 admission 配置
+func UpdateAdmissionConfig(nodeId int64, name string, data map[string]interface{}) *dto.GostDto {
+	req := map[string]interface{}{
+		"admission": name,
+		"data":      data,
+	}
+	return sendOrQueue(nodeId, req, "UpdateAdmissions", name)
+}
+
+// DeleteAdmissionByName 按名称删除 admission
+func DeleteAdmissionByName(nodeId int64, name string) *dto.GostDto {
+	req := map[string]interface{}{
+		"admission": name,
+	}
+	return sendOrQueue(nodeId, req, "DeleteAdmissions", name)
+}
+
+func AddService(nodeId int64, name string, inPort int, limiter *int, connLimit *int, acl *ServiceAcl, remoteAddr string, fowType int, tunnel model.Tunnel, strategy, interfaceName string) *dto.GostDto {
+	services := BuildServiceConfigs(name, inPort, limiter, connLimit, acl, remoteAddr, fowType, tunnel, strategy, interfaceName)
+	return sendOrQueue(nodeId, services, "AddService", configNames(services)...)
+}
+
+func UpdateService(nodeId int64, name string, inPort int, limiter *int, connLimit *int, acl *ServiceAcl, remoteAddr string, fowType int, tunnel model.Tunnel, strategy, interfaceName string) *dto.GostDto {
+	services := BuildServiceConfigs(name, inPort, limiter, connLimit, acl, remoteAddr, fowType, tunnel, strategy, interfaceName)
+	return sendOrQueue(nodeId, services, "UpdateService", configNames(services)...)
 }
 
 func DeleteService(nodeId int64, name string) *dto.GostDto {
+	names := []string{name + "_tcp", name + "_udp"}
 	data := map[string]interface{}{
-		"services": []string{name + "_tcp", name + "_udp"},
+		"services": names,
 	}
-	return websocket.SendMsg(nodeId, data, "DeleteService")
+	return sendOrQueue(nodeId, data, "DeleteService", names...)
 }
 
 func PauseService(nodeId int64, name string) *dto.GostDto {
-	data := map[string]interface{}{
-		"services": []string{name + "_tcp", name + "_udp"},
-	}
-	return websocket.SendMsg(nodeId, data, "PauseService")
+	return SendServiceNames(nodeId, []string{name + "_tcp", name + "_udp"}, "PauseService")
 }
 
 func ResumeService(nodeId int64, name string) *dto.GostDto {
-	data := map[string]interface{}{
-		"services": []string{name + "_tcp", name + "_udp"},
-	}
-	return websocket.SendMsg(nodeId, data, "ResumeService")
+	return SendServiceNames(nodeId, []string{name + "_tcp", name + "_udp"}, "ResumeService")
 }
 
 func AddRemoteService(nodeId int64, name string, outPort int, remoteAddr, protocol, strategy, interfaceName string) *dto.GostDto {
@@ -81,39 +232,33 @@ func AddRemoteService(nodeId int64, name string, outPort int, remoteAddr, protoc
 	// Wait, Java uses AddService for Remote too?
 	// Yes: `GostUtil.AddRemoteService` creates config and calls `AddService` (which sends list).
 	services := []map[string]interface{}{data}
-	return websocket.SendMsg(nodeId, services, "AddService")
+	return sendOrQueue(nodeId, services, "AddService", configNames(services)...)
 }
 
 func UpdateRemoteService(nodeId int64, name string, outPort int, remoteAddr, protocol, strategy, interfaceName string) *dto.GostDto {
 	data := createRemoteServiceConfig(name, outPort, remoteAddr, protocol, strategy, interfaceName)
 	services := []map[string]interface{}{data}
-	return websocket.SendMsg(nodeId, services, "UpdateService")
+	return sendOrQueue(nodeId, services, "UpdateService", configNames(services)...)
 }
 
 func DeleteRemoteService(nodeId int64, name string) *dto.GostDto {
 	req := map[string]interface{}{
 		"services": []string{name + "_tls"},
 	}
-	return websocket.SendMsg(nodeId, req, "DeleteService")
+	return sendOrQueue(nodeId, req, "DeleteService", name+"_tls")
 }
 
 func PauseRemoteService(nodeId int64, name string) *dto.GostDto {
-	req := map[string]interface{}{
-		"services": []string{name + "_tls"},
-	}
-	return websocket.SendMsg(nodeId, req, "PauseService")
+	return SendServiceNames(nodeId, []string{name + "_tls"}, "PauseService")
 }
 
 func ResumeRemoteService(nodeId int64, name string) *dto.GostDto {
-	req := map[string]interface{}{
-		"services": []string{name + "_tls"},
-	}
-	return websocket.SendMsg(nodeId, req, "ResumeService")
+	return SendServiceNames(nodeId, []string{name + "_tls"}, "ResumeService")
 }
 
 func AddChains(nodeId int64, name, remoteAddr, protocol, interfaceName string) *dto.GostDto {
 	data := createChainConfig(name, remoteAddr, protocol, interfaceName)
-	return websocket.SendMsg(nodeId, data, "AddChains")
+	return sendOrQueue(nodeId, data, "AddChains", configName(data))
 }
 
 func UpdateChains(nodeId int64, name, remoteAddr, protocol, interfaceName string) *dto.GostDto {
@@ -122,42 +267,43 @@ func UpdateChains(nodeId int64, name, remoteAddr, protocol, interfaceName string
 		"chain": name + "_chains",
 		"data":  data,
 	}
-	return websocket.SendMsg(nodeId, req, "UpdateChains")
+	return sendOrQueue(nodeId, req, "UpdateChains", name+"_chains")
 }
 
 func DeleteChains(nodeId int64, name string) *dto.GostDto {
 	req := map[string]interface{}{
 		"chain": name + "_chains",
 	}
-	return websocket.SendMsg(nodeId, req, "DeleteChains")
+	return sendOrQueue(nodeId, req, "DeleteChains", name+"_chains")
 }
 
 // --- Tunnel 级别共享服务函数 ---
 
-// buildTunnelChainName 生成 tunnel 级别共享 chain 名称
+// BuildTunnelChainName 生成 tunnel 级别共享 chain 名称
 func BuildTunnelChainName(tunnelId int64) string {
 	return fmt.Sprintf("tunnel_%d_chains", tunnelId)
 }
 
-// buildTunnelServiceName 生成 tunnel 级别共享 service 名称
-func buildTunnelServiceName(tunnelId int64) string {
+// BuildTunnelServiceName 生成 tunnel 级别共享 service 名称
+func BuildTunnelServiceName(tunnelId int64) string {
 	return fmt.Sprintf("tunnel_%d_relay", tunnelId)
 }
 
 // AddTunnelChain 创建 tunnel 级别的共享 chain（在入口节点）
-func AddTunnelChain(nodeId int64, tunnelId int64, remoteAddr, protocol, interfaceName string) *dto.GostDto {
-	data := createTunnelChainConfig(tunnelId, remoteAddr, protocol, interfaceName)
-	return websocket.SendMsg(nodeId, data, "AddChains")
+// transits 为依次经过的中转节点地址，exits 为出口节点组地址
+func AddTunnelChain(nodeId int64, tunnel model.Tunnel, transits, exits []string) *dto.GostDto {
+	data := createTunnelChainConfig(tunnel, transits, exits)
+	return sendOrQueue(nodeId, data, "AddChains", configName(data))
 }
 
 // UpdateTunnelChain 更新 tunnel 级别的共享 chain
-func UpdateTunnelChain(nodeId int64, tunnelId int64, remoteAddr, protocol, interfaceName string) *dto.GostDto {
-	data := createTunnelChainConfig(tunnelId, remoteAddr, protocol, interfaceName)
+func UpdateTunnelChain(nodeId int64, tunnel model.Tunnel, transits, exits []string) *dto.GostDto {
+	data := createTunnelChainConfig(tunnel, transits, exits)
 	req := map[string]interface{}{
-		"chain": BuildTunnelChainName(tunnelId),
+		"chain": BuildTunnelChainName(tunnel.ID),
 		"data":  data,
 	}
-	return websocket.SendMsg(nodeId, req, "UpdateChains")
+	return sendOrQueue(nodeId, req, "UpdateChains", BuildTunnelChainName(tunnel.ID))
 }
 
 // DeleteTunnelChain 删除 tunnel 级别的共享 chain
@@ -165,33 +311,101 @@ func DeleteTunnelChain(nodeId int64, tunnelId int64) *dto.GostDto {
 	req := map[string]interface{}{
 		"chain": BuildTunnelChainName(tunnelId),
 	}
-	return websocket.SendMsg(nodeId, req, "DeleteChains")
+	return sendOrQueue(nodeId, req, "DeleteChains", BuildTunnelChainName(tunnelId))
 }
 
 // AddTunnelRelayService 在出口节点创建 tunnel 共享的 relay service
 func AddTunnelRelayService(nodeId int64, tunnelId int64, outPort int, protocol, interfaceName string) *dto.GostDto {
-	data := createTunnelRelayConfig(tunnelId, outPort, protocol, interfaceName)
+	data := createTunnelRelayConfig(BuildTunnelServiceName(tunnelId), outPort, protocol, interfaceName)
 	services := []map[string]interface{}{data}
-	return websocket.SendMsg(nodeId, services, "AddService")
+	return sendOrQueue(nodeId, services, "AddService", configNames(services)...)
 }
 
 // UpdateTunnelRelayService 更新 tunnel 共享的 relay service
 func UpdateTunnelRelayService(nodeId int64, tunnelId int64, outPort int, protocol, interfaceName string) *dto.GostDto {
-	data := createTunnelRelayConfig(tunnelId, outPort, protocol, interfaceName)
+	data := createTunnelRelayConfig(BuildTunnelServiceName(tunnelId), outPort, protocol, interfaceName)
 	services := []map[string]interface{}{data}
-	return websocket.SendMsg(nodeId, services, "UpdateService")
+	return sendOrQueue(nodeId, services, "UpdateService", configNames(services)...)
 }
 
 // DeleteTunnelRelayService 删除 tunnel 共享的 relay service
 func DeleteTunnelRelayService(nodeId int64, tunnelId int64) *dto.GostDto {
 	req := map[string]interface{}{
-		"services": []string{buildTunnelServiceName(tunnelId)},
+		"services": []string{BuildTunnelServiceName(tunnelId)},
 	}
-	return websocket.SendMsg(nodeId, req, "DeleteService")
+	return sendOrQueue(nodeId, req, "DeleteService", BuildTunnelServiceName(tunnelId))
+}
+
+// BuildTunnelHopServiceName 生成中转节点 relay service 名称
+func BuildTunnelHopServiceName(tunnelId int64, inx int) string {
+	return fmt.Sprintf("tunnel_%d_relay_%d", tunnelId, inx)
+}
+
+// AddTunnelHopService 在中转节点创建 relay service
+func AddTunnelHopService(nodeId int64, tunnelId int64, inx int, port int, protocol string) *dto.GostDto {
+	data := createTunnelRelayConfig(BuildTunnelHopServiceName(tunnelId, inx), port, protocol, "")
+	return sendOrQueue(nodeId, []map[string]interface{}{data}, "AddService", configName(data))
+}
+
+// UpdateTunnelHopService 更新中转节点 relay service
+func UpdateTunnelHopService(nodeId int64, tunnelId int64, inx int, port int, protocol string) *dto.GostDto {
+	data := createTunnelRelayConfig(BuildTunnelHopServiceName(tunnelId, inx), port, protocol, "")
+	return sendOrQueue(nodeId, []map[string]interface{}{data}, "UpdateService", configName(data))
+}
+
+// DeleteTunnelHopService 删除中转节点 relay service
+func DeleteTunnelHopService(nodeId int64, tunnelId int64, inx int) *dto.GostDto {
+	req := map[string]interface{}{
+		"services": []string{BuildTunnelHopServiceName(tunnelId, inx)},
+	}
+	return sendOrQueue(nodeId, req, "DeleteService", BuildTunnelHopServiceName(tunnelId, inx))
 }
 
 // createTunnelChainConfig 创建 tunnel 级别 chain 配置
-func createTunnelChainConfig(tunnelId int64, remoteAddr, protocol, interfaceName string) map[string]interface{} {
+// 每个中转节点一跳，最后一跳为出口节点组；只有第一跳由入口节点直接拨号，网卡绑定只作用于第一跳
+func createTunnelChainConfig(tunnel model.Tunnel, transits, exits []string) map[string]interface{} {
+	tunnelId := tunnel.ID
+	hops := []map[string]interface{}{}
+	for i, addr := range transits {
+		node := createTunnelChainNode(fmt.Sprintf("tunnel-%d-node-%d", tunnelId, i+1), addr, tunnel.Protocol)
+		if i == 0 && tunnel.InterfaceName != "" {
+			node["interface"] = tunnel.InterfaceName
+		}
+		hops = append(hops, map[string]interface{}{
+			"name":  fmt.Sprintf("tunnel-%d-hop-%d", tunnelId, i+1),
+			"nodes": []map[string]interface{}{node},
+		})
+	}
+
+	// 最后一跳（出口）沿用单出口隧道的命名，附加出口节点按顺序编号
+	nodes := []map[string]interface{}{}
+	for i, addr := range exits {
+		name := fmt.Sprintf("tunnel-%d-node", tunnelId)
+		if i > 0 {
+			name = fmt.Sprintf("tunnel-%d-exit-%d", tunnelId, i)
+		}
+		node := createTunnelChainNode(name, addr, tunnel.Protocol)
+		if len(transits) == 0 && tunnel.InterfaceName != "" {
+			node["interface"] = tunnel.InterfaceName
+		}
+		nodes = append(nodes, node)
+	}
+	lastHop := map[string]interface{}{
+		"name":  fmt.Sprintf("tunnel-%d-hop", tunnelId),
+		"nodes": nodes,
+	}
+	if len(exits) > 1 {
+		lastHop["selector"] = BuildTunnelSelector(tunnel.Strategy, tunnel.MaxFails, tunnel.FailTimeout)
+	}
+	hops = append(hops, lastHop)
+
+	return map[string]interface{}{
+		"name": BuildTunnelChainName(tunnelId),
+		"hops": hops,
+	}
+}
+
+func createTunnelChainNode(name, addr, protocol string) map[string]interface{} {
 	dialer := map[string]interface{}{"type": protocol}
 	if protocol == "quic" {
 		dialer["metadata"] = map[string]interface{}{
@@ -199,35 +413,34 @@ func createTunnelChainConfig(tunnelId int64, remoteAddr, protocol, interfaceName
 			"ttl":       "10s",
 		}
 	}
-
-	connector := map[string]interface{}{"type": "relay"}
-
-	node := map[string]interface{}{
-		"name":      fmt.Sprintf("tunnel-%d-node", tunnelId),
-		"addr":      remoteAddr,
-		"connector": connector,
+	return map[string]interface{}{
+		"name":      name,
+		"addr":      addr,
+		"connector": map[string]interface{}{"type": "relay"},
 		"dialer":    dialer,
 	}
-	if interfaceName != "" {
-		node["interface"] = interfaceName
-	}
+}
 
-	hop := map[string]interface{}{
-		"name":  fmt.Sprintf("tunnel-%d-hop", tunnelId),
-		"nodes": []map[string]interface{}{node},
+// BuildTunnelSelector 生成出口节点组的 selector，未设置时与转发目标的默认值一致
+func BuildTunnelSelector(strategy string, maxFails, failTimeout int) map[string]interface{} {
+	if maxFails <= 0 {
+		maxFails = 1
+	}
+	if failTimeout <= 0 {
+		failTimeout = 20
 	}
-
 	return map[string]interface{}{
-		"name": BuildTunnelChainName(tunnelId),
-		"hops": []map[string]interface{}{hop},
+		"strategy":    strategyStr(strategy),
+		"maxFails":    maxFails,
+		"failTimeout": fmt.Sprintf("%ds", failTimeout),
 	}
 }
 
-// createTunnelRelayConfig 创建 tunnel 级别 relay service 配置
-func createTunnelRelayConfig(tunnelId int64, outPort int, protocol, interfaceName string) map[string]interface{} {
+// createTunnelRelayConfig 创建 tunnel 级别 relay service 配置（出口节点与中转节点共用）
+func createTunnelRelayConfig(name string, port int, protocol, interfaceName string) map[string]interface{} {
 	data := make(map[string]interface{})
-	data["name"] = buildTunnelServiceName(tunnelId)
-	data["addr"] = fmt.Sprintf(":%d", outPort)
+	data["name"] = name
+	data["addr"] = fmt.Sprintf(":%d", port)
 
 	if interfaceName != "" {
 		data["metadata"] = map[string]interface{}{"interface": interfaceName}
@@ -243,9 +456,99 @@ func createTunnelRelayConfig(tunnelId int64, outPort int, protocol, interfaceNam
 	return data
 }
 
+// --- 按完整配置下发 (供对账使用) ---
+
+// SendServiceConfigs 直接下发完整的 service 配置列表，msgType 为 AddService / UpdateService
+func SendServiceConfigs(nodeId int64, services []map[string]interface{}, msgType string) *dto.GostDto {
+	return sendOrQueue(nodeId, services, msgType, configNames(services)...)
+}
+
+// SendServiceNames 按完整服务名下发 DeleteService / PauseService / ResumeService
+func SendServiceNames(nodeId int64, names []string, msgType string) *dto.GostDto {
+	req := map[string]interface{}{
+		"services": names,
+	}
+	return sendOrQueue(nodeId, req, msgType, names...)
+}
+
+// AddChainConfig 下发完整的 chain 配置
+func AddChainConfig(nodeId int64, data map[string]interface{}) *dto.GostDto {
+	return sendOrQueue(nodeId, data, "AddChains", configName(data))
+}
+
+// UpdateChainConfig 按名称更新 chain 配置
+func UpdateChainConfig(nodeId int64, name string, data map[string]interface{}) *dto.GostDto {
+	req := map[string]interface{}{
+		"chain": name,
+		"data":  data,
+	}
+	return sendOrQueue(nodeId, req, "UpdateChains", name)
+}
+
+// DeleteChainByName 按完整名称删除 chain
+func DeleteChainByName(nodeId int64, name string) *dto.GostDto {
+	req := map[string]interface{}{
+		"chain": name,
+	}
+	return sendOrQueue(nodeId, req, "DeleteChains", name)
+}
+
+// AddLimiterConfig 下发完整的限速器配置
+func AddLimiterConfig(nodeId int64, data map[string]interface{}) *dto.GostDto {
+	return sendOrQueue(nodeId, data, "AddLimiters", configName(data))
+}
+
+// UpdateLimiterConfig 按名称更新限速器配置
+func UpdateLimiterConfig(nodeId int64, name string, data map[string]interface{}) *dto.GostDto {
+	req := map[string]interface{}{
+		"limiter": name,
+		"data":    data,
+	}
+	return sendOrQueue(nodeId, req, "UpdateLimiters", name)
+}
+
+// DeleteLimiterByName 按完整名称删除限速器
+func DeleteLimiterByName(nodeId int64, name string) *dto.GostDto {
+	req := map[string]interface{}{
+		"limiter": name,
+	}
+	return sendOrQueue(nodeId, req, "DeleteLimiters", name)
+}
+
+// ApplySnapshot 下发节点完整配置快照 {services, chains, limiters}
+// 快照不入队：节点每次重连都会重新下发最新快照，补发旧快照只会回退配置
+func ApplySnapshot(nodeId int64, snapshot map[string]interface{}) *dto.GostDto {
+	return websocket.SendMsg(nodeId, snapshot, "ApplySnapshot")
+}
+
+// --- 配置构建 ---
+
+// BuildServiceConfigs 生成转发入口的 tcp / udp 两个 service 配置
+func BuildServiceConfigs(name string, inPort int, limiter *int, connLimit *int, acl *ServiceAcl, remoteAddr string, fowType int, tunnel model.Tunnel, strategy, interfaceName string) []map[string]interface{} {
+	return []map[string]interface{}{
+		createServiceConfig(name, inPort, limiter, connLimit, acl, remoteAddr, "tcp", fowType, tunnel, strategy, interfaceName),
+		createServiceConfig(name, inPort, limiter, connLimit, acl, remoteAddr, "udp", fowType, tunnel, strategy, interfaceName),
+	}
+}
+
+// BuildTunnelChainConfig 生成 tunnel 级别共享 chain 配置
+func BuildTunnelChainConfig(tunnel model.Tunnel, transits, exits []string) map[string]interface{} {
+	return createTunnelChainConfig(tunnel, transits, exits)
+}
+
+// BuildTunnelRelayConfig 生成 tunnel 级别 relay service 配置
+func BuildTunnelRelayConfig(tunnelId int64, outPort int, protocol, interfaceName string) map[string]interface{} {
+	return createTunnelRelayConfig(BuildTunnelServiceName(tunnelId), outPort, protocol, interfaceName)
+}
+
+// BuildTunnelHopRelayConfig 生成中转节点 relay service 配置
+func BuildTunnelHopRelayConfig(tunnelId int64, inx int, port int, protocol string) map[string]interface{} {
+	return createTunnelRelayConfig(BuildTunnelHopServiceName(tunnelId, inx), port, protocol, "")
+}
+
 // --- Helpers ---
 
-func createServiceConfig(name string, inPort int, limiter *int, remoteAddr, protocol string, fowType int, tunnel model.Tunnel, strategy, interfaceName string) map[string]interface{} {
+func createServiceConfig(name string, inPort int, limiter *int, connLimit *int, acl *ServiceAcl, remoteAddr, protocol string, fowType int, tunnel model.Tunnel, strategy, interfaceName string) map[string]interface{} {
 	service := make(map[string]interface{})
 	service["name"] = name + "_" + protocol
 
@@ -263,6 +566,17 @@ func createServiceConfig(name string, inPort int, limiter *int, remoteAddr, prot
 		service["limiter"] = fmt.Sprintf("%d", *limiter)
 	}
 
+	// 连接数限制：climiter 限制并发连接，rlimiter 限制每秒新建连接
+	if connLimit != nil {
+		service["climiter"] = BuildConnLimiterName(int64(*connLimit))
+		service["rlimiter"] = BuildRateLimiterName(int64(*connLimit))
+	}
+
+	// 来源 IP 访问控制，tcp / udp 服务共用同一组 admission
+	if names := acl.admissionNames(name); len(names) > 0 {
+		service["admissions"] = names
+	}
+
 	// Handler
 	handler := map[string]interface{}{"type": protocol}
 	if fowType == 2 { // Tunnel Forward - 使用 tunnel 级别共享 chain
@@ -14,10 +14,16 @@ type UserClaims struct {
 	RoleId int    `json:"role_id"`
 	User   string `json:"user"`
 	Name   string `json:"name"` // Java sets this to user.User
+	// TotpSetup 要求启用两步验证但尚未绑定，此类 token 只能访问绑定接口
+	TotpSetup bool `json:"totp_setup,omitempty"`
+	// ApiKeyId/Scopes 通过 API Key 访问时由 middleware 填充，不出现在 JWT 中
+	ApiKeyId int64    `json:"-"`
+	Scopes   []string `json:"-"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(user *model.User) (string, error) {
+// GenerateToken 签发 access token，sid 为会话 ID（jti），会话撤销后 token 随之失效
+func GenerateToken(user *model.User, sid string) (string, error) {
 	claims := UserClaims{
 		RoleId: user.RoleId,
 		User:   user.User,
@@ -26,7 +32,9 @@ func GenerateToken(user *model.User) (string, error) {
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // 24小时过期
 			Issuer:    "flux-panel",                                       // Java doesn't strictly set iss, but verifies it? No, Java validateToken only checks signature and exp.
 			// Java sets "sub" to userId.toString()
-			Subject: strconv.FormatInt(user.ID, 10),
+			Subject:  strconv.FormatInt(user.ID, 10),
+			ID:       sid,
+			IssuedAt: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
@@ -37,7 +45,39 @@ func GenerateToken(user *model.User) (string, error) {
 	return token.SignedString([]byte(config.AppConfig.JwtSecret))
 }
 
+// GenerateTotpSetupToken 生成仅用于绑定两步验证的短期 token
+func GenerateTotpSetupToken(user *model.User, sid string) (string, error) {
+	claims := UserClaims{
+		RoleId:    user.RoleId,
+		User:      user.User,
+		Name:      user.User,
+		TotpSetup: true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(30 * time.Minute)),
+			Issuer:    "flux-panel",
+			Subject:   strconv.FormatInt(user.ID, 10),
+			ID:        sid,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(config.AppConfig.JwtSecret))
+}
+
 func (c *UserClaims) GetUserId() int64 {
 	id, _ := strconv.ParseInt(c.Subject, 10, 64)
 	return id
 }
+
+// HasScope 判断 API Key 是否具备指定权限，JWT 登录不受限制
+func (c *UserClaims) HasScope(scope string) bool {
+	if c.ApiKeyId == 0 {
+		return true
+	}
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 密码哈希格式：
+//   - $argon2id$v=19$m=...,t=...,p=...$<salt>$<hash>  当前默认
+//   - $2a$ / $2b$ / $2y$ 开头                          bcrypt
+//   - 32 位十六进制                                     历史 MD5，登录成功后重新哈希
+//
+// 参数取 OWASP 推荐的 argon2id 最低配置，兼顾小内存 VPS
+const (
+	argon2Memory  uint32 = 19 * 1024
+	argon2Time    uint32 = 2
+	argon2Threads uint8  = 1
+	argon2SaltLen        = 16
+	argon2KeyLen  uint32 = 32
+
+	PasswordMinLength = 8
+	PasswordMaxLength = 128
+)
+
+var (
+	md5HashRe    = regexp.MustCompile(`^[0-9a-f]{32}$`)
+	argon2HashRe = regexp.MustCompile(`^\$argon2id\$v=(\d+)\$m=(\d+),t=(\d+),p=(\d+)\$([A-Za-z0-9+/]+)\$([A-Za-z0-9+/]+)$`)
+)
+
+// HashPassword 使用 argon2id 生成带版本和参数的密码哈希
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// VerifyPassword 校验密码，needsRehash 表示哈希不是当前格式或参数，调用方应在校验通过后重新哈希保存
+func VerifyPassword(stored, password string) (ok bool, needsRehash bool) {
+	switch {
+	case strings.HasPrefix(stored, "$argon2id$"):
+		return verifyArgon2(stored, password)
+	case strings.HasPrefix(stored, "$2a$"), strings.HasPrefix(stored, "$2b$"), strings.HasPrefix(stored, "$2y$"):
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil, true
+	case md5HashRe.MatchString(stored):
+		return subtle.ConstantTimeCompare([]byte(stored), []byte(Md5(password))) == 1, true
+	}
+	return false, false
+}
+
+func verifyArgon2(stored, password string) (bool, bool) {
+	m := argon2HashRe.FindStringSubmatch(stored)
+	if m == nil {
+		return false, false
+	}
+	var version int
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(m[1]+" "+m[2]+" "+m[3]+" "+m[4], "%d %d %d %d", &version, &memory, &time, &threads); err != nil {
+		return false, false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(m[5])
+	if err != nil {
+		return false, false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(m[6])
+	if err != nil {
+		return false, false
+	}
+	if version != argon2.Version {
+		return false, false
+	}
+
+	got := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(want)))
+	if subtle.ConstantTimeCompare(got, want) != 1 {
+		return false, false
+	}
+	return true, memory != argon2Memory || time != argon2Time || threads != argon2Threads || uint32(len(want)) != argon2KeyLen
+}
+
+// dummyPasswordHash 是一个固定的 argon2id 哈希，参数与 HashPassword 一致
+const dummyPasswordHash = "$argon2id$v=19$m=19456,t=2,p=1$Q9Z6CseqzrxWTH0tyHcklg$twH2BnTBeFI2oj4hNRqz5JwVDWm4ReIZ8Q7J8iCHJSA"
+
+// DummyVerifyPassword 对固定哈希做一次完整校验，用于账号不存在时消除响应时间差异
+func DummyVerifyPassword(password string) {
+	verifyArgon2(dummyPasswordHash, password)
+}
+
+// CheckPasswordPolicy 校验新密码是否满足密码策略
+func CheckPasswordPolicy(username, password string) error {
+	if len(password) < PasswordMinLength {
+		return fmt.Errorf("密码长度不能少于 %d 位", PasswordMinLength)
+	}
+	if len(password) > PasswordMaxLength {
+		return fmt.Errorf("密码长度不能超过 %d 位", PasswordMaxLength)
+	}
+	if password == "admin_user" {
+		return errors.New("不能使用默认密码")
+	}
+	if strings.EqualFold(password, username) {
+		return errors.New("密码不能与用户名相同")
+	}
+	return nil
+}
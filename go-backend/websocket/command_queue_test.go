@@ -0,0 +1,78 @@
+package websocket_test
+
+import (
+	"testing"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/testutil"
+	"go-backend/utils"
+	"go-backend/websocket"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestOfflineCommandQueue verifies commands to an offline node are persisted
+// and that a newer command with the same idempotency key supersedes the old one.
+func TestOfflineCommandQueue(t *testing.T) {
+	node := testutil.CreateNode(900, "offline_node")
+	key := "service:queue_test"
+
+	res := websocket.SendOrQueue(node.ID, map[string]interface{}{"services": []string{"a_tcp"}}, "PauseService", key)
+	assert.Equal(t, "OK", res.Msg, "offline command should be queued")
+
+	websocket.SendOrQueue(node.ID, map[string]interface{}{"services": []string{"a_tcp"}}, "ResumeService", key)
+
+	var pending []model.NodeCommand
+	global.DB.Where("node_id = ? AND status = ?", node.ID, websocket.CommandPending).Find(&pending)
+	assert.Len(t, pending, 1, "only the latest command per key should stay pending")
+	if len(pending) == 1 {
+		assert.Equal(t, "ResumeService", pending[0].Type)
+	}
+}
+
+// TestOfflineConfigCommands verifies add/update/delete helpers are queued for an
+// offline node, keep their order, and only repeated commands of the same type supersede.
+func TestOfflineConfigCommands(t *testing.T) {
+	node := testutil.CreateNode(901, "offline_config_node")
+	tunnel := model.Tunnel{ID: 9010, Type: 1}
+
+	assert.Equal(t, "OK", utils.AddService(node.ID, "9010_1_0", 10001, nil, nil, nil, "1.1.1.1:80", 1, tunnel, "fifo", "").Msg)
+	utils.UpdateService(node.ID, "9010_1_0", 10002, nil, nil, nil, "1.1.1.1:80", 1, tunnel, "fifo", "")
+	utils.UpdateService(node.ID, "9010_1_0", 10003, nil, nil, nil, "1.1.1.1:80", 1, tunnel, "fifo", "")
+	utils.AddLimiters(node.ID, 9010, "10")
+	utils.DeleteService(node.ID, "9010_1_0")
+
+	var pending []model.NodeCommand
+	global.DB.Where("node_id = ? AND status = ?", node.ID, websocket.CommandPending).Order("id asc").Find(&pending)
+	types := []string{}
+	for _, c := range pending {
+		types = append(types, c.Type)
+	}
+	assert.Equal(t, []string{"AddService", "UpdateService", "AddLimiters", "DeleteService"}, types)
+	if len(pending) == 4 {
+		assert.Contains(t, pending[1].Data, ":10003")
+		assert.Equal(t, "UpdateService:9010_1_0_tcp,9010_1_0_udp", pending[1].IdempotencyKey)
+	}
+}
+
+// TestOfflineServiceState verifies manual, automatic and reconciler pause/resume
+// commands share one idempotency key, so only the latest state stays queued.
+func TestOfflineServiceState(t *testing.T) {
+	node := testutil.CreateNode(902, "offline_state_node")
+
+	assert.Equal(t, "OK", utils.PauseService(node.ID, "9020_1_0").Msg)
+	utils.ResumeService(node.ID, "9020_1_0")
+	utils.SendServiceNames(node.ID, []string{"9020_1_0_tcp", "9020_1_0_udp"}, "PauseService")
+	utils.PauseRemoteService(node.ID, "9021_1_0")
+	utils.ResumeRemoteService(node.ID, "9021_1_0")
+
+	var pending []model.NodeCommand
+	global.DB.Where("node_id = ? AND status = ?", node.ID, websocket.CommandPending).Order("id asc").Find(&pending)
+	if assert.Len(t, pending, 2) {
+		assert.Equal(t, "PauseService", pending[0].Type)
+		assert.Equal(t, "ServiceState:9020_1_0_tcp,9020_1_0_udp", pending[0].IdempotencyKey)
+		assert.Equal(t, "ResumeService", pending[1].Type)
+		assert.Equal(t, "ServiceState:9021_1_0_tls", pending[1].IdempotencyKey)
+	}
+}
@@ -0,0 +1,138 @@
+package websocket
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+
+	"go-backend/model"
+	"go-backend/model/dto"
+	"go-backend/rbac"
+)
+
+// 节点上报的单个服务实时数据，字段与 gost 端 LiveStats 对应
+type nodeLiveStats struct {
+	Service      string `json:"n"`
+	CurrentConns uint64 `json:"cc"`
+	TotalConns   uint64 `json:"tc"`
+	TotalErrs    uint64 `json:"te"`
+	InputRate    uint64 `json:"ir"`
+	OutputRate   uint64 `json:"or"`
+	Time         int64  `json:"t"`
+}
+
+// 转发服务名为 转发ID_用户ID_用户隧道ID_协议
+var forwardServiceRe = regexp.MustCompile(`^(\d+)_(\d+)_\d+_(tcp|udp|tls)$`)
+
+// forwardLiveStats 按转发合并后的实时数据及所属用户
+type forwardLiveStats struct {
+	userId int64
+	stats  dto.ForwardLiveStatsDto
+}
+
+// handleLiveStats 处理节点上报的服务实时数据，按订阅和权限推送给前端会话
+func (c *Client) handleLiveStats(payload []byte) bool {
+	var msg struct {
+		Type string          `json:"type"`
+		Data []nodeLiveStats `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &msg); err != nil || msg.Type != "stats" {
+		return false
+	}
+	nodeId, _ := strconv.ParseInt(c.ID, 10, 64)
+	fanOutLiveStats(nodeId, aggregateLiveStats(nodeId, msg.Data))
+	return true
+}
+
+// aggregateLiveStats 将同一转发的多个服务（tcp / udp）合并，非转发服务（隧道中继等）忽略
+func aggregateLiveStats(nodeId int64, list []nodeLiveStats) []forwardLiveStats {
+	index := make(map[int64]int)
+	var result []forwardLiveStats
+	for _, item := range list {
+		m := forwardServiceRe.FindStringSubmatch(item.Service)
+		if m == nil {
+			continue
+		}
+		forwardId, _ := strconv.ParseInt(m[1], 10, 64)
+		userId, _ := strconv.ParseInt(m[2], 10, 64)
+
+		i, ok := index[forwardId]
+		if !ok {
+			i = len(result)
+			index[forwardId] = i
+			result = append(result, forwardLiveStats{
+				userId: userId,
+				stats:  dto.ForwardLiveStatsDto{ForwardId: forwardId, NodeId: nodeId},
+			})
+		}
+		s := &result[i].stats
+		s.CurrentConns += item.CurrentConns
+		s.TotalConns += item.TotalConns
+		s.TotalErrs += item.TotalErrs
+		s.InputRate += item.InputRate
+		s.OutputRate += item.OutputRate
+		if item.Time > s.Time {
+			s.Time = item.Time
+		}
+	}
+	return result
+}
+
+// fanOutLiveStats 推送给已订阅的会话：管理员可查看全部转发，普通用户只能查看自己的转发
+func fanOutLiveStats(nodeId int64, list []forwardLiveStats) {
+	if len(list) == 0 {
+		return
+	}
+
+	Manager.mu.RLock()
+	defer Manager.mu.RUnlock()
+	for client := range Manager.AdminSessions {
+		if !client.statsSubscribed {
+			continue
+		}
+		var visible []dto.ForwardLiveStatsDto
+		for _, item := range list {
+			if !rbac.Has(client.RoleId, model.PermForwardRead) && item.userId != client.UserId {
+				continue
+			}
+			if len(client.statsForwards) > 0 && !client.statsForwards[item.stats.ForwardId] {
+				continue
+			}
+			visible = append(visible, item.stats)
+		}
+		if len(visible) == 0 {
+			continue
+		}
+		jsonMsg, _ := json.Marshal(map[string]interface{}{
+			"id":   strconv.FormatInt(nodeId, 10),
+			"type": "stats",
+			"data": visible,
+		})
+		go client.SendText(string(jsonMsg))
+	}
+}
+
+// handleSubscribe 处理前端会话的订阅消息
+func (c *Client) handleSubscribe(payload []byte) {
+	var req dto.LiveStatsSubscribeDto
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return
+	}
+
+	Manager.mu.Lock()
+	defer Manager.mu.Unlock()
+	switch req.Type {
+	case "subscribe_stats":
+		c.statsSubscribed = true
+		c.statsForwards = nil
+		if len(req.ForwardIds) > 0 {
+			c.statsForwards = make(map[int64]bool, len(req.ForwardIds))
+			for _, id := range req.ForwardIds {
+				c.statsForwards[id] = true
+			}
+		}
+	case "unsubscribe_stats":
+		c.statsSubscribed = false
+		c.statsForwards = nil
+	}
+}
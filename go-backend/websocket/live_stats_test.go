@@ -0,0 +1,83 @@
+package websocket_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model/dto"
+	"go-backend/service"
+	"go-backend/testutil"
+	"go-backend/websocket"
+
+	"github.com/gin-gonic/gin"
+	gorillaws "github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLiveStatsFanOut(t *testing.T) {
+	secret := "live-stats-node"
+	node := testutil.CreateNode(970, "live")
+	global.DB.Model(node).Update("secret", secret)
+	owner := testutil.CreateUser("live_owner", 1, 10, 999999, time.Now().Add(24*time.Hour).UnixMilli())
+	other := testutil.CreateUser("live_other", 1, 10, 999999, time.Now().Add(24*time.Hour).UnixMilli())
+	ownerToken, _, _ := service.Session.Issue(owner, dto.ClientInfo{}, false)
+	otherToken, _, _ := service.Session.Issue(other, dto.ClientInfo{}, false)
+
+	r := gin.New()
+	r.GET("/system-info", websocket.HandleWebSocket)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/system-info"
+
+	dial := func(query string) *gorillaws.Conn {
+		conn, _, err := gorillaws.DefaultDialer.Dial(wsURL+"?"+query, nil)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return conn
+	}
+	ownerConn := dial("type=0&secret=" + ownerToken)
+	defer ownerConn.Close()
+	otherConn := dial("type=0&secret=" + otherToken)
+	defer otherConn.Close()
+	ownerConn.WriteJSON(map[string]interface{}{"type": "subscribe_stats"})
+	otherConn.WriteJSON(map[string]interface{}{"type": "subscribe_stats"})
+	time.Sleep(100 * time.Millisecond)
+
+	nodeConn := dial("type=1&secret=" + secret)
+	defer nodeConn.Close()
+	nodeConn.WriteJSON(map[string]interface{}{
+		"type": "stats",
+		"data": []map[string]interface{}{
+			{"n": fmt.Sprintf("97001_%d_0_tcp", owner.ID), "cc": 2, "tc": 10, "te": 1, "ir": 100, "or": 200, "t": 1},
+			{"n": fmt.Sprintf("97001_%d_0_udp", owner.ID), "cc": 1, "tc": 3, "ir": 5, "or": 5, "t": 2},
+			{"n": "tunnel_5_relay", "cc": 9},
+		},
+	})
+
+	var msg struct {
+		Id   string                    `json:"id"`
+		Type string                    `json:"type"`
+		Data []dto.ForwardLiveStatsDto `json:"data"`
+	}
+	ownerConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if assert.NoError(t, ownerConn.ReadJSON(&msg)) {
+		assert.Equal(t, "stats", msg.Type)
+		assert.Equal(t, "970", msg.Id)
+		if assert.Len(t, msg.Data, 1) {
+			assert.Equal(t, int64(97001), msg.Data[0].ForwardId)
+			assert.Equal(t, uint64(3), msg.Data[0].CurrentConns)
+			assert.Equal(t, uint64(13), msg.Data[0].TotalConns)
+			assert.Equal(t, uint64(105), msg.Data[0].InputRate)
+		}
+	}
+
+	// 其他用户既收不到该转发的数据，也收不到节点信息
+	otherConn.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+	_, _, err := otherConn.ReadMessage()
+	assert.Error(t, err)
+}
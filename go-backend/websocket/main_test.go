@@ -0,0 +1,11 @@
+package websocket_test
+
+import (
+	"testing"
+
+	"go-backend/testutil"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
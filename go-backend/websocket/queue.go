@@ -0,0 +1,221 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go-backend/global"
+	"go-backend/model"
+	"go-backend/model/dto"
+)
+
+// 持久化命令状态
+const (
+	CommandPending    = 0
+	CommandDone       = 1
+	CommandFailed     = 2
+	CommandSuperseded = 3
+)
+
+const (
+	msgNodeOffline = "节点不在线"
+	msgTimeout     = "Timeout"
+
+	commandMaxAttempts  = 20
+	commandRetryBase    = 10 * time.Second
+	commandRetryMax     = 10 * time.Minute
+	commandRetention    = 7 * 24 * time.Hour
+	commandPollInterval = 30 * time.Second
+)
+
+var drainLocks sync.Map // nodeId -> *sync.Mutex
+
+// SendOrQueue 发送命令，节点离线时写入持久化队列，节点重连后按顺序补发
+// 超时的命令可能已经在节点上执行，只有重复执行结果不变的命令才入队重发，见 canRetry
+// idempotencyKey 相同的待发送命令会被新命令取代（如同一服务的暂停/恢复只保留最后一次）
+// 命令已入队时同样返回 OK，Data 为 {"queued": true}
+func SendOrQueue(nodeId int64, data interface{}, msgType string, idempotencyKey string) *dto.GostDto {
+	// 该节点还有未补发的命令时直接排队，避免新命令越过旧命令
+	if !hasPendingCommands(nodeId) {
+		res := SendMsg(nodeId, data, msgType)
+		if !canRetry(res.Msg, msgType) {
+			return res
+		}
+	}
+
+	if err := enqueueCommand(nodeId, data, msgType, idempotencyKey); err != nil {
+		log.Printf("⚠️ 节点 %d 命令 %s 入队失败: %v", nodeId, msgType, err)
+		return &dto.GostDto{Msg: "命令入队失败: " + err.Error()}
+	}
+	log.Printf("📥 节点 %d 不可达，命令 %s(%s) 已入队", nodeId, msgType, idempotencyKey)
+
+	// 节点在线（例如刚刚超时）时尝试立即补发
+	if IsNodeOnline(nodeId) {
+		go DrainCommands(nodeId)
+	}
+	return &dto.GostDto{Msg: "OK", Data: map[string]interface{}{"queued": true}}
+}
+
+// IsNodeOnline 节点是否有有效的 websocket 会话
+func IsNodeOnline(nodeId int64) bool {
+	Manager.mu.RLock()
+	defer Manager.mu.RUnlock()
+	client, ok := Manager.NodeSessions[nodeId]
+	return ok && client != nil && client.Valid
+}
+
+// DrainCommands 按入队顺序补发节点的待发送命令
+// 遇到离线或超时即停止，保证顺序；不能重发的命令超时后记为失败；节点返回的业务错误视为失败并继续后续命令
+func DrainCommands(nodeId int64) {
+	lock := drainLock(nodeId)
+	lock.Lock()
+	defer lock.Unlock()
+
+	var commands []model.NodeCommand
+	global.DB.Where("node_id = ? AND status = ?", nodeId, CommandPending).Order("id asc").Find(&commands)
+
+	for i := range commands {
+		cmd := &commands[i]
+
+		var data interface{}
+		if err := json.Unmarshal([]byte(cmd.Data), &data); err != nil {
+			markCommand(cmd, CommandFailed, "数据解析失败: "+err.Error())
+			continue
+		}
+
+		// 发送期间命令可能已被取代或清除
+		var current model.NodeCommand
+		if err := global.DB.Where("id = ? AND status = ?", cmd.ID, CommandPending).First(&current).Error; err != nil {
+			continue
+		}
+
+		res := SendMsg(nodeId, data, cmd.Type)
+		cmd.Attempts++
+
+		switch {
+		case res.Msg == "OK":
+			markCommand(cmd, CommandDone, "")
+		case res.Msg == msgTimeout && !canRetry(res.Msg, cmd.Type):
+			markCommand(cmd, CommandFailed, res.Msg)
+			return
+		case canRetry(res.Msg, cmd.Type):
+			if cmd.Attempts >= commandMaxAttempts {
+				markCommand(cmd, CommandFailed, res.Msg)
+				continue
+			}
+			global.DB.Model(&model.NodeCommand{}).Where("id = ?", cmd.ID).Updates(map[string]interface{}{
+				"attempts":        cmd.Attempts,
+				"last_error":      res.Msg,
+				"next_retry_time": time.Now().Add(retryDelay(cmd.Attempts)).UnixMilli(),
+				"updated_time":    time.Now().UnixMilli(),
+			})
+			return
+		default:
+			markCommand(cmd, CommandFailed, res.Msg)
+		}
+	}
+
+	if len(commands) > 0 {
+		log.Printf("📤 节点 %d 待发送命令补发完成，共 %d 条", nodeId, len(commands))
+	}
+}
+
+// StartCommandQueue 启动队列后台任务：定时重试在线节点的待发送命令并清理过期记录
+func StartCommandQueue() {
+	go func() {
+		ticker := time.NewTicker(commandPollInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			retryDueCommands()
+			cleanupCommands()
+		}
+	}()
+}
+
+func retryDueCommands() {
+	var nodeIds []int64
+	global.DB.Model(&model.NodeCommand{}).
+		Where("status = ? AND next_retry_time <= ?", CommandPending, time.Now().UnixMilli()).
+		Distinct().Pluck("node_id", &nodeIds)
+
+	for _, nodeId := range nodeIds {
+		if IsNodeOnline(nodeId) {
+			go DrainCommands(nodeId)
+		}
+	}
+}
+
+func cleanupCommands() {
+	cutoff := time.Now().Add(-commandRetention).UnixMilli()
+	global.DB.Where("status != ? AND updated_time < ?", CommandPending, cutoff).Delete(&model.NodeCommand{})
+}
+
+func enqueueCommand(nodeId int64, data interface{}, msgType string, idempotencyKey string) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	if idempotencyKey != "" {
+		// 旧命令作废，新命令排到队尾
+		global.DB.Model(&model.NodeCommand{}).
+			Where("node_id = ? AND idempotency_key = ? AND status = ?", nodeId, idempotencyKey, CommandPending).
+			Updates(map[string]interface{}{"status": CommandSuperseded, "updated_time": now})
+	}
+
+	cmd := model.NodeCommand{
+		NodeId:         nodeId,
+		Type:           msgType,
+		Data:           string(payload),
+		IdempotencyKey: idempotencyKey,
+		Status:         CommandPending,
+		CreatedTime:    now,
+		UpdatedTime:    now,
+	}
+	return global.DB.Create(&cmd).Error
+}
+
+func hasPendingCommands(nodeId int64) bool {
+	var count int64
+	global.DB.Model(&model.NodeCommand{}).Where("node_id = ? AND status = ?", nodeId, CommandPending).Count(&count)
+	return count > 0
+}
+
+// markCommand 只更新已存在的记录，发送期间被清除的命令不会被重新写入
+func markCommand(cmd *model.NodeCommand, status int, lastError string) {
+	global.DB.Model(&model.NodeCommand{}).Where("id = ?", cmd.ID).Updates(map[string]interface{}{
+		"status":       status,
+		"attempts":     cmd.Attempts,
+		"last_error":   lastError,
+		"updated_time": time.Now().UnixMilli(),
+	})
+}
+
+// canRetry 节点离线时命令没有送达，可以重发；超时的命令可能已经执行，
+// 只重发更新、暂停、恢复这类重复执行结果不变的命令，新增和删除的偏差交给对账修正
+func canRetry(msg, msgType string) bool {
+	switch msg {
+	case msgNodeOffline:
+		return true
+	case msgTimeout:
+		return strings.HasPrefix(msgType, "Update") || msgType == "PauseService" || msgType == "ResumeService"
+	}
+	return false
+}
+
+func retryDelay(attempts int) time.Duration {
+	d := commandRetryBase << uint(attempts-1)
+	if d <= 0 || d > commandRetryMax {
+		return commandRetryMax
+	}
+	return d
+}
+
+func drainLock(nodeId int64) *sync.Mutex {
+	lock, _ := drainLocks.LoadOrStore(nodeId, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
@@ -12,8 +12,11 @@ import (
 
 	"go-backend/config"
 	"go-backend/global"
+	"go-backend/metrics"
 	"go-backend/model"
 	"go-backend/model/dto"
+	"go-backend/rbac"
+	"go-backend/session"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -36,6 +39,15 @@ type Client struct {
 	AES       *AESCrypto
 	Valid     bool
 	WriteLock sync.Mutex
+
+	// 前端会话的用户信息，用于按权限过滤推送内容
+	UserId    int64
+	RoleId    int
+	SessionId string // 登录会话 ID，会话撤销时断开连接
+
+	// 转发实时数据订阅，由 Manager.mu 保护
+	statsSubscribed bool
+	statsForwards   map[int64]bool
 }
 
 type WSManager struct {
@@ -51,6 +63,34 @@ var Manager = &WSManager{
 	PendingRequests: make(map[string]chan dto.GostDto),
 }
 
+// 节点上线回调，由 service 层注册（websocket 不能反向依赖 service）
+var nodeOnlineHandlers []func(nodeId int64)
+
+// OnNodeOnline 注册节点上线回调，回调在独立 goroutine 中执行
+func OnNodeOnline(fn func(nodeId int64)) {
+	nodeOnlineHandlers = append(nodeOnlineHandlers, fn)
+}
+
+func notifyNodeOnline(nodeId int64) {
+	for _, fn := range nodeOnlineHandlers {
+		go fn(nodeId)
+	}
+}
+
+// 节点下线回调，同样由 service 层注册
+var nodeOfflineHandlers []func(nodeId int64)
+
+// OnNodeOffline 注册节点下线回调，回调在独立 goroutine 中执行
+func OnNodeOffline(fn func(nodeId int64)) {
+	nodeOfflineHandlers = append(nodeOfflineHandlers, fn)
+}
+
+func notifyNodeOffline(nodeId int64) {
+	for _, fn := range nodeOfflineHandlers {
+		go fn(nodeId)
+	}
+}
+
 func (m *WSManager) Register(client *Client) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -83,6 +123,7 @@ func (m *WSManager) Unregister(client *Client) {
 			m.broadcastStatus(client.ID, 0)
 			// Update DB Status
 			go updateNodeStatus(nodeId, 0, "")
+			notifyNodeOffline(nodeId)
 		}
 	} else {
 		delete(m.AdminSessions, client)
@@ -102,6 +143,9 @@ func (m *WSManager) broadcastStatus(id string, status int) {
 
 	// Send to all admins
 	for client := range m.AdminSessions {
+		if !rbac.Has(client.RoleId, model.PermNodeRead) {
+			continue
+		}
 		go client.SendText(string(jsonMsg))
 	}
 }
@@ -149,6 +193,9 @@ func HandleWebSocket(c *gin.Context) {
 	version := c.Query("version")
 
 	clientId := idParam
+	var userId int64
+	var sessionId string
+	roleId := -1
 
 	// Validate Node
 	if msgType == "1" {
@@ -191,15 +238,34 @@ func HandleWebSocket(c *gin.Context) {
 			return
 		}
 		clientId = sub
+		userId, _ = strconv.ParseInt(sub, 10, 64)
+		if role, ok := claims["role_id"].(float64); ok {
+			roleId = int(role)
+		}
+
+		// 会话已撤销、或 token 签发于修改密码之前时拒绝连接
+		sessionId, _ = claims["jti"].(string)
+		issuedAt, _ := claims.GetIssuedAt()
+		if issuedAt == nil || session.Validate(sessionId, userId, issuedAt.Time, c.ClientIP()) != nil {
+			conn.Close()
+			return
+		}
+		if setup, _ := claims["totp_setup"].(bool); setup {
+			conn.Close()
+			return
+		}
 	}
 
 	client := &Client{
-		ID:      clientId,
-		Type:    msgType,
-		Conn:    conn,
-		Secret:  secret,
-		Version: version,
-		Valid:   true,
+		ID:        clientId,
+		Type:      msgType,
+		Conn:      conn,
+		Secret:    secret,
+		Version:   version,
+		Valid:     true,
+		UserId:    userId,
+		RoleId:    roleId,
+		SessionId: sessionId,
 	}
 
 	if secret != "" {
@@ -217,6 +283,11 @@ func HandleWebSocket(c *gin.Context) {
 
 		nodeId, _ := strconv.ParseInt(clientId, 10, 64)
 		go updateNodeStatusDetail(nodeId, 1, version, httpPortStr, tlsPortStr, socksPortStr)
+		// 先补发离线期间积压的命令，再通知上线（下发全量快照）
+		go func() {
+			DrainCommands(nodeId)
+			notifyNodeOnline(nodeId)
+		}()
 	}
 
 	go client.ReadPump()
@@ -283,6 +354,17 @@ func (c *Client) handleMessage(payload []byte) {
 		}
 	}
 
+	// 前端会话只处理订阅消息
+	if c.Type != "1" {
+		c.handleSubscribe(payload)
+		return
+	}
+
+	// 服务实时数据按订阅推送，不作为节点信息广播
+	if c.handleLiveStats(payload) {
+		return
+	}
+
 	// 2. Broadcast Info (if Node)
 	if c.Type == "1" {
 		// Heartbeat / Status Update Response
@@ -299,11 +381,15 @@ func (c *Client) handleMessage(payload []byte) {
 			"data": string(payload),
 		}
 		jsonMsg, _ := json.Marshal(msg)
-		Manager.mu.Lock()
+		Manager.mu.RLock()
 		for admin := range Manager.AdminSessions {
+			// 节点信息仅推送给有 node:read 权限的会话
+			if !rbac.Has(admin.RoleId, model.PermNodeRead) {
+				continue
+			}
 			go admin.SendText(string(jsonMsg))
 		}
-		Manager.mu.Unlock()
+		Manager.mu.RUnlock()
 	}
 }
 
@@ -343,10 +429,12 @@ func SendMsg(nodeId int64, data interface{}, msgType string) *dto.GostDto {
 	Manager.mu.RUnlock()
 
 	if !ok || client == nil || !client.Valid {
+		metrics.WsCommands.Inc(msgType, "offline")
 		return &dto.GostDto{Msg: "节点不在线"}
 	}
 
 	requestId := uuid.New().String()
+	start := time.Now()
 
 	// Construct Message
 	// Java puts requestId at root: {type:..., data:..., requestId:...}
@@ -371,12 +459,48 @@ func SendMsg(nodeId int64, data interface{}, msgType string) *dto.GostDto {
 	// Wait for Response (Timeout 10s)
 	select {
 	case res := <-ch:
+		metrics.WsCommandDuration.Observe(time.Since(start).Seconds(), msgType)
+		if res.Msg == "OK" {
+			metrics.WsCommands.Inc(msgType, "ok")
+		} else {
+			metrics.WsCommands.Inc(msgType, "error")
+		}
 		return &res
 	case <-time.After(10 * time.Second):
 		// Clean up
 		Manager.mu.Lock()
 		delete(Manager.PendingRequests, requestId)
 		Manager.mu.Unlock()
+		metrics.WsCommands.Inc(msgType, "timeout")
 		return &dto.GostDto{Msg: "Timeout"}
 	}
 }
+
+// PendingRequestCount 等待节点响应的命令数
+func PendingRequestCount() int {
+	Manager.mu.RLock()
+	defer Manager.mu.RUnlock()
+	return len(Manager.PendingRequests)
+}
+
+// CloseSessions 断开指定登录会话建立的前端连接
+func CloseSessions(sessionIds ...string) {
+	ids := make(map[string]bool, len(sessionIds))
+	for _, id := range sessionIds {
+		ids[id] = true
+	}
+
+	Manager.mu.RLock()
+	var clients []*Client
+	for client := range Manager.AdminSessions {
+		if client.SessionId != "" && ids[client.SessionId] {
+			clients = append(clients, client)
+		}
+	}
+	Manager.mu.RUnlock()
+
+	// 连接关闭后由 ReadPump 负责注销
+	for _, client := range clients {
+		client.Conn.Close()
+	}
+}
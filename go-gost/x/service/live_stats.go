@@ -0,0 +1,60 @@
+package service
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// LiveStats 服务的实时连接与速率，由 observeStats 每个周期刷新，供 websocket 上报面板
+type LiveStats struct {
+	Service      string `json:"n"`
+	CurrentConns uint64 `json:"cc"` // 当前连接数
+	TotalConns   uint64 `json:"tc"` // 累计连接数
+	TotalErrs    uint64 `json:"te"` // 累计错误数
+	InputRate    uint64 `json:"ir"` // 上行速率（字节/秒）
+	OutputRate   uint64 `json:"or"` // 下行速率（字节/秒）
+	Time         int64  `json:"t"`
+}
+
+type liveStatsEntry struct {
+	owner *defaultService
+	stats LiveStats
+}
+
+var (
+	liveStatsMu sync.Mutex
+	liveStats   = make(map[string]*liveStatsEntry)
+)
+
+// setLiveStats 更新服务的实时数据，同名服务重建时以最新的实例为准
+func setLiveStats(owner *defaultService, st LiveStats) {
+	liveStatsMu.Lock()
+	defer liveStatsMu.Unlock()
+	liveStats[st.Service] = &liveStatsEntry{owner: owner, stats: st}
+}
+
+// removeLiveStats 服务停止时移除，仅移除属于该实例的数据
+func removeLiveStats(owner *defaultService) {
+	liveStatsMu.Lock()
+	defer liveStatsMu.Unlock()
+	if e, ok := liveStats[owner.name]; ok && e.owner == owner {
+		delete(liveStats, owner.name)
+	}
+}
+
+// LiveStatsSnapshot 返回 maxAge 内刷新过的服务实时数据，按服务名排序
+func LiveStatsSnapshot(maxAge time.Duration) []LiveStats {
+	liveStatsMu.Lock()
+	defer liveStatsMu.Unlock()
+
+	cutoff := time.Now().Add(-maxAge).UnixMilli()
+	list := make([]LiveStats, 0, len(liveStats))
+	for _, e := range liveStats {
+		if e.stats.Time >= cutoff {
+			list = append(list, e.stats)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Service < list[j].Service })
+	return list
+}
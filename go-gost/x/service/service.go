@@ -63,6 +63,10 @@ func SetProtocolBlock(httpOn int, tlsOn int, socksOn int) {
 type Option func(opts *options)
 
 func init() {
+	// 配置文件缺失时由 main 提示，这里按默认值不拦截协议
+	if _, err := os.Stat("config.json"); os.IsNotExist(err) {
+		return
+	}
 	_, err := LoadConfig("config.json")
 	fmt.Println("config.json loaded")
 	if err != nil {
@@ -356,9 +360,7 @@ func (s *defaultService) setState(state State) {
 }
 
 func (s *defaultService) observeStats(ctx context.Context) {
-	if s.options.observer == nil {
-		return
-	}
+	defer removeLiveStats(s)
 
 	d := s.options.observerPeriod
 	if d == 0 {
@@ -369,13 +371,41 @@ func (s *defaultService) observeStats(ctx context.Context) {
 	}
 
 	var events []observer.Event
+	// 上次统计时的流量计数，用于计算实时速率；流量上报成功后计数被扣减，基准归零
+	var lastInput, lastOutput uint64
+	lastTime := time.Now()
 
 	ticker := time.NewTicker(d)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
+		case now := <-ticker.C:
+			st := s.status.Stats()
+			if st == nil {
+				continue
+			}
+
+			inputBytes := st.Get(stats.KindInputBytes)
+			outputBytes := st.Get(stats.KindOutputBytes)
+			elapsed := now.Sub(lastTime).Seconds()
+			if elapsed <= 0 {
+				elapsed = d.Seconds()
+			}
+			setLiveStats(s, LiveStats{
+				Service:      s.name,
+				CurrentConns: st.Get(stats.KindCurrentConns),
+				TotalConns:   st.Get(stats.KindTotalConns),
+				TotalErrs:    st.Get(stats.KindTotalErrs),
+				InputRate:    uint64(float64(counterDelta(inputBytes, lastInput)) / elapsed),
+				OutputRate:   uint64(float64(counterDelta(outputBytes, lastOutput)) / elapsed),
+				Time:         now.UnixMilli(),
+			})
+			lastInput, lastOutput, lastTime = inputBytes, outputBytes, now
+
+			if s.options.observer == nil {
+				continue
+			}
 
 			// First, try to send any pending events
 			if len(events) > 0 {
@@ -385,15 +415,8 @@ func (s *defaultService) observeStats(ctx context.Context) {
 				continue
 			}
 
-			st := s.status.Stats()
-			if st == nil {
-				continue
-			}
-
 			isUpdated := st.IsUpdated()
 			if isUpdated {
-				inputBytes := st.Get(stats.KindInputBytes)
-				outputBytes := st.Get(stats.KindOutputBytes)
 				dialInputBytes := st.Get(xstats.KindDialInputBytes)
 				dialOutputBytes := st.Get(xstats.KindDialOutputBytes)
 
@@ -428,6 +451,7 @@ func (s *defaultService) observeStats(ctx context.Context) {
 								st.Get(xstats.KindDialInputBytes)-dialInputBytes,
 								st.Get(xstats.KindDialOutputBytes)-dialOutputBytes,
 							)
+							lastInput, lastOutput = 0, 0
 						}
 					}
 				}
@@ -444,6 +468,14 @@ func (s *defaultService) observeStats(ctx context.Context) {
 	}
 }
 
+// counterDelta 计数被扣减（流量已上报）时当前值即为增量
+func counterDelta(cur, last uint64) uint64 {
+	if cur < last {
+		return cur
+	}
+	return cur - last
+}
+
 type ServiceEvent struct {
 	Kind    string
 	Service string
@@ -0,0 +1,97 @@
+package socket
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-gost/x/config"
+	parser "github.com/go-gost/x/config/parsing/admission"
+	"github.com/go-gost/x/registry"
+)
+
+// createAdmission 创建准入控制（来源 IP 白名单 / 黑名单）
+// 服务按名称延迟引用 admission，引用的 admission 不存在时拒绝所有连接，因此需先于服务创建
+func createAdmission(req createAdmissionRequest) error {
+	name := strings.TrimSpace(req.Data.Name)
+	if name == "" {
+		return errors.New("admission name is required")
+	}
+	req.Data.Name = name
+
+	if registry.AdmissionRegistry().IsRegistered(name) {
+		return errors.New("admission " + name + " already exists")
+	}
+
+	if err := registry.AdmissionRegistry().Register(name, parser.ParseAdmission(&req.Data)); err != nil {
+		return errors.New("admission " + name + " already exists")
+	}
+
+	config.OnUpdate(func(c *config.Config) error {
+		c.Admissions = append(c.Admissions, &req.Data)
+		return nil
+	})
+
+	return nil
+}
+
+func updateAdmission(req updateAdmissionRequest) error {
+	name := strings.TrimSpace(req.Admission)
+
+	if !registry.AdmissionRegistry().IsRegistered(name) {
+		return errors.New("admission " + name + " not found")
+	}
+	req.Data.Name = name
+
+	registry.AdmissionRegistry().Unregister(name)
+	if err := registry.AdmissionRegistry().Register(name, parser.ParseAdmission(&req.Data)); err != nil {
+		return errors.New("admission " + name + " already exists")
+	}
+
+	config.OnUpdate(func(c *config.Config) error {
+		for i := range c.Admissions {
+			if c.Admissions[i].Name == name {
+				c.Admissions[i] = &req.Data
+				break
+			}
+		}
+		return nil
+	})
+
+	return nil
+}
+
+func deleteAdmission(req deleteAdmissionRequest) error {
+	name := strings.TrimSpace(req.Admission)
+
+	if !registry.AdmissionRegistry().IsRegistered(name) {
+		return errors.New("admission " + name + " not found")
+	}
+	registry.AdmissionRegistry().Unregister(name)
+
+	config.OnUpdate(func(c *config.Config) error {
+		admissions := c.Admissions
+		c.Admissions = nil
+		for _, a := range admissions {
+			if a.Name == name {
+				continue
+			}
+			c.Admissions = append(c.Admissions, a)
+		}
+		return nil
+	})
+
+	return nil
+}
+
+type createAdmissionRequest struct {
+	Data config.AdmissionConfig `json:"data"`
+}
+
+type updateAdmissionRequest struct {
+	Admission string                 `json:"admission"`
+	Data      config.AdmissionConfig `json:"data"`
+}
+
+type deleteAdmissionRequest struct {
+	Admission string `json:"admission"`
+}
@@ -100,3 +100,149 @@ type updateLimiterRequest struct {
 type deleteLimiterRequest struct {
 	Limiter string `json:"limiter"`
 }
+
+// createConnLimiter 创建并发连接数限制器（climiter）
+func createConnLimiter(req createLimiterRequest) error {
+	name := strings.TrimSpace(req.Data.Name)
+	if name == "" {
+		return errors.New("climiter name is required")
+	}
+	req.Data.Name = name
+
+	if registry.ConnLimiterRegistry().IsRegistered(name) {
+		return errors.New("climiter " + name + " already exists")
+	}
+
+	if err := registry.ConnLimiterRegistry().Register(name, parser.ParseConnLimiter(&req.Data)); err != nil {
+		return errors.New("climiter " + name + " already exists")
+	}
+
+	config.OnUpdate(func(c *config.Config) error {
+		c.CLimiters = append(c.CLimiters, &req.Data)
+		return nil
+	})
+
+	return nil
+}
+
+func updateConnLimiter(req updateLimiterRequest) error {
+	name := strings.TrimSpace(req.Limiter)
+
+	if !registry.ConnLimiterRegistry().IsRegistered(name) {
+		return errors.New("climiter " + name + " not found")
+	}
+	req.Data.Name = name
+
+	registry.ConnLimiterRegistry().Unregister(name)
+	if err := registry.ConnLimiterRegistry().Register(name, parser.ParseConnLimiter(&req.Data)); err != nil {
+		return errors.New("climiter " + name + " already exists")
+	}
+
+	config.OnUpdate(func(c *config.Config) error {
+		for i := range c.CLimiters {
+			if c.CLimiters[i].Name == name {
+				c.CLimiters[i] = &req.Data
+				break
+			}
+		}
+		return nil
+	})
+
+	return nil
+}
+
+func deleteConnLimiter(req deleteLimiterRequest) error {
+	name := strings.TrimSpace(req.Limiter)
+
+	if !registry.ConnLimiterRegistry().IsRegistered(name) {
+		return errors.New("climiter " + name + " not found")
+	}
+	registry.ConnLimiterRegistry().Unregister(name)
+
+	config.OnUpdate(func(c *config.Config) error {
+		limiters := c.CLimiters
+		c.CLimiters = nil
+		for _, l := range limiters {
+			if l.Name == name {
+				continue
+			}
+			c.CLimiters = append(c.CLimiters, l)
+		}
+		return nil
+	})
+
+	return nil
+}
+
+// createRateLimiter 创建新建连接速率限制器（rlimiter）
+func createRateLimiter(req createLimiterRequest) error {
+	name := strings.TrimSpace(req.Data.Name)
+	if name == "" {
+		return errors.New("rlimiter name is required")
+	}
+	req.Data.Name = name
+
+	if registry.RateLimiterRegistry().IsRegistered(name) {
+		return errors.New("rlimiter " + name + " already exists")
+	}
+
+	if err := registry.RateLimiterRegistry().Register(name, parser.ParseRateLimiter(&req.Data)); err != nil {
+		return errors.New("rlimiter " + name + " already exists")
+	}
+
+	config.OnUpdate(func(c *config.Config) error {
+		c.RLimiters = append(c.RLimiters, &req.Data)
+		return nil
+	})
+
+	return nil
+}
+
+func updateRateLimiter(req updateLimiterRequest) error {
+	name := strings.TrimSpace(req.Limiter)
+
+	if !registry.RateLimiterRegistry().IsRegistered(name) {
+		return errors.New("rlimiter " + name + " not found")
+	}
+	req.Data.Name = name
+
+	registry.RateLimiterRegistry().Unregister(name)
+	if err := registry.RateLimiterRegistry().Register(name, parser.ParseRateLimiter(&req.Data)); err != nil {
+		return errors.New("rlimiter " + name + " already exists")
+	}
+
+	config.OnUpdate(func(c *config.Config) error {
+		for i := range c.RLimiters {
+			if c.RLimiters[i].Name == name {
+				c.RLimiters[i] = &req.Data
+				break
+			}
+		}
+		return nil
+	})
+
+	return nil
+}
+
+func deleteRateLimiter(req deleteLimiterRequest) error {
+	name := strings.TrimSpace(req.Limiter)
+
+	if !registry.RateLimiterRegistry().IsRegistered(name) {
+		return errors.New("rlimiter " + name + " not found")
+	}
+	registry.RateLimiterRegistry().Unregister(name)
+
+	config.OnUpdate(func(c *config.Config) error {
+		limiters := c.RLimiters
+		c.RLimiters = nil
+		for _, l := range limiters {
+			if l.Name == name {
+				continue
+			}
+			c.RLimiters = append(c.RLimiters, l)
+		}
+		return nil
+	})
+
+	return nil
+}
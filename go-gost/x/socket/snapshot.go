@@ -0,0 +1,347 @@
+package socket
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-gost/core/admission"
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/limiter/conn"
+	"github.com/go-gost/core/limiter/rate"
+	"github.com/go-gost/core/limiter/traffic"
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/x/config"
+	admissionparser "github.com/go-gost/x/config/parsing/admission"
+	chainparser "github.com/go-gost/x/config/parsing/chain"
+	limiterparser "github.com/go-gost/x/config/parsing/limiter"
+	parser "github.com/go-gost/x/config/parsing/service"
+	"github.com/go-gost/x/registry"
+)
+
+// 面板管理的配置名称，与面板 reconcile_service.go 中的规则保持一致
+// 快照只替换或删除匹配这些规则的条目，本地配置的服务（如 web api、metrics）不受影响
+var (
+	managedServicePattern   = regexp.MustCompile(`^(\d+_\d+_\d+_(tcp|udp|tls)|tunnel_\d+_relay(_\d+)?)$`)
+	managedChainPattern     = regexp.MustCompile(`^(\d+_\d+_\d+_chains|tunnel_\d+_chains)$`)
+	managedLimiterPattern   = regexp.MustCompile(`^\d+$`)
+	managedCLimiterPattern  = regexp.MustCompile(`^climit_\d+$`)
+	managedRLimiterPattern  = regexp.MustCompile(`^rlimit_\d+$`)
+	managedAdmissionPattern = regexp.MustCompile(`^\d+_\d+_\d+_(allow|deny)$`)
+)
+
+// applySnapshot 用面板下发的完整配置替换当前注册表中由面板管理的 service / chain / limiter / admission
+// 配置未变化的服务保持运行，不会中断已有连接；新服务启动失败时整体回滚到原配置
+func applySnapshot(req applySnapshotRequest) error {
+	// 第一阶段：校验并解析 limiter 和 chain
+	if err := checkSnapshotNames(req); err != nil {
+		return err
+	}
+
+	newLimiters := make(map[string]traffic.TrafficLimiter)
+	for i := range req.Limiters {
+		newLimiters[req.Limiters[i].Name] = limiterparser.ParseTrafficLimiter(&req.Limiters[i])
+	}
+
+	newCLimiters := make(map[string]conn.ConnLimiter)
+	for i := range req.CLimiters {
+		newCLimiters[req.CLimiters[i].Name] = limiterparser.ParseConnLimiter(&req.CLimiters[i])
+	}
+	newRLimiters := make(map[string]rate.RateLimiter)
+	for i := range req.RLimiters {
+		newRLimiters[req.RLimiters[i].Name] = limiterparser.ParseRateLimiter(&req.RLimiters[i])
+	}
+
+	newAdmissions := make(map[string]admission.Admission)
+	for i := range req.Admissions {
+		newAdmissions[req.Admissions[i].Name] = admissionparser.ParseAdmission(&req.Admissions[i])
+	}
+
+	newChains := make(map[string]chain.Chainer)
+	for i := range req.Chains {
+		c, err := chainparser.ParseChain(&req.Chains[i], logger.Default())
+		if err != nil {
+			return errors.New("create chain " + req.Chains[i].Name + " failed: " + err.Error())
+		}
+		newChains[req.Chains[i].Name] = c
+	}
+
+	// 第二阶段：替换 limiter 和 chain，服务通过名称延迟引用，替换不影响已有连接
+	old := snapshotRegistries{
+		limiters:   registry.TrafficLimiterRegistry().GetAll(),
+		cLimiters:  registry.ConnLimiterRegistry().GetAll(),
+		rLimiters:  registry.RateLimiterRegistry().GetAll(),
+		admissions: registry.AdmissionRegistry().GetAll(),
+		chains:     registry.ChainRegistry().GetAll(),
+	}
+	snapshotRegistries{limiters: newLimiters, cLimiters: newCLimiters, rLimiters: newRLimiters, admissions: newAdmissions, chains: newChains}.replace()
+
+	// 第三阶段：停止配置发生变化或不再需要的服务
+	oldConfigs := make(map[string]*config.ServiceConfig)
+	for _, s := range config.Global().Services {
+		if s != nil {
+			oldConfigs[s.Name] = s
+		}
+	}
+
+	newConfigs := make(map[string]*config.ServiceConfig)
+	for i := range req.Services {
+		newConfigs[req.Services[i].Name] = &req.Services[i]
+	}
+
+	var stopped []string
+	for name, svc := range registry.ServiceRegistry().GetAll() {
+		nc, ok := newConfigs[name]
+		if ok && sameServiceConfig(oldConfigs[name], nc) {
+			continue
+		}
+		if !ok && !managedServicePattern.MatchString(name) {
+			continue
+		}
+		registry.ServiceRegistry().Unregister(name)
+		svc.Close()
+		stopped = append(stopped, name)
+	}
+	if len(stopped) > 0 {
+		// 等待端口释放
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// 第四阶段：启动新增或变更的服务
+	var started []string
+	for i := range req.Services {
+		sc := &req.Services[i]
+		if registry.ServiceRegistry().IsRegistered(sc.Name) {
+			continue
+		}
+		if err := startSnapshotService(sc); err != nil {
+			for _, name := range started {
+				if svc := registry.ServiceRegistry().Get(name); svc != nil {
+					registry.ServiceRegistry().Unregister(name)
+					svc.Close()
+				}
+			}
+			rollbackSnapshot(old, oldConfigs, stopped)
+			return err
+		}
+		started = append(started, sc.Name)
+	}
+
+	// 第五阶段：更新配置
+	config.OnUpdate(func(c *config.Config) error {
+		services := keepUnmanaged(c.Services, req.Services, managedServicePattern, func(s *config.ServiceConfig) string { return s.Name })
+		for i := range req.Services {
+			services = append(services, &req.Services[i])
+		}
+		c.Services = services
+
+		chains := keepUnmanaged(c.Chains, req.Chains, managedChainPattern, func(s *config.ChainConfig) string { return s.Name })
+		for i := range req.Chains {
+			chains = append(chains, &req.Chains[i])
+		}
+		c.Chains = chains
+
+		limiters := keepUnmanaged(c.Limiters, req.Limiters, managedLimiterPattern, func(s *config.LimiterConfig) string { return s.Name })
+		for i := range req.Limiters {
+			limiters = append(limiters, &req.Limiters[i])
+		}
+		c.Limiters = limiters
+
+		cLimiters := keepUnmanaged(c.CLimiters, req.CLimiters, managedCLimiterPattern, func(s *config.LimiterConfig) string { return s.Name })
+		for i := range req.CLimiters {
+			cLimiters = append(cLimiters, &req.CLimiters[i])
+		}
+		c.CLimiters = cLimiters
+
+		rLimiters := keepUnmanaged(c.RLimiters, req.RLimiters, managedRLimiterPattern, func(s *config.LimiterConfig) string { return s.Name })
+		for i := range req.RLimiters {
+			rLimiters = append(rLimiters, &req.RLimiters[i])
+		}
+		c.RLimiters = rLimiters
+
+		admissions := keepUnmanaged(c.Admissions, req.Admissions, managedAdmissionPattern, func(s *config.AdmissionConfig) string { return s.Name })
+		for i := range req.Admissions {
+			admissions = append(admissions, &req.Admissions[i])
+		}
+		c.Admissions = admissions
+		return nil
+	})
+
+	logger.Default().Infof("已应用配置快照: services=%d chains=%d limiters=%d climiters=%d rlimiters=%d admissions=%d, 重启服务 %d 个",
+		len(req.Services), len(req.Chains), len(req.Limiters), len(req.CLimiters), len(req.RLimiters), len(req.Admissions), len(started))
+	return nil
+}
+
+// startSnapshotService 解析并注册服务，暂停的服务只注册不启动（与 PauseService 一致）
+func startSnapshotService(sc *config.ServiceConfig) error {
+	svc, err := parser.ParseService(sc)
+	if err != nil {
+		return errors.New("create service " + sc.Name + " failed: " + err.Error())
+	}
+	if err := registry.ServiceRegistry().Register(sc.Name, svc); err != nil {
+		svc.Close()
+		return errors.New("service " + sc.Name + " already exists")
+	}
+	if isPausedConfig(sc) {
+		svc.Close()
+		return nil
+	}
+	go svc.Serve()
+	return nil
+}
+
+// rollbackSnapshot 恢复快照应用前的 limiter / chain，并重新启动已停止的旧服务
+func rollbackSnapshot(old snapshotRegistries, oldConfigs map[string]*config.ServiceConfig, stopped []string) {
+	old.replace()
+
+	time.Sleep(100 * time.Millisecond)
+	for _, name := range stopped {
+		sc := oldConfigs[name]
+		if sc == nil {
+			continue
+		}
+		if err := startSnapshotService(sc); err != nil {
+			logger.Default().Warnf("回滚服务 %s 失败: %v", name, err)
+		}
+	}
+}
+
+// snapshotRegistries 快照涉及的按名称引用的注册表内容
+type snapshotRegistries struct {
+	limiters   map[string]traffic.TrafficLimiter
+	cLimiters  map[string]conn.ConnLimiter
+	rLimiters  map[string]rate.RateLimiter
+	admissions map[string]admission.Admission
+	chains     map[string]chain.Chainer
+}
+
+func (r snapshotRegistries) replace() {
+	replaceRegistry(registry.TrafficLimiterRegistry(), r.limiters, managedLimiterPattern)
+	replaceRegistry(registry.ConnLimiterRegistry(), r.cLimiters, managedCLimiterPattern)
+	replaceRegistry(registry.RateLimiterRegistry(), r.rLimiters, managedRLimiterPattern)
+	replaceRegistry(registry.AdmissionRegistry(), r.admissions, managedAdmissionPattern)
+	replaceRegistry(registry.ChainRegistry(), r.chains, managedChainPattern)
+}
+
+// replaceRegistry 用给定内容替换注册表，不在 items 中的条目只删除名称匹配 managed 的
+func replaceRegistry[T any](reg interface {
+	Register(string, T) error
+	Unregister(string)
+	GetAll() map[string]T
+}, items map[string]T, managed *regexp.Regexp) {
+	for name := range reg.GetAll() {
+		if _, ok := items[name]; !ok && managed.MatchString(name) {
+			reg.Unregister(name)
+		}
+	}
+	for name, v := range items {
+		reg.Unregister(name)
+		reg.Register(name, v)
+	}
+}
+
+// keepUnmanaged 返回现有配置中不由面板管理、且未被快照覆盖的条目
+func keepUnmanaged[T any](current []*T, items []T, managed *regexp.Regexp, nameOf func(*T) string) []*T {
+	names := make(map[string]bool, len(items))
+	for i := range items {
+		names[nameOf(&items[i])] = true
+	}
+	var kept []*T
+	for _, v := range current {
+		if v == nil {
+			continue
+		}
+		if name := nameOf(v); !names[name] && !managed.MatchString(name) {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+func checkSnapshotNames(req applySnapshotRequest) error {
+	seen := make(map[string]bool)
+	for i := range req.Services {
+		name := strings.TrimSpace(req.Services[i].Name)
+		if name == "" {
+			return errors.New("service name is required")
+		}
+		if seen[name] {
+			return errors.New("duplicate service " + name)
+		}
+		seen[name] = true
+		req.Services[i].Name = name
+	}
+
+	seen = make(map[string]bool)
+	for i := range req.Chains {
+		name := strings.TrimSpace(req.Chains[i].Name)
+		if name == "" {
+			return errors.New("chain name is required")
+		}
+		if seen[name] {
+			return errors.New("duplicate chain " + name)
+		}
+		seen[name] = true
+		req.Chains[i].Name = name
+	}
+
+	for kind, limiters := range map[string][]config.LimiterConfig{"limiter": req.Limiters, "climiter": req.CLimiters, "rlimiter": req.RLimiters} {
+		seen = make(map[string]bool)
+		for i := range limiters {
+			name := strings.TrimSpace(limiters[i].Name)
+			if name == "" {
+				return errors.New(kind + " name is required")
+			}
+			if seen[name] {
+				return errors.New("duplicate " + kind + " " + name)
+			}
+			seen[name] = true
+			limiters[i].Name = name
+		}
+	}
+
+	seen = make(map[string]bool)
+	for i := range req.Admissions {
+		name := strings.TrimSpace(req.Admissions[i].Name)
+		if name == "" {
+			return errors.New("admission name is required")
+		}
+		if seen[name] {
+			return errors.New("duplicate admission " + name)
+		}
+		seen[name] = true
+		req.Admissions[i].Name = name
+	}
+	return nil
+}
+
+// sameServiceConfig 比较两个服务配置，忽略运行时状态
+func sameServiceConfig(a, b *config.ServiceConfig) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	ac, bc := *a, *b
+	ac.Status, bc.Status = nil, nil
+	aj, err1 := json.Marshal(ac)
+	bj, err2 := json.Marshal(bc)
+	return err1 == nil && err2 == nil && string(aj) == string(bj)
+}
+
+func isPausedConfig(sc *config.ServiceConfig) bool {
+	if sc.Metadata == nil {
+		return false
+	}
+	v, ok := sc.Metadata["paused"]
+	return ok && v == true
+}
+
+type applySnapshotRequest struct {
+	Services   []config.ServiceConfig   `json:"services"`
+	Chains     []config.ChainConfig     `json:"chains"`
+	Limiters   []config.LimiterConfig   `json:"limiters"`
+	CLimiters  []config.LimiterConfig   `json:"climiters"`
+	RLimiters  []config.LimiterConfig   `json:"rlimiters"`
+	Admissions []config.AdmissionConfig `json:"admissions"`
+}
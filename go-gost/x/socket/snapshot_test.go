@@ -0,0 +1,78 @@
+package socket
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/x/config"
+	limiterparser "github.com/go-gost/x/config/parsing/limiter"
+	xlogger "github.com/go-gost/x/logger"
+	"github.com/go-gost/x/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeService struct {
+	closed bool
+}
+
+func (s *fakeService) Serve() error   { return nil }
+func (s *fakeService) Addr() net.Addr { return &net.TCPAddr{} }
+func (s *fakeService) Close() error {
+	s.closed = true
+	return nil
+}
+
+// chdirTemp 切换到临时目录，测试中写出的 config.json / gost.json 不会留在包目录
+func chdirTemp(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"http":0,"tls":0,"socks":0}`), 0644))
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestApplySnapshotKeepsUnmanaged(t *testing.T) {
+	logger.SetDefault(xlogger.NewLogger())
+	chdirTemp(t)
+
+	local := &fakeService{}
+	managed := &fakeService{}
+	require.NoError(t, registry.ServiceRegistry().Register("web_api", local))
+	require.NoError(t, registry.ServiceRegistry().Register("1_2_3_tcp", managed))
+
+	localLimiter := &config.LimiterConfig{Name: "local_limiter"}
+	managedLimiter := &config.LimiterConfig{Name: "7"}
+	require.NoError(t, registry.TrafficLimiterRegistry().Register(localLimiter.Name, limiterparser.ParseTrafficLimiter(localLimiter)))
+	require.NoError(t, registry.TrafficLimiterRegistry().Register(managedLimiter.Name, limiterparser.ParseTrafficLimiter(managedLimiter)))
+
+	config.Set(&config.Config{
+		Services: []*config.ServiceConfig{{Name: "web_api"}, {Name: "1_2_3_tcp"}},
+		Limiters: []*config.LimiterConfig{localLimiter, managedLimiter},
+	})
+	t.Cleanup(func() {
+		registry.ServiceRegistry().Unregister("web_api")
+		registry.TrafficLimiterRegistry().Unregister(localLimiter.Name)
+		config.Set(&config.Config{})
+	})
+
+	require.NoError(t, applySnapshot(applySnapshotRequest{}))
+
+	assert.True(t, registry.ServiceRegistry().IsRegistered("web_api"))
+	assert.False(t, local.closed)
+	assert.False(t, registry.ServiceRegistry().IsRegistered("1_2_3_tcp"))
+	assert.True(t, managed.closed)
+
+	assert.True(t, registry.TrafficLimiterRegistry().IsRegistered("local_limiter"))
+	assert.False(t, registry.TrafficLimiterRegistry().IsRegistered("7"))
+
+	cfg := config.Global()
+	require.Len(t, cfg.Services, 1)
+	assert.Equal(t, "web_api", cfg.Services[0].Name)
+	require.Len(t, cfg.Limiters, 1)
+	assert.Equal(t, "local_limiter", cfg.Limiters[0].Name)
+}
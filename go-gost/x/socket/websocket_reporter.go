@@ -95,6 +95,7 @@ type WebSocketReporter struct {
 	reconnectTime  time.Duration
 	pingInterval   time.Duration
 	configInterval time.Duration
+	statsInterval  time.Duration // 服务实时数据上报间隔
 	ctx            context.Context
 	cancel         context.CancelFunc
 	connected      bool
@@ -121,6 +122,7 @@ func NewWebSocketReporter(serverURL string, secret string) *WebSocketReporter {
 		reconnectTime:  5 * time.Second,  // 重连间隔
 		pingInterval:   2 * time.Second,  // 发送间隔改为2秒
 		configInterval: 10 * time.Minute, // 配置上报间隔
+		statsInterval:  5 * time.Second,  // 服务实时数据上报间隔
 		ctx:            ctx,
 		cancel:         cancel,
 		connected:      false,
@@ -270,6 +272,8 @@ func (w *WebSocketReporter) handleConnection() {
 	// 主发送循环
 	ticker := time.NewTicker(w.pingInterval)
 	defer ticker.Stop()
+	statsTicker := time.NewTicker(w.statsInterval)
+	defer statsTicker.Stop()
 
 	for {
 		select {
@@ -291,6 +295,11 @@ func (w *WebSocketReporter) handleConnection() {
 				fmt.Printf("❌ 发送系统信息失败: %v，准备重连\n", err)
 				return
 			}
+		case <-statsTicker.C:
+			if err := w.sendLiveStats(); err != nil {
+				fmt.Printf("❌ 发送服务实时数据失败: %v，准备重连\n", err)
+				return
+			}
 		}
 	}
 }
@@ -312,6 +321,23 @@ func (w *WebSocketReporter) collectSystemInfo() SystemInfo {
 
 // sendSystemInfo 发送系统信息
 func (w *WebSocketReporter) sendSystemInfo(sysInfo SystemInfo) error {
+	return w.sendJSON(sysInfo)
+}
+
+// sendLiveStats 发送各服务的实时连接数与速率，没有运行中的服务时不发送
+func (w *WebSocketReporter) sendLiveStats() error {
+	list := service.LiveStatsSnapshot(w.statsInterval * 3)
+	if len(list) == 0 {
+		return nil
+	}
+	return w.sendJSON(map[string]interface{}{
+		"type": "stats",
+		"data": list,
+	})
+}
+
+// sendJSON 序列化并（在有加密器时）加密后发送
+func (w *WebSocketReporter) sendJSON(v interface{}) error {
 	w.connMutex.Lock()
 	defer w.connMutex.Unlock()
 
@@ -320,9 +346,9 @@ func (w *WebSocketReporter) sendSystemInfo(sysInfo SystemInfo) error {
 	}
 
 	// 转换为JSON
-	jsonData, err := json.Marshal(sysInfo)
+	jsonData, err := json.Marshal(v)
 	if err != nil {
-		return fmt.Errorf("序列化系统信息失败: %v", err)
+		return fmt.Errorf("序列化消息失败: %v", err)
 	}
 
 	var messageData []byte
@@ -542,6 +568,42 @@ func (w *WebSocketReporter) routeCommand(cmd CommandMessage) {
 		err = w.handleDeleteLimiter(cmd.Data)
 		response.Type = "DeleteLimitersResponse"
 
+	// 并发连接数 / 新建连接速率限制器
+	case "AddCLimiters":
+		err = w.handleAddConnLimiter(cmd.Data)
+		response.Type = "AddCLimitersResponse"
+	case "UpdateCLimiters":
+		err = w.handleUpdateConnLimiter(cmd.Data)
+		response.Type = "UpdateCLimitersResponse"
+	case "DeleteCLimiters":
+		err = w.handleDeleteConnLimiter(cmd.Data)
+		response.Type = "DeleteCLimitersResponse"
+	case "AddRLimiters":
+		err = w.handleAddRateLimiter(cmd.Data)
+		response.Type = "AddRLimitersResponse"
+	case "UpdateRLimiters":
+		err = w.handleUpdateRateLimiter(cmd.Data)
+		response.Type = "UpdateRLimitersResponse"
+	case "DeleteRLimiters":
+		err = w.handleDeleteRateLimiter(cmd.Data)
+		response.Type = "DeleteRLimitersResponse"
+
+	// 来源 IP 准入控制
+	case "AddAdmissions":
+		err = w.handleAddAdmission(cmd.Data)
+		response.Type = "AddAdmissionsResponse"
+	case "UpdateAdmissions":
+		err = w.handleUpdateAdmission(cmd.Data)
+		response.Type = "UpdateAdmissionsResponse"
+	case "DeleteAdmissions":
+		err = w.handleDeleteAdmission(cmd.Data)
+		response.Type = "DeleteAdmissionsResponse"
+
+	// 全量配置快照
+	case "ApplySnapshot":
+		err = w.handleApplySnapshot(cmd.Data)
+		response.Type = "ApplySnapshotResponse"
+
 	// TCP Ping 诊断命令
 	case "TcpPing":
 		var tcpPingResult TcpPingResponse
@@ -728,6 +790,104 @@ func (w *WebSocketReporter) handleDeleteChain(data interface{}) error {
 }
 
 // Limiter 命令处理函数
+// climiter / rlimiter 命令的数据格式与 limiter 命令一致：
+// 新增为 LimiterConfig，更新为 {"limiter": name, "data": {...}}，删除为 {"limiter": name}
+func parseLimiterData(data interface{}, out interface{}) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("序列化数据失败: %v", err)
+	}
+	if err := json.Unmarshal(jsonData, out); err != nil {
+		return fmt.Errorf("解析限流器配置失败: %v", err)
+	}
+	return nil
+}
+
+func (w *WebSocketReporter) handleAddConnLimiter(data interface{}) error {
+	var req createLimiterRequest
+	if err := parseLimiterData(data, &req.Data); err != nil {
+		return err
+	}
+	return createConnLimiter(req)
+}
+
+func (w *WebSocketReporter) handleUpdateConnLimiter(data interface{}) error {
+	var req updateLimiterRequest
+	if err := parseLimiterData(data, &req); err != nil {
+		return err
+	}
+	return updateConnLimiter(req)
+}
+
+func (w *WebSocketReporter) handleDeleteConnLimiter(data interface{}) error {
+	var req deleteLimiterRequest
+	if err := parseLimiterData(data, &req); err != nil {
+		return err
+	}
+	return deleteConnLimiter(req)
+}
+
+func (w *WebSocketReporter) handleAddRateLimiter(data interface{}) error {
+	var req createLimiterRequest
+	if err := parseLimiterData(data, &req.Data); err != nil {
+		return err
+	}
+	return createRateLimiter(req)
+}
+
+func (w *WebSocketReporter) handleUpdateRateLimiter(data interface{}) error {
+	var req updateLimiterRequest
+	if err := parseLimiterData(data, &req); err != nil {
+		return err
+	}
+	return updateRateLimiter(req)
+}
+
+func (w *WebSocketReporter) handleDeleteRateLimiter(data interface{}) error {
+	var req deleteLimiterRequest
+	if err := parseLimiterData(data, &req); err != nil {
+		return err
+	}
+	return deleteRateLimiter(req)
+}
+
+// Admission 命令处理函数
+// 新增为 AdmissionConfig，更新为 {"admission": name, "data": {...}}，删除为 {"admission": name}
+func parseAdmissionData(data interface{}, out interface{}) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("序列化数据失败: %v", err)
+	}
+	if err := json.Unmarshal(jsonData, out); err != nil {
+		return fmt.Errorf("解析准入控制配置失败: %v", err)
+	}
+	return nil
+}
+
+func (w *WebSocketReporter) handleAddAdmission(data interface{}) error {
+	var req createAdmissionRequest
+	if err := parseAdmissionData(data, &req.Data); err != nil {
+		return err
+	}
+	return createAdmission(req)
+}
+
+func (w *WebSocketReporter) handleUpdateAdmission(data interface{}) error {
+	var req updateAdmissionRequest
+	if err := parseAdmissionData(data, &req); err != nil {
+		return err
+	}
+	return updateAdmission(req)
+}
+
+func (w *WebSocketReporter) handleDeleteAdmission(data interface{}) error {
+	var req deleteAdmissionRequest
+	if err := parseAdmissionData(data, &req); err != nil {
+		return err
+	}
+	return deleteAdmission(req)
+}
+
 func (w *WebSocketReporter) handleAddLimiter(data interface{}) error {
 	jsonData, err := json.Marshal(data)
 	if err != nil {
@@ -795,6 +955,27 @@ func (w *WebSocketReporter) handleDeleteLimiter(data interface{}) error {
 	return deleteLimiter(deleteReq)
 }
 
+// handleApplySnapshot 处理全量配置快照
+func (w *WebSocketReporter) handleApplySnapshot(data interface{}) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("序列化数据失败: %v", err)
+	}
+
+	// 预处理：将字符串格式的 duration 转换为纳秒数
+	processedData, err := w.preprocessDurationFields(jsonData)
+	if err != nil {
+		return fmt.Errorf("预处理duration字段失败: %v", err)
+	}
+
+	var req applySnapshotRequest
+	if err := json.Unmarshal(processedData, &req); err != nil {
+		return fmt.Errorf("解析配置快照失败: %v", err)
+	}
+
+	return applySnapshot(req)
+}
+
 // handleSetProtocol 处理设置屏蔽协议的命令
 func (w *WebSocketReporter) handleSetProtocol(data interface{}) error {
 	jsonData, err := json.Marshal(data)